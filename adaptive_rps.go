@@ -0,0 +1,102 @@
+package adsourceopenrtb
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultAdaptiveInterval   = 10 * time.Second
+	defaultAdaptiveErrorRate  = 0.2
+	adaptiveDecreaseFactor    = 0.5
+	adaptiveRecoveryStep      = 0.1
+	adaptiveMinimalSample     = 20
+	defaultAdaptiveFloorShare = 0.1
+)
+
+// adaptiveRPS lowers the effective RPS of the source towards the floor when the timeout
+// and error rate of the interval exceeds the threshold and ramps it back up gradually
+// when the source is healthy, like the circuit breaker with the gradual recovery
+type adaptiveRPS struct {
+	mx        sync.Mutex
+	limiter   *tokenBucket
+	baseRPS   float64
+	floorRPS  float64
+	errorRate float64
+	interval  time.Duration
+	calcTime  time.Time
+
+	requests atomic.Int64
+	failures atomic.Int64
+}
+
+func newAdaptiveRPS(limiter *tokenBucket, opts *DriverOptions, now time.Time) *adaptiveRPS {
+	if limiter == nil {
+		return nil
+	}
+	a := &adaptiveRPS{
+		limiter:   limiter,
+		baseRPS:   limiter.Rate(),
+		floorRPS:  float64(opts.AdaptiveRPSFloor),
+		errorRate: opts.AdaptiveErrorRate,
+		interval:  opts.AdaptiveInterval,
+		calcTime:  now,
+	}
+	if a.floorRPS <= 0 {
+		a.floorRPS = max(1, a.baseRPS*defaultAdaptiveFloorShare)
+	}
+	a.floorRPS = min(a.floorRPS, a.baseRPS)
+	if a.errorRate <= 0 {
+		a.errorRate = defaultAdaptiveErrorRate
+	}
+	if a.interval <= 0 {
+		a.interval = defaultAdaptiveInterval
+	}
+	return a
+}
+
+// Record the result of the source request, the failure is the timeout or the error
+func (a *adaptiveRPS) Record(failed bool) {
+	if a == nil {
+		return
+	}
+	a.requests.Add(1)
+	if failed {
+		a.failures.Add(1)
+	}
+}
+
+// Update the effective RPS of the limiter once per interval
+func (a *adaptiveRPS) Update(now time.Time) {
+	if a == nil {
+		return
+	}
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if now.Sub(a.calcTime) < a.interval {
+		return
+	}
+	a.calcTime = now
+
+	requests := a.requests.Load()
+	if requests < adaptiveMinimalSample {
+		// Keep collecting the statistics of the interval
+		return
+	}
+	failures := a.failures.Swap(0)
+	a.requests.Add(-requests)
+
+	rate := a.limiter.Rate()
+	if float64(failures)/float64(requests) >= a.errorRate {
+		rate = max(a.floorRPS, rate*adaptiveDecreaseFactor)
+	} else {
+		rate = min(a.baseRPS, rate+a.baseRPS*adaptiveRecoveryStep)
+	}
+	a.limiter.SetRate(rate)
+}
+
+// RPS of the source currently effective
+func (a *adaptiveRPS) RPS() int {
+	return int(a.limiter.Rate())
+}
@@ -0,0 +1,78 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+)
+
+func TestAdaptiveRPS(t *testing.T) {
+	var (
+		now      = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		adaptive = newAdaptiveRPS(newTokenBucket(100, 0), &DriverOptions{}, now)
+		record   = func(requests, failures int) {
+			for i := range requests {
+				adaptive.Record(i < failures)
+			}
+			now = now.Add(defaultAdaptiveInterval)
+			adaptive.Update(now)
+		}
+	)
+	assert.Nil(t, newAdaptiveRPS(nil, &DriverOptions{}, now))
+	assert.Equal(t, 100, adaptive.RPS())
+
+	// The small sample doesn't change the rate
+	record(adaptiveMinimalSample-1, adaptiveMinimalSample-1)
+	assert.Equal(t, 100, adaptive.RPS())
+
+	// The error spikes lower the rate down to the floor (10% of the RPS by default)
+	record(1, 1)
+	assert.Equal(t, 50, adaptive.RPS())
+	for range 5 {
+		record(adaptiveMinimalSample, adaptiveMinimalSample/2)
+	}
+	assert.Equal(t, 10, adaptive.RPS())
+
+	// The healthy source ramps the rate back up gradually
+	record(adaptiveMinimalSample, 0)
+	assert.Equal(t, 20, adaptive.RPS())
+	for range 10 {
+		record(adaptiveMinimalSample, 0)
+	}
+	assert.Equal(t, 100, adaptive.RPS())
+}
+
+func TestAdaptiveRPSDriver(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}, WithAdaptiveRPS(30, 0.5, time.Second), DriverOption(func(opts *DriverOptions) {
+		opts.Clock = func() time.Time { return now }
+	}), testSourceOption(func(source *admodels.RTBSource) {
+		source.RPS = 100
+		source.Options.ErrorsIgnore = 1
+	}))
+	request := newTestRequest(context.Background(), "banner_300x250")
+	for range adaptiveMinimalSample {
+		_ = d.Bid(request)
+	}
+	assert.Equal(t, 100, d.Metrics().QPSLimit)
+
+	// The effective limit is lowered after the interval by the next request check
+	now = now.Add(time.Second)
+	_, _ = d.TestWithReason(request)
+	assert.Equal(t, 50, d.Metrics().QPSLimit)
+
+	// The limit is never lowered below the floor
+	for range adaptiveMinimalSample {
+		_ = d.Bid(request)
+	}
+	now = now.Add(time.Second)
+	_, _ = d.TestWithReason(request)
+	assert.Equal(t, 30, d.Metrics().QPSLimit)
+}
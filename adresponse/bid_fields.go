@@ -0,0 +1,128 @@
+package adresponse
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/bsm/openrtb"
+)
+
+// Category taxonomies (OpenRTB 2.6 List: Category Taxonomies)
+const (
+	CategoryTaxonomyIABContent1 = 1
+	CategoryTaxonomyIABContent2 = 2
+	CategoryTaxonomyIABProduct1 = 3
+	CategoryTaxonomyIABAudience = 4
+	CategoryTaxonomyIABContent3 = 7
+)
+
+// bidFieldsV26 of the OpenRTB 2.6 bid which are not present in the base bid structure
+type bidFieldsV26 struct {
+	MType  int `json:"mtype,omitempty"`
+	CatTax int `json:"cattax,omitempty"`
+}
+
+type decodeBid struct {
+	openrtb.Bid
+	bidFieldsV26
+}
+
+type decodeSeatBid struct {
+	openrtb.SeatBid
+	Bid []decodeBid `json:"bid"`
+}
+
+type decodeBidResponse struct {
+	openrtb.BidResponse
+	SeatBid []decodeSeatBid `json:"seatbid"`
+
+	// OpenRTB 3.0 response envelope
+	OpenRTB *envelopeV3 `json:"openrtb,omitempty"`
+}
+
+// DecodeBidResponse from the JSON stream.
+// The OpenRTB 2.6 bid fields (mtype, cattax) are moved into the bid extension
+// to be accessible in the same way for all protocol versions.
+// The OpenRTB 3.0 response envelope is mapped into the 2.x response structure.
+func DecodeBidResponse(r io.Reader, resp *openrtb.BidResponse) error {
+	var dec decodeBidResponse
+	if err := json.NewDecoder(r).Decode(&dec); err != nil {
+		return err
+	}
+	dec.bidResponse(resp)
+	return nil
+}
+
+// DecodeBidResponseBytes from the JSON data in the same way as DecodeBidResponse,
+// it's used for the body already read by the caller, the response doesn't refer
+// the data so the data buffer can be reused
+func DecodeBidResponseBytes(data []byte, resp *openrtb.BidResponse) error {
+	var dec decodeBidResponse
+	if err := json.Unmarshal(data, &dec); err != nil {
+		return err
+	}
+	dec.bidResponse(resp)
+	return nil
+}
+
+// bidResponse maps the decoded response into the 2.x response structure
+func (dec *decodeBidResponse) bidResponse(resp *openrtb.BidResponse) {
+	if dec.OpenRTB != nil && dec.OpenRTB.Response != nil {
+		*resp = dec.OpenRTB.Response.bidResponse()
+		return
+	}
+	*resp = dec.BidResponse
+	resp.SeatBid = make([]openrtb.SeatBid, 0, len(dec.SeatBid))
+	for _, seat := range dec.SeatBid {
+		seatBid := seat.SeatBid
+		seatBid.Bid = make([]openrtb.Bid, 0, len(seat.Bid))
+		for _, bid := range seat.Bid {
+			ext := readBidExtFields(bid.Ext)
+			if bid.MType > 0 && ext.MType == 0 {
+				bid.Ext = ExtSet(bid.Ext, "mtype", bid.MType)
+			}
+			if bid.CatTax > 0 && ext.CatTax == 0 {
+				bid.Ext = ExtSet(bid.Ext, "cattax", bid.CatTax)
+			}
+			seatBid.Bid = append(seatBid.Bid, bid.Bid)
+		}
+		resp.SeatBid = append(resp.SeatBid, seatBid)
+	}
+}
+
+// BidCategoryTaxonomy returns the taxonomy of the bid categories
+// or the default taxonomy if it's not defined in the bid
+func BidCategoryTaxonomy(bid *openrtb.Bid, defaultTaxonomy int) int {
+	if tax := readBidExtFields(bid.Ext).CatTax; tax > 0 {
+		return tax
+	}
+	return defaultTaxonomy
+}
+
+func readBidExtFields(ext openrtb.Extension) (fields bidFieldsV26) {
+	if len(ext) > 0 {
+		_ = json.Unmarshal(ext, &fields)
+	}
+	return fields
+}
+
+// ExtSet returns the JSON object extension with the new key value.
+// The original extension is returned if the value can't be encoded.
+func ExtSet[T ~[]byte](ext T, key string, value any) T {
+	data := map[string]json.RawMessage{}
+	if len(ext) > 0 {
+		if err := json.Unmarshal(ext, &data); err != nil {
+			return ext
+		}
+	}
+	val, err := json.Marshal(value)
+	if err != nil {
+		return ext
+	}
+	data[key] = val
+	res, err := json.Marshal(data)
+	if err != nil {
+		return ext
+	}
+	return T(res)
+}
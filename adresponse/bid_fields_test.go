@@ -0,0 +1,94 @@
+package adresponse
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bsm/openrtb"
+)
+
+// benchBidResponse returns the JSON response with the seats of the banner and native bids
+func benchBidResponse(seats, bids int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"id":"req-1","cur":"USD","seatbid":[`)
+	for i := 0; i < seats; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"seat":"seat-%d","bid":[`, i)
+		for j := 0; j < bids; j++ {
+			if j > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, `{"id":"bid-%d-%d","impid":"imp-%d","price":1.25,"crid":"cr-%d","w":300,"h":250,`+
+				`"mtype":1,"adomain":["example.com"],"cat":["IAB1"],"nurl":"https://example.com/win?p=${AUCTION_PRICE}",`+
+				`"adm":"<div><a href=\"https://example.com/click\"><img src=\"https://example.com/banner.png\"></a></div>"}`,
+				i, j, j, j)
+		}
+		sb.WriteString(`]}`)
+	}
+	sb.WriteString(`]}`)
+	return []byte(sb.String())
+}
+
+func TestDecodeBidResponseBytes(t *testing.T) {
+	data := benchBidResponse(2, 3)
+	for i := 0; i < 3; i++ {
+		var streamResp, bytesResp openrtb.BidResponse
+		if err := DecodeBidResponse(bytes.NewReader(data), &streamResp); err != nil {
+			t.Fatal(err)
+		}
+		buf := AcquireBodyBuffer()
+		_, _ = buf.Write(data)
+		if err := DecodeBidResponseBytes(buf.Bytes(), &bytesResp); err != nil {
+			t.Fatal(err)
+		}
+		ReleaseBodyBuffer(buf)
+		if len(bytesResp.SeatBid) != 2 || len(bytesResp.SeatBid[1].Bid) != 3 {
+			t.Fatalf("unexpected seat bids: %+v", bytesResp.SeatBid)
+		}
+		if bid := bytesResp.SeatBid[1].Bid[2]; bid.ID != "bid-1-2" || BidMarkupType(&bid) != MarkupTypeBanner {
+			t.Fatalf("unexpected bid: %+v", bid)
+		}
+		if !reflect.DeepEqual(streamResp, bytesResp) {
+			t.Fatalf("the decoded responses differ: %+v != %+v", streamResp, bytesResp)
+		}
+	}
+}
+
+// BenchmarkDecodeBidResponse of the streaming decoder as the baseline
+func BenchmarkDecodeBidResponse(b *testing.B) {
+	data := benchBidResponse(3, 5)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp openrtb.BidResponse
+		if err := DecodeBidResponse(bytes.NewReader(data), &resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecodeBidResponseBytes of the body read into the pooled buffer
+// as the driver does for the traced and the observed responses
+func BenchmarkDecodeBidResponseBytes(b *testing.B) {
+	data := benchBidResponse(3, 5)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp openrtb.BidResponse
+		buf := AcquireBodyBuffer()
+		if _, err := buf.ReadFrom(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+		if err := DecodeBidResponseBytes(buf.Bytes(), &resp); err != nil {
+			b.Fatal(err)
+		}
+		ReleaseBodyBuffer(buf)
+	}
+}
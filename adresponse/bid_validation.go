@@ -0,0 +1,106 @@
+package adresponse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+)
+
+// Markup types of the bid (OpenRTB 2.6 mtype)
+const (
+	MarkupTypeBanner = 1
+	MarkupTypeVideo  = 2
+	MarkupTypeAudio  = 3
+	MarkupTypeNative = 4
+)
+
+// BidViolation of the OpenRTB specification by the bid
+type BidViolation struct {
+	BidID   string `json:"bid_id"`
+	ImpID   string `json:"imp_id"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (v BidViolation) Error() string {
+	return fmt.Sprintf("bid[%s] imp[%s]: %s %s", v.BidID, v.ImpID, v.Field, v.Message)
+}
+
+// StrictValidateBid checks the bid against the OpenRTB specification
+// including the required fields of the impression media type.
+// The format can be nil if the impression of the bid is unknown.
+func StrictValidateBid(bid *openrtb.Bid, format *types.Format) []BidViolation {
+	var violations []BidViolation
+	violate := func(field, msg string) {
+		violations = append(violations, BidViolation{BidID: bid.ID, ImpID: bid.ImpID, Field: field, Message: msg})
+	}
+
+	if bid.ID == "" {
+		violate("id", "is required")
+	}
+	if bid.ImpID == "" {
+		violate("impid", "is required")
+	}
+	if bid.Price <= 0 {
+		violate("price", "must be greater than zero")
+	}
+	if bid.CreativeID == "" {
+		violate("crid", "is required")
+	}
+	if bid.AdMarkup == "" && bid.NURL == "" {
+		violate("adm", "either adm or nurl must be present")
+	}
+
+	mtype := BidMarkupType(bid)
+	if format != nil {
+		if expected := formatMarkupType(format); expected > 0 && mtype > 0 && mtype != expected {
+			violate("mtype", fmt.Sprintf("%d does not match the impression type %d", mtype, expected))
+		}
+		if mtype == 0 {
+			mtype = formatMarkupType(format)
+		}
+	}
+
+	switch mtype {
+	case MarkupTypeBanner:
+		if bid.W <= 0 || bid.H <= 0 {
+			violate("w,h", "are required for the banner")
+		}
+	case MarkupTypeVideo, MarkupTypeAudio:
+		if bid.AdMarkup != "" && !strings.Contains(bid.AdMarkup, "<VAST") {
+			violate("adm", "must contain the VAST document")
+		}
+	case MarkupTypeNative:
+		if bid.AdMarkup != "" {
+			if _, err := decodeNativeMarkup([]byte(bid.AdMarkup)); err != nil {
+				violate("adm", "must contain the native response: "+err.Error())
+			}
+		}
+	}
+	return violations
+}
+
+// BidMarkupType returns the markup type of the bid (mtype) or 0 if it's undefined
+func BidMarkupType(bid *openrtb.Bid) int {
+	return readBidExtFields(bid.Ext).MType
+}
+
+func formatMarkupType(format *types.Format) int {
+	switch {
+	case format.IsNative():
+		return MarkupTypeNative
+	case format.IsVideo():
+		return MarkupTypeVideo
+	case format.IsBanner() || format.IsProxy():
+		return MarkupTypeBanner
+	}
+	return 0
+}
+
+// MarkupUsesMRAID returns true if the markup references the MRAID library
+func MarkupUsesMRAID(markup string) bool {
+	return markup != "" && strings.Contains(strings.ToLower(markup), "mraid.js")
+}
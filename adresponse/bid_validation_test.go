@@ -0,0 +1,55 @@
+package adresponse
+
+import (
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+)
+
+func TestStrictValidateBid(t *testing.T) {
+	var (
+		banner = &types.Format{ID: 1, Codename: "banner", Types: *types.NewFormatTypeBitset(types.FormatBannerType), Width: 300, Height: 250}
+		video  = &types.Format{ID: 2, Codename: "video", Types: *types.NewFormatTypeBitset(types.FormatVideoType)}
+		valid  = openrtb.Bid{ID: "1", ImpID: "imp1", Price: 1, CreativeID: "c1", W: 300, H: 250}
+	)
+	tests := []struct {
+		name   string
+		bid    func(bid *openrtb.Bid)
+		format *types.Format
+		fields []string
+	}{
+		{name: "adm", bid: func(bid *openrtb.Bid) { bid.AdMarkup = "<div></div>" }, format: banner},
+		{name: "nurl", bid: func(bid *openrtb.Bid) { bid.NURL = "https://example.com/nurl" }, format: banner},
+		{name: "adm_and_nurl", bid: func(bid *openrtb.Bid) {
+			bid.AdMarkup, bid.NURL = "<div></div>", "https://example.com/nurl"
+		}, format: banner},
+		{name: "no_markup", format: banner, fields: []string{"adm"}},
+		{name: "required_fields", bid: func(bid *openrtb.Bid) {
+			bid.ID, bid.ImpID, bid.Price, bid.CreativeID, bid.AdMarkup = "", "", 0, "", "<div></div>"
+		}, format: banner, fields: []string{"id", "impid", "price", "crid"}},
+		{name: "banner_size", bid: func(bid *openrtb.Bid) {
+			bid.W, bid.H, bid.AdMarkup = 0, 0, "<div></div>"
+		}, format: banner, fields: []string{"w,h"}},
+		{name: "mtype_mismatch", bid: func(bid *openrtb.Bid) {
+			bid.AdMarkup, bid.Ext = "<div></div>", openrtb.Extension(`{"mtype":2}`)
+		}, format: banner, fields: []string{"mtype", "adm"}},
+		{name: "video_markup", bid: func(bid *openrtb.Bid) { bid.AdMarkup = "<div></div>" }, format: video, fields: []string{"adm"}},
+		{name: "video_vast", bid: func(bid *openrtb.Bid) { bid.AdMarkup = `<VAST version="4.0"></VAST>` }, format: video},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bid := valid
+			if test.bid != nil {
+				test.bid(&bid)
+			}
+			var fields []string
+			for _, violation := range StrictValidateBid(&bid, test.format) {
+				fields = append(fields, violation.Field)
+			}
+			assert.Equal(t, test.fields, fields)
+		})
+	}
+}
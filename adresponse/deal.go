@@ -0,0 +1,96 @@
+package adresponse
+
+import (
+	"slices"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// Deal auction types (OpenRTB deal.at)
+const (
+	DealAuctionFirstPrice  = 1
+	DealAuctionSecondPrice = 2
+	DealAuctionFixedPrice  = 3
+)
+
+// Deal terms of the private marketplace
+type Deal struct {
+	ID string `json:"id"`
+
+	// BidFloor of the deal (CPM in the system currency),
+	// the agreed deal price for the fixed price deals
+	BidFloor float64 `json:"bidfloor,omitempty"`
+
+	// AuctionType of the deal overriding the source auction type
+	AuctionType int `json:"at,omitempty"`
+
+	// Seats and advertiser domains allowed to bid on the deal
+	Seats    []string `json:"wseat,omitempty"`
+	ADomains []string `json:"wadomain,omitempty"`
+}
+
+// IsFixedPrice returns true if the deal is billed by the agreed price
+func (d *Deal) IsFixedPrice() bool {
+	return d != nil && d.AuctionType == DealAuctionFixedPrice && d.BidFloor > 0
+}
+
+// AllowsSeat returns true if the seat can bid on the deal
+func (d *Deal) AllowsSeat(seat string) bool {
+	return len(d.Seats) == 0 || slices.Contains(d.Seats, seat)
+}
+
+// PMP is the private marketplace of the impression
+type PMP struct {
+	// Private auction is restricted to the deals only
+	Private bool
+	Deals   []*Deal
+}
+
+// Deal returns the deal by ID or nil
+func (p *PMP) Deal(id string) *Deal {
+	if p == nil || id == "" {
+		return nil
+	}
+	for _, deal := range p.Deals {
+		if deal.ID == id {
+			return deal
+		}
+	}
+	return nil
+}
+
+// DealInfo of the bid matched with the private marketplace deal
+type DealInfo struct {
+	DealIDValue    string `json:"dealid,omitempty"`
+	DealTerms      *Deal  `json:"deal,omitempty"`
+	PrivateAuction bool   `json:"private_auction,omitempty"`
+}
+
+// DealID of the bid or empty string if the bid is not related to any deal
+func (d *DealInfo) DealID() string {
+	return d.DealIDValue
+}
+
+// Deal returns the terms of the matched deal or nil
+func (d *DealInfo) Deal() *Deal {
+	return d.DealTerms
+}
+
+// IsPrivateAuction returns true if the bid won in the private auction
+func (d *DealInfo) IsPrivateAuction() bool {
+	return d.PrivateAuction
+}
+
+// matchedDeal of the bid impression
+func (r *BidResponse) matchedDeal(bid *openrtb.Bid, imp *adtype.Impression) (*Deal, bool) {
+	if r.PMP == nil || imp == nil {
+		return nil, false
+	}
+	pmp := r.PMP(imp)
+	if pmp == nil {
+		return nil, false
+	}
+	return pmp.Deal(bid.DealID), pmp.Private
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
-	"net/url"
 	"strings"
 
 	"golang.org/x/net/html/charset"
@@ -39,23 +38,89 @@ func customDirectURL(data []byte) (val string, err error) {
 	return val, err
 }
 
+// bannerFormatType detects the format type of the banner markup: the VAST document is the video,
+// the AMPHTML document is the HTML5 banner, the URLs, iframes and the JS-tag-only payloads
+// are rendered by the proxy (iframe), the data-URI images and other markups are the banners
 func bannerFormatType(markup string) types.FormatType {
-	if strings.HasPrefix(markup, "http://") ||
+	switch {
+	case IsVASTMarkup(markup):
+		return types.FormatVideoType
+	case isDataImageURI(markup):
+		return types.FormatBannerType
+	case isAMPMarkup(markup):
+		return types.FormatBannerHTML5Type
+	case strings.HasPrefix(markup, "http://") ||
 		strings.HasPrefix(markup, "https://") ||
 		(strings.HasPrefix(markup, "//") && !strings.ContainsAny(markup, "\n\t")) ||
-		strings.Contains(markup, "<iframe") {
+		strings.Contains(markup, "<iframe") ||
+		isScriptTagMarkup(markup):
 		return types.FormatProxyType
 	}
 	return types.FormatBannerType
 }
 
+// isDataImageURI returns true if the markup is the image encoded as the data URI
+func isDataImageURI(markup string) bool {
+	return hasPrefixFold(strings.TrimSpace(markup), "data:image/")
+}
+
+// isAMPMarkup returns true if the markup is the AMPHTML document (<html ⚡> or <html amp>)
+func isAMPMarkup(markup string) bool {
+	markup = strings.TrimSpace(markup)
+	if hasPrefixFold(markup, "<!doctype") {
+		if end := strings.IndexByte(markup, '>'); end > 0 {
+			markup = strings.TrimSpace(markup[end+1:])
+		}
+	}
+	if !hasPrefixFold(markup, "<html") {
+		return false
+	}
+	end := strings.IndexByte(markup, '>')
+	if end < 0 {
+		return false
+	}
+	for _, attr := range strings.Fields(strings.ToLower(markup[len("<html"):end])) {
+		if name, _, _ := strings.Cut(attr, "="); name == "⚡" || name == "amp" || name == "⚡4ads" || name == "amp4ads" {
+			return true
+		}
+	}
+	return false
+}
+
+// isScriptTagMarkup returns true if the markup contains only the external script tags (JS tag)
+func isScriptTagMarkup(markup string) bool {
+	markup = strings.TrimSpace(markup)
+	if markup == "" {
+		return false
+	}
+	for markup != "" {
+		if !hasPrefixFold(markup, "<script") {
+			return false
+		}
+		end := strings.Index(strings.ToLower(markup), "</script>")
+		if end < 0 {
+			return false
+		}
+		if open := strings.IndexByte(markup, '>'); open < 0 || open > end ||
+			!strings.Contains(strings.ToLower(markup[:open]), "src=") ||
+			strings.TrimSpace(markup[open+1:end]) != "" {
+			return false
+		}
+		markup = strings.TrimSpace(markup[end+len("</script>"):])
+	}
+	return true
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// prepareURL replaces the macros of the URL, the percent-encoded macros are replaced
+// by the replacer as well so the rest of the URL is kept escaped
 func prepareURL(surl string, replacer *strings.Replacer) string {
 	if surl == "" {
 		return surl
 	}
-	if u, err := url.QueryUnescape(surl); err == nil {
-		surl = u
-	}
 	return replacer.Replace(surl)
 }
 
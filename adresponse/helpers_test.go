@@ -0,0 +1,60 @@
+package adresponse
+
+import (
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adquery/bidrequest"
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+func TestBannerFormatType(t *testing.T) {
+	tests := []struct {
+		name   string
+		markup string
+		format types.FormatType
+	}{
+		{name: "html", markup: `<div><img src="https://example.com/banner.png"></div>`, format: types.FormatBannerType},
+		{name: "vast", markup: `<?xml version="1.0"?><VAST version="4.0"></VAST>`, format: types.FormatVideoType},
+		{name: "data_image", markup: " DATA:image/png;base64,iVBORw0KGgo=", format: types.FormatBannerType},
+		{name: "amp", markup: `<!doctype html><html ⚡4ads><head></head></html>`, format: types.FormatBannerHTML5Type},
+		{name: "amp_attr", markup: `<HTML lang="en" amp>`, format: types.FormatBannerHTML5Type},
+		{name: "html_document", markup: `<!DOCTYPE html><html lang="en"><body></body></html>`, format: types.FormatBannerType},
+		{name: "url", markup: "https://example.com/ad", format: types.FormatProxyType},
+		{name: "relative_url", markup: "//example.com/ad", format: types.FormatProxyType},
+		{name: "iframe", markup: `<div><iframe src="https://example.com/ad"></iframe></div>`, format: types.FormatProxyType},
+		{
+			name:   "js_tags",
+			markup: "<script src=\"https://example.com/a.js\"></script>\n<SCRIPT type=\"text/javascript\" SRC=\"https://example.com/b.js\"> </SCRIPT>",
+			format: types.FormatProxyType,
+		},
+		{name: "inline_script", markup: `<script>document.write("ad")</script>`, format: types.FormatBannerType},
+		{name: "js_tag_with_html", markup: `<script src="https://example.com/a.js"></script><div></div>`, format: types.FormatBannerType},
+		{name: "unclosed_script", markup: `<script src="https://example.com/a.js">`, format: types.FormatBannerType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.format, bannerFormatType(tt.markup))
+		})
+	}
+}
+
+func TestBannerDataImage(t *testing.T) {
+	var (
+		imp    = &adtype.Impression{ID: "imp1", Target: &adtype.TargetEmpty{Acc: &admodels.Account{IDval: 1}}}
+		req    = &bidrequest.BidRequest{IDVal: "auction1", Imps: []*adtype.Impression{imp}}
+		format = &types.Format{Codename: "banner", Types: *types.NewFormatTypeBitset(types.FormatBannerType)}
+		bid    = &openrtb.Bid{ID: "1", ImpID: "imp1", Price: 1, AdMarkup: " data:image/gif;base64,R0lGODlhAQABAAAAACw= "}
+	)
+	item, err := newResponseBannerBidItem(req, &adtype.SourceEmpty{}, bid, imp, format)
+	if assert.NoError(t, err) {
+		assert.Equal(t, types.FormatBannerType, item.FormatType)
+		assert.Equal(t, "data:image/gif;base64,R0lGODlhAQABAAAAACw=", item.BannerInfo.ImageURL)
+		assert.Equal(t, `<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=">`, item.BannerInfo.HTML)
+		assert.Empty(t, item.BannerInfo.IframeURL)
+	}
+}
@@ -0,0 +1,197 @@
+package adresponse
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// ImpIDCodec controls how the OpenRTB impression IDs are generated from
+// the impression and format and decoded back during the response matching
+type ImpIDCodec interface {
+	// Encode the impression format into the OpenRTB impression ID
+	Encode(imp *adtype.Impression, format *types.Format) string
+
+	// Decode the OpenRTB impression ID into the impression and format of the request.
+	// Returns nil impression if the ID doesn't belong to the request.
+	Decode(req adtype.BidRequester, impID string) (*adtype.Impression, *types.Format)
+}
+
+// ImpIDMatch kind of the impression ID matching with the request impressions
+type ImpIDMatch int
+
+// Impression ID match kinds
+const (
+	// ImpIDMatchNone means that the ID doesn't belong to the request
+	ImpIDMatchNone ImpIDMatch = iota
+	// ImpIDMatchExact means that the ID is equal to the ID sent in the request
+	ImpIDMatchExact
+	// ImpIDMatchFallback means that the impression is matched by the ID variation (prefix)
+	ImpIDMatchFallback
+)
+
+func (m ImpIDMatch) String() string {
+	switch m {
+	case ImpIDMatchExact:
+		return "exact"
+	case ImpIDMatchFallback:
+		return "fallback"
+	}
+	return "none"
+}
+
+// ImpIDMatcher is implemented by the codecs which can distinguish the exact and the fallback matching
+type ImpIDMatcher interface {
+	Match(req adtype.BidRequester, impID string) (*adtype.Impression, *types.Format, ImpIDMatch)
+}
+
+// Impression ID codecs
+var (
+	// CodenameImpIDCodec uses the impression ID with the format codename (default scheme)
+	CodenameImpIDCodec ImpIDCodec = codenameImpIDCodec{}
+
+	// HashImpIDCodec uses the opaque hash of the impression ID and format codename
+	HashImpIDCodec ImpIDCodec = hashImpIDCodec{}
+
+	// UUIDImpIDCodec uses the name based UUID of the impression ID and format codename
+	UUIDImpIDCodec ImpIDCodec = uuidImpIDCodec{}
+)
+
+// MatchImpID decodes the impression ID and returns the kind of the matching.
+// The codecs without ImpIDMatcher support match exactly if both impression and format are found.
+func MatchImpID(codec ImpIDCodec, req adtype.BidRequester, impID string) (*adtype.Impression, *types.Format, ImpIDMatch) {
+	codec = ImpIDCodecOrDefault(codec)
+	if matcher, ok := codec.(ImpIDMatcher); ok {
+		return matcher.Match(req, impID)
+	}
+	imp, format := codec.Decode(req, impID)
+	switch {
+	case imp == nil:
+		return nil, nil, ImpIDMatchNone
+	case format == nil:
+		return imp, nil, ImpIDMatchFallback
+	}
+	return imp, format, ImpIDMatchExact
+}
+
+// StrictImpIDCodec wraps the codec to refuse the fallback matching of the impression IDs
+func StrictImpIDCodec(codec ImpIDCodec) ImpIDCodec {
+	return strictImpIDCodec{codec: ImpIDCodecOrDefault(codec)}
+}
+
+// ImpIDCodecOrDefault returns the codec or the default one if it's nil
+func ImpIDCodecOrDefault(codec ImpIDCodec) ImpIDCodec {
+	if codec == nil {
+		return CodenameImpIDCodec
+	}
+	return codec
+}
+
+type codenameImpIDCodec struct{}
+
+func (codenameImpIDCodec) Encode(imp *adtype.Impression, format *types.Format) string {
+	return imp.IDByFormat(format)
+}
+
+func (c codenameImpIDCodec) Decode(req adtype.BidRequester, impID string) (*adtype.Impression, *types.Format) {
+	imp, format, _ := c.Match(req, impID)
+	return imp, format
+}
+
+// Match the impression by the ID prefix and the format by the exact ID
+func (codenameImpIDCodec) Match(req adtype.BidRequester, impID string) (*adtype.Impression, *types.Format, ImpIDMatch) {
+	for _, imp := range req.Impressions() {
+		if !strings.HasPrefix(impID, imp.ID) {
+			continue
+		}
+		if imp.IsDirect() {
+			format := imp.FormatByType(types.FormatDirectType)
+			if format != nil && impID == imp.IDByFormat(format) {
+				return imp, format, ImpIDMatchExact
+			}
+			return imp, format, ImpIDMatchFallback
+		}
+		for _, format := range imp.Formats() {
+			if impID == imp.IDByFormat(format) {
+				return imp, format, ImpIDMatchExact
+			}
+		}
+		return imp, nil, ImpIDMatchFallback
+	}
+	return nil, nil, ImpIDMatchNone
+}
+
+type hashImpIDCodec struct{}
+
+func (hashImpIDCodec) Encode(imp *adtype.Impression, format *types.Format) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(imp.ID))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(format.Codename))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+func (c hashImpIDCodec) Decode(req adtype.BidRequester, impID string) (*adtype.Impression, *types.Format) {
+	return decodeImpIDByEncoding(c, req, impID)
+}
+
+type uuidImpIDCodec struct{}
+
+func (uuidImpIDCodec) Encode(imp *adtype.Impression, format *types.Format) string {
+	sum := sha1.Sum([]byte(imp.ID + "\x00" + format.Codename))
+	sum[6] = (sum[6] & 0x0f) | 0x50 // Version 5
+	sum[8] = (sum[8] & 0x3f) | 0x80 // Variant RFC 4122
+	var buf [36]byte
+	hex.Encode(buf[0:8], sum[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], sum[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], sum[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], sum[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], sum[10:16])
+	return string(buf[:])
+}
+
+func (c uuidImpIDCodec) Decode(req adtype.BidRequester, impID string) (*adtype.Impression, *types.Format) {
+	return decodeImpIDByEncoding(c, req, impID)
+}
+
+// decodeImpIDByEncoding finds the impression format which is encoded into the same ID
+func decodeImpIDByEncoding(codec ImpIDCodec, req adtype.BidRequester, impID string) (*adtype.Impression, *types.Format) {
+	for _, imp := range req.Impressions() {
+		for _, format := range imp.Formats() {
+			if codec.Encode(imp, format) == impID {
+				return imp, format
+			}
+		}
+	}
+	return nil, nil
+}
+
+type strictImpIDCodec struct {
+	codec ImpIDCodec
+}
+
+func (c strictImpIDCodec) Encode(imp *adtype.Impression, format *types.Format) string {
+	return c.codec.Encode(imp, format)
+}
+
+func (c strictImpIDCodec) Decode(req adtype.BidRequester, impID string) (*adtype.Impression, *types.Format) {
+	imp, format, match := MatchImpID(c.codec, req, impID)
+	if match != ImpIDMatchExact {
+		return nil, nil
+	}
+	return imp, format
+}
+
+// Match returns the kind of the matching of the wrapped codec
+func (c strictImpIDCodec) Match(req adtype.BidRequester, impID string) (*adtype.Impression, *types.Format, ImpIDMatch) {
+	return MatchImpID(c.codec, req, impID)
+}
@@ -0,0 +1,112 @@
+package adresponse
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adquery/bidrequest"
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// newTestImpRequest returns the request of the impressions with the banner and native formats
+func newTestImpRequest(ids ...string) *bidrequest.BidRequest {
+	formats := types.NewSimpleFormatAccessor([]*types.Format{
+		{ID: 1, Codename: "banner_300x250", Types: *types.NewFormatTypeBitset(types.FormatBannerType), Width: 300, Height: 250},
+		{ID: 2, Codename: "native", Types: *types.NewFormatTypeBitset(types.FormatNativeType), Config: &types.FormatConfig{}},
+	})
+	request := &bidrequest.BidRequest{IDVal: "auction1"}
+	for _, id := range ids {
+		imp := &adtype.Impression{ID: id, FormatCodes: []string{"banner_300x250", "native"}}
+		imp.InitFormats(formats)
+		request.Imps = append(request.Imps, imp)
+	}
+	return request
+}
+
+func TestImpIDCodecs(t *testing.T) {
+	request := newTestImpRequest("imp1", "imp2")
+	codecs := map[string]ImpIDCodec{
+		"codename": CodenameImpIDCodec,
+		"hash":     HashImpIDCodec,
+		"uuid":     UUIDImpIDCodec,
+	}
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			ids := map[string]bool{}
+			for _, imp := range request.Imps {
+				for _, format := range imp.Formats() {
+					impID := codec.Encode(imp, format)
+					assert.Equal(t, impID, codec.Encode(imp, format), "the encoding is stable")
+					ids[impID] = true
+
+					decodedImp, decodedFormat := codec.Decode(request, impID)
+					assert.Same(t, imp, decodedImp)
+					assert.Same(t, format, decodedFormat)
+				}
+			}
+			assert.Len(t, ids, 4, "the IDs are unique per impression format")
+
+			imp, format := codec.Decode(request, "other")
+			assert.Nil(t, imp)
+			assert.Nil(t, format)
+		})
+	}
+
+	// The opaque codecs don't expose the impression ID and format codename
+	imp := request.Imps[0]
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-z]+$`), HashImpIDCodec.Encode(imp, imp.Formats()[0]))
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		UUIDImpIDCodec.Encode(imp, imp.Formats()[0]))
+	assert.Equal(t, CodenameImpIDCodec, ImpIDCodecOrDefault(nil))
+	assert.Equal(t, HashImpIDCodec, ImpIDCodecOrDefault(HashImpIDCodec))
+}
+
+func TestMatchImpID(t *testing.T) {
+	var (
+		request = newTestImpRequest("imp1", "imp2")
+		imp     = request.Imps[1]
+		banner  = imp.Formats()[0]
+	)
+	tests := []struct {
+		name   string
+		codec  ImpIDCodec
+		impID  string
+		imp    *adtype.Impression
+		format *types.Format
+		match  ImpIDMatch
+	}{
+		{name: "codename_exact", impID: "imp2_banner_300x250", imp: imp, format: banner, match: ImpIDMatchExact},
+		{name: "codename_variation", impID: "imp2", imp: imp, match: ImpIDMatchFallback},
+		{name: "codename_unknown_format", impID: "imp2_video", imp: imp, match: ImpIDMatchFallback},
+		{name: "codename_none", impID: "other", match: ImpIDMatchNone},
+		{name: "hash_exact", codec: HashImpIDCodec, impID: HashImpIDCodec.Encode(imp, banner), imp: imp, format: banner, match: ImpIDMatchExact},
+		{name: "hash_none", codec: HashImpIDCodec, impID: "imp2", match: ImpIDMatchNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matchedImp, matchedFormat, match := MatchImpID(tt.codec, request, tt.impID)
+			assert.Same(t, tt.imp, matchedImp)
+			assert.Same(t, tt.format, matchedFormat)
+			assert.Equal(t, tt.match, match)
+
+			// The strict codec accepts the exact matches only and reports the match kind as is
+			strict := StrictImpIDCodec(tt.codec)
+			decodedImp, decodedFormat := strict.Decode(request, tt.impID)
+			if tt.match == ImpIDMatchExact {
+				assert.Same(t, tt.imp, decodedImp)
+				assert.Same(t, tt.format, decodedFormat)
+			} else {
+				assert.Nil(t, decodedImp)
+				assert.Nil(t, decodedFormat)
+			}
+			_, _, match = MatchImpID(strict, request, tt.impID)
+			assert.Equal(t, tt.match, match)
+		})
+	}
+	assert.Equal(t, "exact", ImpIDMatchExact.String())
+	assert.Equal(t, "fallback", ImpIDMatchFallback.String())
+	assert.Equal(t, "none", ImpIDMatchNone.String())
+}
@@ -0,0 +1,109 @@
+package adresponse
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// Macros of the markup which can be bound at the render time (see BidResponse.LateMacros)
+const (
+	MacroAuctionPrice    = "${AUCTION_PRICE}"
+	MacroAuctionCurrency = "${AUCTION_CURRENCY}"
+	MacroTimestamp       = "${TIMESTAMP}"
+	MacroCacheBuster     = "${CACHEBUSTER}"
+)
+
+// lateBinding of the markup macros left as the placeholders by the Prepare
+type lateBinding struct {
+	macros   []string
+	currency string
+	rate     float64
+	units    map[string]float64
+}
+
+// finalize replaces the late macros of the markup by the render time values
+// and the final clearing price of the item
+func (b *lateBinding) finalize(ctx context.Context, item adtype.ResponseItem, markup string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if b == nil || markup == "" {
+		return markup, nil
+	}
+	var (
+		replaces []string
+		// The impression price is the clearing price of the item after the internal auction
+		clearing = item.Price(adtype.ActionImpression).Float64() * 1000
+	)
+	if bid := responseItemBid(item); bid != nil {
+		clearing = sourceUnitPrice(clearing, b.units, bid)
+	}
+	for _, macro := range b.macros {
+		switch macro {
+		case MacroAuctionPrice:
+			price, _ := sourcePrice(clearing, b.currency, b.rate)
+			replaces = append(replaces, macro, fmt.Sprintf("%.6f", price))
+		case MacroAuctionCurrency:
+			_, currency := sourcePrice(clearing, b.currency, b.rate)
+			replaces = append(replaces, macro, currency)
+		case MacroTimestamp:
+			replaces = append(replaces, macro, strconv.FormatInt(time.Now().UnixMilli(), 10))
+		case MacroCacheBuster:
+			replaces = append(replaces, macro, strconv.FormatUint(rand.Uint64N(1<<53), 10))
+		}
+	}
+	return strings.NewReplacer(expandMacros(replaces, false)...).Replace(markup), nil
+}
+
+// lateBinding returns the late binding of the response items or nil if disabled
+func (r *BidResponse) lateBinding() *lateBinding {
+	if len(r.LateMacros) == 0 {
+		return nil
+	}
+	return &lateBinding{
+		macros:   r.LateMacros,
+		currency: r.SourceCurrency,
+		rate:     r.SourceCurrencyRate,
+		units:    r.PriceUnits,
+	}
+}
+
+// markupReplacer returns the replacer of the markup macros except the late ones
+func (r *BidResponse) markupReplacer(bid *openrtb.Bid, auctionPrice float64, replacer *strings.Replacer) *strings.Replacer {
+	if len(r.LateMacros) == 0 {
+		return replacer
+	}
+	var (
+		macros = append(r.bidMacros(bid), r.priceMacros(bid, auctionPrice)...)
+		list   = make([]string, 0, len(macros))
+	)
+	for i := 0; i+1 < len(macros); i += 2 {
+		if !slices.Contains(r.LateMacros, macros[i]) {
+			list = append(list, macros[i], macros[i+1])
+		}
+	}
+	return strings.NewReplacer(expandMacros(list, false)...)
+}
+
+// setLateBinding of the response item markup
+func setLateBinding(item adtype.ResponseItemCommon, binding *lateBinding) {
+	switch it := item.(type) {
+	case *ResponseBannerBidItem:
+		it.late = binding
+	case *ResponseNativeBidItem:
+		it.late = binding
+	case *ResponseVASTBidItem:
+		it.late = binding
+	case *ResponseDirectBidItem:
+		it.late = binding
+	}
+}
@@ -0,0 +1,163 @@
+package adresponse
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bsm/openrtb"
+)
+
+// Tolerance of the spec violation fixed by the lenient decoding
+type Tolerance string
+
+// Tolerances of the lenient decoding
+const (
+	// ToleranceNumericString is the number encoded as a string ("1.5")
+	ToleranceNumericString Tolerance = "numeric_string"
+	// ToleranceBoolFlag is the integer flag encoded as a boolean (true)
+	ToleranceBoolFlag Tolerance = "bool_flag"
+	// ToleranceFractionalInt is the integer field encoded as a fractional number (300.0)
+	ToleranceFractionalInt Tolerance = "fractional_int"
+	// ToleranceUnknownEnum is the enum value which is not defined in the specification (removed)
+	ToleranceUnknownEnum Tolerance = "unknown_enum"
+	// ToleranceInvalidValue is the value which can't be converted into the field type (removed)
+	ToleranceInvalidValue Tolerance = "invalid_value"
+)
+
+// Value ranges of the bid enums (OpenRTB 2.6 lists)
+var lenientBidEnums = map[string][2]int{
+	"mtype":          {MarkupTypeBanner, MarkupTypeNative},
+	"api":            {1, 8},
+	"protocol":       {1, 14},
+	"qagmediarating": {1, 3},
+}
+
+var (
+	lenientBidInts     = []string{"w", "h", "wratio", "hratio", "exp", "cattax", "api", "protocol", "qagmediarating", "mtype"}
+	lenientBidIntLists = []string{"attr"}
+)
+
+// DecodeBidResponseLenient from the JSON stream tolerating the common spec violations
+// of the bid fields: the numeric strings, the boolean flags, the fractional integers
+// and the unknown enum values. The observer receives each exercised tolerance.
+func DecodeBidResponseLenient(r io.Reader, resp *openrtb.BidResponse, observe func(Tolerance)) error {
+	var obj map[string]any
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return err
+	}
+	if observe == nil {
+		observe = func(Tolerance) {}
+	}
+	lenientInt(obj, "nbr", observe)
+	seats, _ := obj["seatbid"].([]any)
+	for _, seatVal := range seats {
+		seat, _ := seatVal.(map[string]any)
+		if seat == nil {
+			continue
+		}
+		lenientInt(seat, "group", observe)
+		bids, _ := seat["bid"].([]any)
+		for _, bidVal := range bids {
+			if bid, _ := bidVal.(map[string]any); bid != nil {
+				lenientBid(bid, observe)
+			}
+		}
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return DecodeBidResponseBytes(data, resp)
+}
+
+func lenientBid(bid map[string]any, observe func(Tolerance)) {
+	if val, ok := bid["price"]; ok {
+		if price, tolerance, ok := lenientNumber(val); ok {
+			bid["price"] = price
+			if tolerance != "" {
+				observe(tolerance)
+			}
+		} else {
+			bid["price"] = 0
+			observe(ToleranceInvalidValue)
+		}
+	}
+	for _, key := range lenientBidInts {
+		lenientInt(bid, key, observe)
+	}
+	for _, key := range lenientBidIntLists {
+		list, ok := bid[key].([]any)
+		if !ok {
+			continue
+		}
+		values := list[:0]
+		for _, val := range list {
+			num, tolerance, ok := lenientNumber(val)
+			if !ok {
+				observe(ToleranceInvalidValue)
+				continue
+			}
+			if tolerance != "" {
+				observe(tolerance)
+			}
+			values = append(values, json.Number(strconv.Itoa(int(num))))
+		}
+		bid[key] = values
+	}
+	for key, bounds := range lenientBidEnums {
+		num, ok := bid[key].(json.Number)
+		if !ok {
+			continue
+		}
+		if v, err := num.Int64(); err != nil || v < int64(bounds[0]) || v > int64(bounds[1]) {
+			delete(bid, key)
+			observe(ToleranceUnknownEnum)
+		}
+	}
+}
+
+// lenientInt converts the value of the key into the integer or removes it if it's invalid
+func lenientInt(obj map[string]any, key string, observe func(Tolerance)) {
+	val, ok := obj[key]
+	if !ok || val == nil {
+		return
+	}
+	num, tolerance, ok := lenientNumber(val)
+	if !ok {
+		delete(obj, key)
+		observe(ToleranceInvalidValue)
+		return
+	}
+	if num != math.Trunc(num) {
+		tolerance = ToleranceFractionalInt
+	} else if n, isNum := val.(json.Number); isNum && strings.ContainsAny(n.String(), ".eE") {
+		tolerance = ToleranceFractionalInt
+	}
+	if tolerance != "" {
+		observe(tolerance)
+	}
+	obj[key] = json.Number(strconv.FormatInt(int64(num), 10))
+}
+
+// lenientNumber returns the number of the JSON value and the tolerance used for the conversion
+func lenientNumber(val any) (float64, Tolerance, bool) {
+	switch v := val.(type) {
+	case json.Number:
+		num, err := v.Float64()
+		return num, "", err == nil
+	case string:
+		num, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return num, ToleranceNumericString, err == nil
+	case bool:
+		if v {
+			return 1, ToleranceBoolFlag, true
+		}
+		return 0, ToleranceBoolFlag, true
+	}
+	return 0, "", false
+}
@@ -0,0 +1,58 @@
+package adresponse
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeBidResponseLenient(t *testing.T) {
+	data := []byte(`{"id":"1","nbr":"2","seatbid":[{"group":true,"bid":[` +
+		`{"id":"a","impid":"imp1","price":"1.5","w":300.0,"h":"250","attr":[1,"3","x"],"mtype":9,"api":2},` +
+		`{"id":"b","impid":"imp2","price":"free","exp":10.5,"protocol":{"v":1},"mtype":"2"}]}]}`)
+
+	tolerances := map[Tolerance]int{}
+	var resp openrtb.BidResponse
+	err := DecodeBidResponseLenient(bytes.NewReader(data), &resp, func(tolerance Tolerance) {
+		tolerances[tolerance]++
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, resp.NBR)
+	if !assert.Len(t, resp.SeatBid, 1) || !assert.Len(t, resp.SeatBid[0].Bid, 2) {
+		return
+	}
+	assert.Equal(t, 1, resp.SeatBid[0].Group)
+
+	first, second := resp.SeatBid[0].Bid[0], resp.SeatBid[0].Bid[1]
+	assert.Equal(t, 1.5, first.Price)
+	assert.Equal(t, 300, first.W)
+	assert.Equal(t, 250, first.H)
+	assert.Equal(t, []int{1, 3}, first.Attr)
+	assert.Equal(t, 2, first.API)
+	assert.Equal(t, 0, BidMarkupType(&first), "the unknown mtype is removed")
+
+	assert.Equal(t, 0., second.Price)
+	assert.Equal(t, 10, second.Exp)
+	assert.Equal(t, 0, second.Protocol)
+	assert.Equal(t, MarkupTypeVideo, BidMarkupType(&second))
+
+	assert.Equal(t, map[Tolerance]int{
+		ToleranceNumericString: 5, // nbr, price, h, attr, mtype
+		ToleranceBoolFlag:      1, // group
+		ToleranceFractionalInt: 2, // w, exp
+		ToleranceUnknownEnum:   1, // mtype
+		ToleranceInvalidValue:  3, // attr, price, protocol
+	}, tolerances)
+
+	// The strict decoding rejects the same response
+	assert.Error(t, DecodeBidResponse(bytes.NewReader(data), &resp))
+}
+
+func TestDecodeBidResponseLenientInvalid(t *testing.T) {
+	var resp openrtb.BidResponse
+	assert.Error(t, DecodeBidResponseLenient(bytes.NewReader([]byte(`{"id":`)), &resp, nil))
+}
@@ -0,0 +1,60 @@
+package adresponse
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+)
+
+// MacroSuffixB64 of the macros replaced by the Base64 (URL-safe) encoded value, like `${AUCTION_PRICE:B64}`
+const MacroSuffixB64 = ":B64"
+
+// expandMacros returns the replacement pairs of the macros with the escaped variants:
+// the Base64 encoded macros (`${NAME:B64}`) and the percent-encoded macros of the URLs
+// (`%24%7BNAME%7D`, `%24%7BNAME%3AB64%7D`) replaced by the query-escaped values.
+// The values of the plain macros are query-escaped too in the URL context,
+// so the values don't break the query strings of the tracking URLs.
+func expandMacros(pairs []string, urlContext bool) []string {
+	expanded := make([]string, 0, len(pairs)*5)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		macro, value := pairs[i], pairs[i+1]
+		name, ok := macroName(macro)
+		if !ok {
+			expanded = append(expanded, macro, value)
+			continue
+		}
+		var (
+			escaped = url.QueryEscape(value)
+			b64     = base64.URLEncoding.EncodeToString([]byte(value))
+		)
+		if urlContext {
+			value = escaped
+		}
+		expanded = append(expanded,
+			macro, value,
+			"${"+name+MacroSuffixB64+"}", b64,
+		)
+		for _, encoded := range percentEncodedMacros(name) {
+			expanded = append(expanded, encoded, escaped)
+		}
+		for _, encoded := range percentEncodedMacros(name + MacroSuffixB64) {
+			expanded = append(expanded, encoded, url.QueryEscape(b64))
+		}
+	}
+	return expanded
+}
+
+// macroName returns the name of the `${NAME}` macro
+func macroName(macro string) (string, bool) {
+	if !strings.HasPrefix(macro, "${") || !strings.HasSuffix(macro, "}") {
+		return "", false
+	}
+	return macro[2 : len(macro)-1], true
+}
+
+// percentEncodedMacros returns the percent-encoded forms of the macro with upper and lower case hex digits
+func percentEncodedMacros(name string) []string {
+	upper := "%24%7B" + strings.ReplaceAll(name, ":", "%3A") + "%7D"
+	lower := "%24%7b" + strings.ReplaceAll(name, ":", "%3a") + "%7d"
+	return []string{upper, lower}
+}
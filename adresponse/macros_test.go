@@ -0,0 +1,22 @@
+package adresponse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandMacros(t *testing.T) {
+	macros := []string{"${AUCTION_PRICE}", "1.250000", "${AUCTION_ID}", "a b&c"}
+
+	urlReplacer := strings.NewReplacer(expandMacros(macros, true)...)
+	assert.Equal(t,
+		"https://example.com/win?p=1.250000&id=a+b%26c&e=a+b%26c&l=a+b%26c&b=YSBiJmM=&eb=YSBiJmM%3D",
+		urlReplacer.Replace("https://example.com/win?p=${AUCTION_PRICE}&id=${AUCTION_ID}"+
+			"&e=%24%7BAUCTION_ID%7D&l=%24%7bAUCTION_ID%7d&b=${AUCTION_ID:B64}&eb=%24%7BAUCTION_ID%3AB64%7D"))
+
+	markupReplacer := strings.NewReplacer(expandMacros(macros, false)...)
+	assert.Equal(t, `<div data-id="a b&c" data-price="MS4yNTAwMDA=">`,
+		markupReplacer.Replace(`<div data-id="${AUCTION_ID}" data-price="${AUCTION_PRICE:B64}">`))
+}
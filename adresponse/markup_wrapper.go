@@ -0,0 +1,117 @@
+package adresponse
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"strings"
+)
+
+// MarkupTrustLevel of the third-party markup of the source
+type MarkupTrustLevel int
+
+// Markup trust levels
+const (
+	// MarkupTrustUntrusted markup is isolated in the strict sandbox with the content security policy
+	MarkupTrustUntrusted MarkupTrustLevel = iota
+	// MarkupTrustPartial markup is isolated in the sandbox with the same origin access
+	MarkupTrustPartial
+	// MarkupTrustTrusted markup is rendered as is
+	MarkupTrustTrusted
+)
+
+// defaultMarkupWrapperTemplate isolates the markup document in the sandboxed iframe
+var defaultMarkupWrapperTemplate = template.Must(template.New("markup").Parse(
+	`<iframe sandbox="{{.Sandbox}}" srcdoc="{{.Document}}" frameborder="0" scrolling="no" marginwidth="0" marginheight="0"` +
+		`{{if .LockSize}} width="{{.Width}}" height="{{.Height}}" style="width:{{.Width}}px;height:{{.Height}}px;max-width:{{.Width}}px;max-height:{{.Height}}px;border:0;overflow:hidden"` +
+		`{{else}} style="border:0"{{end}}></iframe>`,
+))
+
+// MarkupWrapper of the third-party HTML markup
+type MarkupWrapper struct {
+	// Sandbox attributes of the iframe (empty list means the maximal restrictions)
+	Sandbox []string
+
+	// CSP is the content security policy of the markup document (disabled if empty)
+	CSP string
+
+	// LockSize of the iframe to the creative size
+	LockSize bool
+
+	// Template of the wrapper, the default iframe template is used if not defined.
+	// The template receives MarkupWrapperData.
+	Template *template.Template
+}
+
+// MarkupWrapperData of the wrapper template
+type MarkupWrapperData struct {
+	Sandbox  string
+	Document string
+	Markup   string
+	Width    int
+	Height   int
+	LockSize bool
+}
+
+// DefaultMarkupWrapper returns the wrapper of the trust level or nil for the trusted markup
+func DefaultMarkupWrapper(level MarkupTrustLevel) *MarkupWrapper {
+	switch level {
+	case MarkupTrustTrusted:
+		return nil
+	case MarkupTrustPartial:
+		return &MarkupWrapper{
+			Sandbox: []string{
+				"allow-scripts", "allow-same-origin", "allow-forms", "allow-popups",
+				"allow-popups-to-escape-sandbox", "allow-top-navigation-by-user-activation",
+			},
+			LockSize: true,
+		}
+	}
+	return &MarkupWrapper{
+		Sandbox: []string{
+			"allow-scripts", "allow-popups", "allow-popups-to-escape-sandbox",
+			"allow-top-navigation-by-user-activation",
+		},
+		CSP:      "default-src https: data: blob: 'unsafe-inline' 'unsafe-eval'; form-action 'none'; base-uri 'none'",
+		LockSize: true,
+	}
+}
+
+// Wrap the markup into the template, the nil wrapper returns the markup as is
+func (w *MarkupWrapper) Wrap(markup string, width, height int) (string, error) {
+	if w == nil || markup == "" {
+		return markup, nil
+	}
+	tmpl := w.Template
+	if tmpl == nil {
+		tmpl = defaultMarkupWrapperTemplate
+	}
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, &MarkupWrapperData{
+		Sandbox:  strings.Join(w.Sandbox, " "),
+		Document: w.document(markup),
+		Markup:   markup,
+		Width:    width,
+		Height:   height,
+		LockSize: w.LockSize && width > 0 && height > 0,
+	})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// document returns the HTML document of the markup with the content security policy
+func (w *MarkupWrapper) document(markup string) string {
+	var doc strings.Builder
+	doc.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8">`)
+	if w.CSP != "" {
+		doc.WriteString(`<meta http-equiv="Content-Security-Policy" content="`)
+		doc.WriteString(html.EscapeString(w.CSP))
+		doc.WriteString(`">`)
+	}
+	doc.WriteString(`<style>html,body{margin:0;padding:0;overflow:hidden}</style></head><body>`)
+	doc.WriteString(markup)
+	doc.WriteString(`</body></html>`)
+	return doc.String()
+}
@@ -0,0 +1,48 @@
+package adresponse
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkupWrapper(t *testing.T) {
+	const markup = `<div onclick="go('x')">ad</div>`
+
+	// The trusted markup is rendered as is
+	wrapped, err := DefaultMarkupWrapper(MarkupTrustTrusted).Wrap(markup, 300, 250)
+	assert.NoError(t, err)
+	assert.Equal(t, markup, wrapped)
+
+	wrapped, err = DefaultMarkupWrapper(MarkupTrustUntrusted).Wrap(markup, 300, 250)
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(wrapped, `<iframe sandbox="allow-scripts allow-popups `), wrapped)
+		assert.NotContains(t, wrapped, "allow-same-origin")
+		assert.Contains(t, wrapped, `width="300" height="250"`)
+		assert.Contains(t, wrapped, "Content-Security-Policy")
+		// The markup document is escaped in the srcdoc attribute
+		assert.Contains(t, wrapped, `&lt;div onclick=&#34;go(&#39;x&#39;)&#34;&gt;ad&lt;/div&gt;`)
+		assert.NotContains(t, wrapped, markup)
+	}
+
+	wrapped, err = DefaultMarkupWrapper(MarkupTrustPartial).Wrap(markup, 0, 0)
+	if assert.NoError(t, err) {
+		assert.Contains(t, wrapped, "allow-same-origin")
+		assert.NotContains(t, wrapped, "Content-Security-Policy")
+		// The size is not locked without the creative size
+		assert.Contains(t, wrapped, `style="border:0"`)
+		assert.NotContains(t, wrapped, ` width=`)
+	}
+
+	// The empty markup is not wrapped
+	wrapped, err = DefaultMarkupWrapper(MarkupTrustUntrusted).Wrap("", 300, 250)
+	assert.NoError(t, err)
+	assert.Empty(t, wrapped)
+
+	custom := &MarkupWrapper{Template: template.Must(template.New("custom").Parse(`<div class="ad">{{.Markup}}</div>`))}
+	wrapped, err = custom.Wrap("<b>ad</b>", 300, 250)
+	assert.NoError(t, err)
+	assert.Equal(t, `<div class="ad">&lt;b&gt;ad&lt;/b&gt;</div>`, wrapped)
+}
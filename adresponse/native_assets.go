@@ -0,0 +1,154 @@
+package adresponse
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	natresp "github.com/bsm/openrtb/native/response"
+	"github.com/haxqer/vast"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+)
+
+// vastTagContentType of the VAST tag assets
+const vastTagContentType = "application/xml"
+
+// mediaContentTypes of the media files which are not registered in all the system MIME tables
+var mediaContentTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".webm": "video/webm",
+	".ogv":  "video/ogg",
+	".mov":  "video/quicktime",
+}
+
+// newNativeFileAsset returns the file asset of the native image or video asset
+// or nil if the asset has no file
+func newNativeFileAsset(asset *natresp.Asset, name string) *admodels.AdFileAsset {
+	var fileAsset *admodels.AdFileAsset
+	switch {
+	case asset.Image != nil && asset.Image.URL != "":
+		fileAsset = &admodels.AdFileAsset{
+			URL:         asset.Image.URL,
+			Type:        types.AdFileAssetImageType,
+			ContentType: urlContentType(asset.Image.URL),
+			Width:       asset.Image.Width,
+			Height:      asset.Image.Height,
+		}
+	case asset.Video != nil:
+		fileAsset = newNativeVideoAsset(asset.Video.VASTTag)
+	}
+	if fileAsset != nil {
+		fileAsset.ID = uint64(asset.ID)
+		fileAsset.Name = name
+	}
+	return fileAsset
+}
+
+// newNativeVideoAsset returns the file asset of the native video by the VAST tag.
+// The tag is the VAST XML document or the URL of the VAST document or the video file.
+// The inline VAST ad is mapped into the video file, the wrapper into the VAST tag URL.
+func newNativeVideoAsset(tag string) *admodels.AdFileAsset {
+	tag = strings.TrimSpace(tag)
+	switch {
+	case tag == "":
+		return nil
+	case IsVASTMarkup(tag):
+		v, err := unmarshalVAST([]byte(tag))
+		if err != nil || len(v.Ads) == 0 {
+			return nil
+		}
+		info := newVASTInfo(v)
+		if info.IsWrapper() {
+			if info.AdTagURI == "" {
+				return nil
+			}
+			return &admodels.AdFileAsset{
+				URL:         info.AdTagURI,
+				Type:        types.AdFileAssetVASTTagType,
+				ContentType: vastTagContentType,
+			}
+		}
+		media := vastVideoMediaFile(v)
+		if media == nil {
+			return nil
+		}
+		return &admodels.AdFileAsset{
+			ExternalID:  media.ID,
+			URL:         strings.TrimSpace(media.URI),
+			Type:        types.AdFileAssetVideoType,
+			ContentType: media.Type,
+			Width:       media.Width,
+			Height:      media.Height,
+			Duration:    int(info.Duration.Seconds()),
+		}
+	case strings.HasPrefix(tag, "https://") || strings.HasPrefix(tag, "http://") || strings.HasPrefix(tag, "//"):
+		if contentType := urlContentType(tag); strings.HasPrefix(contentType, "video/") {
+			return &admodels.AdFileAsset{
+				URL:         tag,
+				Type:        types.AdFileAssetVideoType,
+				ContentType: contentType,
+			}
+		}
+		return &admodels.AdFileAsset{
+			URL:         tag,
+			Type:        types.AdFileAssetVASTTagType,
+			ContentType: vastTagContentType,
+		}
+	}
+	return nil
+}
+
+// vastVideoMediaFile returns the largest progressive video file of the linear creatives
+// of the inline ad, any video file if there are no progressive ones
+func vastVideoMediaFile(v *vast.VAST) *vast.MediaFile {
+	var best *vast.MediaFile
+	for _, ad := range v.Ads {
+		if ad.InLine == nil {
+			continue
+		}
+		for _, creative := range ad.InLine.Creatives {
+			if creative.Linear == nil || creative.Linear.MediaFiles == nil {
+				continue
+			}
+			for i := range creative.Linear.MediaFiles.MediaFile {
+				media := &creative.Linear.MediaFiles.MediaFile[i]
+				if !strings.HasPrefix(media.Type, "video/") || strings.TrimSpace(media.URI) == "" {
+					continue
+				}
+				if best == nil || isBetterMediaFile(media, best) {
+					best = media
+				}
+			}
+		}
+		break
+	}
+	return best
+}
+
+func isBetterMediaFile(media, than *vast.MediaFile) bool {
+	if progressive := media.Delivery == "progressive"; progressive != (than.Delivery == "progressive") {
+		return progressive
+	}
+	return media.Width > than.Width
+}
+
+// urlContentType returns the content type of the file by the URL path extension
+func urlContentType(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if contentType, ok := mediaContentTypes[ext]; ok {
+		return contentType
+	}
+	contentType := mime.TypeByExtension(ext)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
@@ -0,0 +1,72 @@
+package adresponse
+
+import (
+	"testing"
+
+	"github.com/bsm/openrtb/native/response"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+)
+
+func TestNativeVideoAsset(t *testing.T) {
+	tests := []struct {
+		name  string
+		tag   string
+		asset *admodels.AdFileAsset
+	}{
+		{name: "empty", tag: " "},
+		{
+			name: "vast_inline",
+			tag:  testVASTInline,
+			asset: &admodels.AdFileAsset{URL: "https://cdn.example.com/2.mp4", Type: types.AdFileAssetVideoType,
+				ContentType: "video/mp4", Width: 1280, Height: 720, Duration: 30},
+		},
+		{
+			name:  "vast_wrapper",
+			tag:   testVASTWrapper,
+			asset: &admodels.AdFileAsset{URL: "https://ads.example.com/vast.xml", Type: types.AdFileAssetVASTTagType, ContentType: "application/xml"},
+		},
+		{name: "vast_no_ads", tag: `<VAST version="4.0"></VAST>`},
+		{name: "vast_invalid", tag: `<VAST version="4.0"><Ad>`},
+		{
+			name:  "video_url",
+			tag:   "https://cdn.example.com/video.webm?v=1",
+			asset: &admodels.AdFileAsset{URL: "https://cdn.example.com/video.webm?v=1", Type: types.AdFileAssetVideoType, ContentType: "video/webm"},
+		},
+		{
+			name:  "vast_url",
+			tag:   "//ads.example.com/vast",
+			asset: &admodels.AdFileAsset{URL: "//ads.example.com/vast", Type: types.AdFileAssetVASTTagType, ContentType: "application/xml"},
+		},
+		{name: "text", tag: "video"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.asset, newNativeVideoAsset(tt.tag))
+		})
+	}
+}
+
+func TestNativeItemAssets(t *testing.T) {
+	item := &ResponseNativeBidItem{
+		Native: &response.Response{Assets: []response.Asset{
+			{ID: 1, Title: &response.Title{Text: "Title"}},
+			{ID: 2, Image: &response.Image{URL: "https://example.com/item.PNG?size=1", Width: 300, Height: 250}},
+			{ID: 3, Video: &response.Video{VASTTag: "https://cdn.example.com/video.mp4"}},
+			{ID: 4, Image: &response.Image{}},
+		}},
+		RespFormat: &types.Format{Config: &types.FormatConfig{Assets: []types.FormatFileRequirement{
+			{ID: 2, Name: types.FormatAssetMain},
+			{ID: 3, Name: "video"},
+			{ID: 4, Name: "icon"},
+			{ID: 5, Name: "logo"},
+		}}},
+	}
+	assert.Equal(t, admodels.AdFileAssets{
+		{ID: 2, Name: types.FormatAssetMain, URL: "https://example.com/item.PNG?size=1", Type: types.AdFileAssetImageType,
+			ContentType: "image/png", Width: 300, Height: 250},
+		{ID: 3, Name: "video", URL: "https://cdn.example.com/video.mp4", Type: types.AdFileAssetVideoType, ContentType: "video/mp4"},
+	}, item.Assets(), "the assets without the files are skipped")
+}
@@ -34,6 +34,37 @@ func decodeNativeMarkup(data []byte) (*response.Response, error) {
 	return &native.Native, nil
 }
 
+// splitNativeMarkup returns the slot markups of the multi-placement (carousel) response
+// with the list of the native objects (`[{...}, ...]` or `{"native":[{...}, ...]}`)
+// or nil if the markup contains the single native object
+func splitNativeMarkup(data []byte) []string {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+	if data[0] == '{' {
+		var native struct {
+			Native json.RawMessage `json:"native"`
+		}
+		if json.Unmarshal(data, &native) != nil {
+			return nil
+		}
+		data = bytes.TrimSpace(native.Native)
+	}
+	if len(data) == 0 || data[0] != '[' {
+		return nil
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(data, &list) != nil {
+		return nil
+	}
+	markups := make([]string, 0, len(list))
+	for _, item := range list {
+		markups = append(markups, string(item))
+	}
+	return markups
+}
+
 func openrtbNativeLabelNameByType(dataTypeID int) string {
 	switch request.DataTypeID(dataTypeID) {
 	case request.DataTypeSponsored:
@@ -74,6 +105,7 @@ func extractNativeV2Data(req *request.Request, resp *response.Response) map[stri
 		if asset.Title != nil {
 			// Title asset
 			data[types.FormatFieldTitle] = asset.Title.Text
+			setNativeAssetLink(data, types.FormatFieldTitle, &asset)
 		} else if asset.Data != nil {
 			// Data asset: find matching asset in request to determine field name
 			for _, ass := range req.Assets {
@@ -84,10 +116,19 @@ func extractNativeV2Data(req *request.Request, resp *response.Response) map[stri
 					}
 					if name != "" {
 						data[name] = asset.Data.Value
+						setNativeAssetLink(data, name, &asset)
 					}
 					break
 				}
 			}
+		} else if asset.Image != nil && asset.Link != nil {
+			// Image asset link: the asset name is determined by the image type of the request
+			for _, ass := range req.Assets {
+				if ass.ID == asset.ID && ass.Image != nil {
+					setNativeAssetLink(data, openrtbNativeImageNameByType(int(ass.Image.TypeID)), &asset)
+					break
+				}
+			}
 		}
 	}
 	return data
@@ -103,6 +144,7 @@ func extractNativeV3Data(req *requestV3.Request, resp *response.Response) map[st
 		if asset.Title != nil {
 			// Title asset
 			data[types.FormatFieldTitle] = asset.Title.Text
+			setNativeAssetLink(data, types.FormatFieldTitle, &asset)
 		} else if asset.Data != nil {
 			// Data asset: find matching asset in request to determine field name
 			for _, ass := range req.Assets {
@@ -113,10 +155,19 @@ func extractNativeV3Data(req *requestV3.Request, resp *response.Response) map[st
 					}
 					if name != "" {
 						data[name] = asset.Data.Value
+						setNativeAssetLink(data, name, &asset)
 					}
 					break
 				}
 			}
+		} else if asset.Image != nil && asset.Link != nil {
+			// Image asset link: the asset name is determined by the image type of the request
+			for _, ass := range req.Assets {
+				if ass.ID == asset.ID && ass.Image != nil {
+					setNativeAssetLink(data, openrtbNativeImageNameByType(int(ass.Image.TypeID)), &asset)
+					break
+				}
+			}
 		}
 	}
 	return data
@@ -0,0 +1,86 @@
+package adresponse
+
+import (
+	"github.com/bsm/openrtb/native/request"
+	"github.com/bsm/openrtb/native/response"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+)
+
+// NativeAssetLinkSuffix of the content field name with the asset-level click URL
+// (e.g., `title_link` or `main_link` for the carousel items)
+const NativeAssetLinkSuffix = "_link"
+
+// NativeAssetLink of the native asset which overrides the main link of the ad on the asset click
+type NativeAssetLink struct {
+	URL           string   `json:"url"`
+	FallbackURL   string   `json:"fallback,omitempty"`
+	ClickTrackers []string `json:"clicktrackers,omitempty"`
+}
+
+// NativeAssetLinkName returns the content field name of the asset link
+func NativeAssetLinkName(name string) string {
+	return name + NativeAssetLinkSuffix
+}
+
+// AssetLink returns the link of the asset by ID or nil if the asset uses the main link
+func (it *ResponseNativeBidItem) AssetLink(id int) *NativeAssetLink {
+	for _, asset := range it.Native.Assets {
+		if asset.ID == id {
+			return newNativeAssetLink(&asset)
+		}
+	}
+	return nil
+}
+
+// AssetLinks returns the links of the assets by ID which override the main link
+func (it *ResponseNativeBidItem) AssetLinks() map[int]*NativeAssetLink {
+	var links map[int]*NativeAssetLink
+	for _, asset := range it.Native.Assets {
+		if link := newNativeAssetLink(&asset); link != nil {
+			if links == nil {
+				links = map[int]*NativeAssetLink{}
+			}
+			links[asset.ID] = link
+		}
+	}
+	return links
+}
+
+// newNativeAssetLink returns the link of the content asset or nil,
+// the link-only assets are the content fields themselves
+func newNativeAssetLink(asset *response.Asset) *NativeAssetLink {
+	if asset.Link == nil || asset.Link.URL == "" || isNativeLinkAsset(asset) {
+		return nil
+	}
+	return &NativeAssetLink{
+		URL:           asset.Link.URL,
+		FallbackURL:   asset.Link.FallbackURL,
+		ClickTrackers: asset.Link.ClickTrackers,
+	}
+}
+
+// isNativeLinkAsset returns true if the asset contains the link only
+func isNativeLinkAsset(asset *response.Asset) bool {
+	return asset.Title == nil && asset.Image == nil && asset.Video == nil && asset.Data == nil
+}
+
+// setNativeAssetLink adds the asset link URL into the content data by the field name
+func setNativeAssetLink(data map[string]any, name string, asset *response.Asset) {
+	if link := newNativeAssetLink(asset); link != nil && name != "" {
+		data[NativeAssetLinkName(name)] = link.URL
+	}
+}
+
+// openrtbNativeImageNameByType returns the format asset name of the image type
+func openrtbNativeImageNameByType(typeID int) string {
+	switch request.ImageTypeID(typeID) {
+	case request.ImageTypeIcon:
+		return types.FormatAssetIcon
+	case request.ImageTypeLogo:
+		return types.FormatAssetLogo
+	case request.ImageTypeMain:
+		return types.FormatAssetMain
+	}
+	return ""
+}
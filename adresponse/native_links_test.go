@@ -0,0 +1,70 @@
+package adresponse
+
+import (
+	"testing"
+
+	"github.com/bsm/openrtb/native/request"
+	"github.com/bsm/openrtb/native/response"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// newTestNativeLinksResponse returns the native response with the links of the title, the main image
+// and the link-only asset
+func newTestNativeLinksResponse() *response.Response {
+	return &response.Response{
+		Link: response.Link{URL: "https://example.com/main"},
+		Assets: []response.Asset{
+			{ID: 1, Title: &response.Title{Text: "Title"}, Link: &response.Link{URL: "https://example.com/title"}},
+			{ID: 2, Image: &response.Image{URL: "https://example.com/item.png"}, Link: &response.Link{
+				URL: "https://example.com/item", FallbackURL: "https://example.com/fallback",
+				ClickTrackers: []string{"https://example.com/click"},
+			}},
+			{ID: 3, Data: &response.Data{Value: "Brand"}},
+			{ID: 4, Link: &response.Link{URL: "https://example.com/cta"}},
+		},
+	}
+}
+
+func TestNativeAssetLinks(t *testing.T) {
+	item := &ResponseNativeBidItem{
+		Native: newTestNativeLinksResponse(),
+		RespFormat: &types.Format{Config: &types.FormatConfig{
+			Assets: []types.FormatFileRequirement{{ID: 2, Name: types.FormatAssetMain}},
+			Fields: []types.FormatField{{ID: 1, Name: "title"}, {ID: 3, Name: "brandname"}, {ID: 4, Name: "cta"}},
+		}},
+	}
+
+	assert.Equal(t, map[int]*NativeAssetLink{
+		1: {URL: "https://example.com/title"},
+		2: {URL: "https://example.com/item", FallbackURL: "https://example.com/fallback",
+			ClickTrackers: []string{"https://example.com/click"}},
+	}, item.AssetLinks())
+	assert.Equal(t, "https://example.com/item", item.AssetLink(2).URL)
+	assert.Nil(t, item.AssetLink(3), "the asset uses the main link")
+	assert.Nil(t, item.AssetLink(4), "the link-only asset is the content field itself")
+	assert.Nil(t, item.AssetLink(5))
+
+	assert.Equal(t, map[string]any{
+		"title":      "Title",
+		"title_link": "https://example.com/title",
+		"brandname":  "Brand",
+		"cta":        "https://example.com/cta",
+		"main_link":  "https://example.com/item",
+	}, item.ContentFields())
+}
+
+func TestExtractNativeAssetLinks(t *testing.T) {
+	data := extractNativeV2Data(&request.Request{Assets: []request.Asset{
+		{ID: 1, Title: &request.Title{Length: 25}},
+		{ID: 2, Image: &request.Image{TypeID: request.ImageTypeMain}},
+		{ID: 3, Data: &request.Data{TypeID: request.DataTypeSponsored}},
+	}}, newTestNativeLinksResponse())
+
+	assert.Equal(t, "https://example.com/main", data[adtype.ContentItemLink])
+	assert.Equal(t, "https://example.com/title", data[NativeAssetLinkName(types.FormatFieldTitle)])
+	assert.Equal(t, "https://example.com/item", data[NativeAssetLinkName(types.FormatAssetMain)])
+	assert.NotContains(t, data, NativeAssetLinkName(types.FormatFieldBrandname))
+}
@@ -0,0 +1,25 @@
+package adresponse
+
+import (
+	"bytes"
+	"sync"
+)
+
+var bodyBufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooledBodyBuffer capacity of the body buffer returned to the pool,
+// the buffers of the huge responses are released to the GC
+const maxPooledBodyBuffer = 1 << 20
+
+// AcquireBodyBuffer returns the empty buffer of the response body from the pool
+func AcquireBodyBuffer() *bytes.Buffer {
+	return bodyBufferPool.Get().(*bytes.Buffer)
+}
+
+// ReleaseBodyBuffer returns the buffer into the pool, the buffer data can't be used after it
+func ReleaseBodyBuffer(buf *bytes.Buffer) {
+	if buf != nil && buf.Cap() <= maxPooledBodyBuffer {
+		buf.Reset()
+		bodyBufferPool.Put(buf)
+	}
+}
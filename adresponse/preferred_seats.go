@@ -0,0 +1,19 @@
+package adresponse
+
+import "github.com/bsm/openrtb"
+
+// rankedBid of the optimal bids selection
+type rankedBid struct {
+	bid       *openrtb.Bid
+	rank      float64
+	preferred bool
+}
+
+// seatBoost returns the selection boost of the seat bids and true if the seat is preferred
+func (r *BidResponse) seatBoost(seat string) (float64, bool) {
+	boost, ok := r.PreferredSeats[seat]
+	if !ok {
+		return 1, false
+	}
+	return max(boost, 1), true
+}
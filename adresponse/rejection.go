@@ -0,0 +1,112 @@
+package adresponse
+
+import (
+	"slices"
+
+	"github.com/bsm/openrtb"
+)
+
+// RejectionReason of the dropped bid
+type RejectionReason string
+
+// Rejection reasons of the bids
+const (
+	RejectionMaxBid          RejectionReason = "max_bid"
+	RejectionBlockedCategory RejectionReason = "blocked_category"
+	RejectionMRAID           RejectionReason = "mraid_unsupported"
+	RejectionDeal            RejectionReason = "deal_mismatch"
+	RejectionInvalid         RejectionReason = "invalid_bid"
+	RejectionUnknownImp      RejectionReason = "unknown_imp"
+	RejectionInvalidMarkup   RejectionReason = "invalid_markup"
+	RejectionLostAuction     RejectionReason = "lost_auction"
+	RejectionBidDensity      RejectionReason = "bid_density"
+	RejectionCorrelation     RejectionReason = "correlation_mismatch"
+	RejectionPriceUnit       RejectionReason = "price_unit"
+	RejectionSpoof           RejectionReason = "spoof_suspected"
+	RejectionLanguage        RejectionReason = "language_mismatch"
+	RejectionSeat            RejectionReason = "seat_not_allowed"
+	RejectionBlockedAdv      RejectionReason = "blocked_advertiser"
+	RejectionBlockedAttr     RejectionReason = "blocked_attribute"
+	RejectionFormat          RejectionReason = "format_filtered"
+)
+
+// BidRejection of the bid dropped during the response filtering or preparation
+type BidRejection struct {
+	Reason     RejectionReason `json:"reason"`
+	Message    string          `json:"message,omitempty"`
+	Seat       string          `json:"seat,omitempty"`
+	BidID      string          `json:"bid_id"`
+	ImpID      string          `json:"imp_id"`
+	Price      float64         `json:"price"`
+	CreativeID string          `json:"crid,omitempty"`
+}
+
+// BidRejections list of the dropped bids
+type BidRejections []BidRejection
+
+// Add the rejection of the bid
+func (r *BidRejections) Add(seat *openrtb.SeatBid, bid *openrtb.Bid, reason RejectionReason, message string) {
+	rejection := BidRejection{
+		Reason:     reason,
+		Message:    message,
+		BidID:      bid.ID,
+		ImpID:      bid.ImpID,
+		Price:      bid.Price,
+		CreativeID: bid.CreativeID,
+	}
+	if seat != nil {
+		rejection.Seat = seat.Seat
+	}
+	*r = append(*r, rejection)
+}
+
+// Rejections returns the bids dropped during the filtering and the preparation of the response
+func (r *BidResponse) Rejections() BidRejections {
+	return r.rejections
+}
+
+// AddRejections of the bids dropped before the response preparation
+func (r *BidResponse) AddRejections(rejections ...BidRejection) {
+	r.rejections = append(r.rejections, rejections...)
+}
+
+// rejectBid adds the rejection of the response bid
+func (r *BidResponse) rejectBid(bid *openrtb.Bid, reason RejectionReason, message string) {
+	r.rejections.Add(r.bidSeat(bid), bid, reason, message)
+}
+
+// rejectLostBids adds the rejections of the bids which are not selected as optimal
+func (r *BidResponse) rejectLostBids() {
+	for i := range r.BidResponse.SeatBid {
+		seat := &r.BidResponse.SeatBid[i]
+		for j := range seat.Bid {
+			bid := &seat.Bid[j]
+			switch {
+			case slices.Contains(r.optimalBids, bid):
+			case r.isUnknownImp(bid):
+				r.rejections.Add(seat, bid, RejectionUnknownImp, "")
+			default:
+				r.rejections.Add(seat, bid, RejectionLostAuction, "")
+			}
+		}
+	}
+}
+
+// bidSeat returns the seat of the response bid or nil
+func (r *BidResponse) bidSeat(bid *openrtb.Bid) *openrtb.SeatBid {
+	for i := range r.BidResponse.SeatBid {
+		seat := &r.BidResponse.SeatBid[i]
+		for j := range seat.Bid {
+			if &seat.Bid[j] == bid {
+				return seat
+			}
+		}
+	}
+	return nil
+}
+
+// isUnknownImp returns true if the bid impression or format doesn't match the request
+func (r *BidResponse) isUnknownImp(bid *openrtb.Bid) bool {
+	imp, format := r.impIDCodec().Decode(r.Req, bid.ImpID)
+	return imp == nil || format == nil
+}
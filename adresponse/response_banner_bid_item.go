@@ -7,6 +7,7 @@ package adresponse
 
 import (
 	"context"
+	"html"
 	"strings"
 
 	"github.com/bsm/openrtb"
@@ -57,11 +58,23 @@ type ResponseBannerBidItem struct {
 	Bid        *openrtb.Bid `json:"bid,omitempty"`
 	BannerInfo BannerInfo   `json:"banner_info"`
 
+	// MRAID is true if the markup requires the MRAID container to be rendered
+	MRAID bool `json:"mraid,omitempty"`
+
 	PriceScope price.PriceScopeImpression `json:"price_scope,omitempty"`
 
 	// Competitive second AD
 	SecondAd adtype.SecondAd `json:"second_ad,omitempty"`
 
+	// DealInfo of the private marketplace deal matched with the bid
+	DealInfo
+
+	// wrapper of the HTML markup by the source trust level
+	wrapper *MarkupWrapper
+
+	// late binding of the markup macros at the render time
+	late *lateBinding
+
 	assets  admodels.AdFileAssets `json:"-"`
 	context context.Context       `json:"-"`
 }
@@ -88,6 +101,7 @@ func newResponseBannerBidItem(req adtype.BidRequester, src adtype.Source, bid *o
 		FormatType: bannerFormatType(bid.AdMarkup),
 		RespFormat: format,
 		PriceScope: priceScope,
+		MRAID:      MarkupUsesMRAID(bid.AdMarkup),
 		BannerInfo: BannerInfo{
 			Width:  bid.W,
 			Height: bid.H,
@@ -99,6 +113,10 @@ func newResponseBannerBidItem(req adtype.BidRequester, src adtype.Source, bid *o
 	// Determine the content of the banner ad based on the ad markup
 	if strings.HasPrefix(bid.AdMarkup, "https://") || strings.HasPrefix(bid.AdMarkup, "http://") {
 		bidItem.BannerInfo.IframeURL = bid.AdMarkup
+	} else if isDataImageURI(bid.AdMarkup) {
+		// The data-URI image has no click link, so it's rendered by the image tag as well
+		bidItem.BannerInfo.ImageURL = strings.TrimSpace(bid.AdMarkup)
+		bidItem.BannerInfo.HTML = `<img src="` + html.EscapeString(bidItem.BannerInfo.ImageURL) + `">`
 	} else if strings.HasPrefix(bid.AdMarkup, "<?xml") {
 		iframeURL, clickURL, imgURL, err := parseInterstitialAdMarkup(bid.AdMarkup)
 		if err != nil {
@@ -155,7 +173,11 @@ func (it *ResponseBannerBidItem) ContentItem(name string) any {
 	case adtype.ContentItemIFrameURL:
 		return it.BannerInfo.IframeURL
 	case adtype.ContentItemContent:
-		return it.BannerInfo.HTML
+		markup, err := it.wrappedHTML()
+		if err != nil {
+			return nil
+		}
+		return markup
 	case adtype.ContentItemNotifyWinURL:
 		if it.Bid != nil {
 			return it.Bid.NURL
@@ -180,7 +202,9 @@ func (it *ResponseBannerBidItem) ContentFields() map[string]any {
 		fields[adtype.ContentItemIFrameURL] = it.BannerInfo.IframeURL
 	}
 	if it.BannerInfo.HTML != "" {
-		fields[adtype.ContentItemContent] = it.BannerInfo.HTML
+		if markup, err := it.wrappedHTML(); err == nil {
+			fields[adtype.ContentItemContent] = markup
+		}
 	}
 	if it.BannerInfo.Title != "" {
 		fields[types.FormatFieldTitle] = it.BannerInfo.Title
@@ -446,9 +470,36 @@ func (it *ResponseBannerBidItem) Height() int {
 	return it.Bid.H
 }
 
+// IsMRAID returns true if the markup requires the MRAID container
+func (it *ResponseBannerBidItem) IsMRAID() bool {
+	return it.MRAID
+}
+
 // Markup advertisement
 func (it *ResponseBannerBidItem) Markup() (string, error) {
-	return "", nil
+	return it.wrappedHTML()
+}
+
+// FinalizeMarkup returns the markup (HTML or iframe URL) with the late macros
+// bound at the render time by the final clearing price
+func (it *ResponseBannerBidItem) FinalizeMarkup(ctx context.Context) (string, error) {
+	if it.BannerInfo.HTML == "" {
+		return it.late.finalize(ctx, it, it.BannerInfo.IframeURL)
+	}
+	markup, err := it.wrappedHTML()
+	if err != nil {
+		return "", err
+	}
+	return it.late.finalize(ctx, it, markup)
+}
+
+// wrappedHTML returns the HTML markup wrapped by the source trust level template.
+// The MRAID markup is not wrapped because it requires the MRAID container of the SDK.
+func (it *ResponseBannerBidItem) wrappedHTML() (string, error) {
+	if it.MRAID {
+		return it.BannerInfo.HTML, nil
+	}
+	return it.wrapper.Wrap(it.BannerInfo.HTML, it.BannerInfo.Width, it.BannerInfo.Height)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
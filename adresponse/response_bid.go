@@ -24,7 +24,6 @@ import (
 	"strings"
 
 	openrtb "github.com/bsm/openrtb"
-	"github.com/demdxx/xtypes"
 	"go.uber.org/zap"
 
 	"github.com/geniusrabbit/adcorelib/admodels/types"
@@ -32,6 +31,12 @@ import (
 	"github.com/geniusrabbit/adcorelib/context/ctxlogger"
 )
 
+// Keys of the response values accessible by the Get method
+const (
+	ResponseKeyRawRequest  = "raw_request"
+	ResponseKeyRawResponse = "raw_response"
+)
+
 // BidResponse represents an OpenRTB bid response with additional processing capabilities.
 // It encapsulates the original OpenRTB response along with request context and derived data.
 type BidResponse struct {
@@ -44,12 +49,59 @@ type BidResponse struct {
 	// BidResponse RTB record
 	BidResponse openrtb.BidResponse
 
+	// Cached indicates that the response was restored from the bid cache
+	Cached bool
+
+	// SourceCurrency of the bids and the exchange rate from the system currency
+	// used to report the auction price macros in the source currency
+	SourceCurrency     string
+	SourceCurrencyRate float64
+
+	// ImpIDCodec of the impression IDs used in the request (codename scheme by default)
+	ImpIDCodec ImpIDCodec
+
+	// SourceAuctionType of the source request and the price increment (CPM) of the second-price auction.
+	// The second-price bids are settled by the highest competing bid plus the increment.
+	SourceAuctionType types.AuctionType
+	PriceIncrement    float64
+
+	// PMP returns the private marketplace of the impression used in the request
+	PMP func(imp *adtype.Impression) *PMP
+
+	// PreferredSeats with the selection boost factor of the seat bids.
+	// The boost 1 means that the seat wins the price ties only.
+	PreferredSeats map[string]float64
+
+	// MarkupWrapper of the third-party HTML markup (not wrapped if nil)
+	MarkupWrapper *MarkupWrapper
+
+	// DeferredNURL keeps the win and billing notice URLs out of the items until the internal clearing is final,
+	// the URLs with the final clearing price are returned by WinNoticeURL and BillingNoticeURL
+	DeferredNURL bool
+
+	// LateMacros of the markup left as the placeholders until the render time,
+	// the items bind them by FinalizeMarkup (e.g., MacroAuctionPrice, MacroTimestamp)
+	LateMacros []string
+
+	// PriceUnits factors of the bid prices converted from the source pricing model (CPC, CPV)
+	// into the effective CPM by the impression IDs of the bids, the auction price macros
+	// are reported in the source pricing model
+	PriceUnits map[string]float64
+
+	// RawRequest and RawResponse wire payloads retained for debugging (sampled and bounded)
+	RawRequest  []byte
+	RawResponse []byte
+
 	bidRespBidCount int
 
 	optimalBids []*openrtb.Bid
 	ads         []adtype.ResponseItemCommon
 
-	// TODO: add errors list
+	// rejections of the bids dropped during the filtering and the preparation
+	rejections BidRejections
+
+	// deferredNotices of the bids with the unresolved price macros
+	deferredNotices map[*openrtb.Bid]deferredNotice
 }
 
 // AuctionID returns the auction identifier from the bid response.
@@ -81,8 +133,7 @@ func (r *BidResponse) Prepare() {
 	// Prepare URLs and markup for response
 	for i, seat := range r.BidResponse.SeatBid {
 		for i, bid := range seat.Bid {
-			imp := xtypes.Slice[*adtype.Impression](r.Req.Impressions()).FirstOr(nil,
-				func(imp **adtype.Impression) bool { return strings.HasPrefix(bid.ImpID, (*imp).ID) })
+			imp, _ := r.impIDCodec().Decode(r.Req, bid.ImpID)
 
 			// Set default dimensions from impression if not present in bid
 			if imp != nil && (bid.W == 0 && bid.H == 0) {
@@ -90,10 +141,26 @@ func (r *BidResponse) Prepare() {
 			}
 
 			// Replace auction-related macros in creative content and tracking URLs
-			replacer := r.newBidReplacer(&bid)
-			bid.AdMarkup = replacer.Replace(bid.AdMarkup)
-			bid.NURL = prepareURL(bid.NURL, replacer)
-			bid.BURL = prepareURL(bid.BURL, replacer)
+			clearing := r.clearingPrice(&seat.Bid[i], imp)
+			replacer := r.newBidReplacer(&bid, clearing, false)
+			// The late macros of the markup are left for the FinalizeMarkup of the item
+			bid.AdMarkup = r.markupReplacer(&bid, clearing, replacer).Replace(bid.AdMarkup)
+			if r.DeferredNURL && (bid.NURL != "" || bid.BURL != "") {
+				// The price macros are replaced by the final clearing price in WinNoticeURL and BillingNoticeURL
+				if r.deferredNotices == nil {
+					r.deferredNotices = map[*openrtb.Bid]deferredNotice{}
+				}
+				macros := strings.NewReplacer(expandMacros(r.bidMacros(&bid), true)...)
+				r.deferredNotices[&seat.Bid[i]] = deferredNotice{
+					nurl: prepareURL(bid.NURL, macros),
+					burl: prepareURL(bid.BURL, macros),
+				}
+				bid.NURL, bid.BURL = "", ""
+			} else {
+				urlReplacer := r.newBidReplacer(&bid, clearing, true)
+				bid.NURL = prepareURL(bid.NURL, urlReplacer)
+				bid.BURL = prepareURL(bid.BURL, urlReplacer)
+			}
 
 			seat.Bid[i] = bid
 		}
@@ -103,89 +170,159 @@ func (r *BidResponse) Prepare() {
 	} // end for
 
 	// Create response ad items from the optimal bids for each impression
+	late := r.lateBinding()
 	for _, bid := range r.OptimalBids() {
-		imp := xtypes.Slice[*adtype.Impression](r.Req.Impressions()).FirstOr(nil,
-			func(imp **adtype.Impression) bool { return strings.HasPrefix(bid.ImpID, (*imp).ID) })
-		if imp != nil {
-			if bidItem := r.prepareBidItem(bid, imp); bidItem != nil {
-				r.ads = append(r.ads, bidItem)
-			}
+		// Match the bid impression ID with the impression and the correct format
+		imp, format := r.impIDCodec().Decode(r.Req, bid.ImpID)
+		if imp == nil || format == nil {
+			r.rejectBid(bid, RejectionUnknownImp, "")
+			continue
+		}
+		bidItems := r.prepareBidItems(bid, imp, format)
+		if len(bidItems) == 0 {
+			r.rejectBid(bid, RejectionInvalidMarkup, "")
+			continue
+		}
+		for _, bidItem := range bidItems {
+			r.settlePrice(bidItem, bid, imp)
+			setLateBinding(bidItem, late)
+			r.ads = append(r.ads, bidItem)
 		}
 	}
+
+	// Collect the bids which are not selected for the impressions
+	r.rejectLostBids()
 }
 
-// prepareBidItem creates a standardized ResponseBidItem from an OpenRTB bid and impression.
-// It handles different creative formats (direct, native, banner) and sets up pricing information.
-// Returns nil if no appropriate format can be determined.
-func (r *BidResponse) prepareBidItem(bid *openrtb.Bid, imp *adtype.Impression) adtype.ResponseItemCommon {
-	var (
-		format  *types.Format
-		bidItem adtype.ResponseItemCommon
-		err     error
-	)
-
-	// Determine the appropriate format based on impression type
-	if imp.IsDirect() {
-		format = imp.FormatByType(types.FormatDirectType)
-	} else {
-		// Match the bid impression ID with the correct format
-		for _, formatObj := range imp.Formats() {
-			if bid.ImpID != imp.IDByFormat(formatObj) {
-				continue
+// prepareBidItems creates the response items of the bid, the multi-placement (carousel)
+// native response is split into the items per slot
+func (r *BidResponse) prepareBidItems(bid *openrtb.Bid, imp *adtype.Impression, format *types.Format) []adtype.ResponseItemCommon {
+	format = BidFormat(bid, imp, format)
+	if format.IsNative() {
+		if markups := splitNativeMarkup([]byte(bid.AdMarkup)); markups != nil {
+			items, err := newResponseNativeBidItems(r.Req, r.Src, bid, imp, format, markups)
+			if err != nil {
+				// Log native markup decoding failures
+				ctxlogger.Get(r.Context()).Debug(
+					"Failed to decode multi-placement native markup",
+					zap.String("markup", bid.AdMarkup),
+					zap.Error(err),
+				)
 			}
-			format = formatObj
-			break
+			list := make([]adtype.ResponseItemCommon, 0, len(items))
+			for _, item := range items {
+				list = append(list, item)
+			}
+			return list
 		}
 	}
+	if bidItem := r.prepareBidItem(bid, imp, format); bidItem != nil {
+		return []adtype.ResponseItemCommon{bidItem}
+	}
+	return nil
+}
 
-	// No matching format found, can't create bid item
-	if format == nil {
-		return nil
+// BidFormat returns the format of the impression matched with the media type of the bid.
+// The markup type (mtype) is authoritative, the markup content is checked only if it's undefined.
+// The format of the bid impression ID is kept if the impression has no format of the media type.
+func BidFormat(bid *openrtb.Bid, imp *adtype.Impression, format *types.Format) *types.Format {
+	var candidates []types.FormatType
+	switch BidMarkupType(bid) {
+	case MarkupTypeBanner:
+		if format.IsBanner() || format.IsProxy() {
+			return format
+		}
+		candidates = []types.FormatType{types.FormatBannerType, types.FormatBannerHTML5Type, types.FormatProxyType}
+	case MarkupTypeVideo:
+		if format.IsVideo() {
+			return format
+		}
+		candidates = []types.FormatType{types.FormatVideoType}
+	case MarkupTypeNative:
+		if format.IsNative() {
+			return format
+		}
+		candidates = []types.FormatType{types.FormatNativeType}
+	case 0:
+		// The VAST markup of the video bid is matched with the video format of the impression
+		if !format.IsVideo() && IsVASTMarkup(bid.AdMarkup) {
+			candidates = []types.FormatType{types.FormatVideoType}
+		}
+	}
+	for _, tp := range candidates {
+		if typeFormat := imp.FormatByType(tp); typeFormat != nil {
+			return typeFormat
+		}
 	}
+	return format
+}
+
+// prepareBidItem creates a standardized ResponseBidItem from an OpenRTB bid and impression.
+// It handles different creative formats (direct, native, banner) and sets up pricing information.
+// Returns nil if no appropriate format can be determined.
+func (r *BidResponse) prepareBidItem(bid *openrtb.Bid, imp *adtype.Impression, format *types.Format) adtype.ResponseItemCommon {
+	var bidItem adtype.ResponseItemCommon
 
 	// Create appropriate bid item based on format type
 	switch {
 	case format.IsDirect():
-		if bidItem, err = newResponseDirectBidItem(r.Req, r.Src, bid, imp, format); err != nil {
+		direct, err := newResponseDirectBidItem(r.Req, r.Src, bid, imp, format)
+		if err != nil {
 			// Log direct bid item creation failures
 			ctxlogger.Get(r.Context()).Debug(
 				"Failed to create direct bid item",
 				zap.String("markup", bid.AdMarkup),
 				zap.Error(err),
 			)
+			break
 		}
+		bidItem = direct
 	case format.IsNative():
-		if bidItem, err = newResponseNativeBidItem(r.Req, r.Src, bid, imp, format); err != nil {
+		native, err := newResponseNativeBidItem(r.Req, r.Src, bid, imp, format)
+		if err != nil {
 			// Log native markup decoding failures
 			ctxlogger.Get(r.Context()).Debug(
 				"Failed to decode native markup",
 				zap.String("markup", bid.AdMarkup),
 				zap.Error(err),
 			)
+			break
 		}
+		bidItem = native
 	case format.IsBanner() || format.IsProxy():
-		if bidItem, err = newResponseBannerBidItem(r.Req, r.Src, bid, imp, format); err != nil {
+		banner, err := newResponseBannerBidItem(r.Req, r.Src, bid, imp, format)
+		if err != nil {
 			// Log banner markup decoding failures
 			ctxlogger.Get(r.Context()).Debug(
 				"Failed to decode banner markup",
 				zap.String("markup", bid.AdMarkup),
 				zap.Error(err),
 			)
+			break
 		}
+		banner.wrapper = r.MarkupWrapper
+		bidItem = banner
 	case format.IsVideo():
-		if bidItem, err = newResponseVASTBidItem(r.Req, r.Src, bid, imp, format); err != nil {
+		video, err := newResponseVASTBidItem(r.Req, r.Src, bid, imp, format)
+		if err != nil {
 			// Log video markup decoding failures
 			ctxlogger.Get(r.Context()).Debug(
 				"Failed to decode video markup",
 				zap.String("markup", bid.AdMarkup),
 				zap.Error(err),
 			)
+			break
 		}
+		bidItem = video
 	}
 
 	return bidItem
 }
 
+func (r *BidResponse) impIDCodec() ImpIDCodec {
+	return ImpIDCodecOrDefault(r.ImpIDCodec)
+}
+
 // Request returns the original bid request associated with this response.
 func (r *BidResponse) Request() adtype.BidRequester {
 	return r.Req
@@ -269,32 +406,44 @@ func (r *BidResponse) OptimalBids() []*openrtb.Bid {
 	// Find the highest-priced bid for each impression ID
 	totalBidsCount := 0
 
-	// Sort bids by impression ID and price to ensure we get the most expensive bid for each impression
+	// Count the bids of all seats to allocate the ranking list once
 	for _, seat := range r.BidResponse.SeatBid {
 		totalBidsCount += len(seat.Bid)
 	}
 
-	allBids := make([]*openrtb.Bid, 0, totalBidsCount)
+	// The bids of the preferred seats are ranked with the boost and win the price ties
+	allBids := make([]rankedBid, 0, totalBidsCount)
 	for _, seat := range r.BidResponse.SeatBid {
+		boost, preferred := r.seatBoost(seat.Seat)
 		for i := range seat.Bid {
-			allBids = append(allBids, &seat.Bid[i])
+			allBids = append(allBids, rankedBid{
+				bid:       &seat.Bid[i],
+				rank:      seat.Bid[i].Price * boost,
+				preferred: preferred,
+			})
 		}
 	}
 
-	sort.Slice(allBids, func(i, j int) bool {
-		return allBids[i].ImpID < allBids[j].ImpID ||
-			(allBids[i].ImpID == allBids[j].ImpID && allBids[i].Price > allBids[j].Price)
+	// The bids are ranked across the impression ID variations and formats
+	// because all of them compete for the same impression
+	sort.SliceStable(allBids, func(i, j int) bool {
+		a, b := &allBids[i], &allBids[j]
+		if a.rank != b.rank {
+			return a.rank > b.rank
+		}
+		return a.preferred && !b.preferred
 	})
 
 	// Map to store the highest bid for each impression ID
 	optimalBids := make([]*openrtb.Bid, 0, totalBidsCount)
 
+	codec := r.impIDCodec()
 	for _, imp := range r.Req.Impressions() {
 		added := 0
 		bidCount := max(imp.Count, 1)
-		for _, bid := range allBids {
-			if strings.HasPrefix(bid.ImpID, imp.ID) {
-				optimalBids = append(optimalBids, bid)
+		for _, ranked := range allBids {
+			if bidImp, _ := codec.Decode(r.Req, ranked.bid.ImpID); bidImp == imp {
+				optimalBids = append(optimalBids, ranked.bid)
 				added++
 			}
 			if added >= bidCount {
@@ -321,8 +470,19 @@ func (r *BidResponse) Context(ctx ...context.Context) context.Context {
 }
 
 // Get retrieves a value from the response context by key.
+// The raw wire payloads are accessible by the "raw_request" and "raw_response" keys.
 // Returns nil if context is nil or key is not found.
 func (r *BidResponse) Get(key string) any {
+	switch key {
+	case ResponseKeyRawRequest:
+		if r.RawRequest != nil {
+			return r.RawRequest
+		}
+	case ResponseKeyRawResponse:
+		if r.RawResponse != nil {
+			return r.RawResponse
+		}
+	}
 	if r.context != nil {
 		return r.context.Value(key)
 	}
@@ -331,23 +491,110 @@ func (r *BidResponse) Get(key string) any {
 
 // newBidReplacer creates a string replacer for macro substitution in creative content and URLs.
 // It handles standard OpenRTB macros for auction IDs, prices, etc.
-func (r *BidResponse) newBidReplacer(bid *openrtb.Bid) *strings.Replacer {
-	return strings.NewReplacer(
+// The auction price is the clearing price of the bid in the system currency.
+// The values are query-escaped in the URL context (see expandMacros).
+func (r *BidResponse) newBidReplacer(bid *openrtb.Bid, auctionPrice float64, urlContext bool) *strings.Replacer {
+	return strings.NewReplacer(expandMacros(append(r.bidMacros(bid), r.priceMacros(bid, auctionPrice)...), urlContext)...)
+}
+
+// bidMacros returns the auction macros of the bid except the price macros
+func (r *BidResponse) bidMacros(bid *openrtb.Bid) []string {
+	return []string{
 		"${AUCTION_AD_ID}", bid.AdID,
 		"${AUCTION_ID}", r.BidResponse.ID,
 		"${AUCTION_BID_ID}", r.BidResponse.BidID,
 		"${AUCTION_IMP_ID}", bid.ImpID,
-		"${AUCTION_PRICE}", fmt.Sprintf("%.6f", bid.Price),
-		"${AUCTION_CURRENCY}", "USD",
-	)
+	}
+}
+
+// priceMacros returns the price macros of the auction price in the source currency
+// and the source pricing model of the bid
+func (r *BidResponse) priceMacros(bid *openrtb.Bid, auctionPrice float64) []string {
+	price, currency := sourcePrice(sourceUnitPrice(auctionPrice, r.PriceUnits, bid), r.SourceCurrency, r.SourceCurrencyRate)
+	return []string{
+		"${AUCTION_PRICE}", fmt.Sprintf("%.6f", price),
+		"${AUCTION_CURRENCY}", currency,
+	}
+}
+
+// sourcePrice converts the price from the system currency into the source currency
+func sourcePrice(price float64, currency string, rate float64) (float64, string) {
+	if currency != "" && rate > 0 {
+		return price * rate, currency
+	}
+	return price, "USD"
+}
+
+// sourceUnitPrice converts the effective CPM into the price per action of the source pricing model
+func sourceUnitPrice(price float64, units map[string]float64, bid *openrtb.Bid) float64 {
+	if factor := units[bid.ImpID]; factor > 0 {
+		return price / factor
+	}
+	return price
+}
+
+// deferredNotice URLs of the bid with the unresolved price macros
+type deferredNotice struct {
+	nurl string
+	burl string
+}
+
+// WinNoticeURL returns the deferred win notice URL of the item with the final clearing price
+// or empty string if the win notice is not deferred
+func (r *BidResponse) WinNoticeURL(item adtype.ResponseItem) string {
+	bid := responseItemBid(item)
+	if bid == nil {
+		return ""
+	}
+	return r.clearingNoticeURL(item, bid, r.deferredNotices[bid].nurl)
+}
+
+// BillingNoticeURL returns the deferred billing notice URL of the item with the final clearing price
+// or empty string if the billing notice is not deferred
+func (r *BidResponse) BillingNoticeURL(item adtype.ResponseItem) string {
+	bid := responseItemBid(item)
+	if bid == nil {
+		return ""
+	}
+	return r.clearingNoticeURL(item, bid, r.deferredNotices[bid].burl)
+}
+
+// clearingNoticeURL replaces the price macros of the notice URL by the clearing price of the item
+func (r *BidResponse) clearingNoticeURL(item adtype.ResponseItem, bid *openrtb.Bid, noticeURL string) string {
+	if noticeURL == "" {
+		return ""
+	}
+	// The impression price is the clearing price of the item after the internal auction
+	clearing := item.Price(adtype.ActionImpression).Float64() * 1000
+	return strings.NewReplacer(expandMacros(r.priceMacros(bid, clearing), true)...).Replace(noticeURL)
+}
+
+// responseItemBid returns the OpenRTB bid of the response item or nil
+func responseItemBid(item adtype.ResponseItemCommon) *openrtb.Bid {
+	switch it := item.(type) {
+	case *ResponseBannerBidItem:
+		return it.Bid
+	case *ResponseNativeBidItem:
+		return it.Bid
+	case *ResponseVASTBidItem:
+		return it.Bid
+	case *ResponseDirectBidItem:
+		return it.Bid
+	}
+	return nil
 }
 
 // Release frees resources used by the response.
 // This method should be called when the response is no longer needed.
+// The response drops the references to the request and the items only,
+// the items stay valid for the event streams and the caches which keep them.
 func (r *BidResponse) Release() {
 	if r == nil {
 		return
 	}
+	clear(r.ads)
+	clear(r.optimalBids)
+	r.context = nil
 	r.Req = nil
 	r.ads = r.ads[:0]
 	r.optimalBids = r.optimalBids[:0]
@@ -0,0 +1,27 @@
+package adresponse
+
+import (
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+func TestBidResponseRelease(t *testing.T) {
+	item := &ResponseNativeBidItem{ItemID: "imp1", Bid: &openrtb.Bid{ID: "1"}}
+	response := &BidResponse{
+		BidResponse: openrtb.BidResponse{ID: "auction1", SeatBid: []openrtb.SeatBid{{}}},
+		ads:         []adtype.ResponseItemCommon{item},
+	}
+	response.Release()
+	assert.Empty(t, response.Ads())
+	assert.Empty(t, response.BidResponse.SeatBid)
+
+	// The items are kept for the holders of them and the repeated release is safe
+	assert.Equal(t, "imp1", item.ItemID)
+	assert.Equal(t, "1", item.Bid.ID)
+	response.Release()
+	assert.Empty(t, response.Ads())
+}
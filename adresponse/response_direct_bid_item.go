@@ -42,6 +42,12 @@ type ResponseDirectBidItem struct {
 	// Competitive second AD
 	SecondAd adtype.SecondAd `json:"second_ad,omitempty"`
 
+	// DealInfo of the private marketplace deal matched with the bid
+	DealInfo
+
+	// late binding of the markup macros at the render time
+	late *lateBinding
+
 	assets  admodels.AdFileAssets `json:"-"`
 	context context.Context       `json:"-"`
 }
@@ -400,6 +406,12 @@ func (it *ResponseDirectBidItem) Markup() (string, error) {
 	return "", nil
 }
 
+// FinalizeMarkup returns the direct link with the late macros
+// bound at the render time by the final clearing price
+func (it *ResponseDirectBidItem) FinalizeMarkup(ctx context.Context) (string, error) {
+	return it.late.finalize(ctx, it, it.DirectLink)
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Context methods
 ///////////////////////////////////////////////////////////////////////////////
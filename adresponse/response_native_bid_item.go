@@ -7,6 +7,7 @@ package adresponse
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/demdxx/gocast/v2"
 
@@ -38,19 +39,57 @@ type ResponseNativeBidItem struct {
 	Native     *natresp.Response `json:"native,omitempty"`
 	ActionLink string            `json:"action_link,omitempty"`
 
+	// Slot of the multi-placement (carousel) response, the first slot is 0
+	Slot int `json:"slot,omitempty"`
+
 	PriceScope price.PriceScopeImpression `json:"price_scope,omitempty"`
 
 	// Competitive second AD
 	SecondAd adtype.SecondAd `json:"second_ad,omitempty"`
 
+	// DealInfo of the private marketplace deal matched with the bid
+	DealInfo
+
+	// late binding of the markup macros at the render time
+	late *lateBinding
+
+	// markup of the slot of the multi-placement response
+	markup string
+
 	Data    map[string]any        `json:"data,omitempty"`
 	assets  admodels.AdFileAssets `json:"-"`
 	context context.Context       `json:"-"`
 }
 
 func newResponseNativeBidItem(req adtype.BidRequester, src adtype.Source, bid *openrtb.Bid, imp *adtype.Impression, format *types.Format) (*ResponseNativeBidItem, error) {
+	return newResponseNativeSlotBidItem(req, src, bid, imp, format, bid.AdMarkup, 0)
+}
+
+// newResponseNativeBidItems creates the items of the multi-placement (carousel) response,
+// one item per slot up to the placement count of the impression.
+// The bid price is the price of each slot, the invalid slots are skipped.
+func newResponseNativeBidItems(req adtype.BidRequester, src adtype.Source, bid *openrtb.Bid, imp *adtype.Impression, format *types.Format, markups []string) ([]*ResponseNativeBidItem, error) {
+	var (
+		items []*ResponseNativeBidItem
+		err   error
+	)
+	for slot, markup := range markups[:min(len(markups), max(imp.Count, 1))] {
+		item, itemErr := newResponseNativeSlotBidItem(req, src, bid, imp, format, markup, slot)
+		if itemErr != nil {
+			err = itemErr
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, err
+	}
+	return items, nil
+}
+
+func newResponseNativeSlotBidItem(req adtype.BidRequester, src adtype.Source, bid *openrtb.Bid, imp *adtype.Impression, format *types.Format, markup string, slot int) (*ResponseNativeBidItem, error) {
 	// Handle native ad format with structured data
-	native, err := decodeNativeMarkup([]byte(bid.AdMarkup))
+	native, err := decodeNativeMarkup([]byte(markup))
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +108,7 @@ func newResponseNativeBidItem(req adtype.BidRequester, src adtype.Source, bid *o
 	// Create bid item for native format
 	bidItem := &ResponseNativeBidItem{
 		ItemID:     imp.ID,
+		Slot:       slot,
 		Src:        src,
 		Req:        req,
 		Imp:        imp,
@@ -80,6 +120,12 @@ func newResponseNativeBidItem(req adtype.BidRequester, src adtype.Source, bid *o
 		Data:       extractNativeDataFromImpression(imp, native),
 		PriceScope: priceScope,
 	}
+	if slot > 0 {
+		bidItem.ItemID = imp.ID + "_" + strconv.Itoa(slot)
+	}
+	if markup != bid.AdMarkup {
+		bidItem.markup = markup
+	}
 
 	// Set the bid impression price based on the bid price and impression
 	bidItem.PriceScope.MaxBidImpPrice = price.CalculatePurchasePrice(bidItem, adtype.ActionImpression)
@@ -158,14 +204,24 @@ func (it *ResponseNativeBidItem) ContentFields() map[string]any {
 			switch {
 			case asset.Title != nil:
 				fields[field.Name] = asset.Title.Text
-			case asset.Link != nil:
+			case asset.Link != nil && isNativeLinkAsset(&asset):
 				fields[field.Name] = asset.Link.URL
 			case asset.Data != nil:
 				fields[field.Name] = asset.Data.Value
 			}
+			setNativeAssetLink(fields, field.Name, &asset)
 			break
 		}
 	}
+	// The asset-level links of the media assets (e.g., the carousel items)
+	for _, configAsset := range config.Assets {
+		for _, asset := range it.Native.Assets {
+			if asset.ID == configAsset.ID && (asset.Image != nil || asset.Video != nil) {
+				setNativeAssetLink(fields, configAsset.GetName(), &asset)
+				break
+			}
+		}
+	}
 	return fields
 }
 
@@ -206,26 +262,14 @@ func (it *ResponseNativeBidItem) Assets() (assets admodels.AdFileAssets) {
 
 	config := it.Format().Config
 	for _, configAsset := range config.Assets {
-		for _, asset := range it.Native.Assets {
-			if asset.ID != configAsset.ID && (asset.Image != nil || asset.Video != nil) {
+		for i := range it.Native.Assets {
+			asset := &it.Native.Assets[i]
+			if asset.ID != configAsset.ID {
 				continue
 			}
-			newAsset := &admodels.AdFileAsset{
-				ID:   uint64(asset.ID),
-				Name: configAsset.GetName(),
-			}
-			switch {
-			case asset.Image != nil:
-				newAsset.URL = asset.Image.URL
-				newAsset.Type = types.AdFileAssetImageType
-				newAsset.ContentType = ""
-				newAsset.Width = asset.Image.Width
-				newAsset.Height = asset.Image.Height
-			case asset.Video != nil:
-				newAsset.URL = asset.Video.VASTTag
-				newAsset.Type = types.AdFileAssetVideoType
+			if newAsset := newNativeFileAsset(asset, configAsset.GetName()); newAsset != nil {
+				it.assets = append(it.assets, newAsset)
 			}
-			it.assets = append(it.assets, newAsset)
 			break
 		}
 	}
@@ -470,6 +514,18 @@ func (it *ResponseNativeBidItem) Markup() (string, error) {
 	return "", nil
 }
 
+// FinalizeMarkup returns the native markup (JSON) with the late macros
+// bound at the render time by the final clearing price
+func (it *ResponseNativeBidItem) FinalizeMarkup(ctx context.Context) (string, error) {
+	if it.markup != "" {
+		return it.late.finalize(ctx, it, it.markup)
+	}
+	if it.Bid == nil {
+		return "", nil
+	}
+	return it.late.finalize(ctx, it, it.Bid.AdMarkup)
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Context methods
 ///////////////////////////////////////////////////////////////////////////////
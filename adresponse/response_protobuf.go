@@ -0,0 +1,199 @@
+package adresponse
+
+import (
+	"errors"
+	"io"
+	"math"
+	"mime"
+
+	"github.com/bsm/openrtb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ContentTypeProtobuf of the OpenRTB protobuf messages
+const ContentTypeProtobuf = "application/x-protobuf"
+
+var errProtobufInvalidField = errors.New("invalid protobuf field")
+
+// IsProtobufContentType returns true if the content type is the protobuf message
+func IsProtobufContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case ContentTypeProtobuf, "application/protobuf", "application/vnd.google.protobuf":
+		return true
+	}
+	return false
+}
+
+// DecodeBidResponseProtobuf from the OpenRTB 2.x protobuf message (openrtb.proto).
+// The proto extensions can't be decoded without the schema and are skipped,
+// the native markup is expected in the `adm` field.
+func DecodeBidResponseProtobuf(r io.Reader, resp *openrtb.BidResponse) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	*resp = openrtb.BidResponse{}
+	return protoRange(data, func(num protowire.Number, typ protowire.Type, val []byte) error {
+		switch num {
+		case 1:
+			resp.ID = protoString(val)
+		case 2:
+			seat, err := protoSeatBid(val)
+			if err != nil {
+				return err
+			}
+			resp.SeatBid = append(resp.SeatBid, seat)
+		case 3:
+			resp.BidID = protoString(val)
+		case 4:
+			resp.Currency = protoString(val)
+		case 5:
+			resp.CustomData = protoString(val)
+		case 6:
+			resp.NBR = protoInt(val)
+		}
+		return nil
+	})
+}
+
+func protoSeatBid(msg []byte) (seat openrtb.SeatBid, err error) {
+	err = protoRange(protoBytes(msg), func(num protowire.Number, typ protowire.Type, val []byte) error {
+		switch num {
+		case 1:
+			bid, err := protoBid(val)
+			if err != nil {
+				return err
+			}
+			seat.Bid = append(seat.Bid, bid)
+		case 2:
+			seat.Seat = protoString(val)
+		case 3:
+			seat.Group = protoInt(val)
+		}
+		return nil
+	})
+	return seat, err
+}
+
+func protoBid(msg []byte) (bid openrtb.Bid, err error) {
+	err = protoRange(protoBytes(msg), func(num protowire.Number, typ protowire.Type, val []byte) error {
+		switch num {
+		case 1:
+			bid.ID = protoString(val)
+		case 2:
+			bid.ImpID = protoString(val)
+		case 3:
+			if typ != protowire.Fixed64Type {
+				return errProtobufInvalidField
+			}
+			bid.Price = protoDouble(val)
+		case 4:
+			bid.AdID = protoString(val)
+		case 5:
+			bid.NURL = protoString(val)
+		case 6:
+			bid.AdMarkup = protoString(val)
+		case 7:
+			bid.AdvDomain = append(bid.AdvDomain, protoString(val))
+		case 8:
+			bid.IURL = protoString(val)
+		case 9:
+			bid.CampaignID = openrtb.StringOrNumber(protoString(val))
+		case 10:
+			bid.CreativeID = protoString(val)
+		case 11:
+			bid.Attr = protoInts(bid.Attr, typ, val)
+		case 13:
+			bid.DealID = protoString(val)
+		case 14:
+			bid.Bundle = protoString(val)
+		case 15:
+			bid.Cat = append(bid.Cat, protoString(val))
+		case 16:
+			bid.W = protoInt(val)
+		case 17:
+			bid.H = protoInt(val)
+		case 18:
+			bid.API = protoInt(val)
+		case 19:
+			bid.Protocol = protoInt(val)
+		case 20:
+			bid.QAGMediaRating = protoInt(val)
+		case 21:
+			bid.Exp = protoInt(val)
+		case 22:
+			bid.BURL = protoString(val)
+		case 23:
+			bid.LURL = protoString(val)
+		case 24:
+			bid.Tactic = protoString(val)
+		case 25:
+			bid.Language = protoString(val)
+		case 26:
+			bid.WRatio = protoInt(val)
+		case 27:
+			bid.HRatio = protoInt(val)
+		}
+		return nil
+	})
+	return bid, err
+}
+
+// protoRange calls the function for each field of the message with the encoded field value
+func protoRange(msg []byte, fn func(num protowire.Number, typ protowire.Type, val []byte) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		m := protowire.ConsumeFieldValue(num, typ, msg)
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		if err := fn(num, typ, msg[:m]); err != nil {
+			return err
+		}
+		msg = msg[m:]
+	}
+	return nil
+}
+
+func protoBytes(val []byte) []byte {
+	v, _ := protowire.ConsumeBytes(val)
+	return v
+}
+
+func protoString(val []byte) string {
+	return string(protoBytes(val))
+}
+
+func protoInt(val []byte) int {
+	v, _ := protowire.ConsumeVarint(val)
+	return int(int64(v))
+}
+
+func protoDouble(val []byte) float64 {
+	v, _ := protowire.ConsumeFixed64(val)
+	return math.Float64frombits(v)
+}
+
+// protoInts appends the repeated integer values in the packed or the unpacked encoding
+func protoInts(list []int, typ protowire.Type, val []byte) []int {
+	if typ != protowire.BytesType {
+		return append(list, protoInt(val))
+	}
+	for packed := protoBytes(val); len(packed) > 0; {
+		v, n := protowire.ConsumeVarint(packed)
+		if n < 0 {
+			break
+		}
+		list = append(list, int(int64(v)))
+		packed = packed[n:]
+	}
+	return list
+}
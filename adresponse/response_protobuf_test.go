@@ -0,0 +1,90 @@
+package adresponse
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoTestBid returns the protobuf message of the bid
+func protoTestBid(price float64) []byte {
+	var bid []byte
+	bid = protowire.AppendTag(bid, 1, protowire.BytesType)
+	bid = protowire.AppendString(bid, "bid1")
+	bid = protowire.AppendTag(bid, 2, protowire.BytesType)
+	bid = protowire.AppendString(bid, "imp1")
+	bid = protowire.AppendTag(bid, 3, protowire.Fixed64Type)
+	bid = protowire.AppendFixed64(bid, math.Float64bits(price))
+	bid = protowire.AppendTag(bid, 6, protowire.BytesType)
+	bid = protowire.AppendString(bid, "<div></div>")
+	for _, domain := range []string{"a.com", "b.com"} {
+		bid = protowire.AppendTag(bid, 7, protowire.BytesType)
+		bid = protowire.AppendString(bid, domain)
+	}
+	bid = protowire.AppendTag(bid, 10, protowire.BytesType)
+	bid = protowire.AppendString(bid, "cr1")
+	// Packed and unpacked repeated attributes
+	bid = protowire.AppendTag(bid, 11, protowire.BytesType)
+	bid = protowire.AppendBytes(bid, protowire.AppendVarint(protowire.AppendVarint(nil, 1), 3))
+	bid = protowire.AppendTag(bid, 11, protowire.VarintType)
+	bid = protowire.AppendVarint(bid, 6)
+	bid = protowire.AppendTag(bid, 16, protowire.VarintType)
+	bid = protowire.AppendVarint(bid, 300)
+	bid = protowire.AppendTag(bid, 17, protowire.VarintType)
+	bid = protowire.AppendVarint(bid, 250)
+	// Unknown extension field is skipped
+	bid = protowire.AppendTag(bid, 100, protowire.BytesType)
+	bid = protowire.AppendString(bid, "ext")
+	return bid
+}
+
+func TestDecodeBidResponseProtobuf(t *testing.T) {
+	var seat []byte
+	seat = protowire.AppendTag(seat, 1, protowire.BytesType)
+	seat = protowire.AppendBytes(seat, protoTestBid(1.25))
+	seat = protowire.AppendTag(seat, 2, protowire.BytesType)
+	seat = protowire.AppendString(seat, "seat1")
+
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+	msg = protowire.AppendString(msg, "resp1")
+	msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, seat)
+	msg = protowire.AppendTag(msg, 4, protowire.BytesType)
+	msg = protowire.AppendString(msg, "EUR")
+
+	var resp openrtb.BidResponse
+	if !assert.NoError(t, DecodeBidResponseProtobuf(bytes.NewReader(msg), &resp)) {
+		return
+	}
+	assert.Equal(t, "resp1", resp.ID)
+	assert.Equal(t, "EUR", resp.Currency)
+	if assert.Len(t, resp.SeatBid, 1) && assert.Len(t, resp.SeatBid[0].Bid, 1) {
+		assert.Equal(t, "seat1", resp.SeatBid[0].Seat)
+		assert.Equal(t, openrtb.Bid{
+			ID: "bid1", ImpID: "imp1", Price: 1.25, AdMarkup: "<div></div>", AdvDomain: []string{"a.com", "b.com"},
+			CreativeID: "cr1", Attr: []int{1, 3, 6}, W: 300, H: 250,
+		}, resp.SeatBid[0].Bid[0])
+	}
+
+	// The price must be the double value
+	bid := protowire.AppendVarint(protowire.AppendTag(nil, 3, protowire.VarintType), 1)
+	seat = protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), bid)
+	invalid := protowire.AppendBytes(protowire.AppendTag(nil, 2, protowire.BytesType), seat)
+	assert.ErrorIs(t, DecodeBidResponseProtobuf(bytes.NewReader(invalid), &resp), errProtobufInvalidField)
+
+	// The truncated message
+	assert.Error(t, DecodeBidResponseProtobuf(bytes.NewReader(msg[:len(msg)-2]), &resp))
+}
+
+func TestIsProtobufContentType(t *testing.T) {
+	assert.True(t, IsProtobufContentType("application/x-protobuf"))
+	assert.True(t, IsProtobufContentType("application/protobuf; charset=binary"))
+	assert.True(t, IsProtobufContentType("application/vnd.google.protobuf"))
+	assert.False(t, IsProtobufContentType("application/json"))
+	assert.False(t, IsProtobufContentType(""))
+}
@@ -0,0 +1,112 @@
+package adresponse
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+)
+
+// SchemaFeature of the bid response used by the partner, it shows which version
+// and fields of the OpenRTB specification the partners actually send
+type SchemaFeature string
+
+// Schema features of the response bids
+const (
+	// SchemaFeatureBid is counted for each bid, it's the base of the other features
+	SchemaFeatureBid SchemaFeature = "bid"
+	// SchemaFeatureV3 is the bid of the OpenRTB 3.0 response envelope
+	SchemaFeatureV3 SchemaFeature = "v3_envelope"
+	// SchemaFeatureMType is the OpenRTB 2.6 markup type field (mtype)
+	SchemaFeatureMType SchemaFeature = "mtype"
+	// SchemaFeatureCatTax is the OpenRTB 2.6 category taxonomy field (cattax)
+	SchemaFeatureCatTax SchemaFeature = "cattax"
+	// SchemaFeatureNURL, SchemaFeatureBURL and SchemaFeatureLURL are the notice URLs of the bid
+	SchemaFeatureNURL SchemaFeature = "nurl"
+	SchemaFeatureBURL SchemaFeature = "burl"
+	SchemaFeatureLURL SchemaFeature = "lurl"
+	// SchemaFeatureEventTrackers is the native markup with the event trackers (Native 1.2)
+	SchemaFeatureEventTrackers SchemaFeature = "eventtrackers"
+	// SchemaFeatureImpTrackers is the native markup with the legacy impression trackers
+	SchemaFeatureImpTrackers SchemaFeature = "imptrackers"
+	// SchemaFeatureExtOther is the bid extension field which is not in the known list
+	SchemaFeatureExtOther SchemaFeature = "ext_other"
+)
+
+// Known bid extension fields reported as the `ext_<name>` features,
+// the other fields are reported as SchemaFeatureExtOther to keep the metric cardinality
+var schemaKnownExtFields = []string{"mtype", "cattax", "prebid", "skadn", "dsa", "signaldata", "duration"}
+
+type schemaSeatBid struct {
+	Bid []map[string]json.RawMessage `json:"bid"`
+}
+
+type schemaBidResponse struct {
+	SeatBid []schemaSeatBid `json:"seatbid"`
+	OpenRTB *struct {
+		Response *struct {
+			SeatBid []schemaSeatBid `json:"seatbid"`
+		} `json:"response"`
+	} `json:"openrtb"`
+}
+
+// ObserveResponseSchema reports the schema features of each bid of the JSON response,
+// the features are reported before any normalization of the response
+func ObserveResponseSchema(data []byte, observe func(SchemaFeature)) error {
+	var resp schemaBidResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	seats, v3 := resp.SeatBid, false
+	if resp.OpenRTB != nil && resp.OpenRTB.Response != nil {
+		seats, v3 = resp.OpenRTB.Response.SeatBid, true
+	}
+	for _, seat := range seats {
+		for _, bid := range seat.Bid {
+			observe(SchemaFeatureBid)
+			if v3 {
+				observe(SchemaFeatureV3)
+			}
+			observeBidSchema(bid, observe)
+		}
+	}
+	return nil
+}
+
+func observeBidSchema(bid map[string]json.RawMessage, observe func(SchemaFeature)) {
+	for _, field := range []SchemaFeature{SchemaFeatureMType, SchemaFeatureCatTax,
+		SchemaFeatureNURL, SchemaFeatureBURL, SchemaFeatureLURL} {
+		if schemaFieldPresent(bid[string(field)]) {
+			observe(field)
+		}
+	}
+	if adm := bid["adm"]; len(adm) > 0 {
+		if bytes.Contains(adm, []byte(`eventtrackers`)) {
+			observe(SchemaFeatureEventTrackers)
+		}
+		if bytes.Contains(adm, []byte(`imptrackers`)) {
+			observe(SchemaFeatureImpTrackers)
+		}
+	}
+	var ext map[string]json.RawMessage
+	if err := json.Unmarshal(bid["ext"], &ext); err != nil {
+		return
+	}
+	other := false
+	for key := range ext {
+		if slices.Contains(schemaKnownExtFields, key) {
+			observe(SchemaFeature("ext_" + key))
+		} else {
+			other = true
+		}
+	}
+	if other {
+		observe(SchemaFeatureExtOther)
+	}
+}
+
+// schemaFieldPresent returns true if the field value is not empty
+func schemaFieldPresent(val json.RawMessage) bool {
+	val = bytes.TrimSpace(val)
+	return len(val) > 0 && !bytes.Equal(val, []byte("null")) &&
+		!bytes.Equal(val, []byte(`""`)) && !bytes.Equal(val, []byte("0"))
+}
@@ -0,0 +1,39 @@
+package adresponse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveResponseSchema(t *testing.T) {
+	observe := func(data string) map[SchemaFeature]int {
+		features := map[SchemaFeature]int{}
+		assert.NoError(t, ObserveResponseSchema([]byte(data), func(feature SchemaFeature) {
+			features[feature]++
+		}))
+		return features
+	}
+
+	features := observe(`{"seatbid":[{"bid":[
+		{"id":"1","mtype":1,"cattax":0,"nurl":"http://win","burl":"","lurl":null,
+			"ext":{"prebid":{},"skadn":{},"custom":1,"other":2}},
+		{"id":"2","adm":"{\"native\":{\"eventtrackers\":[],\"imptrackers\":[]}}"}
+	]}]}`)
+	assert.Equal(t, map[SchemaFeature]int{
+		SchemaFeatureBid:           2,
+		SchemaFeatureMType:         1,
+		SchemaFeatureNURL:          1,
+		"ext_prebid":               1,
+		"ext_skadn":                1,
+		SchemaFeatureExtOther:      1,
+		SchemaFeatureEventTrackers: 1,
+		SchemaFeatureImpTrackers:   1,
+	}, features)
+
+	// The bids of the OpenRTB 3.0 envelope
+	features = observe(`{"openrtb":{"response":{"seatbid":[{"bid":[{"id":"1","lurl":"http://loss"}]}]}}}`)
+	assert.Equal(t, map[SchemaFeature]int{SchemaFeatureBid: 1, SchemaFeatureV3: 1, SchemaFeatureLURL: 1}, features)
+
+	assert.Error(t, ObserveResponseSchema([]byte(`{"seatbid":`), func(SchemaFeature) {}))
+}
@@ -0,0 +1,180 @@
+package adresponse
+
+import (
+	"encoding/json"
+
+	"github.com/bsm/openrtb"
+)
+
+// OpenRTB 3.0 response envelope with the AdCOM media of the bids
+type (
+	envelopeV3 struct {
+		Ver        string      `json:"ver,omitempty"`
+		DomainSpec string      `json:"domainspec,omitempty"`
+		DomainVer  string      `json:"domainver,omitempty"`
+		Response   *responseV3 `json:"response,omitempty"`
+	}
+
+	responseV3 struct {
+		ID      string            `json:"id"`
+		BidID   string            `json:"bidid,omitempty"`
+		NBR     int               `json:"nbr,omitempty"`
+		Cur     string            `json:"cur,omitempty"`
+		CData   string            `json:"cdata,omitempty"`
+		SeatBid []seatBidV3       `json:"seatbid,omitempty"`
+		Ext     openrtb.Extension `json:"ext,omitempty"`
+	}
+
+	seatBidV3 struct {
+		Seat    string            `json:"seat,omitempty"`
+		Package int               `json:"package,omitempty"`
+		Bid     []bidV3           `json:"bid"`
+		Ext     openrtb.Extension `json:"ext,omitempty"`
+	}
+
+	bidV3 struct {
+		ID     string                 `json:"id"`
+		Item   string                 `json:"item"`
+		Price  float64                `json:"price"`
+		Deal   string                 `json:"deal,omitempty"`
+		CID    openrtb.StringOrNumber `json:"cid,omitempty"`
+		Tactic string                 `json:"tactic,omitempty"`
+		PURL   string                 `json:"purl,omitempty"`
+		BURL   string                 `json:"burl,omitempty"`
+		LURL   string                 `json:"lurl,omitempty"`
+		Exp    int                    `json:"exp,omitempty"`
+		MID    string                 `json:"mid,omitempty"`
+		Media  *mediaV3               `json:"media,omitempty"`
+		Ext    openrtb.Extension      `json:"ext,omitempty"`
+	}
+
+	mediaV3 struct {
+		Ad *adV3 `json:"ad,omitempty"`
+	}
+
+	adV3 struct {
+		ID      string        `json:"id,omitempty"`
+		ADomain []string      `json:"adomain,omitempty"`
+		Bundle  []string      `json:"bundle,omitempty"`
+		IURL    string        `json:"iurl,omitempty"`
+		Cat     []string      `json:"cat,omitempty"`
+		CatTax  int           `json:"cattax,omitempty"`
+		Lang    string        `json:"lang,omitempty"`
+		Attr    []int         `json:"attr,omitempty"`
+		MRating int           `json:"mrating,omitempty"`
+		Display *displayV3    `json:"display,omitempty"`
+		Video   *videoMediaV3 `json:"video,omitempty"`
+		Audio   *videoMediaV3 `json:"audio,omitempty"`
+	}
+
+	displayV3 struct {
+		API    []int           `json:"api,omitempty"`
+		W      int             `json:"w,omitempty"`
+		H      int             `json:"h,omitempty"`
+		WRatio int             `json:"wratio,omitempty"`
+		HRatio int             `json:"hratio,omitempty"`
+		Adm    string          `json:"adm,omitempty"`
+		CURL   string          `json:"curl,omitempty"`
+		Native json.RawMessage `json:"native,omitempty"`
+	}
+
+	videoMediaV3 struct {
+		API  []int  `json:"api,omitempty"`
+		Adm  string `json:"adm,omitempty"`
+		CURL string `json:"curl,omitempty"`
+	}
+)
+
+// bidResponse maps the OpenRTB 3.0 response into the 2.x response structure.
+// The item ID is mapped into the impression ID and the AdCOM media into the markup,
+// the media type and the category taxonomy are moved into the bid extension.
+func (resp *responseV3) bidResponse() openrtb.BidResponse {
+	bidResp := openrtb.BidResponse{
+		ID:         resp.ID,
+		BidID:      resp.BidID,
+		Currency:   resp.Cur,
+		CustomData: resp.CData,
+		NBR:        resp.NBR,
+		Ext:        resp.Ext,
+		SeatBid:    make([]openrtb.SeatBid, 0, len(resp.SeatBid)),
+	}
+	for _, seat := range resp.SeatBid {
+		seatBid := openrtb.SeatBid{
+			Seat:  seat.Seat,
+			Group: seat.Package,
+			Ext:   seat.Ext,
+			Bid:   make([]openrtb.Bid, 0, len(seat.Bid)),
+		}
+		for _, bid := range seat.Bid {
+			seatBid.Bid = append(seatBid.Bid, bid.bid())
+		}
+		bidResp.SeatBid = append(bidResp.SeatBid, seatBid)
+	}
+	return bidResp
+}
+
+func (b *bidV3) bid() openrtb.Bid {
+	bid := openrtb.Bid{
+		ID:         b.ID,
+		ImpID:      b.Item,
+		Price:      b.Price,
+		DealID:     b.Deal,
+		CampaignID: b.CID,
+		Tactic:     b.Tactic,
+		NURL:       b.PURL,
+		BURL:       b.BURL,
+		LURL:       b.LURL,
+		Exp:        b.Exp,
+		Ext:        b.Ext,
+	}
+	if b.Media == nil || b.Media.Ad == nil {
+		return bid
+	}
+	ad := b.Media.Ad
+	bid.AdID = ad.ID
+	bid.CreativeID = ad.ID
+	bid.AdvDomain = ad.ADomain
+	bid.IURL = ad.IURL
+	bid.Cat = ad.Cat
+	bid.Attr = ad.Attr
+	bid.Language = ad.Lang
+	bid.QAGMediaRating = ad.MRating
+	if len(ad.Bundle) > 0 {
+		bid.Bundle = ad.Bundle[0]
+	}
+
+	var mtype int
+	switch {
+	case ad.Display != nil:
+		mtype = MarkupTypeBanner
+		bid.W, bid.H = ad.Display.W, ad.Display.H
+		bid.WRatio, bid.HRatio = ad.Display.WRatio, ad.Display.HRatio
+		bid.AdMarkup = markupOrURL(ad.Display.Adm, ad.Display.CURL)
+		// The AdCOM native object is passed as is to the native markup decoder
+		if len(ad.Display.Native) > 0 && bid.AdMarkup == "" {
+			mtype = MarkupTypeNative
+			bid.AdMarkup = string(ad.Display.Native)
+		}
+	case ad.Video != nil:
+		mtype = MarkupTypeVideo
+		bid.AdMarkup = markupOrURL(ad.Video.Adm, ad.Video.CURL)
+	case ad.Audio != nil:
+		mtype = MarkupTypeAudio
+		bid.AdMarkup = markupOrURL(ad.Audio.Adm, ad.Audio.CURL)
+	}
+	if mtype > 0 {
+		bid.Ext = ExtSet(bid.Ext, "mtype", mtype)
+	}
+	if ad.CatTax > 0 {
+		bid.Ext = ExtSet(bid.Ext, "cattax", ad.CatTax)
+	}
+	return bid
+}
+
+// markupOrURL returns the markup or the URL which returns the markup
+func markupOrURL(adm, curl string) string {
+	if adm != "" {
+		return adm
+	}
+	return curl
+}
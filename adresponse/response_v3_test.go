@@ -0,0 +1,70 @@
+package adresponse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeBidResponseV3(t *testing.T) {
+	data := `{"openrtb":{"ver":"3.0","domainspec":"adcom","response":{"id":"req-1","bidid":"b1","cur":"EUR","seatbid":[
+		{"seat":"s1","package":1,"bid":[
+			{"id":"1","item":"imp-1","price":1.5,"deal":"d1","purl":"https://example.com/win","burl":"https://example.com/bill",
+				"media":{"ad":{"id":"ad1","adomain":["example.com"],"bundle":["com.example"],"cat":["483"],"cattax":2,
+					"display":{"w":300,"h":250,"adm":"<div></div>"}}}},
+			{"id":"2","item":"imp-2","price":2,
+				"media":{"ad":{"id":"ad2","display":{"native":{"asset":[]}}}}}]},
+		{"seat":"s2","bid":[
+			{"id":"3","item":"imp-1","price":1,"media":{"ad":{"id":"ad3","video":{"curl":"https://example.com/vast"}}}},
+			{"id":"4","item":"imp-1","price":1,"media":{"ad":{"id":"ad4","audio":{"adm":"<VAST></VAST>"}}}},
+			{"id":"5","item":"imp-1","price":1}]}]}}}`
+
+	var resp openrtb.BidResponse
+	if !assert.NoError(t, DecodeBidResponse(strings.NewReader(data), &resp)) {
+		return
+	}
+	assert.Equal(t, "req-1", resp.ID)
+	assert.Equal(t, "b1", resp.BidID)
+	assert.Equal(t, "EUR", resp.Currency)
+	if !assert.Len(t, resp.SeatBid, 2) || !assert.Len(t, resp.SeatBid[0].Bid, 2) || !assert.Len(t, resp.SeatBid[1].Bid, 3) {
+		return
+	}
+	assert.Equal(t, "s1", resp.SeatBid[0].Seat)
+	assert.Equal(t, 1, resp.SeatBid[0].Group)
+
+	display := resp.SeatBid[0].Bid[0]
+	assert.Equal(t, "imp-1", display.ImpID)
+	assert.Equal(t, 1.5, display.Price)
+	assert.Equal(t, "d1", display.DealID)
+	assert.Equal(t, "https://example.com/win", display.NURL)
+	assert.Equal(t, "https://example.com/bill", display.BURL)
+	assert.Equal(t, "ad1", display.CreativeID)
+	assert.Equal(t, []string{"example.com"}, display.AdvDomain)
+	assert.Equal(t, "com.example", display.Bundle)
+	assert.Equal(t, 300, display.W)
+	assert.Equal(t, 250, display.H)
+	assert.Equal(t, "<div></div>", display.AdMarkup)
+	assert.Equal(t, MarkupTypeBanner, BidMarkupType(&display))
+	assert.Equal(t, 2, BidCategoryTaxonomy(&display, 1))
+
+	native := resp.SeatBid[0].Bid[1]
+	assert.Equal(t, MarkupTypeNative, BidMarkupType(&native))
+	assert.JSONEq(t, `{"asset":[]}`, native.AdMarkup)
+	assert.Equal(t, 1, BidCategoryTaxonomy(&native, 1))
+
+	video, audio, empty := resp.SeatBid[1].Bid[0], resp.SeatBid[1].Bid[1], resp.SeatBid[1].Bid[2]
+	assert.Equal(t, MarkupTypeVideo, BidMarkupType(&video))
+	assert.Equal(t, "https://example.com/vast", video.AdMarkup)
+	assert.Equal(t, MarkupTypeAudio, BidMarkupType(&audio))
+	assert.Equal(t, "<VAST></VAST>", audio.AdMarkup)
+	assert.Equal(t, "5", empty.ID)
+	assert.Empty(t, empty.AdMarkup)
+
+	// The envelope is decoded in the same way from the bytes
+	var bytesResp openrtb.BidResponse
+	if assert.NoError(t, DecodeBidResponseBytes([]byte(data), &bytesResp)) {
+		assert.Equal(t, resp, bytesResp)
+	}
+}
@@ -83,11 +83,20 @@ type ResponseVASTBidItem struct {
 	Bid  *openrtb.Bid `json:"bid,omitempty"`
 	VAST *vast.VAST   `json:"vast,omitempty"`
 
+	// VASTInfo classification of the VAST markup (wrapper or inline, duration, media files)
+	VASTInfo VASTInfo `json:"vast_info"`
+
 	PriceScope price.PriceScopeImpression `json:"price_scope,omitempty"`
 
 	// Competitive second AD
 	SecondAd adtype.SecondAd `json:"second_ad,omitempty"`
 
+	// DealInfo of the private marketplace deal matched with the bid
+	DealInfo
+
+	// late binding of the markup macros at the render time
+	late *lateBinding
+
 	Data map[string]any `json:"data,omitempty"`
 
 	// Tracking links for impression and click actions
@@ -142,6 +151,8 @@ func newResponseVASTBidItem(req adtype.BidRequester, src adtype.Source, bid *ope
 		)
 		return nil, err
 	}
+	bidItem.VAST = vastAd
+	bidItem.VASTInfo = newVASTInfo(vastAd)
 
 	// Set the bid impression price based on the bid price and impression
 	bidItem.PriceScope.MaxBidImpPrice = price.CalculatePurchasePrice(bidItem, adtype.ActionImpression)
@@ -151,9 +162,11 @@ func newResponseVASTBidItem(req adtype.BidRequester, src adtype.Source, bid *ope
 		bidItem.impressionTrackers = xtypes.SliceApply(
 			vastAd.Ads[0].InLine.Impressions,
 			func(impression vast.Impression) string { return impression.URI })
-		bidItem.viewTrackers = xtypes.SliceApply(
-			vastAd.Ads[0].InLine.ViewableImpression.Viewable,
-			func(click vast.CDATAString) string { return click.CDATA })
+		if vastAd.Ads[0].InLine.ViewableImpression != nil {
+			bidItem.viewTrackers = xtypes.SliceApply(
+				vastAd.Ads[0].InLine.ViewableImpression.Viewable,
+				func(click vast.CDATAString) string { return click.CDATA })
+		}
 		bidItem.clickTrackers = xtypes.SliceApply(
 			vastAd.Ads[0].InLine.Creatives[0].Linear.VideoClicks.ClickTrackings,
 			func(click vast.VideoClick) string { return click.URI })
@@ -164,9 +177,11 @@ func newResponseVASTBidItem(req adtype.BidRequester, src adtype.Source, bid *ope
 		bidItem.impressionTrackers = xtypes.SliceApply(
 			vastAd.Ads[0].Wrapper.Impressions,
 			func(impression vast.Impression) string { return impression.URI })
-		bidItem.viewTrackers = xtypes.SliceApply(
-			vastAd.Ads[0].Wrapper.ViewableImpression.Viewable,
-			func(click vast.CDATAString) string { return click.CDATA })
+		if vastAd.Ads[0].Wrapper.ViewableImpression != nil {
+			bidItem.viewTrackers = xtypes.SliceApply(
+				vastAd.Ads[0].Wrapper.ViewableImpression.Viewable,
+				func(click vast.CDATAString) string { return click.CDATA })
+		}
 		bidItem.clickTrackers = xtypes.SliceApply(
 			vastAd.Ads[0].Wrapper.Creatives[0].Linear.VideoClicks.ClickTrackings,
 			func(click vast.VideoClick) string { return click.URI })
@@ -558,6 +573,15 @@ func (it *ResponseVASTBidItem) Markup() (string, error) {
 	return "", nil
 }
 
+// FinalizeMarkup returns the VAST markup with the late macros
+// bound at the render time by the final clearing price
+func (it *ResponseVASTBidItem) FinalizeMarkup(ctx context.Context) (string, error) {
+	if it.Bid == nil {
+		return "", nil
+	}
+	return it.late.finalize(ctx, it, it.Bid.AdMarkup)
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Context methods
 ///////////////////////////////////////////////////////////////////////////////
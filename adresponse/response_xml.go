@@ -0,0 +1,131 @@
+package adresponse
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"slices"
+	"strings"
+
+	"github.com/bsm/openrtb"
+)
+
+var errXMLEmptyDocument = errors.New("empty XML document")
+
+// Fields of the XML response which are always decoded as the lists
+var xmlListFields = []string{"seatbid", "bid", "adomain", "cat", "attr"}
+
+// IsXMLContentType returns true if the content type is the XML document
+func IsXMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// DecodeBidResponseXML from the XML document with the elements named by the OpenRTB JSON fields
+// (the repeated elements are the lists). The values are normalized by the lenient decoding,
+// the ad markup is taken as is if it's the nested XML (like VAST) or as the text otherwise.
+func DecodeBidResponseXML(r io.Reader, resp *openrtb.BidResponse) error {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return errXMLEmptyDocument
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			obj, err := decodeXMLObject(dec)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			return DecodeBidResponseLenient(bytes.NewReader(data), resp, nil)
+		}
+	}
+}
+
+// decodeXMLObject decodes the children of the current element into the map
+// or returns the text of the element without children
+func decodeXMLObject(dec *xml.Decoder) (any, error) {
+	var (
+		obj  map[string]any
+		text strings.Builder
+	)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var val any
+			if t.Name.Local == "adm" {
+				val, err = decodeXMLMarkup(dec, t)
+			} else {
+				val, err = decodeXMLObject(dec)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if obj == nil {
+				obj = map[string]any{}
+			}
+			addXMLValue(obj, t.Name.Local, val)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if obj != nil {
+				return obj, nil
+			}
+			return strings.TrimSpace(text.String()), nil
+		}
+	}
+}
+
+// addXMLValue into the object, the repeated and the list fields are collected into the lists
+func addXMLValue(obj map[string]any, key string, val any) {
+	prev, exists := obj[key]
+	switch {
+	case exists:
+		if list, ok := prev.([]any); ok {
+			obj[key] = append(list, val)
+		} else {
+			obj[key] = []any{prev, val}
+		}
+	case slices.Contains(xmlListFields, key):
+		obj[key] = []any{val}
+	default:
+		obj[key] = val
+	}
+}
+
+// decodeXMLMarkup returns the nested XML markup as is or the text of the element
+func decodeXMLMarkup(dec *xml.Decoder, start xml.StartElement) (string, error) {
+	var inner struct {
+		Data string `xml:",innerxml"`
+	}
+	if err := dec.DecodeElement(&inner, &start); err != nil {
+		return "", err
+	}
+	data := strings.TrimSpace(inner.Data)
+	if strings.HasPrefix(data, "<") && !strings.HasPrefix(data, "<![CDATA[") {
+		return data, nil
+	}
+	var text struct {
+		Data string `xml:",chardata"`
+	}
+	if err := xml.Unmarshal([]byte("<adm>"+inner.Data+"</adm>"), &text); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text.Data), nil
+}
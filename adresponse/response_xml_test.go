@@ -0,0 +1,70 @@
+package adresponse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeBidResponseXML(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<BidResponse>
+  <id>resp1</id>
+  <cur>USD</cur>
+  <seatbid>
+    <seat>seat1</seat>
+    <bid>
+      <id>bid1</id>
+      <impid>imp1</impid>
+      <price>1.25</price>
+      <crid>cr1</crid>
+      <adomain>a.com</adomain>
+      <w>640</w>
+      <h>480</h>
+      <adm><VAST version="4.0"><Ad id="1"></Ad></VAST></adm>
+    </bid>
+    <bid>
+      <id>bid2</id>
+      <impid>imp2</impid>
+      <price>2</price>
+      <crid>cr2</crid>
+      <adomain>a.com</adomain>
+      <adomain>b.com</adomain>
+      <adm><![CDATA[<div>ad</div>]]></adm>
+    </bid>
+  </seatbid>
+</BidResponse>`
+
+	var resp openrtb.BidResponse
+	if !assert.NoError(t, DecodeBidResponseXML(strings.NewReader(doc), &resp)) {
+		return
+	}
+	assert.Equal(t, "resp1", resp.ID)
+	assert.Equal(t, "USD", resp.Currency)
+	if !assert.Len(t, resp.SeatBid, 1) || !assert.Len(t, resp.SeatBid[0].Bid, 2) {
+		return
+	}
+	assert.Equal(t, "seat1", resp.SeatBid[0].Seat)
+
+	vast, html := resp.SeatBid[0].Bid[0], resp.SeatBid[0].Bid[1]
+	assert.Equal(t, "bid1", vast.ID)
+	assert.Equal(t, 1.25, vast.Price)
+	assert.Equal(t, []string{"a.com"}, vast.AdvDomain)
+	assert.Equal(t, 640, vast.W)
+	assert.Equal(t, `<VAST version="4.0"><Ad id="1"></Ad></VAST>`, vast.AdMarkup)
+	assert.Equal(t, 2., html.Price)
+	assert.Equal(t, []string{"a.com", "b.com"}, html.AdvDomain)
+	assert.Equal(t, "<div>ad</div>", html.AdMarkup)
+
+	assert.ErrorIs(t, DecodeBidResponseXML(strings.NewReader(""), &resp), errXMLEmptyDocument)
+	assert.Error(t, DecodeBidResponseXML(strings.NewReader("<BidResponse><id>1</BidResponse>"), &resp))
+}
+
+func TestIsXMLContentType(t *testing.T) {
+	assert.True(t, IsXMLContentType("application/xml"))
+	assert.True(t, IsXMLContentType("text/xml; charset=utf-8"))
+	assert.True(t, IsXMLContentType("application/openrtb+xml"))
+	assert.False(t, IsXMLContentType("application/json"))
+}
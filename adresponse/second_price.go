@@ -0,0 +1,120 @@
+package adresponse
+
+import (
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/billing"
+	"github.com/geniusrabbit/adcorelib/price"
+)
+
+// DefaultSecondPriceIncrement added to the second price (CPM in the system currency)
+const DefaultSecondPriceIncrement = 0.01
+
+// isSecondPrice returns true if the bids are settled by the second price
+func (r *BidResponse) isSecondPrice() bool {
+	return r.SourceAuctionType.IsSecondPrice()
+}
+
+// secondPriceIncrement returns the price increment of the second-price auction
+func (r *BidResponse) secondPriceIncrement() float64 {
+	if r.PriceIncrement > 0 {
+		return r.PriceIncrement
+	}
+	return DefaultSecondPriceIncrement
+}
+
+// secondPrice returns the highest competing price of the bid impression
+// or the impression floor if there are no competing bids
+func (r *BidResponse) secondPrice(bid *openrtb.Bid, imp *adtype.Impression) (second float64, ok bool) {
+	for i := range r.BidResponse.SeatBid {
+		seat := &r.BidResponse.SeatBid[i]
+		for j := range seat.Bid {
+			other := &seat.Bid[j]
+			if other == bid || other.ImpID != bid.ImpID || other.Price > bid.Price {
+				continue
+			}
+			if !ok || other.Price > second {
+				second, ok = other.Price, true
+			}
+		}
+	}
+	if !ok && imp != nil {
+		if floor := imp.BidFloorCPM.Float64(); floor > 0 {
+			second, ok = floor, true
+		}
+	}
+	return second, ok
+}
+
+// clearingPrice returns the price paid by the bid (CPM).
+// The deal terms have priority over the source auction type: the fixed price deals
+// are billed by the deal price and the first price deals by the bid price.
+// In the second-price auction it's the second price plus the increment
+// limited by the bid price, otherwise the bid price.
+func (r *BidResponse) clearingPrice(bid *openrtb.Bid, imp *adtype.Impression) float64 {
+	clearing, _, _ := r.settlement(bid, imp)
+	return clearing
+}
+
+// settlement returns the clearing price of the bid and the competing price
+// of the second-price auction (ok is false if the bid is not settled by the second price)
+func (r *BidResponse) settlement(bid *openrtb.Bid, imp *adtype.Impression) (clearing, second float64, ok bool) {
+	secondPrice := r.isSecondPrice()
+	if deal, _ := r.matchedDeal(bid, imp); deal != nil {
+		switch {
+		case deal.IsFixedPrice():
+			return deal.BidFloor, 0, false
+		case deal.AuctionType == DealAuctionFirstPrice:
+			return bid.Price, 0, false
+		case deal.AuctionType == DealAuctionSecondPrice:
+			secondPrice = true
+		}
+	}
+	if !secondPrice {
+		return bid.Price, 0, false
+	}
+	if second, ok = r.secondPrice(bid, imp); !ok {
+		return bid.Price, 0, false
+	}
+	return min(bid.Price, second+r.secondPriceIncrement()), second, true
+}
+
+// settlePrice sets the deal terms and the clearing price of the item as the charged impression price
+// and the competing price as the second ad price for the settlement
+func (r *BidResponse) settlePrice(item adtype.ResponseItemCommon, bid *openrtb.Bid, imp *adtype.Impression) {
+	var (
+		scope    *price.PriceScopeImpression
+		secondAd *adtype.SecondAd
+		dealInfo *DealInfo
+		purchase func() billing.Money
+	)
+	switch it := item.(type) {
+	case *ResponseBannerBidItem:
+		scope, secondAd, dealInfo = &it.PriceScope, &it.SecondAd, &it.DealInfo
+		purchase = func() billing.Money { return price.CalculatePurchasePrice(it, adtype.ActionImpression) }
+	case *ResponseNativeBidItem:
+		scope, secondAd, dealInfo = &it.PriceScope, &it.SecondAd, &it.DealInfo
+		purchase = func() billing.Money { return price.CalculatePurchasePrice(it, adtype.ActionImpression) }
+	case *ResponseVASTBidItem:
+		scope, secondAd, dealInfo = &it.PriceScope, &it.SecondAd, &it.DealInfo
+		purchase = func() billing.Money { return price.CalculatePurchasePrice(it, adtype.ActionImpression) }
+	case *ResponseDirectBidItem:
+		scope, secondAd, dealInfo = &it.PriceScope, &it.SecondAd, &it.DealInfo
+		purchase = func() billing.Money { return price.CalculatePurchasePrice(it, adtype.ActionImpression) }
+	default:
+		return
+	}
+
+	deal, private := r.matchedDeal(bid, imp)
+	dealInfo.DealIDValue, dealInfo.DealTerms, dealInfo.PrivateAuction = bid.DealID, deal, private
+
+	clearing, second, ok := r.settlement(bid, imp)
+	if ok {
+		secondAd.Price = billing.MoneyFloat(second)
+	}
+	if clearing != bid.Price {
+		scope.ImpPrice = billing.MoneyFloat(clearing) / 1000 // Convert from CPM to the impression price
+		scope.MaxBidImpPrice = purchase()
+	}
+}
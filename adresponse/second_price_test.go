@@ -0,0 +1,55 @@
+package adresponse
+
+import (
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/billing"
+)
+
+func TestSecondPriceSettlement(t *testing.T) {
+	tests := []struct {
+		name      string
+		auction   types.AuctionType
+		increment float64
+		floor     float64
+		bids      []float64
+		clearing  float64
+		second    float64
+		ok        bool
+	}{
+		{name: "first_price", auction: types.FirstPriceAuctionType, bids: []float64{2, 1.5}, clearing: 2},
+		{name: "competing_bid", auction: types.SecondPriceAuctionType, bids: []float64{2, 1.5, 1}, clearing: 1.51, second: 1.5, ok: true},
+		{name: "custom_increment", auction: types.SecondPriceAuctionType, increment: 0.1, bids: []float64{2, 1.5}, clearing: 1.6, second: 1.5, ok: true},
+		{name: "capped_by_bid", auction: types.SecondPriceAuctionType, increment: 0.5, bids: []float64{2, 1.9}, clearing: 2, second: 1.9, ok: true},
+		{name: "equal_bids", auction: types.SecondPriceAuctionType, bids: []float64{2, 2}, clearing: 2, second: 2, ok: true},
+		{name: "floor", auction: types.SecondPriceAuctionType, floor: 0.5, bids: []float64{2}, clearing: 0.51, second: 0.5, ok: true},
+		{name: "no_competition", auction: types.SecondPriceAuctionType, bids: []float64{2}, clearing: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			imp := &adtype.Impression{BidFloorCPM: billing.MoneyFloat(test.floor)}
+			resp := &BidResponse{
+				SourceAuctionType: test.auction,
+				PriceIncrement:    test.increment,
+				BidResponse:       openrtb.BidResponse{SeatBid: []openrtb.SeatBid{{}, {}}},
+			}
+			for i, price := range test.bids {
+				// The competing bids are in the other seat
+				seat := &resp.BidResponse.SeatBid[min(i, 1)]
+				seat.Bid = append(seat.Bid, openrtb.Bid{ID: string(rune('a' + i)), ImpID: "imp1", Price: price})
+			}
+			resp.BidResponse.SeatBid[1].Bid = append(resp.BidResponse.SeatBid[1].Bid,
+				openrtb.Bid{ID: "other_imp", ImpID: "imp2", Price: 1.8})
+
+			clearing, second, ok := resp.settlement(&resp.BidResponse.SeatBid[0].Bid[0], imp)
+			assert.InDelta(t, test.clearing, clearing, 1e-9)
+			assert.InDelta(t, test.second, second, 1e-9)
+			assert.Equal(t, test.ok, ok)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package adresponse
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bsm/openrtb"
+)
+
+// SKAdNetwork fidelity types
+const (
+	// SKAdNFidelityViewThrough is the view-through ad attribution
+	SKAdNFidelityViewThrough = 0
+	// SKAdNFidelityStoreKit is the StoreKit-rendered ad attribution
+	SKAdNFidelityStoreKit = 1
+)
+
+// skadnSeparator of the fields in the signed SKAdNetwork message (invisible separator U+2063)
+const skadnSeparator = "\u2063"
+
+var (
+	errSKAdNInvalidSignature = errors.New("invalid SKAdNetwork signature")
+	errSKAdNUnknownNetwork   = errors.New("unknown SKAdNetwork network key")
+)
+
+// SKAdNValue is the string value of the SKAdNetwork field which can be encoded as JSON number or string
+type SKAdNValue string
+
+// UnmarshalJSON of the string or number value
+func (v *SKAdNValue) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		*v = ""
+		return nil
+	}
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*v = SKAdNValue(s)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return err
+	}
+	*v = SKAdNValue(num.String())
+	return nil
+}
+
+// SKAdNFidelity of the SKAdNetwork attribution signature
+type SKAdNFidelity struct {
+	Fidelity  int        `json:"fidelity"`
+	Nonce     string     `json:"nonce"`
+	Timestamp SKAdNValue `json:"timestamp"`
+	Signature string     `json:"signature"`
+}
+
+// SKAdN is the SKAdNetwork extension of the bid (bid.ext.skadn)
+type SKAdN struct {
+	Version          string          `json:"version"`
+	Network          string          `json:"network"`
+	Campaign         SKAdNValue      `json:"campaign,omitempty"`
+	SourceIdentifier SKAdNValue      `json:"sourceidentifier,omitempty"`
+	ITunesItem       SKAdNValue      `json:"itunesitem"`
+	SourceApp        SKAdNValue      `json:"sourceapp,omitempty"`
+	Fidelities       []SKAdNFidelity `json:"fidelities,omitempty"`
+
+	// Signature fields of the SKAdNetwork versions before 2.2
+	Nonce     string     `json:"nonce,omitempty"`
+	Timestamp SKAdNValue `json:"timestamp,omitempty"`
+	Signature string     `json:"signature,omitempty"`
+}
+
+// BidSKAdN returns the SKAdNetwork extension of the bid or nil if it's not present
+func BidSKAdN(bid *openrtb.Bid) (*SKAdN, error) {
+	if len(bid.Ext) == 0 {
+		return nil, nil
+	}
+	var ext struct {
+		SKAdN *SKAdN `json:"skadn"`
+	}
+	if err := json.Unmarshal(bid.Ext, &ext); err != nil {
+		return nil, err
+	}
+	return ext.SKAdN, nil
+}
+
+// FidelityList returns the signatures of all attribution fidelities
+// including the legacy signature which is always StoreKit-rendered
+func (s *SKAdN) FidelityList() []SKAdNFidelity {
+	if len(s.Fidelities) > 0 || s.Signature == "" {
+		return s.Fidelities
+	}
+	return []SKAdNFidelity{{
+		Fidelity:  SKAdNFidelityStoreKit,
+		Nonce:     s.Nonce,
+		Timestamp: s.Timestamp,
+		Signature: s.Signature,
+	}}
+}
+
+// Fidelity returns the signature of the fidelity type or nil
+func (s *SKAdN) Fidelity(fidelityType int) *SKAdNFidelity {
+	for _, f := range s.FidelityList() {
+		if f.Fidelity == fidelityType {
+			return &f
+		}
+	}
+	return nil
+}
+
+// SignedMessage returns the message signed by the ad network for the fidelity
+// in the format defined by the SKAdNetwork version
+func (s *SKAdN) SignedMessage(f *SKAdNFidelity) string {
+	var fields []string
+	switch ver := skadnVersion(s.Version); {
+	case ver >= 4:
+		fields = []string{s.Version, s.Network, string(s.SourceIdentifier), string(s.ITunesItem),
+			f.Nonce, string(s.SourceApp), strconv.Itoa(f.Fidelity), string(f.Timestamp)}
+	case ver >= 2.2:
+		fields = []string{s.Version, s.Network, string(s.Campaign), string(s.ITunesItem),
+			f.Nonce, string(s.SourceApp), strconv.Itoa(f.Fidelity), string(f.Timestamp)}
+	case ver >= 2:
+		fields = []string{s.Version, s.Network, string(s.Campaign), string(s.ITunesItem),
+			f.Nonce, string(s.SourceApp), string(f.Timestamp)}
+	default:
+		fields = []string{s.Network, string(s.Campaign), string(s.ITunesItem),
+			f.Nonce, string(f.Timestamp)}
+	}
+	return strings.Join(fields, skadnSeparator)
+}
+
+// Verify the signatures of all fidelities by the public key of the ad network
+func (s *SKAdN) Verify(key *ecdsa.PublicKey) error {
+	if key == nil {
+		return errSKAdNUnknownNetwork
+	}
+	for _, f := range s.FidelityList() {
+		sig, err := base64.StdEncoding.DecodeString(f.Signature)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errSKAdNInvalidSignature, err)
+		}
+		hash := sha256.Sum256([]byte(s.SignedMessage(&f)))
+		if !ecdsa.VerifyASN1(key, hash[:], sig) {
+			return fmt.Errorf("%w: fidelity %d", errSKAdNInvalidSignature, f.Fidelity)
+		}
+	}
+	return nil
+}
+
+// ValidateBidSKAdN checks the SKAdNetwork extension of the bid.
+// The signatures are verified if the public key of the ad network is known.
+func ValidateBidSKAdN(bid *openrtb.Bid, networkKey func(network string) *ecdsa.PublicKey) []BidViolation {
+	skadn, err := BidSKAdN(bid)
+	if err != nil {
+		return []BidViolation{{BidID: bid.ID, ImpID: bid.ImpID, Field: "ext.skadn", Message: "invalid format: " + err.Error()}}
+	}
+	if skadn == nil {
+		return nil
+	}
+
+	var violations []BidViolation
+	violate := func(field, msg string) {
+		violations = append(violations, BidViolation{BidID: bid.ID, ImpID: bid.ImpID, Field: "ext.skadn." + field, Message: msg})
+	}
+
+	if skadn.Network == "" {
+		violate("network", "is required")
+	}
+	if skadn.ITunesItem == "" {
+		violate("itunesitem", "is required")
+	}
+	fidelities := skadn.FidelityList()
+	if len(fidelities) == 0 {
+		violate("fidelities", "at least one signature is required")
+	}
+	for _, f := range fidelities {
+		if f.Fidelity != SKAdNFidelityViewThrough && f.Fidelity != SKAdNFidelityStoreKit {
+			violate("fidelities.fidelity", fmt.Sprintf("%d is unsupported", f.Fidelity))
+		}
+		if !isSKAdNNonce(f.Nonce) {
+			violate("fidelities.nonce", "must be UUID")
+		}
+		if _, err := strconv.ParseInt(string(f.Timestamp), 10, 64); err != nil {
+			violate("fidelities.timestamp", "must be unix time in milliseconds")
+		}
+		if f.Signature == "" {
+			violate("fidelities.signature", "is required")
+		}
+	}
+	if len(violations) == 0 && networkKey != nil {
+		if key := networkKey(strings.ToLower(skadn.Network)); key != nil {
+			if err := skadn.Verify(key); err != nil {
+				violate("fidelities.signature", err.Error())
+			}
+		}
+	}
+	return violations
+}
+
+// NewSKAdNNonce returns new random nonce (UUID v4) of the SKAdNetwork signature
+func NewSKAdNNonce() string {
+	var uuid [16]byte
+	_, _ = rand.Read(uuid[:])
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
+
+func isSKAdNNonce(nonce string) bool {
+	if len(nonce) != 36 {
+		return false
+	}
+	for i, c := range nonce {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func skadnVersion(version string) float64 {
+	ver, _ := strconv.ParseFloat(version, 64)
+	return ver
+}
@@ -0,0 +1,121 @@
+package adresponse
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+)
+
+// signSKAdN signs all fidelities of the SKAdNetwork extension by the ad network key
+func signSKAdN(t *testing.T, key *ecdsa.PrivateKey, skadn *SKAdN) {
+	t.Helper()
+	sign := func(f *SKAdNFidelity) string {
+		hash := sha256.Sum256([]byte(skadn.SignedMessage(f)))
+		sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+		assert.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+	for i := range skadn.Fidelities {
+		skadn.Fidelities[i].Signature = sign(&skadn.Fidelities[i])
+	}
+	if len(skadn.Fidelities) == 0 {
+		skadn.Signature = sign(&SKAdNFidelity{
+			Fidelity: SKAdNFidelityStoreKit, Nonce: skadn.Nonce, Timestamp: skadn.Timestamp})
+	}
+}
+
+// skadnBid returns the bid with the SKAdNetwork extension
+func skadnBid(t *testing.T, skadn any) *openrtb.Bid {
+	t.Helper()
+	ext, err := json.Marshal(map[string]any{"skadn": skadn})
+	assert.NoError(t, err)
+	return &openrtb.Bid{ID: "1", ImpID: "imp1", Ext: ext}
+}
+
+func TestValidateBidSKAdN(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	networkKey := func(network string) *ecdsa.PublicKey {
+		if network == "example.skadnetwork" {
+			return &key.PublicKey
+		}
+		return nil
+	}
+
+	t.Run("fidelities", func(t *testing.T) {
+		skadn := &SKAdN{
+			Version: "4.0", Network: "Example.skadnetwork", SourceIdentifier: "1234",
+			ITunesItem: "880047117", SourceApp: "0",
+			Fidelities: []SKAdNFidelity{
+				{Fidelity: SKAdNFidelityViewThrough, Nonce: NewSKAdNNonce(), Timestamp: "1594406341"},
+				{Fidelity: SKAdNFidelityStoreKit, Nonce: NewSKAdNNonce(), Timestamp: "1594406342"},
+			},
+		}
+		signSKAdN(t, key, skadn)
+		assert.Empty(t, ValidateBidSKAdN(skadnBid(t, skadn), networkKey))
+
+		// The signature of the other fidelity values is invalid
+		skadn.Fidelities[1].Timestamp = "1594406343"
+		violations := ValidateBidSKAdN(skadnBid(t, skadn), networkKey)
+		if assert.Len(t, violations, 1) {
+			assert.Equal(t, "ext.skadn.fidelities.signature", violations[0].Field)
+		}
+	})
+
+	t.Run("legacy_signature", func(t *testing.T) {
+		skadn := &SKAdN{
+			Version: "2.0", Network: "example.skadnetwork", Campaign: "45", ITunesItem: "880047117",
+			SourceApp: "123456789", Nonce: NewSKAdNNonce(), Timestamp: "1594406341",
+		}
+		signSKAdN(t, key, skadn)
+		assert.Empty(t, ValidateBidSKAdN(skadnBid(t, skadn), networkKey))
+		if fidelity := skadn.Fidelity(SKAdNFidelityStoreKit); assert.NotNil(t, fidelity) {
+			assert.Equal(t, skadn.Signature, fidelity.Signature)
+		}
+	})
+
+	t.Run("numeric_values", func(t *testing.T) {
+		nonce := NewSKAdNNonce()
+		skadn := &SKAdN{Version: "2.2", Network: "example.skadnetwork", Campaign: "45", ITunesItem: "880047117",
+			SourceApp: "0", Fidelities: []SKAdNFidelity{{Fidelity: SKAdNFidelityStoreKit, Nonce: nonce, Timestamp: "1594406341"}}}
+		signSKAdN(t, key, skadn)
+		bid := skadnBid(t, map[string]any{
+			"version": "2.2", "network": "example.skadnetwork", "campaign": 45, "itunesitem": 880047117, "sourceapp": 0,
+			"fidelities": []any{map[string]any{"fidelity": 1, "nonce": nonce, "timestamp": 1594406341,
+				"signature": skadn.Fidelities[0].Signature}},
+		})
+		assert.Empty(t, ValidateBidSKAdN(bid, networkKey))
+	})
+
+	t.Run("unknown_network", func(t *testing.T) {
+		skadn := &SKAdN{Version: "4.0", Network: "other.skadnetwork", ITunesItem: "880047117",
+			Fidelities: []SKAdNFidelity{{Fidelity: SKAdNFidelityStoreKit, Nonce: NewSKAdNNonce(),
+				Timestamp: "1594406341", Signature: "c2lnbmF0dXJl"}}}
+		assert.Empty(t, ValidateBidSKAdN(skadnBid(t, skadn), networkKey), "the signature of the unknown network isn't verified")
+	})
+
+	t.Run("invalid_fields", func(t *testing.T) {
+		skadn := &SKAdN{Version: "4.0", Fidelities: []SKAdNFidelity{{Fidelity: 2, Nonce: "nonce", Timestamp: "now"}}}
+		var fields []string
+		for _, violation := range ValidateBidSKAdN(skadnBid(t, skadn), networkKey) {
+			fields = append(fields, violation.Field)
+		}
+		assert.ElementsMatch(t, []string{
+			"ext.skadn.network", "ext.skadn.itunesitem", "ext.skadn.fidelities.fidelity",
+			"ext.skadn.fidelities.nonce", "ext.skadn.fidelities.timestamp", "ext.skadn.fidelities.signature",
+		}, fields)
+	})
+
+	t.Run("no_skadn", func(t *testing.T) {
+		assert.Empty(t, ValidateBidSKAdN(&openrtb.Bid{ID: "1"}, networkKey))
+	})
+}
@@ -0,0 +1,75 @@
+package adresponse
+
+import (
+	"strings"
+	"time"
+
+	"github.com/haxqer/vast"
+)
+
+// VASTKind of the VAST ad
+type VASTKind string
+
+// VAST ad kinds
+const (
+	VASTKindInline  VASTKind = "inline"
+	VASTKindWrapper VASTKind = "wrapper"
+)
+
+// VASTInfo is the classification of the VAST markup of the video bid
+type VASTInfo struct {
+	Version string   `json:"version,omitempty"`
+	Kind    VASTKind `json:"kind,omitempty"`
+
+	// Duration of the longest linear creative of the inline ad
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// MediaFiles count of the linear creatives of the inline ad
+	MediaFiles int `json:"media_files,omitempty"`
+
+	// AdTagURI of the wrapper ad
+	AdTagURI string `json:"ad_tag_uri,omitempty"`
+}
+
+// IsWrapper returns true if the ad refers to the secondary ad server by the VAST tag URI
+func (info *VASTInfo) IsWrapper() bool {
+	return info.Kind == VASTKindWrapper
+}
+
+// IsVASTMarkup returns true if the markup is the VAST XML document
+func IsVASTMarkup(markup string) bool {
+	markup = strings.TrimSpace(markup)
+	if strings.HasPrefix(markup, "<?xml") {
+		end := strings.Index(markup, "?>")
+		if end < 0 {
+			return false
+		}
+		markup = strings.TrimSpace(markup[end+2:])
+	}
+	return strings.HasPrefix(markup, "<VAST")
+}
+
+// newVASTInfo returns the classification of the first ad of the VAST document
+func newVASTInfo(v *vast.VAST) VASTInfo {
+	info := VASTInfo{Version: v.Version}
+	if len(v.Ads) == 0 {
+		return info
+	}
+	switch ad := v.Ads[0]; {
+	case ad.InLine != nil:
+		info.Kind = VASTKindInline
+		for _, creative := range ad.InLine.Creatives {
+			if creative.Linear == nil {
+				continue
+			}
+			info.Duration = max(info.Duration, time.Duration(creative.Linear.Duration))
+			if creative.Linear.MediaFiles != nil {
+				info.MediaFiles += len(creative.Linear.MediaFiles.MediaFile)
+			}
+		}
+	case ad.Wrapper != nil:
+		info.Kind = VASTKindWrapper
+		info.AdTagURI = strings.TrimSpace(ad.Wrapper.VASTAdTagURI.CDATA)
+	}
+	return info
+}
@@ -0,0 +1,80 @@
+package adresponse
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/haxqer/vast"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testVASTInline = `<?xml version="1.0" encoding="UTF-8"?>
+<VAST version="4.0"><Ad id="1"><InLine><AdSystem>test</AdSystem><AdTitle>ad</AdTitle><Creatives>
+  <Creative><Linear><Duration>00:00:15</Duration><MediaFiles>
+    <MediaFile delivery="progressive" type="video/mp4" width="640" height="360"><![CDATA[https://cdn.example.com/1.mp4]]></MediaFile>
+    <MediaFile delivery="progressive" type="video/webm" width="640" height="360"><![CDATA[https://cdn.example.com/1.webm]]></MediaFile>
+  </MediaFiles></Linear></Creative>
+  <Creative><Linear><Duration>00:00:30</Duration><MediaFiles>
+    <MediaFile delivery="progressive" type="video/mp4" width="1280" height="720"><![CDATA[https://cdn.example.com/2.mp4]]></MediaFile>
+  </MediaFiles></Linear></Creative>
+</Creatives></InLine></Ad></VAST>`
+	testVASTWrapper = `<VAST version="3.0"><Ad id="1"><Wrapper><AdSystem>test</AdSystem>
+  <VASTAdTagURI><![CDATA[ https://ads.example.com/vast.xml ]]></VASTAdTagURI>
+</Wrapper></Ad></VAST>`
+)
+
+func TestIsVASTMarkup(t *testing.T) {
+	tests := []struct {
+		markup string
+		vast   bool
+	}{
+		{markup: testVASTInline, vast: true},
+		{markup: testVASTWrapper, vast: true},
+		{markup: "\n  <VAST version=\"4.0\"></VAST>", vast: true},
+		{markup: `<?xml version="1.0"?><div></div>`},
+		{markup: `<?xml version="1.0"<VAST></VAST>`},
+		{markup: `<div><VAST></VAST></div>`},
+		{markup: `https://ads.example.com/vast.xml`},
+		{markup: ``},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.vast, IsVASTMarkup(tt.markup), tt.markup)
+	}
+}
+
+func TestVASTInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		markup string
+		info   VASTInfo
+	}{
+		{
+			name:   "inline",
+			markup: testVASTInline,
+			info:   VASTInfo{Version: "4.0", Kind: VASTKindInline, Duration: 30 * time.Second, MediaFiles: 3},
+		},
+		{
+			name:   "wrapper",
+			markup: testVASTWrapper,
+			info:   VASTInfo{Version: "3.0", Kind: VASTKindWrapper, AdTagURI: "https://ads.example.com/vast.xml"},
+		},
+		{
+			name:   "no_ads",
+			markup: `<VAST version="4.0"></VAST>`,
+			info:   VASTInfo{Version: "4.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ad vast.VAST
+			if !assert.NoError(t, xml.Unmarshal([]byte(tt.markup), &ad)) {
+				return
+			}
+			info := newVASTInfo(&ad)
+			assert.Equal(t, tt.info, info)
+			assert.Equal(t, tt.info.Kind == VASTKindWrapper, info.IsWrapper())
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package adsourceopenrtb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// Defaults of the anomaly trace capture
+const (
+	defaultAnomalyWindow      = time.Minute
+	defaultAnomalyMinRequests = 20
+	defaultAnomalyCaptures    = 10
+)
+
+// TraceCapture of the request and the response payloads captured on the source anomaly
+type TraceCapture struct {
+	SourceID uint64    `json:"source_id"`
+	TraceID  string    `json:"trace_id"`
+	Time     time.Time `json:"time"`
+	Request  []byte    `json:"request,omitempty"`
+	Response []byte    `json:"response,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// TraceSink receives the trace payloads captured automatically on the source anomalies
+type TraceSink interface {
+	CaptureTrace(capture *TraceCapture)
+}
+
+// TraceSinkFunc wrapper of the function to the TraceSink interface
+type TraceSinkFunc func(capture *TraceCapture)
+
+// CaptureTrace sends the captured trace to the function
+func (f TraceSinkFunc) CaptureTrace(capture *TraceCapture) {
+	f(capture)
+}
+
+// anomalyTrigger arms the trace capture of the limited number of the next requests
+// when the failure rate of the window exceeds the threshold, it's triggered once per window
+type anomalyTrigger struct {
+	mx          sync.Mutex
+	window      time.Duration
+	errorRate   float64
+	minRequests int
+	captures    int
+
+	windowStart time.Time
+	requests    int
+	failures    int
+	triggered   bool
+	remaining   int
+}
+
+func newAnomalyTrigger(errorRate float64, captures int, window time.Duration, minRequests int) *anomalyTrigger {
+	if window <= 0 {
+		window = defaultAnomalyWindow
+	}
+	if minRequests <= 0 {
+		minRequests = defaultAnomalyMinRequests
+	}
+	if captures <= 0 {
+		captures = defaultAnomalyCaptures
+	}
+	return &anomalyTrigger{
+		window:      window,
+		errorRate:   errorRate,
+		minRequests: minRequests,
+		captures:    captures,
+	}
+}
+
+// Take returns true if the request has to be captured
+func (t *anomalyTrigger) Take() bool {
+	if t == nil {
+		return false
+	}
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.remaining <= 0 {
+		return false
+	}
+	t.remaining--
+	return true
+}
+
+// Record the result of the request and arms the capture if the failure rate spikes
+func (t *anomalyTrigger) Record(failed bool, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if now.Sub(t.windowStart) >= t.window {
+		t.windowStart, t.requests, t.failures, t.triggered = now, 0, 0, false
+	}
+	t.requests++
+	if failed {
+		t.failures++
+	}
+	if !t.triggered && t.requests >= t.minRequests &&
+		float64(t.failures)/float64(t.requests) >= t.errorRate {
+		t.triggered, t.remaining = true, t.captures
+	}
+}
+
+// recordAnomaly records the request result and sends the captured payloads to the trace sink
+func (d *driver) recordAnomaly(request adtype.BidRequester, raw *rawPayloads, capture bool, failure error) {
+	if d.anomaly == nil {
+		return
+	}
+	d.anomaly.Record(failure != nil, d.now())
+	if !capture || raw == nil {
+		return
+	}
+	trace := &TraceCapture{
+		SourceID: d.ID(),
+		TraceID:  d.traceID(request),
+		Time:     d.now(),
+		Request:  raw.request,
+		Response: raw.response.buf,
+	}
+	if failure != nil {
+		trace.Error = failure.Error()
+	}
+	d.opts.TraceSink.CaptureTrace(trace)
+}
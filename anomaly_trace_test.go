@@ -0,0 +1,69 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnomalyTrigger(t *testing.T) {
+	var (
+		now     = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		trigger = newAnomalyTrigger(0.5, 2, time.Minute, 4)
+	)
+	assert.False(t, (*anomalyTrigger)(nil).Take())
+
+	// The capture is armed after the minimal number of the requests only
+	for _, failed := range []bool{true, true, false} {
+		trigger.Record(failed, now)
+		assert.False(t, trigger.Take())
+	}
+	trigger.Record(false, now)
+	assert.True(t, trigger.Take())
+	assert.True(t, trigger.Take())
+	assert.False(t, trigger.Take())
+
+	// The capture is triggered once per window
+	for range 4 {
+		trigger.Record(true, now.Add(time.Second))
+	}
+	assert.False(t, trigger.Take())
+
+	// The next window starts with the new counters
+	for range 4 {
+		trigger.Record(true, now.Add(time.Minute))
+	}
+	assert.True(t, trigger.Take())
+}
+
+func TestAnomalyTrace(t *testing.T) {
+	var (
+		mx       sync.Mutex
+		captures []*TraceCapture
+		sink     = TraceSinkFunc(func(capture *TraceCapture) {
+			mx.Lock()
+			defer mx.Unlock()
+			captures = append(captures, capture)
+		})
+	)
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}, WithAnomalyTrace(sink, 0.5, 2), WithAnomalyWindow(time.Minute, 2))
+
+	for range 5 {
+		_ = d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	}
+
+	// The two requests after the failure rate spike are captured
+	mx.Lock()
+	defer mx.Unlock()
+	if assert.Len(t, captures, 2) {
+		assert.Equal(t, uint64(1), captures[0].SourceID)
+		assert.NotEmpty(t, captures[0].Request)
+		assert.Equal(t, ErrInvalidResponseStatus.Error(), captures[0].Error)
+	}
+}
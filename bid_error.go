@@ -0,0 +1,91 @@
+package adsourceopenrtb
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/geniusrabbit/adcorelib/net/httpclient"
+)
+
+// BidStage of the bid request processing where the error happened
+type BidStage string
+
+// Bid stages
+const (
+	// BidStageLimit is the request rejected by the source limits before sending
+	BidStageLimit BidStage = "limit"
+	// BidStageEncode is the request building and encoding
+	BidStageEncode BidStage = "encode"
+	// BidStageNetwork is the HTTP call of the source (connection errors and timeouts)
+	BidStageNetwork BidStage = "network"
+	// BidStageStatus is the response with the failure status of the bidder
+	BidStageStatus BidStage = "status"
+	// BidStageDecode is the response reading and decoding
+	BidStageDecode BidStage = "decode"
+)
+
+// BidError of the source classified by the stage of the bid request,
+// the original error is wrapped so errors.Is works with the error values of the package
+type BidError struct {
+	SourceID   uint64
+	Stage      BidStage
+	StatusCode int  // HTTP status of the response (0 if there is no response)
+	Retryable  bool // The failure is transient and the request can be repeated later
+	Err        error
+}
+
+func (e *BidError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("source %d: %s [%d]: %v", e.SourceID, e.Stage, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("source %d: %s: %v", e.SourceID, e.Stage, e.Err)
+}
+
+// Unwrap returns the original error
+func (e *BidError) Unwrap() error {
+	return e.Err
+}
+
+// IsNetwork returns true if the error is caused by the network or the source timeout
+func (e *BidError) IsNetwork() bool {
+	return e.Stage == BidStageNetwork
+}
+
+// IsBidder returns true if the error is caused by the bidder response
+func (e *BidError) IsBidder() bool {
+	return e.Stage == BidStageStatus || e.Stage == BidStageDecode
+}
+
+// AsBidError returns the bid error of the error chain
+func AsBidError(err error) (*BidError, bool) {
+	var bidErr *BidError
+	if errors.As(err, &bidErr) {
+		return bidErr, true
+	}
+	return nil, false
+}
+
+// bidError wraps the error into the bid error of the source and keeps it in the error history
+func (d *driver) bidError(stage BidStage, resp httpclient.Response, err error) error {
+	if err == nil {
+		return nil
+	}
+	bidErr := &BidError{SourceID: d.ID(), Stage: stage, Err: err}
+	if resp != nil {
+		bidErr.StatusCode = resp.StatusCode()
+	}
+	switch stage {
+	case BidStageLimit:
+		bidErr.Retryable = true
+	case BidStageNetwork:
+		bidErr.Retryable = isRetryableFailure(nil, err, d.now())
+	case BidStageStatus:
+		bidErr.Retryable = errors.Is(err, ErrSourceThrottled) || isRetryableFailure(resp, nil, d.now())
+	}
+	// The requests rejected by the source limits aren't the failures of the source
+	if stage != BidStageLimit {
+		d.errorHistory.Add(d.now(), bidErr)
+	}
+	return bidErr
+}
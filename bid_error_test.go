@@ -0,0 +1,96 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+)
+
+func TestBidError(t *testing.T) {
+	err := &BidError{SourceID: 1, Stage: BidStageStatus, StatusCode: 500, Err: ErrInvalidResponseStatus}
+	assert.Equal(t, "source 1: status [500]: "+ErrInvalidResponseStatus.Error(), err.Error())
+	assert.True(t, err.IsBidder())
+	assert.False(t, err.IsNetwork())
+
+	err = &BidError{SourceID: 1, Stage: BidStageNetwork, Err: context.DeadlineExceeded}
+	assert.Equal(t, "source 1: network: "+context.DeadlineExceeded.Error(), err.Error())
+	assert.True(t, err.IsNetwork())
+	assert.False(t, err.IsBidder())
+
+	bidErr, ok := AsBidError(errors.Join(errors.New("wrapped"), err))
+	assert.True(t, ok)
+	assert.Same(t, err, bidErr)
+	_, ok = AsBidError(ErrInvalidResponseStatus)
+	assert.False(t, ok)
+}
+
+func TestBidErrorStages(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		header    string
+		stage     BidStage
+		retryable bool
+		err       error
+	}{
+		{name: "unavailable", status: http.StatusServiceUnavailable, stage: BidStageStatus, retryable: true, err: ErrInvalidResponseStatus},
+		{name: "bad_request", status: http.StatusBadRequest, stage: BidStageStatus, err: ErrInvalidResponseStatus},
+		{name: "throttled", status: http.StatusTooManyRequests, header: "30", stage: BidStageStatus, retryable: true, err: ErrSourceThrottled},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {
+				if test.header != "" {
+					w.Header().Set("Retry-After", test.header)
+				}
+				w.WriteHeader(test.status)
+			})
+			resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+			bidErr, ok := AsBidError(resp.Error())
+			if !assert.True(t, ok, resp.Error()) {
+				return
+			}
+			assert.Equal(t, uint64(1), bidErr.SourceID)
+			assert.Equal(t, test.stage, bidErr.Stage)
+			assert.Equal(t, test.status, bidErr.StatusCode)
+			assert.Equal(t, test.retryable, bidErr.Retryable)
+			if test.err != nil {
+				assert.ErrorIs(t, resp.Error(), test.err)
+			}
+		})
+	}
+}
+
+func TestBidErrorRetryable(t *testing.T) {
+	d := newTestDriver(t, nil)
+	assert.NoError(t, d.bidError(BidStageDecode, nil, nil))
+
+	bidErr, ok := AsBidError(d.bidError(BidStageLimit, nil, ErrSourceOverloaded))
+	if assert.True(t, ok) {
+		assert.True(t, bidErr.Retryable)
+	}
+
+	bidErr, ok = AsBidError(d.bidError(BidStageDecode, nil, ErrInvalidResponseStatus))
+	if assert.True(t, ok) {
+		assert.True(t, bidErr.IsBidder())
+		assert.False(t, bidErr.Retryable)
+	}
+}
+
+func TestBidErrorNetwork(t *testing.T) {
+	d := newTestDriver(t, nil, testSourceOption(func(source *admodels.RTBSource) {
+		source.URL = "http://127.0.0.1:1/bid"
+	}))
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	bidErr, ok := AsBidError(resp.Error())
+	if assert.True(t, ok, resp.Error()) {
+		assert.True(t, bidErr.IsNetwork())
+		assert.True(t, bidErr.Retryable)
+		assert.Zero(t, bidErr.StatusCode)
+	}
+}
@@ -0,0 +1,115 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// filterBids keeps only the bids accepted by the filter function
+// and removes empty seats from the response
+func filterBids(resp *openrtb.BidResponse, accept func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool) {
+	seats := resp.SeatBid[:0]
+	for _, seat := range resp.SeatBid {
+		bids := seat.Bid[:0]
+		for i := range seat.Bid {
+			if accept(&seat, &seat.Bid[i]) {
+				bids = append(bids, seat.Bid[i])
+			}
+		}
+		if seat.Bid = bids; len(seat.Bid) > 0 {
+			seats = append(seats, seat)
+		}
+	}
+	resp.SeatBid = seats
+}
+
+// rejectBids keeps only the bids accepted by the filter function
+// and adds the dropped bids into the rejections with the reason
+func rejectBids(resp *openrtb.BidResponse, rejections *adresponse.BidRejections, reason adresponse.RejectionReason, accept func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool) {
+	filterBids(resp, func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		if accept(seat, bid) {
+			return true
+		}
+		rejections.Add(seat, bid, reason, "")
+		return false
+	})
+}
+
+// limitBidDensity keeps the most expensive bids up to the limit per seat and per response
+// (0 - unlimited) and adds the dropped bids into the rejections
+func limitBidDensity(resp *openrtb.BidResponse, rejections *adresponse.BidRejections, maxSeatBids, maxResponseBids int) {
+	if maxSeatBids > 0 {
+		var dropped []*openrtb.Bid
+		for i := range resp.SeatBid {
+			dropped = append(dropped, cheapestBids(resp.SeatBid[i].Bid, maxSeatBids)...)
+		}
+		rejectBids(resp, rejections, adresponse.RejectionBidDensity, func(_ *openrtb.SeatBid, bid *openrtb.Bid) bool {
+			return !slices.Contains(dropped, bid)
+		})
+	}
+	if maxResponseBids > 0 {
+		var bids []*openrtb.Bid
+		for i := range resp.SeatBid {
+			for j := range resp.SeatBid[i].Bid {
+				bids = append(bids, &resp.SeatBid[i].Bid[j])
+			}
+		}
+		if len(bids) <= maxResponseBids {
+			return
+		}
+		slices.SortStableFunc(bids, compareBidPriceDesc)
+		dropped := bids[maxResponseBids:]
+		rejectBids(resp, rejections, adresponse.RejectionBidDensity, func(_ *openrtb.SeatBid, bid *openrtb.Bid) bool {
+			return !slices.Contains(dropped, bid)
+		})
+	}
+}
+
+// cheapestBids returns the bids over the limit ordered by the price
+func cheapestBids(bids []openrtb.Bid, limit int) []*openrtb.Bid {
+	if len(bids) <= limit {
+		return nil
+	}
+	list := make([]*openrtb.Bid, 0, len(bids))
+	for i := range bids {
+		list = append(list, &bids[i])
+	}
+	slices.SortStableFunc(list, compareBidPriceDesc)
+	return list[limit:]
+}
+
+func compareBidPriceDesc(a, b *openrtb.Bid) int {
+	switch {
+	case a.Price > b.Price:
+		return -1
+	case a.Price < b.Price:
+		return 1
+	}
+	return 0
+}
+
+// isBidCategoryBlocked returns true if any category of the bid is in the blocked list.
+// The categories of the taxonomy other than the taxonomy of the blocked list can't be checked,
+// so such bids are blocked as well.
+// For IAB 1.0 taxonomy the blocked top-level category blocks all subcategories.
+func isBidCategoryBlocked(bid *openrtb.Bid, blocked []string, taxonomy int) bool {
+	if len(blocked) == 0 || len(bid.Cat) == 0 {
+		return false
+	}
+	taxonomy = max(taxonomy, adresponse.CategoryTaxonomyIABContent1)
+	if adresponse.BidCategoryTaxonomy(bid, adresponse.CategoryTaxonomyIABContent1) != taxonomy {
+		return true
+	}
+	for _, cat := range bid.Cat {
+		if slices.ContainsFunc(blocked, func(b string) bool {
+			return cat == b || strings.HasPrefix(cat, b+"-")
+		}) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package adsourceopenrtb
+
+import (
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+func TestIsBidCategoryBlocked(t *testing.T) {
+	tests := []struct {
+		name     string
+		bid      openrtb.Bid
+		blocked  []string
+		taxonomy int
+		result   bool
+	}{
+		{name: "no_blocked", bid: openrtb.Bid{Cat: []string{"IAB25"}}},
+		{name: "no_categories", blocked: []string{"IAB25"}},
+		{name: "blocked", bid: openrtb.Bid{Cat: []string{"IAB1", "IAB25"}}, blocked: []string{"IAB25"}, result: true},
+		{name: "subcategory", bid: openrtb.Bid{Cat: []string{"IAB25-3"}}, blocked: []string{"IAB25"}, result: true},
+		{name: "other_category", bid: openrtb.Bid{Cat: []string{"IAB2"}}, blocked: []string{"IAB25"}},
+		{name: "prefix_only", bid: openrtb.Bid{Cat: []string{"IAB250"}}, blocked: []string{"IAB25"}},
+		{name: "same_taxonomy", bid: openrtb.Bid{Cat: []string{"1"}, Ext: openrtb.Extension(`{"cattax":2}`)},
+			blocked: []string{"1"}, taxonomy: adresponse.CategoryTaxonomyIABContent2, result: true},
+		{name: "other_taxonomy", bid: openrtb.Bid{Cat: []string{"483"}, Ext: openrtb.Extension(`{"cattax":2}`)},
+			blocked: []string{"IAB25"}, result: true},
+		{name: "default_taxonomy", bid: openrtb.Bid{Cat: []string{"IAB2"}},
+			blocked: []string{"1"}, taxonomy: adresponse.CategoryTaxonomyIABContent2, result: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.result, isBidCategoryBlocked(&test.bid, test.blocked, test.taxonomy))
+		})
+	}
+}
+
+func TestLimitBidDensity(t *testing.T) {
+	newResponse := func() *openrtb.BidResponse {
+		return &openrtb.BidResponse{SeatBid: []openrtb.SeatBid{
+			{Seat: "s1", Bid: []openrtb.Bid{{ID: "1", Price: 1}, {ID: "2", Price: 3}, {ID: "3", Price: 2}}},
+			{Seat: "s2", Bid: []openrtb.Bid{{ID: "4", Price: 2.5}, {ID: "5", Price: 0.5}}},
+		}}
+	}
+	bidIDs := func(resp *openrtb.BidResponse) (ids []string) {
+		for _, seat := range resp.SeatBid {
+			for _, bid := range seat.Bid {
+				ids = append(ids, bid.ID)
+			}
+		}
+		return ids
+	}
+	rejectedIDs := func(rejections adresponse.BidRejections) (ids []string) {
+		for _, rejection := range rejections {
+			assert.Equal(t, adresponse.RejectionBidDensity, rejection.Reason)
+			ids = append(ids, rejection.BidID)
+		}
+		return ids
+	}
+	tests := []struct {
+		name            string
+		maxSeatBids     int
+		maxResponseBids int
+		bids            []string
+		rejected        []string
+	}{
+		{name: "unlimited", bids: []string{"1", "2", "3", "4", "5"}},
+		{name: "per_seat", maxSeatBids: 2, bids: []string{"2", "3", "4", "5"}, rejected: []string{"1"}},
+		{name: "per_response", maxResponseBids: 2, bids: []string{"2", "4"}, rejected: []string{"1", "3", "5"}},
+		{name: "both", maxSeatBids: 1, maxResponseBids: 1, bids: []string{"2"}, rejected: []string{"1", "3", "5", "4"}},
+		{name: "under_limits", maxSeatBids: 3, maxResponseBids: 5, bids: []string{"1", "2", "3", "4", "5"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				resp       = newResponse()
+				rejections adresponse.BidRejections
+			)
+			limitBidDensity(resp, &rejections, test.maxSeatBids, test.maxResponseBids)
+			assert.Equal(t, test.bids, bidIDs(resp))
+			assert.Equal(t, test.rejected, rejectedIDs(rejections))
+		})
+	}
+
+	// The seats without the bids are removed
+	resp := newResponse()
+	limitBidDensity(resp, &adresponse.BidRejections{}, 0, 1)
+	if assert.Len(t, resp.SeatBid, 1) {
+		assert.Equal(t, "s1", resp.SeatBid[0].Seat)
+	}
+}
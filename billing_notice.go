@@ -0,0 +1,149 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/geniusrabbit/adcorelib/eventtraking/events"
+	"github.com/geniusrabbit/adcorelib/eventtraking/eventstream"
+)
+
+// defaultBillingNoticeTTL of the billing notice waiting for the impression
+const defaultBillingNoticeTTL = 30 * time.Minute
+
+// BillingNotices keeps the billing notice URLs (burl) of the won bids until the impression
+// is rendered, so the billing notice is fired on the billable event instead of the win
+// as the OpenRTB 2.5 defines. The same notices are shared by all drivers and the event stream.
+type BillingNotices struct {
+	mx        sync.Mutex
+	wins      *eventstream.WinNotifier
+	ttl       time.Duration
+	notices   map[billingKey]billingNotice
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type billingKey struct {
+	auctionID string
+	impID     string
+}
+
+type billingNotice struct {
+	url     string
+	expires time.Time
+}
+
+// NewBillingNotices returns the billing notices fired by the win notifier (the context one if nil),
+// the notices without the impression during the TTL are dropped (30 minutes by default)
+func NewBillingNotices(wins *eventstream.WinNotifier, ttl time.Duration) *BillingNotices {
+	if ttl <= 0 {
+		ttl = defaultBillingNoticeTTL
+	}
+	return &BillingNotices{
+		wins:    wins,
+		ttl:     ttl,
+		notices: map[billingKey]billingNotice{},
+		now:     time.Now,
+	}
+}
+
+// Add the billing notice URL of the auction impression
+func (n *BillingNotices) Add(auctionID, impID, url string) {
+	if n == nil || url == "" {
+		return
+	}
+	now := n.now()
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if now.Sub(n.lastSweep) >= n.ttl {
+		for key, notice := range n.notices {
+			if now.After(notice.expires) {
+				delete(n.notices, key)
+			}
+		}
+		n.lastSweep = now
+	}
+	n.notices[billingKey{auctionID: auctionID, impID: impID}] = billingNotice{url: url, expires: now.Add(n.ttl)}
+}
+
+// Take returns and removes the billing notice URL of the auction impression
+func (n *BillingNotices) Take(auctionID, impID string) (string, bool) {
+	if n == nil {
+		return "", false
+	}
+	key := billingKey{auctionID: auctionID, impID: impID}
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	notice, ok := n.notices[key]
+	if !ok {
+		return "", false
+	}
+	delete(n.notices, key)
+	if n.now().After(notice.expires) {
+		return "", false
+	}
+	return notice.url, true
+}
+
+// Fire the billing notice of the rendered auction impression,
+// it returns false if there is no billing notice of the impression
+func (n *BillingNotices) Fire(ctx context.Context, auctionID, impID string) (bool, error) {
+	url, ok := n.Take(auctionID, impID)
+	if !ok {
+		return false, nil
+	}
+	wins := n.wins
+	if wins == nil {
+		wins = eventstream.WinsFromContext(ctx)
+	}
+	return true, wins.Send(ctx, url)
+}
+
+// BillingEventKey returns the auction and impression IDs of the tracking event,
+// the event is passed without the extra pointer added by the trackers
+type BillingEventKey func(event any) (auctionID, impID string, ok bool)
+
+// billingStream fires the billing notices of the impression events sent to the stream
+type billingStream struct {
+	eventstream.Stream
+	notices *BillingNotices
+	key     BillingEventKey
+}
+
+// NewBillingStream wraps the event stream to fire the billing notices of the impression events,
+// the key returns the auction and impression IDs of the event
+func NewBillingStream(stream eventstream.Stream, notices *BillingNotices, key BillingEventKey) eventstream.Stream {
+	return &billingStream{Stream: stream, notices: notices, key: key}
+}
+
+// SendEvent to the stream and fires the billing notice if it's the impression event
+func (s *billingStream) SendEvent(ctx context.Context, event any) error {
+	if err := s.Stream.SendEvent(ctx, event); err != nil {
+		return err
+	}
+	event = trackingEvent(event)
+	if ev, ok := event.(interface{ EventType() events.Type }); !ok || ev.EventType() != events.Impression {
+		return nil
+	}
+	auctionID, impID, ok := s.key(event)
+	if !ok {
+		return nil
+	}
+	_, err := s.notices.Fire(ctx, auctionID, impID)
+	return err
+}
+
+// trackingEvent returns the event sent by the pointer to the event pointer,
+// the trackers send the address of the allocated event which is the pointer itself
+func trackingEvent(event any) any {
+	val := reflect.ValueOf(event)
+	for val.Kind() == reflect.Pointer && !val.IsNil() && val.Elem().Kind() == reflect.Pointer {
+		val = val.Elem()
+	}
+	if !val.IsValid() || val.IsNil() {
+		return event
+	}
+	return val.Interface()
+}
@@ -0,0 +1,88 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/eventtraking/events"
+	"github.com/geniusrabbit/adcorelib/eventtraking/eventstream"
+)
+
+type testTrackingEvent struct {
+	Type      events.Type
+	AuctionID string
+	ImpID     string
+}
+
+func (e *testTrackingEvent) EventType() events.Type { return e.Type }
+
+type testEventStream struct {
+	eventstream.Stream
+	events []any
+}
+
+func (s *testEventStream) SendEvent(_ context.Context, event any) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *testEventStream) Send(event events.Type, _ uint8, _ adtype.Response, _ adtype.ResponseItem) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+type testPublisher struct {
+	messages []any
+}
+
+func (p *testPublisher) Publish(_ context.Context, messages ...any) error {
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+// sendTrackerEvent sends the event the same way as the pixel and action trackers of adcorelib
+func sendTrackerEvent[EventT any](ctx context.Context, stream eventstream.Stream, allocate func() EventT) error {
+	event := allocate()
+	return stream.SendEvent(ctx, &event)
+}
+
+func TestBillingStream(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		publisher = &testPublisher{}
+		notices   = NewBillingNotices(eventstream.WinNotifications(publisher), 0)
+		upstream  = &testEventStream{}
+		stream    = NewBillingStream(upstream, notices, func(event any) (string, string, bool) {
+			ev, ok := event.(*testTrackingEvent)
+			if !ok {
+				return "", "", false
+			}
+			return ev.AuctionID, ev.ImpID, true
+		})
+	)
+	notices.Add("auction1", "imp1", "https://example.com/burl")
+
+	// The view event doesn't fire the billing notice
+	err := sendTrackerEvent(ctx, stream, func() *testTrackingEvent {
+		return &testTrackingEvent{Type: events.View, AuctionID: "auction1", ImpID: "imp1"}
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, publisher.messages)
+
+	err = sendTrackerEvent(ctx, stream, func() *testTrackingEvent {
+		return &testTrackingEvent{Type: events.Impression, AuctionID: "auction1", ImpID: "imp1"}
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, publisher.messages, 1) {
+		assert.Equal(t, "https://example.com/burl", publisher.messages[0].(*adtype.WinEvent).URL)
+	}
+	assert.Len(t, upstream.events, 2)
+
+	// The notice is fired once
+	err = stream.SendEvent(ctx, &testTrackingEvent{Type: events.Impression, AuctionID: "auction1", ImpID: "imp1"})
+	assert.NoError(t, err)
+	assert.Len(t, publisher.messages, 1)
+}
@@ -0,0 +1,150 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// BlockedAdvertisers of the placement: the advertisement categories (bcat),
+// the advertiser domains (badv) and the application bundles (bapp)
+type BlockedAdvertisers struct {
+	Categories []string `json:"categories,omitempty"`
+	Domains    []string `json:"domains,omitempty"`
+	Apps       []string `json:"apps,omitempty"`
+}
+
+// BlockedAdvertisersProvider returns the blocked advertisers of the placement
+type BlockedAdvertisersProvider interface {
+	BlockedAdvertisers(imp *adtype.Impression) *BlockedAdvertisers
+}
+
+// BlockedAdvertisersProviderFunc wrapper of the function to the BlockedAdvertisersProvider interface
+type BlockedAdvertisersProviderFunc func(imp *adtype.Impression) *BlockedAdvertisers
+
+// BlockedAdvertisers returns the blocked advertisers of the placement
+func (f BlockedAdvertisersProviderFunc) BlockedAdvertisers(imp *adtype.Impression) *BlockedAdvertisers {
+	return f(imp)
+}
+
+// IsEmpty returns true if nothing is blocked
+func (b *BlockedAdvertisers) IsEmpty() bool {
+	return b == nil || len(b.Categories)+len(b.Domains)+len(b.Apps) == 0
+}
+
+// merge returns the union of the blocked advertisers
+func (b *BlockedAdvertisers) merge(other *BlockedAdvertisers) *BlockedAdvertisers {
+	switch {
+	case other.IsEmpty():
+		return b
+	case b.IsEmpty():
+		return other
+	}
+	return &BlockedAdvertisers{
+		Categories: mergeBlocked(b.Categories, other.Categories),
+		Domains:    mergeBlocked(b.Domains, other.Domains),
+		Apps:       mergeBlocked(b.Apps, other.Apps),
+	}
+}
+
+func mergeBlocked(list, values []string) []string {
+	if len(values) == 0 {
+		return list
+	}
+	res := slices.Clone(list)
+	for _, val := range values {
+		if !slices.Contains(res, val) {
+			res = append(res, val)
+		}
+	}
+	return res
+}
+
+// sourceBlockedAdvertisers returns the blocked advertisers of the source config (`blocked_categories`,
+// `blocked_adomains`, `blocked_apps`) merged with the driver options
+func sourceBlockedAdvertisers(source *admodels.RTBSource, opts *DriverOptions) *BlockedAdvertisers {
+	var cfg BlockedAdvertisers
+	_ = sourceConfigValue(source, sourceConfigBlockedCategories, &cfg.Categories)
+	_ = sourceConfigValue(source, sourceConfigBlockedAdvDomains, &cfg.Domains)
+	_ = sourceConfigValue(source, sourceConfigBlockedApps, &cfg.Apps)
+	for i, domain := range cfg.Domains {
+		cfg.Domains[i] = strings.ToLower(strings.TrimSpace(domain))
+	}
+	defaults := &BlockedAdvertisers{
+		Categories: opts.BlockedCategories,
+		Domains:    opts.BlockedAdvDomains,
+		Apps:       opts.BlockedApps,
+	}
+	if blocked := defaults.merge(&cfg); !blocked.IsEmpty() {
+		return blocked
+	}
+	return nil
+}
+
+// blockedAdvertisersResolver returns the blocked advertisers of the placement
+// merged with the defaults of the source
+func blockedAdvertisersResolver(defaults *BlockedAdvertisers, provider BlockedAdvertisersProvider) func(imp *adtype.Impression) *BlockedAdvertisers {
+	if provider == nil && defaults.IsEmpty() {
+		return nil
+	}
+	return func(imp *adtype.Impression) *BlockedAdvertisers {
+		if provider == nil || imp == nil {
+			return defaults
+		}
+		return defaults.merge(provider.BlockedAdvertisers(imp))
+	}
+}
+
+// blockedAdvertisers returns the union of the blocked advertisers of the request placements
+func (opts *BidRequestRTBOptions) blockedAdvertisers(req adtype.BidRequester) *BlockedAdvertisers {
+	if opts.BlockedAdvertisers == nil {
+		return nil
+	}
+	var blocked *BlockedAdvertisers
+	for _, imp := range req.Impressions() {
+		if opts.impAllowed(imp) {
+			blocked = blocked.merge(opts.BlockedAdvertisers(imp))
+		}
+	}
+	if blocked == nil {
+		blocked = opts.BlockedAdvertisers(nil)
+	}
+	return blocked
+}
+
+// filterBlockedAdvertiserBids removes the bids with the categories, the advertiser domains
+// or the application bundles blocked by the placement of the bid
+func filterBlockedAdvertiserBids(request adtype.BidRequester, bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) {
+	if opts.BlockedAdvertisers == nil {
+		return
+	}
+	codec := adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+	filterBids(bidResp, func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		imp, _ := codec.Decode(request, bid.ImpID)
+		blocked := opts.BlockedAdvertisers(imp)
+		if blocked.IsEmpty() {
+			return true
+		}
+		if isBidCategoryBlocked(bid, blocked.Categories, opts.CategoryTaxonomy) {
+			rejections.Add(seat, bid, adresponse.RejectionBlockedCategory, "")
+			return false
+		}
+		for _, domain := range bid.AdvDomain {
+			if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" && hostMatches(domain, blocked.Domains) {
+				rejections.Add(seat, bid, adresponse.RejectionBlockedAdv, domain)
+				return false
+			}
+		}
+		if bid.Bundle != "" && slices.Contains(blocked.Apps, bid.Bundle) {
+			rejections.Add(seat, bid, adresponse.RejectionBlockedAdv, bid.Bundle)
+			return false
+		}
+		return true
+	})
+}
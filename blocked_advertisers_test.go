@@ -0,0 +1,97 @@
+package adsourceopenrtb
+
+import (
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// testBlockedAdvertisers returns the provider of the blocked advertisers of the placements by the codename
+func testBlockedAdvertisers(blocked map[string]*BlockedAdvertisers) BlockedAdvertisersProvider {
+	return BlockedAdvertisersProviderFunc(func(imp *adtype.Impression) *BlockedAdvertisers {
+		return blocked[imp.Target.Codename()]
+	})
+}
+
+func TestBlockedAdvertisersMerge(t *testing.T) {
+	var (
+		empty *BlockedAdvertisers
+		a     = &BlockedAdvertisers{Categories: []string{"IAB25"}, Domains: []string{"a.com"}}
+		b     = &BlockedAdvertisers{Domains: []string{"b.com", "a.com"}, Apps: []string{"com.b"}}
+	)
+	assert.True(t, empty.IsEmpty())
+	assert.True(t, (&BlockedAdvertisers{}).IsEmpty())
+	assert.Same(t, a, empty.merge(a))
+	assert.Same(t, a, a.merge(&BlockedAdvertisers{}))
+	assert.Equal(t, &BlockedAdvertisers{
+		Categories: []string{"IAB25"},
+		Domains:    []string{"a.com", "b.com"},
+		Apps:       []string{"com.b"},
+	}, a.merge(b))
+	assert.Equal(t, []string{"a.com"}, a.Domains, "the merged lists are not changed")
+}
+
+func TestSourceBlockedAdvertisers(t *testing.T) {
+	assert.Nil(t, sourceBlockedAdvertisers(&admodels.RTBSource{}, &DriverOptions{}))
+	assert.Nil(t, blockedAdvertisersResolver(nil, nil))
+
+	d := newTestDriver(t, nil,
+		WithBlockedCategories("IAB25"), WithBlockedAdvDomains("bad.com"), WithBlockedApps("com.bad"),
+		testSourceConfig(map[string]any{
+			"blocked_categories": []string{"IAB26"},
+			"blocked_adomains":   []string{" Worse.COM "},
+			"blocked_apps":       []string{"com.worse"},
+		}),
+		WithBlockedAdvertisersProvider(testBlockedAdvertisers(map[string]*BlockedAdvertisers{
+			"zone1": {Domains: []string{"evil.com"}},
+			"zone2": {Apps: []string{"com.evil"}},
+		})),
+	)
+	rtbRequest := testEncodeRequest(t, d, newTestZonesRequest("example.com", 1, 2))
+	assert.Equal(t, []any{"IAB25", "IAB26"}, rtbRequest["bcat"])
+	assert.Equal(t, []any{"bad.com", "worse.com", "evil.com"}, rtbRequest["badv"])
+	assert.Equal(t, []any{"com.bad", "com.worse", "com.evil"}, rtbRequest["bapp"])
+
+	// The OpenRTB 3.x requests have the same blocks
+	rtbRequestV3 := BuildRequestV3(newTestZonesRequest("example.com", 1), WithBlockedAdvertisers(d.blockedAdvertisers))
+	assert.Len(t, rtbRequestV3.BlockedCategories, 2)
+	assert.Equal(t, []string{"bad.com", "worse.com", "evil.com"}, rtbRequestV3.BlockedAdvDomains)
+	assert.Equal(t, []string{"com.bad", "com.worse"}, rtbRequestV3.BlockedApps)
+}
+
+func TestParseBlockedAdvertisers(t *testing.T) {
+	var (
+		request = newTestZonesRequest("example.com", 1)
+		impID   = BuildRequestV2(request).Imp[0].ID
+		bid     = func(id string, price float64, update func(bid *openrtb.Bid)) openrtb.Bid {
+			bid := openrtb.Bid{ID: id, ImpID: impID, Price: price, CreativeID: id, AdMarkup: "<div></div>"}
+			update(&bid)
+			return bid
+		}
+		seats = []openrtb.SeatBid{{Bid: []openrtb.Bid{
+			bid("category", 5, func(bid *openrtb.Bid) { bid.Cat = []string{"IAB3", "IAB25-3"} }),
+			bid("domain", 4, func(bid *openrtb.Bid) { bid.AdvDomain = []string{"Shop.Evil.com"} }),
+			bid("app", 3, func(bid *openrtb.Bid) { bid.Bundle = "com.bad" }),
+			bid("clean", 2, func(bid *openrtb.Bid) { bid.Cat, bid.AdvDomain = []string{"IAB3"}, []string{"notevil.com"} }),
+		}}}
+		resolver = blockedAdvertisersResolver(
+			&BlockedAdvertisers{Categories: []string{"IAB25"}, Apps: []string{"com.bad"}},
+			testBlockedAdvertisers(map[string]*BlockedAdvertisers{"zone1": {Domains: []string{"evil.com"}}}),
+		)
+	)
+	resp, err := testParseBids(t, request, seats, WithParseBlockedAdvertisers(resolver))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"clean"}, testResponseBids(resp))
+		assert.Equal(t, map[string]adresponse.RejectionReason{
+			"category": adresponse.RejectionBlockedCategory,
+			"domain":   adresponse.RejectionBlockedAdv,
+			"app":      adresponse.RejectionBlockedAdv,
+		}, testRejectionReasons(resp))
+	}
+}
@@ -0,0 +1,130 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// BlockedAttributes of the creatives (battr) per media type
+type BlockedAttributes struct {
+	Banner []int `json:"banner,omitempty"`
+	Video  []int `json:"video,omitempty"`
+	Native []int `json:"native,omitempty"`
+}
+
+// BlockedAttributesProvider returns the blocked creative attributes of the placement
+type BlockedAttributesProvider interface {
+	BlockedAttributes(imp *adtype.Impression) *BlockedAttributes
+}
+
+// BlockedAttributesProviderFunc wrapper of the function to the BlockedAttributesProvider interface
+type BlockedAttributesProviderFunc func(imp *adtype.Impression) *BlockedAttributes
+
+// BlockedAttributes returns the blocked creative attributes of the placement
+func (f BlockedAttributesProviderFunc) BlockedAttributes(imp *adtype.Impression) *BlockedAttributes {
+	return f(imp)
+}
+
+// blockedAttributesResolver returns the placement blocked attributes
+// with fallback to the default attributes of the source
+func blockedAttributesResolver(defaults *BlockedAttributes, provider BlockedAttributesProvider) func(imp *adtype.Impression) *BlockedAttributes {
+	if provider == nil && defaults == nil {
+		return nil
+	}
+	return func(imp *adtype.Impression) *BlockedAttributes {
+		if provider != nil {
+			if attrs := provider.BlockedAttributes(imp); attrs != nil {
+				return attrs
+			}
+		}
+		return defaults
+	}
+}
+
+func intsToEnum[T ~int](vals []int) []T {
+	if len(vals) == 0 {
+		return nil
+	}
+	list := make([]T, 0, len(vals))
+	for _, v := range vals {
+		list = append(list, T(v))
+	}
+	return list
+}
+
+func (attrs *BlockedAttributes) banner() []int {
+	if attrs == nil {
+		return nil
+	}
+	return attrs.Banner
+}
+
+func (attrs *BlockedAttributes) video() []int {
+	if attrs == nil {
+		return nil
+	}
+	return attrs.Video
+}
+
+func (attrs *BlockedAttributes) native() []int {
+	if attrs == nil {
+		return nil
+	}
+	return attrs.Native
+}
+
+// bid returns the blocked attributes of the bid media type, the media type is defined by
+// the bid markup type (mtype) or by the matched format of the impression otherwise
+func (attrs *BlockedAttributes) bid(bid *openrtb.Bid, format *types.Format) []int {
+	switch adresponse.BidMarkupType(bid) {
+	case adresponse.MarkupTypeBanner:
+		return attrs.banner()
+	case adresponse.MarkupTypeVideo:
+		return attrs.video()
+	case adresponse.MarkupTypeNative:
+		return attrs.native()
+	}
+	switch {
+	case format.IsVideo() || adresponse.IsVASTMarkup(bid.AdMarkup):
+		return attrs.video()
+	case format.IsNative():
+		return attrs.native()
+	}
+	return attrs.banner()
+}
+
+// filterBlockedAttributeBids removes the bids with the creative attributes blocked by the placements
+func filterBlockedAttributeBids(request adtype.BidRequester, bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) {
+	if opts.BlockedAttributes == nil {
+		return
+	}
+	codec := adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+	filterBids(bidResp, func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		if len(bid.Attr) == 0 {
+			return true
+		}
+		imp, format := codec.Decode(request, bid.ImpID)
+		if imp == nil || format == nil {
+			return true
+		}
+		blocked := opts.BlockedAttributes(imp).bid(bid, format)
+		for _, attr := range bid.Attr {
+			if !slices.Contains(blocked, attr) {
+				continue
+			}
+			if opts.BlockedAttributeObserver != nil {
+				opts.BlockedAttributeObserver(attr)
+			}
+			rejections.Add(seat, bid, adresponse.RejectionBlockedAttr, strconv.Itoa(attr))
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,88 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+func TestBlockedAttributesRequest(t *testing.T) {
+	var codename string
+	provider := BlockedAttributesProviderFunc(func(imp *adtype.Impression) *BlockedAttributes {
+		if imp.Target.Codename() != codename {
+			return nil
+		}
+		return &BlockedAttributes{Banner: []int{6}}
+	})
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {},
+		WithSourceBlockedAttributes(BlockedAttributes{Banner: []int{1, 2}, Native: []int{3}}),
+		WithBlockedAttributesProvider(provider))
+
+	// The default attributes of the source are sent per media type
+	rtbRequest := testEncodeRequest(t, d, newTestRequest(context.Background(), "banner_300x250", "native"))
+	if imps, _ := rtbRequest["imp"].([]any); assert.Len(t, imps, 2) {
+		assert.Equal(t, []any{1., 2.}, imps[0].(map[string]any)["banner"].(map[string]any)["battr"])
+		assert.Equal(t, []any{3.}, imps[1].(map[string]any)["native"].(map[string]any)["battr"])
+	}
+
+	// The attributes of the placement replace the defaults of the source
+	codename = "zone1"
+	rtbRequest = testEncodeRequest(t, d, newTestRequest(context.Background(), "banner_300x250", "native"))
+	if imps, _ := rtbRequest["imp"].([]any); assert.Len(t, imps, 2) {
+		assert.Equal(t, []any{6.}, imps[0].(map[string]any)["banner"].(map[string]any)["battr"])
+		assert.NotContains(t, imps[1].(map[string]any)["native"], "battr")
+	}
+}
+
+func TestBlockedAttributesBids(t *testing.T) {
+	var (
+		request  = newTestRequest(context.Background(), "banner_300x250")
+		impID    = BuildRequestV2(request).Imp[0].ID
+		observed []int
+		seats    = []openrtb.SeatBid{{Bid: []openrtb.Bid{
+			{ID: "banner", ImpID: impID, Price: 4, CreativeID: "c1", AdMarkup: "<div></div>", Attr: []int{1, 6}},
+			{ID: "video", ImpID: impID, Price: 3, CreativeID: "c2", AdMarkup: testVASTMarkup, Attr: []int{14}},
+			{ID: "native_attr", ImpID: impID, Price: 2, CreativeID: "c3", AdMarkup: "<div></div>", Attr: []int{14}},
+		}}}
+		blocked = func(imp *adtype.Impression) *BlockedAttributes {
+			return &BlockedAttributes{Banner: []int{6}, Video: []int{14}}
+		}
+	)
+	resp, err := testParseBids(t, request, seats, WithParseBlockedAttributes(blocked, func(attr int) {
+		observed = append(observed, attr)
+	}))
+	if assert.NoError(t, err) {
+		// The attributes are checked per media type, the VAST markup is checked by the video attributes
+		assert.Equal(t, []string{"native_attr"}, testResponseBids(resp))
+		assert.Equal(t, map[string]adresponse.RejectionReason{
+			"banner": adresponse.RejectionBlockedAttr,
+			"video":  adresponse.RejectionBlockedAttr,
+		}, testRejectionReasons(resp))
+		assert.Equal(t, []int{6, 14}, observed)
+	}
+}
+
+func TestBlockedAttributesMetric(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var response openrtb.BidResponse
+		_ = json.Unmarshal(testBidResponse(t, data, 1), &response)
+		response.SeatBid[0].Bid[0].Attr = []int{6}
+		_ = json.NewEncoder(w).Encode(response)
+	}, WithSourceBlockedAttributes(BlockedAttributes{Banner: []int{6}}), testMetricsRegistry(registry))
+
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.Empty(t, resp.Ads())
+	assert.Equal(t, map[string]float64{"6": 1}, testCounters(t, registry, "adsource_bid_blocked_attr_total", "attr"))
+}
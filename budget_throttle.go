@@ -0,0 +1,102 @@
+package adsourceopenrtb
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	budgetThrottleWindow       = 256
+	budgetThrottleRecalcEvery  = 32
+	budgetThrottleMinAdmitRate = 0.05
+	defaultBudgetPercentile    = 0.95
+)
+
+// budgetThrottle tracks the latency and the response size of the source
+// and reduces the admission rate of the requests proportionally to the
+// exceeding of the budgets by the measured percentile
+type budgetThrottle struct {
+	mx        sync.Mutex
+	latencies []int64
+	sizes     []int64
+	pos       int
+	count     int
+
+	latencyBudget time.Duration
+	sizeBudget    int64
+	percentile    float64
+
+	// admitRate bits of the float64 value from 0 to 1
+	admitRate atomic.Uint64
+}
+
+func newBudgetThrottle(latencyBudget time.Duration, sizeBudget int64, percentile float64) *budgetThrottle {
+	if percentile <= 0 || percentile > 1 {
+		percentile = defaultBudgetPercentile
+	}
+	t := &budgetThrottle{
+		latencies:     make([]int64, budgetThrottleWindow),
+		sizes:         make([]int64, budgetThrottleWindow),
+		latencyBudget: latencyBudget,
+		sizeBudget:    sizeBudget,
+		percentile:    percentile,
+	}
+	t.admitRate.Store(math.Float64bits(1))
+	return t
+}
+
+// Admit returns true if the request can be sent to the source
+func (t *budgetThrottle) Admit() bool {
+	if t == nil {
+		return true
+	}
+	rate := math.Float64frombits(t.admitRate.Load())
+	return rate >= 1 || rand.Float64() < rate
+}
+
+// Record the latency and the response size of the request
+func (t *budgetThrottle) Record(latency time.Duration, size int64) {
+	if t == nil {
+		return
+	}
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.latencies[t.pos] = latency.Nanoseconds()
+	t.sizes[t.pos] = size
+	t.pos = (t.pos + 1) % len(t.latencies)
+	t.count++
+
+	if t.count%budgetThrottleRecalcEvery == 0 {
+		t.admitRate.Store(math.Float64bits(t.calcAdmitRate()))
+	}
+}
+
+func (t *budgetThrottle) calcAdmitRate() float64 {
+	n := min(t.count, len(t.latencies))
+	rate := 1.
+	if t.latencyBudget > 0 {
+		if p := percentileOf(t.latencies[:n], t.percentile); p > t.latencyBudget.Nanoseconds() {
+			rate = min(rate, float64(t.latencyBudget.Nanoseconds())/float64(p))
+		}
+	}
+	if t.sizeBudget > 0 {
+		if p := percentileOf(t.sizes[:n], t.percentile); p > t.sizeBudget {
+			rate = min(rate, float64(t.sizeBudget)/float64(p))
+		}
+	}
+	return max(rate, budgetThrottleMinAdmitRate)
+}
+
+func percentileOf(values []int64, percentile float64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted[min(int(float64(len(sorted))*percentile), len(sorted)-1)]
+}
@@ -0,0 +1,74 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetThrottleAdmitRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		latency time.Duration
+		size    int64
+		rate    float64
+	}{
+		{name: "within_budget", latency: 50 * time.Millisecond, size: 500, rate: 1},
+		{name: "latency_exceeded", latency: 400 * time.Millisecond, size: 500, rate: 0.25},
+		{name: "size_exceeded", latency: 50 * time.Millisecond, size: 2000, rate: 0.5},
+		{name: "both_exceeded", latency: 200 * time.Millisecond, size: 4000, rate: 0.25},
+		{name: "minimal_rate", latency: 10 * time.Second, size: 500, rate: budgetThrottleMinAdmitRate},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			throttle := newBudgetThrottle(100*time.Millisecond, 1000, 0)
+			for range budgetThrottleRecalcEvery {
+				throttle.Record(test.latency, test.size)
+			}
+			assert.InDelta(t, test.rate, math.Float64frombits(throttle.admitRate.Load()), 1e-9)
+		})
+	}
+}
+
+func TestBudgetThrottleRecalc(t *testing.T) {
+	throttle := newBudgetThrottle(100*time.Millisecond, 0, 0.5)
+
+	// The rate is recalculated once per the batch of the measurements
+	for range budgetThrottleRecalcEvery - 1 {
+		throttle.Record(time.Second, 0)
+	}
+	assert.True(t, throttle.Admit())
+	throttle.Record(time.Second, 0)
+	assert.InDelta(t, 0.1, math.Float64frombits(throttle.admitRate.Load()), 1e-9)
+
+	// The median of the window returns into the budget
+	for range budgetThrottleRecalcEvery * 2 {
+		throttle.Record(10*time.Millisecond, 0)
+	}
+	assert.InDelta(t, 1, math.Float64frombits(throttle.admitRate.Load()), 1e-9)
+
+	var disabled *budgetThrottle
+	disabled.Record(time.Second, 0)
+	assert.True(t, disabled.Admit())
+}
+
+func TestBudgetThrottleSkip(t *testing.T) {
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {},
+		WithBudgetThrottle(0, 0, 0))
+	request := newTestRequest(context.Background(), "banner_300x250")
+
+	// The latency budget is the source timeout by default
+	assert.Equal(t, time.Second, d.budget.latencyBudget)
+	ok, reason := d.TestWithReason(request)
+	assert.True(t, ok)
+	assert.Equal(t, SkipReasonNone, reason)
+
+	d.budget.admitRate.Store(math.Float64bits(0))
+	ok, reason = d.TestWithReason(request)
+	assert.False(t, ok)
+	assert.Equal(t, SkipReasonBudgetThrottled, reason)
+}
@@ -0,0 +1,72 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+)
+
+// Capabilities of the driver for the source: the media types, the protocol versions,
+// the native versions, the request encodings and the privacy frameworks
+type Capabilities struct {
+	MediaTypes       []string `json:"media_types"`
+	ProtocolVersions []string `json:"protocol_versions"`
+	NativeVersions   []string `json:"native_versions"`
+	Transports       []string `json:"transports"`
+	Privacy          []string `json:"privacy"`
+}
+
+// Transports of the requests
+const (
+	TransportJSON     = "json"
+	TransportProtobuf = "protobuf"
+	TransportXML      = "xml"
+)
+
+// Privacy frameworks of the requests
+const (
+	PrivacyGDPR = "gdpr"
+	PrivacyTCF  = "tcf"
+	PrivacyGPP  = "gpp"
+)
+
+// compiledCapabilities of the driver independent of the source
+var compiledCapabilities = Capabilities{
+	MediaTypes:       []string{"banner", "video", "native", "direct"},
+	ProtocolVersions: protocolVersions,
+	NativeVersions:   []string{"1.1", "1.2"},
+	Transports:       []string{TransportJSON, TransportProtobuf, TransportXML},
+	Privacy:          []string{PrivacyGDPR, PrivacyTCF, PrivacyGPP},
+}
+
+// Capabilities of the driver derived from the source protocol, the media types, the request type and the driver options
+func (d *driver) Capabilities() Capabilities {
+	versions := d.protocol.Versions()
+	transports := []string{TransportJSON}
+	switch {
+	case d.source.RequestType == RequestTypeXML:
+		transports = []string{TransportXML}
+	case d.source.RequestType == RequestTypeProtobuff && slices.ContainsFunc(versions, d.isProtobufRequest):
+		// The protobuf encoding is available for the OpenRTB 2.x versions only
+		transports = []string{TransportProtobuf}
+		if slices.Contains(versions, ProtocolVersion30) {
+			transports = append(transports, TransportJSON)
+		}
+	}
+	privacy := []string{PrivacyGDPR, PrivacyTCF}
+	if slices.Contains(versions, ProtocolVersion26) {
+		privacy = append(privacy, PrivacyGPP)
+	}
+	mediaTypes := slices.Clone(compiledCapabilities.MediaTypes)
+	if len(d.mediaTypes) > 0 {
+		mediaTypes = mediaTypes[:0]
+		for _, formatType := range d.mediaTypes {
+			mediaTypes = append(mediaTypes, formatType.Name())
+		}
+	}
+	return Capabilities{
+		MediaTypes:       mediaTypes,
+		ProtocolVersions: versions,
+		NativeVersions:   slices.Clone(compiledCapabilities.NativeVersions),
+		Transports:       transports,
+		Privacy:          privacy,
+	}
+}
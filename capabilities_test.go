@@ -0,0 +1,67 @@
+package adsourceopenrtb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	d := newTestDriver(t, nil)
+	assert.Equal(t, Capabilities{
+		MediaTypes:       []string{"banner", "video", "native", "direct"},
+		ProtocolVersions: []string{ProtocolVersion25},
+		NativeVersions:   []string{"1.1", "1.2"},
+		Transports:       []string{TransportJSON},
+		Privacy:          []string{PrivacyGDPR, PrivacyTCF},
+	}, d.Capabilities())
+
+	// The negotiation can use all versions up to the maximal one
+	d = newTestDriver(t, nil, WithMaxProtocolVersion(ProtocolVersion26), WithProtocolNegotiation(time.Minute))
+	caps := d.Capabilities()
+	assert.Equal(t, []string{ProtocolVersion25, ProtocolVersion26}, caps.ProtocolVersions)
+	assert.Equal(t, []string{PrivacyGDPR, PrivacyTCF, PrivacyGPP}, caps.Privacy)
+
+	// The capabilities don't share the compiled lists
+	caps.MediaTypes[0] = "audio"
+	assert.Equal(t, "banner", compiledCapabilities.MediaTypes[0])
+}
+
+func TestCapabilitiesTransports(t *testing.T) {
+	requestType := func(protocol string, requestType admodels.RTBRequestType) testSourceOption {
+		return testSourceOption(func(source *admodels.RTBSource) {
+			source.Protocol, source.RequestType = protocol, requestType
+		})
+	}
+	tests := []struct {
+		name       string
+		options    []any
+		transports []string
+	}{
+		{name: "xml", options: []any{requestType("openrtb", RequestTypeXML)}, transports: []string{TransportXML}},
+		{name: "protobuf", options: []any{requestType("openrtb", RequestTypeProtobuff)}, transports: []string{TransportProtobuf}},
+		{
+			// OpenRTB 3.0 has no protobuf encoding, the JSON is used for it
+			name:       "protobuf_negotiation",
+			options:    []any{requestType("openrtb3", RequestTypeProtobuff), WithProtocolNegotiation(time.Minute)},
+			transports: []string{TransportProtobuf, TransportJSON},
+		},
+		{name: "protobuf_v3", options: []any{requestType("openrtb3", RequestTypeProtobuff)}, transports: []string{TransportJSON}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.transports, newTestDriver(t, nil, test.options...).Capabilities().Transports)
+		})
+	}
+}
+
+func TestFactoryInfoTransports(t *testing.T) {
+	info := (&factory{}).Info()
+	if assert.Len(t, info.Options, 1) {
+		assert.Equal(t, "request_type", info.Options[0].Name)
+		assert.Len(t, info.Options[0].Select, len(compiledCapabilities.Transports))
+	}
+	assert.Equal(t, compiledCapabilities.NativeVersions, info.Subprotocols[1].Versions)
+}
@@ -0,0 +1,55 @@
+package adsourceopenrtb
+
+import (
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// ClickBrowser type of the browser opened on the click in the application (imp.clickbrowser)
+type ClickBrowser int
+
+// Click browser types
+const (
+	// ClickBrowserUndefined doesn't send the click browser type
+	ClickBrowserUndefined ClickBrowser = iota
+	// ClickBrowserEmbedded opens the click in the embedded (in-app) browser
+	ClickBrowserEmbedded
+	// ClickBrowserNative opens the click in the native (system) browser
+	ClickBrowserNative
+)
+
+// Value of the `imp.clickbrowser` field (0 - embedded, 1 - native)
+func (b ClickBrowser) Value() int {
+	if b == ClickBrowserNative {
+		return 1
+	}
+	return 0
+}
+
+// ClickBrowserProvider returns the click browser type of the placement
+type ClickBrowserProvider interface {
+	ClickBrowser(imp *adtype.Impression) ClickBrowser
+}
+
+// ClickBrowserProviderFunc wrapper of the function to the ClickBrowserProvider interface
+type ClickBrowserProviderFunc func(imp *adtype.Impression) ClickBrowser
+
+// ClickBrowser returns the click browser type of the placement
+func (f ClickBrowserProviderFunc) ClickBrowser(imp *adtype.Impression) ClickBrowser {
+	return f(imp)
+}
+
+// clickBrowserResolver returns the placement click browser type
+// with fallback to the default type of the source
+func clickBrowserResolver(defaultBrowser ClickBrowser, provider ClickBrowserProvider) func(imp *adtype.Impression) ClickBrowser {
+	if provider == nil && defaultBrowser == ClickBrowserUndefined {
+		return nil
+	}
+	return func(imp *adtype.Impression) ClickBrowser {
+		if provider != nil {
+			if browser := provider.ClickBrowser(imp); browser != ClickBrowserUndefined {
+				return browser
+			}
+		}
+		return defaultBrowser
+	}
+}
@@ -0,0 +1,46 @@
+package adsourceopenrtb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/net/httpclient"
+)
+
+const encodingGzip = "gzip"
+
+// sourceGzip returns true if the requests of the source are compressed by gzip
+// by the source config (`gzip`) or the driver options
+func sourceGzip(source *admodels.RTBSource, opts *DriverOptions) bool {
+	var enabled bool
+	if sourceConfigValue(source, sourceConfigGzip, &enabled) {
+		return enabled
+	}
+	return opts.Gzip
+}
+
+// gzipRequest compresses the encoded request body
+func gzipRequest(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// responseBody returns the reader of the response body decompressed by the content encoding,
+// the gzip responses are decompressed independently of the request compression
+// if the HTTP client passes the encoded body through
+func responseBody(resp httpclient.Response) (io.Reader, error) {
+	if !strings.EqualFold(strings.TrimSpace(responseHeader(resp, "Content-Encoding")), encodingGzip) {
+		return resp.Body(), nil
+	}
+	return gzip.NewReader(resp.Body())
+}
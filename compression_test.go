@@ -0,0 +1,38 @@
+package adsourceopenrtb
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzipRoundTrip(t *testing.T) {
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, encodingGzip, r.Header.Get("Content-Encoding"))
+		zr, err := gzip.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		data, err := io.ReadAll(zr)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"id":"auction1"`)
+
+		// The transport requests the compressed response by itself
+		assert.Contains(t, r.Header.Get("Accept-Encoding"), encodingGzip)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", encodingGzip)
+		zw := gzip.NewWriter(w)
+		_, _ = zw.Write(testBidResponse(t, data, 1.5))
+		_ = zw.Close()
+	}, WithGzip())
+
+	response := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	if assert.NotNil(t, response) {
+		assert.NoError(t, response.Error())
+		assert.Len(t, response.Ads(), 1)
+	}
+}
@@ -0,0 +1,107 @@
+package adsourceopenrtb
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/bsm/openrtb"
+	"go.uber.org/zap"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// CorrelationMode of the impression correlation token echo check
+type CorrelationMode int
+
+// Correlation modes
+const (
+	// CorrelationOff disables the correlation token
+	CorrelationOff CorrelationMode = iota
+	// CorrelationReport reports the bids without the echoed token but keeps them
+	CorrelationReport
+	// CorrelationDrop reports and removes the bids without the echoed token
+	CorrelationDrop
+)
+
+// correlationExtKey of the correlation token in the `imp.ext` and the `bid.ext`
+const correlationExtKey = "corr"
+
+// CorrelationToken of the impression is the truncated HMAC-SHA256 of the auction
+// and the impression IDs, so the bids cached by the partner for another auction don't match
+func CorrelationToken(key []byte, auctionID, impID string) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(auctionID))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(impID))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// correlationToken returns the correlation token of the impression or empty string if disabled
+func (opts *BidRequestRTBOptions) correlationToken(req adtype.BidRequester, imp *adtype.Impression) string {
+	if len(opts.CorrelationKey) == 0 || req == nil || imp == nil {
+		return ""
+	}
+	return CorrelationToken(opts.CorrelationKey, req.ID(), imp.ID)
+}
+
+// isBidCorrelated returns true if the bid echoes the correlation token or the tag ID in the `bid.ext`
+func isBidCorrelated(bid *openrtb.Bid, token, tagID string) bool {
+	if len(bid.Ext) == 0 {
+		return false
+	}
+	var ext struct {
+		Token string `json:"corr"`
+		TagID string `json:"tagid"`
+	}
+	if err := json.Unmarshal(bid.Ext, &ext); err != nil {
+		return false
+	}
+	return (ext.Token != "" && hmac.Equal([]byte(ext.Token), []byte(token))) ||
+		(ext.TagID != "" && ext.TagID == tagID)
+}
+
+// correlateBids checks the correlation token echo of the response bids,
+// reports the mismatched bids and removes them in the drop mode
+func correlateBids(request adtype.BidRequester, bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) {
+	if opts.CorrelationMode == CorrelationOff || len(opts.CorrelationKey) == 0 {
+		return
+	}
+	codec := adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+	filterBids(bidResp, func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		imp, _ := codec.Decode(request, bid.ImpID)
+		if imp == nil {
+			// The unknown impressions are rejected by the response preparation
+			return true
+		}
+		token := CorrelationToken(opts.CorrelationKey, request.ID(), imp.ID)
+		matched := isBidCorrelated(bid, token, imp.Target.Codename())
+		if opts.CorrelationObserver != nil {
+			opts.CorrelationObserver(matched)
+		}
+		if matched {
+			return true
+		}
+		opts.logger(request.Context()).Warn("bid doesn't echo the impression correlation token",
+			zap.String("trace_id", RequestTraceID(request)),
+			zap.Uint64("source_id", opts.SourceID),
+			zap.String("bid_id", bid.ID),
+			zap.String("imp_id", bid.ImpID))
+		if opts.CorrelationMode != CorrelationDrop {
+			return true
+		}
+		rejections.Add(seat, bid, adresponse.RejectionCorrelation, "")
+		return false
+	})
+}
+
+// correlationResult label of the correlation metric
+func correlationResult(matched bool) string {
+	if matched {
+		return "matched"
+	}
+	return "mismatched"
+}
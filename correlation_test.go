@@ -0,0 +1,98 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+func TestCorrelationToken(t *testing.T) {
+	key := []byte("secret")
+	token := CorrelationToken(key, "auction1", "imp1")
+	assert.Regexp(t, "^[0-9a-f]{16}$", token)
+	assert.Equal(t, token, CorrelationToken(key, "auction1", "imp1"))
+	assert.NotEqual(t, token, CorrelationToken(key, "auction2", "imp1"))
+	assert.NotEqual(t, token, CorrelationToken(key, "auction1", "imp2"))
+	assert.NotEqual(t, token, CorrelationToken([]byte("other"), "auction1", "imp1"))
+
+	// The token is sent in the impression extension
+	request := newTestRequest(context.Background(), "banner_300x250")
+	imp := testEncodeRequest(t, newTestDriver(t, nil, WithCorrelation(CorrelationDrop, key)), request)["imp"].([]any)[0].(map[string]any)
+	assert.Equal(t, token, imp["ext"].(map[string]any)["corr"])
+
+	imp = testEncodeRequest(t, newTestDriver(t, nil), request)["imp"].([]any)[0].(map[string]any)
+	ext, _ := imp["ext"].(map[string]any)
+	assert.NotContains(t, ext, "corr")
+}
+
+func TestCorrelateBids(t *testing.T) {
+	var (
+		key     = []byte("secret")
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		token   = CorrelationToken(key, "auction1", "imp1")
+		bid     = func(id string, price float64, ext string) openrtb.Bid {
+			return openrtb.Bid{ID: id, ImpID: impID, Price: price, CreativeID: id, AdMarkup: "<div></div>",
+				Ext: openrtb.Extension(ext)}
+		}
+		seats = []openrtb.SeatBid{{Bid: []openrtb.Bid{
+			bid("token", 1, `{"corr":"`+token+`"}`),
+			bid("tagid", 2, `{"tagid":"zone1"}`),
+			bid("no_ext", 3, ``),
+			bid("stale", 4, `{"corr":"0000000000000000","tagid":"zone2"}`),
+		}}}
+	)
+	tests := []struct {
+		name     string
+		mode     CorrelationMode
+		key      []byte
+		bids     []string
+		rejected map[string]adresponse.RejectionReason
+		results  map[bool]int
+	}{
+		{name: "off", mode: CorrelationOff, key: key, bids: []string{"token", "tagid", "no_ext", "stale"}},
+		{name: "no_key", mode: CorrelationDrop, bids: []string{"token", "tagid", "no_ext", "stale"}},
+		{
+			name:    "report",
+			mode:    CorrelationReport,
+			key:     key,
+			bids:    []string{"token", "tagid", "no_ext", "stale"},
+			results: map[bool]int{true: 2, false: 2},
+		},
+		{
+			name: "drop",
+			mode: CorrelationDrop,
+			key:  key,
+			bids: []string{"token", "tagid"},
+			rejected: map[string]adresponse.RejectionReason{
+				"token":  adresponse.RejectionLostAuction,
+				"no_ext": adresponse.RejectionCorrelation,
+				"stale":  adresponse.RejectionCorrelation,
+			},
+			results: map[bool]int{true: 2, false: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := map[bool]int{}
+			resp, err := testParseBids(t, request, seats, WithParseCorrelation(tt.mode, tt.key, func(matched bool) {
+				results[matched]++
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.bids, testResponseBids(resp))
+			if tt.rejected != nil {
+				assert.Equal(t, tt.rejected, testRejectionReasons(resp))
+			}
+			if tt.results == nil {
+				tt.results = map[bool]int{}
+			}
+			assert.Equal(t, tt.results, results)
+		})
+	}
+}
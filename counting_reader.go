@@ -0,0 +1,15 @@
+package adsourceopenrtb
+
+import "io"
+
+// countingReader counts the number of bytes read from the reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,148 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+)
+
+// SystemCurrency of the prices inside of the system
+const SystemCurrency = "USD"
+
+// ExchangeRateProvider returns the exchange rate to convert amount
+// of one currency into another one (amount in `to` = amount in `from` * rate)
+type ExchangeRateProvider interface {
+	ExchangeRate(from, to string) (float64, bool)
+}
+
+// ExchangeRateProviderFunc wrapper of the function to the ExchangeRateProvider interface
+type ExchangeRateProviderFunc func(from, to string) (float64, bool)
+
+// ExchangeRate returns the exchange rate from one currency into another one
+func (f ExchangeRateProviderFunc) ExchangeRate(from, to string) (float64, bool) {
+	return f(from, to)
+}
+
+// sourceCurrency returns the currency of the source bids
+func (d *driver) sourceCurrency() string {
+	if d.opts.Currency == "" {
+		return SystemCurrency
+	}
+	return strings.ToUpper(d.opts.Currency)
+}
+
+// sourceBidCurrency returns the currency of the source bids and floors
+// from the source config (`currency`) which has priority over the driver options
+func sourceBidCurrency(source *admodels.RTBSource, opts *DriverOptions) string {
+	var currency string
+	if sourceConfigValue(source, sourceConfigCurrency, &currency) && strings.TrimSpace(currency) != "" {
+		return strings.ToUpper(strings.TrimSpace(currency))
+	}
+	return opts.Currency
+}
+
+// sourceCurrencies returns the accepted currencies of the source bids from the source config
+// (`currencies`) merged with the driver options, the source currency is excluded
+func sourceCurrencies(source *admodels.RTBSource, opts *DriverOptions) []string {
+	var configured []string
+	sourceConfigValue(source, sourceConfigCurrencies, &configured)
+
+	primary := strings.ToUpper(opts.Currency)
+	if primary == "" {
+		primary = SystemCurrency
+	}
+	var currencies []string
+	for _, cur := range append(configured, opts.Currencies...) {
+		cur = strings.ToUpper(strings.TrimSpace(cur))
+		if cur != "" && cur != primary && !slices.Contains(currencies, cur) {
+			currencies = append(currencies, cur)
+		}
+	}
+	return currencies
+}
+
+// currencyRate returns the exchange rate from the system currency into the source currency.
+// Returns false if the source uses the system currency or the rate is unknown.
+func (d *driver) currencyRate() (float64, bool) {
+	cur := d.sourceCurrency()
+	if cur == SystemCurrency || d.opts.ExchangeRates == nil {
+		return 1, false
+	}
+	rate, ok := d.opts.ExchangeRates.ExchangeRate(SystemCurrency, cur)
+	if !ok || rate <= 0 {
+		return 1, false
+	}
+	return rate, true
+}
+
+// CurrencyPolicy of the response which currency is absent or differs from the requested one
+type CurrencyPolicy int
+
+// Currency policies
+const (
+	// CurrencyPolicyConvert converts the bids from the response currency
+	// (USD if the currency is absent) into the system currency
+	CurrencyPolicyConvert CurrencyPolicy = iota
+	// CurrencyPolicyReject rejects the response with the mismatched currency
+	CurrencyPolicyReject
+	// CurrencyPolicyAssume treats the bids as the prices in the requested currency,
+	// it keeps the legacy behaviour of the sources which ignore the response currency
+	CurrencyPolicyAssume
+)
+
+// responseCurrency returns the effective currency of the response bids and the exchange rate
+// from the system currency into it according to the currency policy
+func (opts *ParseOptions) responseCurrency(respCurrency string) (string, float64, error) {
+	requested := opts.currency()
+	respCurrency = strings.ToUpper(strings.TrimSpace(respCurrency))
+
+	if respCurrency == "" {
+		switch opts.CurrencyPolicy {
+		case CurrencyPolicyReject:
+			if requested != "USD" {
+				return "", 0, ErrResponseCurrencyMismatch
+			}
+			respCurrency = requested
+		case CurrencyPolicyAssume:
+			respCurrency = requested
+		default:
+			// The OpenRTB default currency is USD
+			respCurrency = "USD"
+		}
+	}
+
+	if respCurrency != requested && !slices.Contains(opts.AcceptedCurrencies, respCurrency) {
+		switch opts.CurrencyPolicy {
+		case CurrencyPolicyReject:
+			return "", 0, ErrResponseCurrencyMismatch
+		case CurrencyPolicyAssume:
+			respCurrency = requested
+		}
+	}
+
+	// The prices of the other currencies can't be booked without the exchange rate
+	if respCurrency == SystemCurrency {
+		return respCurrency, 1, nil
+	}
+	if opts.ExchangeRates == nil {
+		return "", 0, ErrResponseCurrencyMismatch
+	}
+	rate, ok := opts.ExchangeRates.ExchangeRate(SystemCurrency, respCurrency)
+	if !ok || rate <= 0 {
+		return "", 0, ErrResponseCurrencyMismatch
+	}
+	return respCurrency, rate, nil
+}
+
+// convertBidsToSystemCurrency converts prices of the bids from the source currency
+func convertBidsToSystemCurrency(bidResp *openrtb.BidResponse, rate float64) {
+	for i := range bidResp.SeatBid {
+		for j := range bidResp.SeatBid[i].Bid {
+			bidResp.SeatBid[i].Bid[j].Price /= rate
+		}
+	}
+	bidResp.Currency = SystemCurrency
+}
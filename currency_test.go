@@ -0,0 +1,200 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// testExchangeRates of the system currency: 1 USD = 0.5 EUR = 100 JPY
+var testExchangeRates = ExchangeRateProviderFunc(func(from, to string) (float64, bool) {
+	rates := map[string]float64{"USD": 1, "EUR": 0.5, "JPY": 100}
+	fromRate, fromOK := rates[from]
+	toRate, toOK := rates[to]
+	return toRate / fromRate, fromOK && toOK
+})
+
+func TestResponseCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []ParseOption
+		currency string
+		want     string
+		rate     float64
+		err      error
+	}{
+		{name: "system", currency: "USD", want: "USD", rate: 1},
+		{name: "absent_assumed", opts: []ParseOption{WithParseCurrency("EUR", testExchangeRates, CurrencyPolicyAssume)}, want: "EUR", rate: 0.5},
+		{name: "absent_converted_as_usd", opts: []ParseOption{WithParseCurrency("EUR", testExchangeRates, CurrencyPolicyConvert)}, want: "USD", rate: 1},
+		{name: "absent_rejected", opts: []ParseOption{WithParseCurrency("EUR", testExchangeRates, CurrencyPolicyReject)}, err: ErrResponseCurrencyMismatch},
+		{name: "absent_usd_rejected", opts: []ParseOption{WithParseCurrency("USD", testExchangeRates, CurrencyPolicyReject)}, want: "USD", rate: 1},
+		{name: "mismatch_assumed", opts: []ParseOption{WithParseCurrency("EUR", testExchangeRates, CurrencyPolicyAssume)}, currency: "JPY", want: "EUR", rate: 0.5},
+		{name: "mismatch_converted", opts: []ParseOption{WithParseCurrency("EUR", testExchangeRates, CurrencyPolicyConvert)}, currency: "jpy", want: "JPY", rate: 100},
+		{name: "mismatch_rejected", opts: []ParseOption{WithParseCurrency("EUR", testExchangeRates, CurrencyPolicyReject)}, currency: "JPY", err: ErrResponseCurrencyMismatch},
+		{
+			name: "accepted_currency",
+			opts: []ParseOption{
+				WithParseCurrency("EUR", testExchangeRates, CurrencyPolicyReject),
+				WithParseAcceptedCurrencies("JPY"),
+			},
+			currency: "JPY", want: "JPY", rate: 100,
+		},
+		{name: "unknown_rate", opts: []ParseOption{WithParseCurrency("EUR", testExchangeRates, CurrencyPolicyConvert)}, currency: "GBP", err: ErrResponseCurrencyMismatch},
+		{name: "absent_default_as_usd", opts: []ParseOption{WithParseCurrency("EUR", testExchangeRates, 0)}, want: "USD", rate: 1},
+		{name: "mismatch_default_converted", opts: []ParseOption{WithParseCurrency("EUR", testExchangeRates, 0)}, currency: "JPY", want: "JPY", rate: 100},
+		{name: "no_rates", opts: []ParseOption{WithParseCurrency("EUR", nil, CurrencyPolicyAssume)}, currency: "EUR", err: ErrResponseCurrencyMismatch},
+		{name: "no_rates_assumed", opts: []ParseOption{WithParseCurrency("EUR", nil, CurrencyPolicyAssume)}, err: ErrResponseCurrencyMismatch},
+		{name: "no_rates_system", opts: []ParseOption{WithParseCurrency("EUR", nil, CurrencyPolicyConvert)}, want: "USD", rate: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			currency, rate, err := newParseOptions(test.opts...).responseCurrency(test.currency)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.want, currency)
+			assert.Equal(t, test.rate, rate)
+		})
+	}
+}
+
+func TestSourceCurrency(t *testing.T) {
+	var request openrtb.BidRequest
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &request)
+		var resp openrtb.BidResponse
+		_ = json.Unmarshal(testBidResponse(t, data, 2), &resp)
+		resp.Currency = "EUR"
+		_ = json.NewEncoder(w).Encode(resp)
+	}, WithSourceCurrency("eur", testExchangeRates))
+
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	if !assert.NoError(t, resp.Error()) || !assert.Len(t, resp.Ads(), 1) {
+		return
+	}
+	assert.Equal(t, []string{"EUR"}, request.Cur)
+	if assert.Len(t, request.Imp, 1) {
+		assert.Equal(t, "EUR", request.Imp[0].BidFloorCurrency)
+	}
+
+	// The bid of 2 EUR is 4 USD in the system currency
+	bidResp := resp.(*adresponse.BidResponse)
+	assert.Equal(t, "EUR", bidResp.SourceCurrency)
+	assert.Equal(t, 0.5, bidResp.SourceCurrencyRate)
+	assert.Equal(t, SystemCurrency, bidResp.BidResponse.Currency)
+	if item, ok := resp.Ads()[0].(*adresponse.ResponseBannerBidItem); assert.True(t, ok) {
+		assert.Equal(t, 4., item.Bid.Price)
+	}
+}
+
+func TestSourceCurrencyMismatch(t *testing.T) {
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var resp openrtb.BidResponse
+		_ = json.Unmarshal(testBidResponse(t, data, 2), &resp)
+		resp.Currency = "GBP"
+		_ = json.NewEncoder(w).Encode(resp)
+	}, WithSourceCurrency("EUR", testExchangeRates), WithCurrencyPolicy(CurrencyPolicyReject))
+
+	// The rejected response is the empty one without the bids
+	request := newTestRequest(context.Background(), "banner_300x250")
+	resp := d.Bid(request)
+	assert.NoError(t, resp.Error())
+	assert.Empty(t, resp.Ads())
+
+	_, err := ParseBidResponse(request, d, []byte(`{"id":"1","cur":"GBP","seatbid":[{"bid":[{"id":"1","impid":"imp1","price":1}]}]}`),
+		WithParseCurrency("EUR", testExchangeRates, CurrencyPolicyReject))
+	assert.ErrorIs(t, err, ErrResponseCurrencyMismatch)
+}
+
+func TestCurrencyPolicy(t *testing.T) {
+	var (
+		d       = newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {})
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+	)
+	tests := []struct {
+		policy   CurrencyPolicy
+		currency string
+		price    float64
+		err      error
+	}{
+		// The absent currency is USD by the OpenRTB default
+		{policy: CurrencyPolicyConvert, price: 2},
+		{policy: CurrencyPolicyReject, err: ErrResponseCurrencyMismatch},
+		{policy: CurrencyPolicyAssume, price: 4},
+		// The mismatched currency is converted, rejected or replaced by the requested one
+		{policy: CurrencyPolicyConvert, currency: "JPY", price: 0.02},
+		{policy: CurrencyPolicyReject, currency: "JPY", err: ErrResponseCurrencyMismatch},
+		{policy: CurrencyPolicyAssume, currency: "JPY", price: 4},
+	}
+	for _, test := range tests {
+		body, _ := json.Marshal(openrtb.BidResponse{ID: "1", Currency: test.currency, SeatBid: []openrtb.SeatBid{{
+			Bid: []openrtb.Bid{{ID: "1", ImpID: impID, Price: 2, AdMarkup: "<div></div>"}},
+		}}})
+		resp, err := ParseBidResponse(request, d, body, WithParseCurrency("EUR", testExchangeRates, test.policy))
+		if test.err != nil {
+			assert.ErrorIs(t, err, test.err, "policy %d of %q", test.policy, test.currency)
+			continue
+		}
+		if assert.NoError(t, err) && assert.Len(t, resp.BidResponse.SeatBid, 1) {
+			assert.InDelta(t, test.price, resp.BidResponse.SeatBid[0].Bid[0].Price, 1e-9,
+				"policy %d of %q", test.policy, test.currency)
+		}
+	}
+}
+
+func TestSourceCurrencies(t *testing.T) {
+	var request openrtb.BidRequest
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &request)
+		var resp openrtb.BidResponse
+		_ = json.Unmarshal(testBidResponse(t, data, 200), &resp)
+		resp.Currency = "JPY"
+		_ = json.NewEncoder(w).Encode(resp)
+	}, DriverOption(func(opts *DriverOptions) {
+		// The exchange rates of the factory are kept by the source currency without the rates
+		opts.ExchangeRates = testExchangeRates
+	}), WithSourceCurrency("EUR", nil), WithSourceCurrencies("GBP", "jpy"), WithCurrencyPolicy(CurrencyPolicyReject),
+		testSourceConfig(map[string]any{"currencies": []string{" jpy", "EUR"}}))
+	assert.Equal(t, []string{"JPY", "GBP"}, d.opts.Currencies)
+
+	// The bid of 200 JPY is accepted and converted into 2 USD
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	if !assert.NoError(t, resp.Error()) || !assert.Len(t, resp.Ads(), 1) {
+		return
+	}
+	assert.Equal(t, []string{"EUR", "JPY", "GBP"}, request.Cur)
+	if assert.Len(t, request.Imp, 1) {
+		assert.Equal(t, "EUR", request.Imp[0].BidFloorCurrency)
+	}
+	if item, ok := resp.Ads()[0].(*adresponse.ResponseBannerBidItem); assert.True(t, ok) {
+		assert.Equal(t, 2., item.Bid.Price)
+	}
+}
+
+func TestSourceConfigCurrency(t *testing.T) {
+	// The floor currency is sent for the system currency as well
+	rtbRequest := testEncodeRequest(t, newTestDriver(t, nil), newTestRequest(context.Background(), "banner_300x250"))
+	assert.Equal(t, []any{"USD"}, rtbRequest["cur"])
+	if imps, _ := rtbRequest["imp"].([]any); assert.Len(t, imps, 1) {
+		assert.Equal(t, "USD", imps[0].(map[string]any)["bidfloorcur"])
+	}
+
+	// The currency of the source config has priority over the driver options
+	d := newTestDriver(t, nil, WithSourceCurrency("EUR", testExchangeRates),
+		testSourceConfig(map[string]any{"currency": " jpy "}))
+	assert.Equal(t, "JPY", d.sourceCurrency())
+	rtbRequest = testEncodeRequest(t, d, newTestRequest(context.Background(), "banner_300x250"))
+	assert.Equal(t, []any{"JPY"}, rtbRequest["cur"])
+	if imps, _ := rtbRequest["imp"].([]any); assert.Len(t, imps, 1) {
+		assert.Equal(t, "JPY", imps[0].(map[string]any)["bidfloorcur"])
+	}
+}
@@ -0,0 +1,67 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// DealProvider returns the private marketplace deals of the placement
+// or nil if the placement is sold in the open auction only
+type DealProvider interface {
+	PMP(imp *adtype.Impression) *adresponse.PMP
+}
+
+// DealProviderFunc wrapper of the function to the DealProvider interface
+type DealProviderFunc func(imp *adtype.Impression) *adresponse.PMP
+
+// PMP returns the private marketplace of the placement
+func (f DealProviderFunc) PMP(imp *adtype.Impression) *adresponse.PMP {
+	return f(imp)
+}
+
+// filterDealBids removes the bids of the private auctions without the valid deal
+// and the deal bids which don't match the deal terms (floor, seats and advertiser domains)
+func filterDealBids(request adtype.BidRequester, bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) {
+	if opts.PMP == nil {
+		return
+	}
+	codec := adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+	rejectBids(bidResp, rejections, adresponse.RejectionDeal, func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		imp, _ := codec.Decode(request, bid.ImpID)
+		if imp == nil {
+			return true
+		}
+		pmp := opts.PMP(imp)
+		if pmp == nil {
+			return true
+		}
+		deal := pmp.Deal(bid.DealID)
+		if deal == nil {
+			// The unknown deal is ignored in the open auction
+			return !pmp.Private
+		}
+		return bid.Price >= deal.BidFloor && deal.AllowsSeat(seat.Seat) &&
+			isBidDomainAllowed(bid, deal.ADomains)
+	})
+}
+
+// isBidDomainAllowed returns true if all advertiser domains of the bid are in the allowed list
+func isBidDomainAllowed(bid *openrtb.Bid, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	if len(bid.AdvDomain) == 0 {
+		return false
+	}
+	for _, domain := range bid.AdvDomain {
+		if !slices.Contains(allowed, domain) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// testDealProvider returns the same private marketplace for all placements
+func testDealProvider(pmp *adresponse.PMP) DealProvider {
+	return DealProviderFunc(func(*adtype.Impression) *adresponse.PMP { return pmp })
+}
+
+func TestDealRequest(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	pmp := &adresponse.PMP{Private: true, Deals: []*adresponse.Deal{{
+		ID:          "d1",
+		BidFloor:    2.5,
+		AuctionType: adresponse.DealAuctionFixedPrice,
+		Seats:       []string{"s1"},
+		ADomains:    []string{"example.com"},
+	}}}
+
+	imp := testEncodeRequest(t, newTestDriver(t, nil, WithDealProvider(testDealProvider(pmp))), request)["imp"].([]any)[0].(map[string]any)
+	assert.Equal(t, map[string]any{
+		"private_auction": float64(1),
+		"deals": []any{map[string]any{
+			"id":          "d1",
+			"bidfloor":    2.5,
+			"bidfloorcur": "USD",
+			"at":          float64(adresponse.DealAuctionFixedPrice),
+			"wseat":       []any{"s1"},
+			"wadomain":    []any{"example.com"},
+		}},
+	}, imp["pmp"])
+
+	// The placements without deals are sold in the open auction
+	imp = testEncodeRequest(t, newTestDriver(t, nil, WithDealProvider(testDealProvider(&adresponse.PMP{}))), request)["imp"].([]any)[0].(map[string]any)
+	assert.NotContains(t, imp, "pmp")
+}
+
+func TestFilterDealBids(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		deals   = []*adresponse.Deal{
+			{ID: "d1", BidFloor: 2},
+			{ID: "d2", Seats: []string{"s1"}, ADomains: []string{"example.com"}},
+		}
+		bid = func(id, deal string, price float64, domains ...string) openrtb.Bid {
+			return openrtb.Bid{ID: id, ImpID: impID, Price: price, CreativeID: id, DealID: deal,
+				AdvDomain: domains, AdMarkup: "<div></div>"}
+		}
+		seats = []openrtb.SeatBid{
+			{Seat: "s1", Bid: []openrtb.Bid{
+				bid("open", "", 1),
+				bid("unknown", "d9", 1),
+				bid("below_floor", "d1", 1.5),
+				bid("floor", "d1", 2),
+				bid("no_domain", "d2", 1),
+				bid("domain", "d2", 1, "example.com"),
+				bid("other_domain", "d2", 1, "example.com", "other.com"),
+			}},
+			{Seat: "s2", Bid: []openrtb.Bid{bid("other_seat", "d2", 1, "example.com")}},
+		}
+	)
+
+	// The private auction accepts the bids of the valid deals only,
+	// the cheaper deal bid of the same impression loses the auction
+	resp, err := testParseBids(t, request, seats,
+		WithParsePMP(testDealProvider(&adresponse.PMP{Private: true, Deals: deals})))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"floor", "domain"}, testResponseBids(resp))
+	assert.Equal(t, map[string]adresponse.RejectionReason{
+		"open":         adresponse.RejectionDeal,
+		"unknown":      adresponse.RejectionDeal,
+		"below_floor":  adresponse.RejectionDeal,
+		"no_domain":    adresponse.RejectionDeal,
+		"other_domain": adresponse.RejectionDeal,
+		"other_seat":   adresponse.RejectionDeal,
+		"domain":       adresponse.RejectionLostAuction,
+	}, testRejectionReasons(resp))
+
+	// The open auction accepts the bids without the deal or with the unknown deal
+	resp, err = testParseBids(t, request, seats,
+		WithParsePMP(testDealProvider(&adresponse.PMP{Deals: deals})))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"open", "unknown", "floor", "domain"}, testResponseBids(resp))
+
+	// The bids are not checked without the deals provider
+	resp, err = testParseBids(t, request, seats)
+	assert.NoError(t, err)
+	assert.Len(t, testResponseBids(resp), 8)
+}
+
+func TestDealPricing(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+	)
+	tests := []struct {
+		name     string
+		deal     *adresponse.Deal
+		dealID   string
+		impPrice float64
+	}{
+		{
+			name:     "open_auction",
+			dealID:   "",
+			impPrice: 3,
+		},
+		{
+			name:     "fixed_price",
+			deal:     &adresponse.Deal{ID: "d1", BidFloor: 2, AuctionType: adresponse.DealAuctionFixedPrice},
+			dealID:   "d1",
+			impPrice: 2,
+		},
+		{
+			name:     "first_price",
+			deal:     &adresponse.Deal{ID: "d1", BidFloor: 2, AuctionType: adresponse.DealAuctionFirstPrice},
+			dealID:   "d1",
+			impPrice: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pmp := &adresponse.PMP{Private: tt.deal != nil}
+			if tt.deal != nil {
+				pmp.Deals = []*adresponse.Deal{tt.deal}
+			}
+			resp, err := testParseBids(t, request, []openrtb.SeatBid{{Bid: []openrtb.Bid{
+				{ID: "1", ImpID: impID, Price: 3, CreativeID: "c1", DealID: tt.dealID, AdMarkup: "<div></div>"},
+			}}}, WithParsePMP(testDealProvider(pmp)))
+			if !assert.NoError(t, err) || !assert.Len(t, resp.Ads(), 1) {
+				return
+			}
+			item, ok := resp.Ads()[0].(*adresponse.ResponseBannerBidItem)
+			if !assert.True(t, ok) {
+				return
+			}
+			assert.Equal(t, tt.dealID, item.DealID())
+			assert.Equal(t, tt.deal, item.Deal())
+			assert.Equal(t, tt.deal != nil, item.IsPrivateAuction())
+			assert.InDelta(t, tt.impPrice/1000, item.PriceScope.ImpPrice.Float64(), 1e-9)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package adsourceopenrtb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/fasttime"
+)
+
+type directBidCacheItem struct {
+	bid      openrtb.Bid
+	expireAt int64
+	uses     int
+}
+
+// directBidCache keeps the winning direct (pop) bids per zone
+// for their expiration window to reuse them on the subsequent requests
+type directBidCache struct {
+	mx      sync.Mutex
+	items   map[string]*directBidCacheItem
+	ttl     time.Duration
+	maxUses int
+}
+
+func newDirectBidCache(ttl time.Duration, maxUses int) *directBidCache {
+	return &directBidCache{
+		items:   map[string]*directBidCacheItem{},
+		ttl:     ttl,
+		maxUses: max(maxUses, 1),
+	}
+}
+
+// Put the bid into the cache if there is no actual bid for the zone.
+// The shorter bid expiration (exp) has priority, the configured TTL is the upper limit.
+func (c *directBidCache) Put(zone string, bid *openrtb.Bid) {
+	if zone == "" || bid == nil {
+		return
+	}
+	ttl := c.ttl
+	if exp := time.Duration(bid.Exp) * time.Second; exp > 0 && exp < ttl {
+		ttl = exp
+	}
+	if ttl <= 0 {
+		return
+	}
+	now := int64(fasttime.UnixTimestampNano())
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if item := c.items[zone]; item != nil && item.expireAt > now {
+		return
+	}
+	c.items[zone] = &directBidCacheItem{
+		bid:      *bid,
+		expireAt: now + ttl.Nanoseconds(),
+		uses:     c.maxUses,
+	}
+	c.cleanup(now)
+}
+
+// Take returns the copy of cached bid for the zone if it's still actual
+func (c *directBidCache) Take(zone string) (openrtb.Bid, bool) {
+	now := int64(fasttime.UnixTimestampNano())
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	item := c.items[zone]
+	if item == nil {
+		return openrtb.Bid{}, false
+	}
+	if item.expireAt <= now {
+		delete(c.items, zone)
+		return openrtb.Bid{}, false
+	}
+	if item.uses--; item.uses <= 0 {
+		delete(c.items, zone)
+	}
+	return item.bid, true
+}
+
+func (c *directBidCache) cleanup(now int64) {
+	for zone, item := range c.items {
+		if item.expireAt <= now {
+			delete(c.items, zone)
+		}
+	}
+}
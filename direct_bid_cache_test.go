@@ -0,0 +1,78 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/eventtraking/eventstream"
+	"github.com/geniusrabbit/adcorelib/fasttime"
+)
+
+func TestDirectBidCacheTTL(t *testing.T) {
+	cache := newDirectBidCache(time.Minute, 1)
+
+	// The partner expiration can't extend the configured TTL
+	cache.Put("zone1", &openrtb.Bid{ID: "1", Exp: 3600})
+	expireAt := cache.items["zone1"].expireAt
+	assert.LessOrEqual(t, expireAt-int64(fasttime.UnixTimestampNano()), time.Minute.Nanoseconds())
+
+	// The shorter partner expiration is kept
+	cache.Put("zone2", &openrtb.Bid{ID: "2", Exp: 10})
+	expireAt = cache.items["zone2"].expireAt
+	assert.LessOrEqual(t, expireAt-int64(fasttime.UnixTimestampNano()), 10*time.Second.Nanoseconds())
+
+	bid, ok := cache.Take("zone1")
+	assert.True(t, ok)
+	assert.Equal(t, "1", bid.ID)
+	_, ok = cache.Take("zone1")
+	assert.False(t, ok, "the uses of the bid are exhausted")
+}
+
+func TestDirectBidCacheNotices(t *testing.T) {
+	var requests atomic.Int32
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		data, _ := io.ReadAll(r.Body)
+		var request openrtb.BidRequest
+		_ = json.Unmarshal(data, &request)
+		_ = json.NewEncoder(w).Encode(openrtb.BidResponse{ID: request.ID, SeatBid: []openrtb.SeatBid{{
+			Bid: []openrtb.Bid{{ID: "1", ImpID: request.Imp[0].ID, Price: 1, CreativeID: "c1",
+				AdMarkup: "https://example.com/landing", NURL: "https://example.com/nurl",
+				BURL: "https://example.com/burl", Exp: 60}},
+		}}})
+	}, WithDirectBidCache(time.Minute, 2))
+
+	var (
+		publisher = &testPublisher{}
+		ctx       = eventstream.WithWins(context.Background(), eventstream.WinNotifications(publisher))
+	)
+	ctx = eventstream.WithStream(ctx, &testEventStream{})
+
+	response := d.Bid(newTestRequest(ctx, "direct"))
+	if !assert.NoError(t, response.Error()) || !assert.Len(t, response.Ads(), 1) {
+		return
+	}
+	d.ProcessResponseItem(response, nil)
+	assert.Len(t, publisher.messages, 1, "billing notice of the source bid")
+
+	// The cached bid is served without the source request and without the notices
+	cached := d.Bid(newTestRequest(ctx, "direct"))
+	assert.True(t, isCachedResponse(cached))
+	if assert.Len(t, cached.Ads(), 1) {
+		item := cached.Ads()[0].(adtype.ResponseItem)
+		assert.Empty(t, item.ContentItemString(adtype.ContentItemNotifyWinURL))
+		assert.Empty(t, item.ContentItemString(adtype.ContentItemNotifyDisplayURL))
+	}
+	d.ProcessResponseItem(cached, nil)
+	assert.Len(t, publisher.messages, 1)
+	assert.Equal(t, int32(1), requests.Load())
+}
@@ -55,8 +55,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -65,16 +67,16 @@ import (
 	"github.com/bsm/openrtb"
 	"github.com/demdxx/gocast/v2"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 
 	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
 	"github.com/geniusrabbit/adcorelib/adquery/bidresponse"
 	"github.com/geniusrabbit/adcorelib/adtype"
 	"github.com/geniusrabbit/adcorelib/context/ctxlogger"
-	counter "github.com/geniusrabbit/adcorelib/errorcounter"
 	"github.com/geniusrabbit/adcorelib/eventtraking/events"
 	"github.com/geniusrabbit/adcorelib/eventtraking/eventstream"
-	"github.com/geniusrabbit/adcorelib/fasttime"
 	"github.com/geniusrabbit/adcorelib/net/httpclient"
 	"github.com/geniusrabbit/adcorelib/openlatency"
 	"github.com/geniusrabbit/adcorelib/openlatency/prometheuswrapper"
@@ -83,18 +85,16 @@ import (
 )
 
 const (
-	headerRequestOpenRTBVersion  = "X-Openrtb-Version"
-	headerRequestOpenRTBVersion2 = "2.5"
-	headerRequestOpenRTBVersion3 = "3.0"
-	defaultMinWeight             = 0.001
+	headerRequestOpenRTBVersion = "X-Openrtb-Version"
+	defaultMinWeight            = 0.001
 )
 
 type driver struct {
-	lastRequestTime uint64
+	// Requests RPS limiter (nil if unlimited) and its adaptive rate (nil if disabled)
+	rateLimiter *tokenBucket
+	adaptive    *adaptiveRPS
 
-	// Requests RPS counter
-	rpsCurrent     counter.Counter
-	errorCounter   counter.ErrorCounter
+	errorCounter   errorBreaker
 	latencyMetrics *prometheuswrapper.Wrapper
 
 	// Original source model
@@ -105,19 +105,254 @@ type driver struct {
 
 	// Client of HTTP requests
 	netClient httpclient.Driver
+
+	// Driver options
+	opts DriverOptions
+
+	// Effective protocol version of the source
+	protocol *protocolNegotiator
+
+	// Cache of the winning direct bids
+	directCache *directBidCache
+
+	// Cache of the contextual page data
+	pageContext *pageContextCache
+
+	// parseOptions of the source responses
+	parseOptions *ParseOptions
+
+	// mediaTypes bought by the source (all types if empty)
+	mediaTypes []types.FormatType
+
+	// formatBidFloors overrides the source minimal bid per format type
+	formatBidFloors map[types.FormatType]float64
+
+	// toleranceMetric of the lenient response decoding (nil if disabled)
+	toleranceMetric *prometheus.CounterVec
+
+	// downgradeMetric of the objects removed from the invalid requests (nil if disabled)
+	downgradeMetric *prometheus.CounterVec
+
+	// schemaMetric of the response schema features used by the partner (nil if disabled)
+	schemaMetric *prometheus.CounterVec
+
+	// fieldFilter of the encoded requests (nil if disabled)
+	fieldFilter *RequestFieldFilter
+
+	// gzip compression of the request bodies
+	gzip bool
+
+	// priceDecimals of the request prices (0 - the default float formatting)
+	priceDecimals int
+
+	// createdAt time of the driver used for the warm-up grace window
+	createdAt time.Time
+
+	// anomaly trigger of the automatic trace capture (nil if disabled)
+	anomaly *anomalyTrigger
+
+	// budget throttle of the requests by the latency and response size
+	budget *budgetThrottle
+
+	// configRevision of the source settings and the last errors of the requests
+	configRevision string
+	errorHistory   *errorHistory
+
+	// inFlight requests counter and the overflow metric
+	inFlight         atomic.Int64
+	overloadedMetric prometheus.Counter
+
+	// keyLimiter of the requests per publisher or zone (nil if disabled)
+	keyLimiter *keyedRateLimiter
+
+	// blocklist of the zones and the domains of the source (nil if disabled)
+	blocklist *sourceBlocklist
+
+	// spend caps of the source won bids (nil if disabled) and the spend metric
+	spend       *spendCap
+	spendMetric *prometheus.GaugeVec
+
+	// weighter of the source by the performance statistics (nil if disabled)
+	weighter *sourceWeighter
+
+	// throttledUntil time (unix nano) of the back off requested by the partner and the throttle metric
+	throttledUntil  atomic.Int64
+	throttledMetric prometheus.Counter
+
+	// canceledMetric of the requests cancelled by the auction context by the reason
+	canceledMetric *prometheus.CounterVec
+
+	// skipMetric of the skipped requests by the reason
+	skipMetric *prometheus.CounterVec
+
+	// Partner reported processing time and the network time metrics
+	processingMetric prometheus.Observer
+	networkMetric    prometheus.Observer
+
+	// blockedAttributes resolver per placement
+	blockedAttributes func(imp *adtype.Impression) *BlockedAttributes
+
+	// blockedAdvertisers resolver per placement
+	blockedAdvertisers func(imp *adtype.Impression) *BlockedAdvertisers
+
+	// clickBrowser resolver per placement
+	clickBrowser func(imp *adtype.Impression) ClickBrowser
 }
 
-func newDriver(_ context.Context, source *admodels.RTBSource, netClient httpclient.Driver, _ ...any) (*driver, error) {
+func newDriver(_ context.Context, source *admodels.RTBSource, netClient httpclient.Driver, options ...any) (*driver, error) {
+	var opts DriverOptions
+	opts.apply(options...)
+	if opts.StrictImpIDMatch {
+		opts.ImpIDCodec = adresponse.StrictImpIDCodec(opts.ImpIDCodec)
+	}
 	source.MinimalWeight = max(source.MinimalWeight, defaultMinWeight)
-	return &driver{
-		source:    source,
-		headers:   source.Headers.DataOr(nil),
-		netClient: netClient,
-		latencyMetrics: prometheuswrapper.NewWrapperDefault("adsource_",
-			[]string{"id", "protocol", "driver"},
-			[]string{gocast.Str(source.ID), source.Protocol, "openrtb"},
-		),
-	}, nil
+	opts.AllowedSeats, opts.BlockedSeats = sourceSeats(source, &opts)
+	opts.Currency = sourceBidCurrency(source, &opts)
+	opts.Currencies = sourceCurrencies(source, &opts)
+	opts.URLNormalization = sourceURLNormalization(source, &opts)
+	opts.EmptyDevicePolicy = sourceEmptyDevicePolicy(source, &opts)
+	dailySpendCap := opts.DailySpendCap
+	if dailySpendCap <= 0 {
+		dailySpendCap = source.DailyBudget.Float64()
+	}
+	d := &driver{
+		source:      source,
+		headers:     source.Headers.DataOr(nil),
+		netClient:   netClient,
+		opts:        opts,
+		rateLimiter: newTokenBucket(source.RPS, opts.RPSBurst),
+		keyLimiter:  newKeyedRateLimiter(opts.KeyRPS, opts.KeyRPSFunc),
+		blocklist:   newSourceBlocklist(source, &opts),
+		spend:       newSpendCap(opts.HourlySpendCap, dailySpendCap, opts.SpendCapLocation),
+		fieldFilter: sourceRequestFieldFilter(source, &opts),
+
+		configRevision: sourceConfigRevision(source, &opts),
+		errorHistory:   newErrorHistory(opts.ErrorHistorySize),
+
+		formatBidFloors: sourceFormatBidFloors(source, &opts),
+		mediaTypes:      sourceMediaTypes(source, &opts),
+		priceDecimals:   sourcePriceDecimals(source, &opts),
+		gzip:            sourceGzip(source, &opts),
+
+		blockedAttributes:  blockedAttributesResolver(opts.BlockedAttributes, opts.BlockedAttributesProvider),
+		blockedAdvertisers: blockedAdvertisersResolver(sourceBlockedAdvertisers(source, &opts), opts.BlockedAdvertisersProvider),
+		clickBrowser:       clickBrowserResolver(opts.ClickBrowser, opts.ClickBrowserProvider),
+	}
+	d.createdAt = d.now()
+	d.protocol = newProtocolNegotiator(source.Protocol, &opts, d.now)
+
+	labels := sourceMetricLabels(source)
+	d.initMetrics(labels)
+	d.initParseOptions(labels)
+	d.initThrottles()
+	return d, nil
+}
+
+// initMetrics of the driver curried with the source labels
+func (d *driver) initMetrics(labels prometheus.Labels) {
+	reg := d.opts.MetricsRegistry
+	d.overloadedMetric = newOverloadedMetric(reg).With(labels)
+	d.throttledMetric = newThrottledMetric(reg).With(labels)
+	d.canceledMetric = curryMetric(newCanceledMetric(reg), labels)
+	d.skipMetric = curryMetric(newSkipMetric(reg), labels)
+	d.spendMetric = curryMetric(newSpendMetric(reg), labels)
+	d.processingMetric = newProcessingTimeMetric(reg).With(labels)
+	d.networkMetric = newNetworkTimeMetric(reg).With(labels)
+	if sourceLenientDecoding(d.source, &d.opts) {
+		d.toleranceMetric = curryMetric(newToleranceMetric(reg), labels)
+	}
+	if d.opts.ResponseSchemaMetrics {
+		d.schemaMetric = curryMetric(newSchemaMetric(reg), labels)
+	}
+	if d.opts.RequestDowngrade {
+		d.downgradeMetric = curryMetric(newDowngradeMetric(reg), labels)
+	}
+	d.latencyMetrics = prometheuswrapper.NewWrapperDefault("adsource_",
+		metricLabels, []string{labels["id"], labels["protocol"], labels["driver"]})
+}
+
+// initParseOptions of the source responses with the observers of the bid checks
+func (d *driver) initParseOptions(labels prometheus.Labels) {
+	var (
+		opts              = &d.opts
+		reg               = opts.MetricsRegistry
+		impIDMatchMetric  = curryMetric(newImpIDMatchMetric(reg), labels)
+		correlationMetric = curryMetric(newCorrelationMetric(reg), labels)
+		blockedAttrMetric = curryMetric(newBlockedAttrMetric(reg), labels)
+	)
+	d.parseOptions = newParseOptions(
+		WithParseSourceID(d.source.ID),
+		WithParseMaxBid(d.source.MaxBid.Float64()),
+		WithParseBlockedCategories(opts.CategoryTaxonomy, opts.BlockedCategories...),
+		WithParseBlockedAdvertisers(d.blockedAdvertisers),
+		WithParseBlockedAttributes(d.blockedAttributes, func(attr int) {
+			blockedAttrMetric.WithLabelValues(strconv.Itoa(attr)).Inc()
+		}),
+		WithParseCurrency(opts.Currency, opts.ExchangeRates, opts.CurrencyPolicy),
+		WithParseAcceptedCurrencies(opts.Currencies...),
+		WithParseStrictValidation(opts.StrictValidation, opts.ViolationReporter),
+		WithParseImpIDCodec(opts.ImpIDCodec),
+		WithParseCorrelation(opts.CorrelationMode, opts.CorrelationKey, func(matched bool) {
+			correlationMetric.WithLabelValues(correlationResult(matched)).Inc()
+		}),
+		WithParseImpIDMatchObserver(func(match adresponse.ImpIDMatch) {
+			impIDMatchMetric.WithLabelValues(match.String()).Inc()
+		}),
+		WithParseLogger(opts.Logger),
+		WithParseSKAdNKeys(opts.SKAdNKeys),
+		WithParseMRAID(opts.MRAIDProvider),
+		WithParseMarkupWrapper(opts.MarkupWrapper),
+		WithParsePMP(opts.DealProvider),
+		WithParseAuction(d.source.AuctionType, opts.SecondPriceIncrement),
+		WithParsePreferredSeats(opts.PreferredSeats),
+		WithParseDeferredNURL(opts.DeferredNURL),
+		WithParseBidDensity(opts.MaxSeatBids, opts.MaxResponseBids),
+		WithParseLateMacros(opts.LateMacros...),
+		WithParsePricingModel(sourcePricingModel(d.source, opts), opts.ActionRateProvider),
+		WithParseLanguages(opts.LanguageProvider),
+		WithParseSeats(opts.AllowedSeats, opts.BlockedSeats),
+		// The bids of the formats filtered out of the request are rejected by the same filter
+		WithParseFormatFilter(d.testFormat),
+	)
+	if opts.SpoofMode != SpoofOff {
+		detector := newSpoofDetector(opts.SpoofWindow, opts.SpoofMaxNewDomains, opts.SpoofQuarantine)
+		spoofMetric := curryMetric(newSpoofMetric(reg), labels)
+		WithParseSpoofDetection(opts.SpoofMode, func(seat string, bid *openrtb.Bid) SpoofKind {
+			kind := detector.Check(seat, bid, d.now())
+			if kind != SpoofNone {
+				spoofMetric.WithLabelValues(string(kind)).Inc()
+			}
+			return kind
+		})(d.parseOptions)
+	}
+}
+
+// initThrottles of the optional caches, budgets, rate adjustments and weighting of the source
+func (d *driver) initThrottles() {
+	opts := &d.opts
+	if opts.DirectBidCacheTTL > 0 {
+		d.directCache = newDirectBidCache(opts.DirectBidCacheTTL, opts.DirectBidCacheMaxUses)
+	}
+	if opts.PageContextProvider != nil {
+		d.pageContext = newPageContextCache(opts.PageContextProvider,
+			opts.PageContextTTL, opts.PageContextTimeout, opts.PageContextCacheSize)
+	}
+	if opts.BudgetThrottle {
+		latencyBudget := opts.LatencyBudget
+		if latencyBudget <= 0 {
+			latencyBudget = time.Duration(d.source.Timeout) * time.Millisecond
+		}
+		d.budget = newBudgetThrottle(latencyBudget, opts.ResponseSizeBudget, opts.BudgetPercentile)
+	}
+	if opts.TraceSink != nil && opts.AnomalyErrorRate > 0 {
+		d.anomaly = newAnomalyTrigger(opts.AnomalyErrorRate, opts.AnomalyCaptures, opts.AnomalyWindow, opts.AnomalyMinRequests)
+	}
+	if opts.AdaptiveRPS {
+		d.adaptive = newAdaptiveRPS(d.rateLimiter, opts, d.createdAt)
+	}
+	if opts.DynamicWeight {
+		d.weighter = newSourceWeighter(d.source.ID, opts, d.now)
+	}
 }
 
 // ID of source
@@ -147,28 +382,71 @@ func (d *driver) AccountID() uint64 {
 
 // Test request before processing
 func (d *driver) Test(request adtype.BidRequester) bool {
+	ok, _ := d.TestWithReason(request)
+	return ok
+}
+
+// TestWithReason tests the request before processing and returns the reason of the skip
+func (d *driver) TestWithReason(request adtype.BidRequester) (bool, SkipReason) {
 	if d.source.RPS > 0 {
-		if d.source.Options.ErrorsIgnore == 0 && !d.errorCounter.Next() {
-			d.latencyMetrics.IncSkip()
-			return false
+		if d.source.Options.ErrorsIgnore == 0 && !d.errorCounter.Next(d.now()) {
+			return d.skip(SkipReasonErrorBreaker)
 		}
+		d.adaptive.Update(d.now())
+	}
 
-		now := fasttime.UnixTimestampNano()
-		if now-atomic.LoadUint64(&d.lastRequestTime) >= uint64(time.Second) {
-			atomic.StoreUint64(&d.lastRequestTime, now)
-			d.rpsCurrent.Set(0)
-		} else if d.rpsCurrent.Get() >= int64(d.source.RPS) {
-			d.latencyMetrics.IncSkip()
-			return false
-		}
+	// Back off the source for the time requested by the partner throttle response
+	if d.isThrottled() {
+		return d.skip(SkipReasonPartnerThrottled)
 	}
 
 	if !d.source.Test(request) {
-		d.latencyMetrics.IncSkip()
-		return false
+		return d.skip(SkipReasonTargetingMismatch)
+	}
+
+	if !d.hasAllowedFormats(request) {
+		return d.skip(SkipReasonFormatFilter)
+	}
+
+	// Skip the request if the domain or all the placements are blocklisted
+	if !d.blocklist.Allow(request) {
+		return d.skip(SkipReasonBlocklisted)
+	}
+
+	// Skip the request without the IP address or the user agent if the source policy requires
+	if d.opts.EmptyDevicePolicy.Skip(request) {
+		return d.skip(SkipReasonEmptyDevice)
+	}
+
+	// Skip the request if the source has too many requests in flight
+	if d.opts.MaxInFlight > 0 && d.inFlight.Load() >= int64(d.opts.MaxInFlight) {
+		d.overloadedMetric.Inc()
+		return d.skip(SkipReasonOverloaded)
+	}
+
+	// Stop the bidding until the next hour or day once the spend cap is reached
+	if !d.spend.Allow(d.now()) {
+		return d.skip(SkipReasonSpendCapped)
+	}
+
+	// Throttle the sources which exceed the latency or response size budget
+	if !d.budget.Admit() {
+		return d.skip(SkipReasonBudgetThrottled)
 	}
 
-	return true
+	// The rate limits are checked the last, so the tokens are taken
+	// only by the requests which are sent to the source
+	if !d.rateLimiter.Allow(d.now()) {
+		return d.skip(SkipReasonRPSLimited)
+	}
+
+	// Limit the requests per publisher or zone if the source contract caps them
+	if !d.keyLimiter.Allow(request, d.now()) {
+		d.rateLimiter.Refund()
+		return d.skip(SkipReasonKeyRateLimited)
+	}
+
+	return true, SkipReasonNone
 }
 
 // PriceCorrectionReduceFactor which is a potential
@@ -185,57 +463,131 @@ func (d *driver) RequestStrategy() adtype.RequestStrategy {
 
 // Bid request for standart system filter
 func (d *driver) Bid(request adtype.BidRequester) (response adtype.Response) {
-	beginTime := fasttime.UnixTimestampNano()
-	d.rpsCurrent.Inc(1)
+	// Serve direct bids from the cache without the source request
+	if cached := d.cachedDirectResponse(request); cached != nil {
+		return cached
+	}
+
+	// Limit the concurrent requests to protect the timeout budget
+	if inFlight := d.inFlight.Add(1); d.opts.MaxInFlight > 0 && inFlight > int64(d.opts.MaxInFlight) {
+		d.inFlight.Add(-1)
+		d.latencyMetrics.IncSkip()
+		d.overloadedMetric.Inc()
+		return bidresponse.NewEmptyResponse(request, d, d.bidError(BidStageLimit, nil, ErrSourceOverloaded))
+	}
+	defer d.inFlight.Add(-1)
+
+	// The request of the expired auction is not sent at all
+	if err := contextError(request.Context()); err != nil {
+		d.recordCanceled(request, err)
+		return adtype.NewErrorResponse(request, d.bidError(BidStageNetwork, nil, err))
+	}
+
+	beginTime := d.now()
 	d.latencyMetrics.BeginQuery()
 
-	httpRequest, err := d.request(request)
+	// The payloads of the requests after the failure rate spike are captured for the trace sink
+	capture := d.anomaly.Take()
+	raw := d.sampleRawPayloads()
+	if raw == nil && capture {
+		raw = d.newRawPayloads()
+	}
+	data, version, err := d.encodeRequest(request, raw)
 	if err != nil {
-		return adtype.NewErrorResponse(request, err)
+		d.protocol.Complete(version, false)
+		return adtype.NewErrorResponse(request, d.bidError(BidStageEncode, nil, err))
 	}
+	var failure error
+	defer func() { d.recordAnomaly(request, raw, capture, failure) }()
 
-	// Send request to source
-	resp, err := d.netClient.Do(httpRequest)
-	d.latencyMetrics.UpdateQueryLatency(time.Duration(fasttime.UnixTimestampNano() - beginTime))
+	// Send request to source with retries of the failed attempts if the budget allows
+	sendCtx, cancel := d.sendContext(request)
+	defer cancel()
+	resp, err := d.send(sendCtx, request, data, version)
+	d.protocol.Complete(version, err == nil)
+	latency := d.now().Sub(beginTime)
+	d.latencyMetrics.UpdateQueryLatency(latency)
 
 	// Process response status and errors
 	if err != nil {
+		failure = err
+		d.recordBudget(latency, 0)
+		d.recordCanceled(request, err)
 		d.processHTTPReponse(resp, err)
-		ctxlogger.Get(request.Context()).Debug("bid",
+		d.requestLogger(request).Debug("bid",
 			zap.String("source_url", d.source.URL),
 			zap.Error(err))
-		return adtype.NewErrorResponse(request, err)
+		return adtype.NewErrorResponse(request, d.bidError(BidStageNetwork, resp, err))
 	}
 	defer func() { _ = resp.Close() }()
 
 	// Log response status and latency
-	ctxlogger.Get(request.Context()).Debug("bid",
+	d.requestLogger(request).Debug("bid",
 		zap.String("source_url", d.source.URL),
 		zap.String("http_response_status_txt", http.StatusText(resp.StatusCode())),
 		zap.Int("http_response_status", resp.StatusCode()))
 
+	// The processed request confirms the protocol version (the probe of the newer version upgrades it)
+	if resp.StatusCode() == http.StatusOK || d.isNoBidStatus(resp.StatusCode()) {
+		d.protocol.Accept(version)
+	}
+
+	// Split the latency into the bidder processing and network time if the partner reports it
+	d.recordPartnerLatency(resp, request, latency)
+
 	// NOTE: StatusNoContent - is the standard OpenRTB response for no bid, but some sources can return StatusNotFound in this case
-	if resp.StatusCode() == http.StatusNoContent || resp.StatusCode() == http.StatusNotFound {
+	if d.isNoBidStatus(resp.StatusCode()) {
 		d.latencyMetrics.IncNobid()
+		d.weighter.RecordRequest(false)
+		d.adaptive.Record(false)
 		return bidresponse.NewEmptyResponse(request, d, ErrResponseNoBid)
 	}
 
+	// The throttled source backs off without tripping the error counter
+	if d.throttle(resp) {
+		d.requestLogger(request).Warn("source throttled",
+			zap.String("source_url", d.source.URL),
+			zap.Int("http_response_status", resp.StatusCode()))
+		return adtype.NewErrorResponse(request, d.bidError(BidStageStatus, resp, ErrSourceThrottled))
+	}
+
 	// Not success status code
 	if resp.StatusCode() != http.StatusOK {
+		failure = ErrInvalidResponseStatus
+		d.recordBudget(latency, 0)
+		if d.protocol.Fallback(version, resp.StatusCode(), responseHeader(resp, headerRequestOpenRTBVersion)) {
+			d.requestLogger(request).Warn("protocol version fallback",
+				zap.String("source_url", d.source.URL),
+				zap.String("protocol_version", d.protocol.Current()),
+				zap.Int("http_response_status", resp.StatusCode()))
+		}
 		d.processHTTPReponse(resp, nil)
-		return adtype.NewErrorResponse(request, ErrInvalidResponseStatus)
+		return adtype.NewErrorResponse(request, d.bidError(BidStageStatus, resp, ErrInvalidResponseStatus))
 	}
 
 	// Decode response body
-	if res, err := d.unmarshal(request, resp.Body()); d.source.Options.Trace != 0 && err != nil {
-		response = adtype.NewErrorResponse(request, err)
-		ctxlogger.Get(request.Context()).Error("bid response", zap.Error(err))
+	respBody, errResp := responseBody(resp)
+	if errResp != nil {
+		failure = errResp
+		d.recordBudget(latency, 0)
+		d.processHTTPReponse(resp, errResp)
+		return adtype.NewErrorResponse(request, d.bidError(BidStageDecode, resp, errResp))
+	}
+	body := &countingReader{r: raw.responseReader(respBody)}
+	res, errResp := d.unmarshal(request, body, responseContentType(resp))
+	d.recordBudget(latency, body.n)
+	raw.attach(res)
+	failure = errResp
+	if d.isTrace(request) && errResp != nil {
+		response = adtype.NewErrorResponse(request, d.bidError(BidStageDecode, resp, errResp))
+		d.requestLogger(request).Error("bid response", zap.Error(errResp))
 	} else if res != nil {
 		response = res
 	}
 
 	if response != nil && response.Error() == nil {
 		if len(response.Ads()) > 0 {
+			d.weighter.RecordBids(len(response.Ads()))
 			d.latencyMetrics.IncSuccess()
 		} else {
 			d.latencyMetrics.IncNobid()
@@ -255,37 +607,109 @@ func (d *driver) ProcessResponseItem(response adtype.Response, item adtype.Respo
 	if response == nil || response.Error() != nil {
 		return
 	}
+	logger := d.responseLogger(response)
 	for _, ad := range response.Ads() {
 		switch bid := ad.(type) {
 		case adtype.ResponseItem:
 			if bid.Source().ID() != d.ID() {
-				ctxlogger.Get(response.Context()).Debug("bid source mismatch",
+				logger.Debug("bid source mismatch",
 					zap.Uint64("source_id", bid.Source().ID()),
 					zap.Uint64("driver_id", d.ID()),
 				)
 				continue
 			}
-			if nurl := bid.ContentItemString(adtype.ContentItemNotifyDisplayURL); nurl != "" {
-				ctxlogger.Get(response.Context()).Info("ping", zap.String("url", nurl))
-				err := eventstream.WinsFromContext(response.Context()).Send(response.Context(), nurl)
-				if err != nil {
-					ctxlogger.Get(response.Context()).Error("ping error", zap.Error(err))
+			// The notices of the cached bid are fired once by the original win
+			if isCachedResponse(response) {
+				d.recordWin(response, bid, logger)
+				continue
+			}
+			if d.directCache != nil {
+				if direct, _ := bid.(*adresponse.ResponseDirectBidItem); direct != nil {
+					d.directCache.Put(direct.TargetCodename(), direct.Bid)
 				}
 			}
-			err := eventstream.StreamFromContext(response.Context()).
-				Send(events.SourceWin, events.StatusUndefined, response, bid)
-			if err != nil {
-				ctxlogger.Get(response.Context()).Error("send win event", zap.Error(err))
+			burl := bid.ContentItemString(adtype.ContentItemNotifyDisplayURL)
+			// The deferred win and billing notices are fired with the final clearing price
+			if bidResp, _ := response.(*adresponse.BidResponse); bidResp != nil {
+				if nurl := bidResp.WinNoticeURL(bid); nurl != "" {
+					d.ping(response, logger, nurl)
+				}
+				if deferred := bidResp.BillingNoticeURL(bid); deferred != "" {
+					burl = deferred
+				}
+			}
+			if burl != "" {
+				d.bill(response, bid, logger, burl)
 			}
+			d.recordWin(response, bid, logger)
 		default:
 			// Dummy...
 		}
 	}
 }
 
-// Weight of the source
+// recordWin of the bid in the source weight, spend and the event stream
+func (d *driver) recordWin(response adtype.Response, bid adtype.ResponseItem, logger *zap.Logger) {
+	d.weighter.RecordWin(bid.ECPM().Float64())
+	d.recordSpend(bid.FinalPrice(adtype.ActionImpression).Float64())
+	err := eventstream.StreamFromContext(response.Context()).
+		Send(events.SourceWin, events.StatusUndefined, response, bid)
+	if err != nil {
+		logger.Error("send win event", zap.Error(err))
+	}
+}
+
+// ping the notification URL by the win events stream
+// if the URL is allowed by the notification policy
+func (d *driver) ping(response adtype.Response, logger *zap.Logger, url string) {
+	if err := d.notifyURLPolicy().Validate(response.Context(), url); err != nil {
+		logger.Warn("ping URL rejected", zap.String("url", url), zap.Error(err))
+		return
+	}
+	logger.Info("ping", zap.String("url", url))
+	if err := eventstream.WinsFromContext(response.Context()).Send(response.Context(), url); err != nil {
+		logger.Error("ping error", zap.Error(err))
+	}
+}
+
+// bill fires the billing notice of the won bid or keeps it until the impression
+// if the billing notices are fired on the impression events
+func (d *driver) bill(response adtype.Response, item adtype.ResponseItem, logger *zap.Logger, url string) {
+	if d.opts.BillingNotices == nil {
+		d.ping(response, logger, url)
+		return
+	}
+	if err := d.notifyURLPolicy().Validate(response.Context(), url); err != nil {
+		logger.Warn("billing URL rejected", zap.String("url", url), zap.Error(err))
+		return
+	}
+	d.opts.BillingNotices.Add(response.Request().AuctionID(), item.ImpressionID(), url)
+}
+
+// recordSpend of the won bid in the spend caps and the spend metric
+func (d *driver) recordSpend(amount float64) {
+	if d.spend == nil {
+		return
+	}
+	now := d.now()
+	d.spend.Add(now, amount)
+	d.observeSpend(d.spend.Info(now))
+}
+
+// observeSpend of the current period in the spend metric
+func (d *driver) observeSpend(info SpendInfo) {
+	d.spendMetric.WithLabelValues("hourly").Set(info.Hourly)
+	d.spendMetric.WithLabelValues("daily").Set(info.Daily)
+}
+
+// Spend of the source in the current hour and day with the caps
+func (d *driver) Spend() SpendInfo {
+	return d.spend.Info(d.now())
+}
+
+// Weight of the source computed by the source performance if the dynamic weighting is enabled
 func (d *driver) Weight() float64 {
-	return d.source.MinimalWeight
+	return d.weighter.Weight(d.source.MinimalWeight)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -299,6 +723,14 @@ func (d *driver) Metrics() *openlatency.MetricsInfo {
 	info.ID = d.ID()
 	info.Protocol = d.source.Protocol
 	info.QPSLimit = d.source.RPS
+	if d.adaptive != nil {
+		// The effective limit lowered by the timeouts and errors of the source
+		info.QPSLimit = d.adaptive.RPS()
+	}
+	// The spend is reported by the spend metric, the reset of the period is observed as well
+	if d.spend != nil {
+		d.observeSpend(d.spend.Info(d.now()))
+	}
 	return &info
 }
 
@@ -306,21 +738,34 @@ func (d *driver) Metrics() *openlatency.MetricsInfo {
 /// Internal methods
 ///////////////////////////////////////////////////////////////////////////////
 
-// prepare request for RTB
-func (d *driver) request(request adtype.BidRequester) (req httpclient.Request, err error) {
+// encodeRequest prepares the RTB request data and returns it with the protocol version
+func (d *driver) encodeRequest(request adtype.BidRequester, raw *rawPayloads) (data []byte, version string, err error) {
 	var (
 		rtbRequest interface{ Validate() error }
-		bufData    bytes.Buffer
+		rtbFields  jsonFields
+		opts       []BidRequestRTBOption
 	)
+	version = d.protocol.Version()
+	opts = d.getRequestOptions(request, version)
 
-	if d.source.Protocol == "openrtb3" {
-		rtbRequest = requestToRTBv3(request, d.getRequestOptions()...)
+	// The invalid objects are removed before the OpenRTB 2.6 fields which refer the impressions by index
+	if version == ProtocolVersion30 {
+		rtbRequestV3 := BuildRequestV3(request, opts...)
+		if d.opts.RequestDowngrade {
+			d.reportRequestDrops(request, openrtbV3Downgrade(rtbRequestV3))
+		}
+		rtbRequest = rtbRequestV3
 	} else {
-		rtbRequest = requestToRTBv2(request, d.getRequestOptions()...)
+		rtbRequestV2 := BuildRequestV2(request, opts...)
+		if d.opts.RequestDowngrade {
+			d.reportRequestDrops(request, openrtbV2Downgrade(rtbRequestV2))
+		}
+		rtbFields = openrtbV26Extend(request, rtbRequestV2, newBidRequestRTBOptions(opts...))
+		rtbRequest = rtbRequestV2
 	}
 
-	if d.source.Options.Trace != 0 {
-		ctxlogger.Get(request.Context()).Error("trace marshal",
+	if d.isTrace(request) {
+		d.requestLogger(request).Error("trace marshal",
 			zap.String("src_url", d.source.URL))
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
@@ -328,45 +773,110 @@ func (d *driver) request(request adtype.BidRequester) (req httpclient.Request, e
 	}
 
 	if err := rtbRequest.Validate(); err != nil {
-		return nil,
+		return nil, version,
 			errors.Wrap(err, fmt.Sprintf("source[%s]: %d", d.source.Protocol, d.source.ID))
 	}
 
-	// Prepare data for request
-	if err = json.NewEncoder(&bufData).Encode(rtbRequest); err != nil {
-		return nil,
+	// Prepare data for request, the extra fields, the field restrictions and the price
+	// precision of the source are applied by the single rewrite of the encoded request
+	if data, err = json.Marshal(rtbRequest); err == nil {
+		data, err = rewriteRequest(data, rtbFields, d.fieldFilter, d.priceDecimals)
+	}
+	// The protobuf request is converted from the final JSON request (OpenRTB 2.x schema only)
+	if err == nil && d.isProtobufRequest(version) {
+		data, err = encodeRequestProtobuf(data)
+	}
+	// The XML request is converted from the final JSON request with the same field names
+	if err == nil && d.source.RequestType == RequestTypeXML {
+		data, err = encodeRequestXML(data)
+	}
+	if err != nil {
+		return nil, version,
 			errors.Wrap(err, fmt.Sprintf("source[%s]: %d", d.source.Protocol, d.source.ID))
 	}
 
-	// Create new request
-	if req, err = d.netClient.Request(d.source.Method, d.source.URL, &bufData); err != nil {
-		return req, err
+	raw.setRequest(data)
+	if d.gzip {
+		if data, err = gzipRequest(data); err != nil {
+			return nil, version, err
+		}
 	}
+	return data, version, nil
+}
 
-	d.fillRequest(request, req)
+// prepare HTTP request for RTB from the encoded data bound to the context
+func (d *driver) request(ctx context.Context, request adtype.BidRequester, data []byte, version string) (req httpclient.Request, err error) {
+	if req, err = newHTTPRequest(ctx, d.netClient, d.source.Method, d.source.URL, bytes.NewReader(data)); err != nil {
+		return req, err
+	}
+	d.fillRequest(request, req, version)
 	return req, nil
 }
 
-func (d *driver) unmarshal(request adtype.BidRequester, r io.Reader) (_ *adresponse.BidResponse, err error) {
+// decodeJSON response in the strict or the lenient mode of the source
+func (d *driver) decodeJSON(r io.Reader, bidResp *openrtb.BidResponse) error {
+	if d.toleranceMetric == nil {
+		return adresponse.DecodeBidResponse(r, bidResp)
+	}
+	return adresponse.DecodeBidResponseLenient(r, bidResp, d.observeTolerance)
+}
+
+// decodeJSONBytes of the response body already read in the same way as decodeJSON,
+// the strict mode decodes the data without the copy into the decoder buffer
+func (d *driver) decodeJSONBytes(data []byte, bidResp *openrtb.BidResponse) error {
+	if d.toleranceMetric == nil {
+		return adresponse.DecodeBidResponseBytes(data, bidResp)
+	}
+	return adresponse.DecodeBidResponseLenient(bytes.NewReader(data), bidResp, d.observeTolerance)
+}
+
+// observeTolerance counts the tolerance exercised by the lenient decoding
+func (d *driver) observeTolerance(tolerance adresponse.Tolerance) {
+	d.toleranceMetric.WithLabelValues(string(tolerance)).Inc()
+}
+
+// observeResponseSchema counts the schema features of the response bids if enabled
+func (d *driver) observeResponseSchema(data []byte) {
+	if d.schemaMetric == nil {
+		return
+	}
+	_ = adresponse.ObserveResponseSchema(data, func(feature adresponse.SchemaFeature) {
+		d.schemaMetric.WithLabelValues(string(feature)).Inc()
+	})
+}
+
+func (d *driver) unmarshal(request adtype.BidRequester, r io.Reader, contentType string) (_ *adresponse.BidResponse, err error) {
 	var bidResp openrtb.BidResponse
 
-	switch d.source.RequestType {
-	case RequestTypeJSON:
-		if d.source.Options.Trace != 0 {
-			var data []byte
-			if data, err = io.ReadAll(r); err == nil {
-				var buf bytes.Buffer
-				_ = json.Indent(&buf, data, "", "  ")
-				ctxlogger.Get(request.Context()).Error("trace unmarshal",
-					zap.String("src_url", d.source.URL))
-				_, _ = fmt.Fprintln(os.Stdout, "UNMARSHAL: "+buf.String())
-				err = json.Unmarshal(data, &bidResp)
+	switch {
+	// The protobuf response is detected by the content type independently of the request type,
+	// the protobuf sources can respond by JSON as well
+	case adresponse.IsProtobufContentType(contentType) ||
+		(d.source.RequestType == RequestTypeProtobuff && !isJSONContentType(contentType)):
+		err = adresponse.DecodeBidResponseProtobuf(r, &bidResp)
+	// The XML sources can respond by JSON as well
+	case adresponse.IsXMLContentType(contentType) ||
+		(d.source.RequestType == RequestTypeXML && !isJSONContentType(contentType)):
+		err = adresponse.DecodeBidResponseXML(r, &bidResp)
+	case d.source.RequestType == RequestTypeJSON || d.source.RequestType == RequestTypeProtobuff ||
+		d.source.RequestType == RequestTypeXML:
+		if trace := d.isTrace(request); trace || d.schemaMetric != nil {
+			data := adresponse.AcquireBodyBuffer()
+			if _, err = data.ReadFrom(r); err == nil {
+				if trace {
+					var buf bytes.Buffer
+					_ = json.Indent(&buf, data.Bytes(), "", "  ")
+					d.requestLogger(request).Error("trace unmarshal",
+						zap.String("src_url", d.source.URL))
+					_, _ = fmt.Fprintln(os.Stdout, "UNMARSHAL: "+buf.String())
+				}
+				d.observeResponseSchema(data.Bytes())
+				err = d.decodeJSONBytes(data.Bytes(), &bidResp)
 			}
+			adresponse.ReleaseBodyBuffer(data)
 		} else {
-			err = json.NewDecoder(r).Decode(&bidResp)
+			err = d.decodeJSON(r, &bidResp)
 		}
-	case RequestTypeXML, RequestTypeProtobuff:
-		err = fmt.Errorf("request body type not supported: %s", d.source.RequestType.Name())
 	default:
 		err = fmt.Errorf("undefined request type: %s", d.source.RequestType.Name())
 	}
@@ -375,69 +885,53 @@ func (d *driver) unmarshal(request adtype.BidRequester, r io.Reader) (_ *adrespo
 		return nil, err
 	}
 
-	// Check response for support HTTPS
-	if request.IsSecure() {
-		for _, seat := range bidResp.SeatBid {
-			for _, bid := range seat.Bid {
-				if strings.Contains(bid.AdMarkup, "http://") {
-					return nil, ErrResponseAreNotSecure
-				}
-			}
-		} // end for
-	}
-
-	// Check response for price limits
-	if d.source.MaxBid > 0 {
-		maxBid := d.source.MaxBid.Float64()
-		for i, seat := range bidResp.SeatBid {
-			changed := false
-			for j, bid := range seat.Bid {
-				if bid.Price > maxBid {
-					// Remove bid from response if price is more than max bid
-					// TODO: add metrics for this case
-					seat.Bid = append(seat.Bid[:j], seat.Bid[j+1:]...)
-					changed = true
-				}
-			}
-			if changed {
-				if len(seat.Bid) == 0 {
-					bidResp.SeatBid = append(bidResp.SeatBid[:i], bidResp.SeatBid[i+1:]...)
-				} else {
-					bidResp.SeatBid[i] = seat
-				}
-			}
-		}
-	}
+	return prepareBidResponse(request, d, bidResp, d.parseOptions)
+}
 
-	// If the response is empty, then return nil
-	if len(bidResp.SeatBid) == 0 {
-		return nil, nil
-	}
+// isProtobufRequest returns true if the request of the version is encoded by protobuf,
+// only the OpenRTB 2.x schema is supported and the 3.0 requests are sent as JSON
+func (d *driver) isProtobufRequest(version string) bool {
+	return d.source.RequestType == RequestTypeProtobuff && version != ProtocolVersion30
+}
 
-	// Build response
-	bidResponse := &adresponse.BidResponse{
-		Src:         d,
-		Req:         request,
-		BidResponse: bidResp,
-	}
+// isJSONContentType returns true if the content type is the JSON document
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
 
-	bidResponse.Prepare()
-	return bidResponse, nil
+// responseContentType returns the content type of the response if the client provides the headers
+func responseContentType(resp httpclient.Response) string {
+	return responseHeader(resp, "Content-Type")
 }
 
 // fillRequest of HTTP
-func (d *driver) fillRequest(request adtype.BidRequester, httpReq httpclient.Request) {
-	httpReq.SetHeader("Content-Type", "application/json")
+func (d *driver) fillRequest(request adtype.BidRequester, httpReq httpclient.Request, version string) {
+	switch {
+	case d.isProtobufRequest(version):
+		httpReq.SetHeader("Content-Type", adresponse.ContentTypeProtobuf)
+		httpReq.SetHeader("Accept", adresponse.ContentTypeProtobuf+", application/json;q=0.5")
+	case d.source.RequestType == RequestTypeXML:
+		httpReq.SetHeader("Content-Type", ContentTypeXML)
+		httpReq.SetHeader("Accept", ContentTypeXML+", text/xml, application/json;q=0.5")
+	default:
+		httpReq.SetHeader("Content-Type", "application/json")
+	}
+
+	// Compressed request body, the compressed response is negotiated by the HTTP transport
+	// which decompresses it transparently only if Accept-Encoding isn't set manually
+	if d.gzip {
+		httpReq.SetHeader("Content-Encoding", encodingGzip)
+	}
 
 	// Set OpenRTB version
 	if _, ok := d.headers[headerRequestOpenRTBVersion]; !ok {
-		if d.source.Protocol == "openrtb3" {
-			httpReq.SetHeader(headerRequestOpenRTBVersion, headerRequestOpenRTBVersion3)
-		} else {
-			httpReq.SetHeader(headerRequestOpenRTBVersion, headerRequestOpenRTBVersion2)
-		}
+		httpReq.SetHeader(headerRequestOpenRTBVersion, version)
 	}
 
+	// Set trace ID to follow the auction across the systems
+	httpReq.SetHeader(d.traceIDHeader(), d.traceID(request))
+
 	// Set request timemark for latency tracking
 	httpReq.SetHeader(openlatency.HTTPHeaderRequestTimemark,
 		strconv.FormatInt(openlatency.RequestInitTime(request.Time()), 10))
@@ -451,13 +945,20 @@ func (d *driver) fillRequest(request adtype.BidRequester, httpReq httpclient.Req
 // @link https://golang.org/src/net/http/status.go
 func (d *driver) processHTTPReponse(resp httpclient.Response, err error) {
 	switch {
+	case errors.Is(err, context.Canceled):
+		// The request cancelled by the auction doesn't affect the source state
 	case err != nil || resp == nil ||
-		(resp.StatusCode() != http.StatusOK &&
-			resp.StatusCode() != http.StatusNoContent &&
-			resp.StatusCode() != http.StatusNotFound):
-		if errors.Is(err, http.ErrHandlerTimeout) {
+		(resp.StatusCode() != http.StatusOK && !d.isNoBidStatus(resp.StatusCode())):
+		if d.inWarmupGrace() {
+			// The cold-start errors don't affect the source state
+			return
+		}
+		timeout := errors.Is(err, http.ErrHandlerTimeout) || errors.Is(err, context.DeadlineExceeded)
+		if timeout {
 			d.latencyMetrics.IncTimeout()
 		}
+		d.weighter.RecordRequest(timeout)
+		d.adaptive.Record(true)
 		d.errorCounter.Inc()
 		if resp == nil {
 			d.latencyMetrics.IncError(openlatency.MetricErrorHTTP, "")
@@ -466,15 +967,221 @@ func (d *driver) processHTTPReponse(resp httpclient.Response, err error) {
 		}
 	default:
 		d.errorCounter.Dec()
+		d.weighter.RecordRequest(false)
+		d.adaptive.Record(false)
 	}
 }
 
-func (d *driver) getRequestOptions() []BidRequestRTBOption {
-	return []BidRequestRTBOption{
+func (d *driver) getRequestOptions(request adtype.BidRequester, version string) []BidRequestRTBOption {
+	opts := []BidRequestRTBOption{
+		WithProtocolVersion(version),
 		WithRTBOpenNativeVersion("1.1"),
-		WithFormatFilter(d.source.TestFormat),
-		WithMaxTimeDuration(time.Duration(d.source.Timeout) * time.Millisecond),
+		WithFormatFilter(d.testFormat),
+		WithMaxTimeDuration(d.requestTimeMax(request)),
 		WithAuctionType(d.source.AuctionType),
 		WithBidFloor(d.source.MinBid.Float64()),
+		WithKeywordsFormat(d.opts.KeywordsFormat),
+		WithCategoryTaxonomy(d.opts.CategoryTaxonomy),
+		WithBlockedAttributes(d.blockedAttributes),
+		WithBlockedAdvertisers(d.blockedAdvertisers),
+		WithClickBrowser(d.clickBrowser),
+		WithImpIDCodec(d.opts.ImpIDCodec),
+	}
+	if len(d.formatBidFloors) > 0 {
+		opts = append(opts, WithFormatBidFloor(d.formatBidFloors))
+	}
+	if len(d.opts.AllowedSeats) > 0 || len(d.opts.BlockedSeats) > 0 {
+		opts = append(opts, WithSeats(d.opts.AllowedSeats, d.opts.BlockedSeats))
+	}
+	if d.blocklist != nil {
+		opts = append(opts, WithImpFilter(d.blocklist.AllowImp))
+	}
+	if rate, ok := d.currencyRate(); ok {
+		opts = append(opts, WithCurrency(d.sourceCurrency(), rate))
+	}
+	if len(d.opts.Currencies) > 0 {
+		opts = append(opts, WithAcceptedCurrencies(d.opts.Currencies...))
+	}
+	if d.opts.URLNormalization != nil {
+		opts = append(opts, WithURLNormalization(d.opts.URLNormalization))
+	}
+	if d.opts.EmptyDevicePolicy != nil {
+		opts = append(opts, WithEmptyDevicePolicy(d.opts.EmptyDevicePolicy))
+	}
+	if d.opts.RewardedProvider != nil {
+		opts = append(opts, WithRewarded(d.opts.RewardedProvider.IsRewarded))
+	}
+	if d.opts.MRAIDProvider != nil {
+		opts = append(opts, WithMRAID(d.opts.MRAIDProvider.MRAIDFrameworks))
+	}
+	if d.opts.LanguageProvider != nil {
+		opts = append(opts, WithLanguages(d.opts.LanguageProvider.Languages))
+	}
+	if lang := requestContentLanguage(request); lang != "" {
+		opts = append(opts, WithContentLanguage(lang))
+	}
+	if d.opts.DealProvider != nil {
+		opts = append(opts, WithPMP(d.opts.DealProvider.PMP))
+	}
+	if d.opts.CorrelationMode != CorrelationOff {
+		opts = append(opts, WithCorrelationKey(d.opts.CorrelationKey))
+	}
+	if d.opts.VideoProvider != nil {
+		opts = append(opts, WithVideo(d.opts.VideoProvider.VideoPlacement))
+	}
+	if d.opts.SKAdNProvider != nil {
+		opts = append(opts, WithSKAdN(d.opts.SKAdNProvider.SKAdN))
+	}
+	if d.opts.PlacementDataProvider != nil {
+		opts = append(opts, WithPlacementData(d.opts.PlacementDataProvider.PlacementData))
+	}
+	if pub := requestPublisher(request, d.opts.PublisherProvider); pub != nil {
+		opts = append(opts, WithPublisher(pub))
+	}
+	if d.opts.PublisherDataProvider != nil {
+		data := filterDataKeys(d.opts.PublisherDataProvider.PublisherData(request), d.opts.PublisherDataKeys)
+		if len(data) > 0 {
+			opts = append(opts, WithPublisherData(data))
+		}
+	}
+	if d.opts.GDPRGeoDetection {
+		opts = append(opts, WithGDPRGeoDetection(d.opts.GDPRDefault))
+	}
+	if d.opts.GDPR != nil {
+		opts = append(opts, WithGDPR(*d.opts.GDPR))
+	} else if applies, ok := requestGDPR(request); ok {
+		opts = append(opts, WithGDPR(applies))
+	}
+	if consent := requestGDPRConsent(request); consent != "" {
+		opts = append(opts, WithGDPRConsent(consent))
+	}
+	if gpp, sids := requestGPP(request); gpp != "" {
+		opts = append(opts, WithGPP(gpp, sids...))
+	}
+	if offset, ok := requestUTCOffset(request, d.now()); ok {
+		opts = append(opts, WithUTCOffset(offset))
+	}
+	if enabled, ok := requestGeoFetch(request); ok {
+		opts = append(opts, WithGeoFetch(enabled))
+	}
+	if d.opts.FrequencyProvider != nil {
+		if freq := d.opts.FrequencyProvider.UserFrequency(request.Context(), request, d.ID()); freq != nil {
+			opts = append(opts, WithUserFrequency(freq))
+		}
+	}
+	if d.opts.SegmentProvider != nil {
+		segments := resolveUserSegments(request.Context(),
+			d.opts.SegmentProvider, request, d.segmentTimeout(request))
+		if len(segments) > 0 {
+			opts = append(opts, WithUserSegments(segments))
+		}
+	}
+	if d.pageContext != nil {
+		if site := request.SiteInfo(); site != nil {
+			if pageCtx := d.pageContext.Get(site.Page); pageCtx != nil {
+				opts = append(opts, WithPageContext(pageCtx))
+			}
+		}
+	}
+	if override := d.requestOverride(request); override != nil {
+		opts = append(opts, override.Options...)
+	}
+	return opts
+}
+
+// logger of the driver or the context logger
+func (d *driver) logger(ctx context.Context) *zap.Logger {
+	if d.opts.Logger != nil {
+		return d.opts.Logger
+	}
+	return ctxlogger.Get(ctx)
+}
+
+// now returns the current time of the driver clock
+func (d *driver) now() time.Time {
+	if d.opts.Clock != nil {
+		return d.opts.Clock()
+	}
+	return time.Now()
+}
+
+// isNoBidStatus returns true if the HTTP status code means benign no-bid response
+// which doesn't affect the error counter of the source
+func (d *driver) isNoBidStatus(statusCode int) bool {
+	if d.opts.NoBidStatusCodes != nil {
+		return slices.Contains(d.opts.NoBidStatusCodes, statusCode)
+	}
+	return statusCode == http.StatusNoContent || statusCode == http.StatusNotFound
+}
+
+// requestTimeMax returns the maximal time of the bid response.
+// If the request context has a deadline, then the remaining time minus
+// network overhead is used when it's less than the source timeout.
+func (d *driver) requestTimeMax(request adtype.BidRequester) time.Duration {
+	timeMax := time.Duration(d.source.Timeout) * time.Millisecond
+	if ctx := request.Context(); ctx != nil {
+		if deadline, ok := ctx.Deadline(); ok {
+			remaining := deadline.Sub(d.now()) - d.opts.NetworkOverhead
+			if remaining > 0 && (timeMax <= 0 || remaining < timeMax) {
+				timeMax = remaining
+			} else if remaining <= 0 {
+				timeMax = time.Millisecond
+			}
+		}
+	}
+	if override := d.requestOverride(request); override != nil && override.Timeout > 0 &&
+		(timeMax <= 0 || override.Timeout < timeMax) {
+		timeMax = override.Timeout
+	}
+	return timeMax
+}
+
+// cachedDirectResponse returns the response from the cached direct bid
+// if the request contains the only direct impression
+func (d *driver) cachedDirectResponse(request adtype.BidRequester) adtype.Response {
+	imps := request.Impressions()
+	if d.directCache == nil || len(imps) != 1 || !imps[0].IsDirect() {
+		return nil
+	}
+	format := imps[0].FormatByType(types.FormatDirectType)
+	if format == nil {
+		return nil
+	}
+	bid, ok := d.directCache.Take(imps[0].TargetCodename())
+	if !ok {
+		return nil
+	}
+	bid.ImpID = adresponse.ImpIDCodecOrDefault(d.opts.ImpIDCodec).Encode(imps[0], format)
+	// The win and billing notices are fired by the original win of the bid only
+	bid.NURL, bid.BURL = "", ""
+	bidResponse := &adresponse.BidResponse{
+		Src:        d,
+		Req:        request,
+		Cached:     true,
+		ImpIDCodec: d.opts.ImpIDCodec,
+		BidResponse: openrtb.BidResponse{
+			ID:      request.ID(),
+			SeatBid: []openrtb.SeatBid{{Bid: []openrtb.Bid{bid}}},
+		},
+	}
+	bidResponse.Prepare()
+	if len(bidResponse.Ads()) == 0 {
+		return nil
+	}
+	return bidResponse
+}
+
+func isCachedResponse(response adtype.Response) bool {
+	resp, _ := response.(*adresponse.BidResponse)
+	return resp != nil && resp.Cached
+}
+
+// segmentTimeout returns the time of the audience segments resolving
+// which never exceeds the half of the request time budget
+func (d *driver) segmentTimeout(request adtype.BidRequester) time.Duration {
+	timeout := d.opts.SegmentTimeout
+	if budget := d.requestTimeMax(request) / 2; budget > 0 && (timeout <= 0 || budget < timeout) {
+		timeout = budget
 	}
+	return timeout
 }
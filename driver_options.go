@@ -0,0 +1,993 @@
+package adsourceopenrtb
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// DriverOptions of the driver initialization
+type DriverOptions struct {
+	// ProtocolNegotiation enables automatic fallback to the older protocol
+	// versions if the source rejects requests of the current version
+	// and reports the version it supports by the X-Openrtb-Version header
+	ProtocolNegotiation bool
+
+	// MaxProtocolVersion supported by the source ("2.5", "2.6", "3.0")
+	MaxProtocolVersion string
+
+	// ProtocolProbeInterval after which the driver sends the probe request
+	// to upgrade the protocol version back to the maximal one
+	ProtocolProbeInterval time.Duration
+
+	// NetworkOverhead subtracted from the remaining request context deadline
+	// to calculate the maximal time of the bid response (TMax)
+	NetworkOverhead time.Duration
+
+	// DirectBidCacheTTL of the winning direct (pop) bids if the bid has no expiration.
+	// The cache is disabled if the value is zero.
+	DirectBidCacheTTL time.Duration
+
+	// DirectBidCacheMaxUses of the cached bid before removal
+	DirectBidCacheMaxUses int
+
+	// FrequencyProvider of the user frequency data sent in `user.ext.frequency`
+	FrequencyProvider FrequencyProvider
+
+	// SegmentProvider of the external audience segments sent in `user.data`
+	SegmentProvider SegmentProvider
+
+	// SegmentTimeout of the audience segments resolving
+	SegmentTimeout time.Duration
+
+	// PageContextProvider of the contextual keywords and categories of the site page
+	PageContextProvider PageContextProvider
+
+	// PageContextTTL of the cached page context
+	PageContextTTL time.Duration
+
+	// PageContextTimeout of the page context resolving (1 second by default)
+	// and PageContextCacheSize of the cached pages (10000 by default)
+	PageContextTimeout   time.Duration
+	PageContextCacheSize int
+
+	// KeywordsFormat of the site/app/user keywords supported by the source
+	KeywordsFormat KeywordsFormat
+
+	// CategoryTaxonomy of the categories used by the source (cattax)
+	CategoryTaxonomy int
+
+	// BlockedCategories of the advertisement in terms of the category taxonomy
+	BlockedCategories []string
+
+	// BlockedAdvDomains and BlockedApps of the advertisers (badv, bapp)
+	BlockedAdvDomains []string
+	BlockedApps       []string
+
+	// BlockedAdvertisersProvider of the blocked advertisers of the placements
+	// merged with the blocked advertisers of the source
+	BlockedAdvertisersProvider BlockedAdvertisersProvider
+
+	// BlockedAttributes of the creatives per media type by default
+	BlockedAttributes *BlockedAttributes
+
+	// BlockedAttributesProvider of the creative attributes blocked per placement
+	BlockedAttributesProvider BlockedAttributesProvider
+
+	// ClickBrowser type of the in-app placements by default
+	ClickBrowser ClickBrowser
+
+	// ClickBrowserProvider of the click browser type per placement
+	ClickBrowserProvider ClickBrowserProvider
+
+	// GDPRGeoDetection enables inference of the `regs.ext.gdpr` flag by the user country
+	GDPRGeoDetection bool
+
+	// GDPRDefault applicability for the requests with unknown user country
+	GDPRDefault bool
+
+	// GDPR forces the applicability flag of the source requests (the request values
+	// and the geo detection are used if not defined)
+	GDPR *bool
+
+	// StrictValidation mode of the bids in the response
+	StrictValidation StrictValidationMode
+
+	// ViolationReporter receives the specification violations of the source bids
+	ViolationReporter ViolationReporter
+
+	// CorrelationMode of the impression correlation token echo and the key of the tokens
+	CorrelationMode CorrelationMode
+	CorrelationKey  []byte
+
+	// SpoofMode of the advertiser domain and bundle spoof detection, the seat which introduces
+	// more than SpoofMaxNewDomains new adomains in the SpoofWindow is quarantined for SpoofQuarantine
+	SpoofMode          SpoofMode
+	SpoofWindow        time.Duration
+	SpoofMaxNewDomains int
+	SpoofQuarantine    time.Duration
+
+	// BudgetThrottle enables the probabilistic throttling of the source
+	// which exceeds the latency or the response size budget
+	BudgetThrottle bool
+
+	// LatencyBudget of the source responses (source timeout by default)
+	LatencyBudget time.Duration
+
+	// ResponseSizeBudget of the source responses in bytes (0 - unlimited)
+	ResponseSizeBudget int64
+
+	// BudgetPercentile of the measurements compared with the budgets (0.95 by default)
+	BudgetPercentile float64
+
+	// Currency of the source bids and floors (system currency by default),
+	// the source config (`currency`) has priority
+	Currency string
+
+	// ExchangeRates provider to convert prices between the system and the source currencies
+	ExchangeRates ExchangeRateProvider
+
+	// Currencies of the bids accepted in addition to the source currency,
+	// the source config (`currencies`) is merged with them
+	Currencies []string
+
+	// CurrencyPolicy of the responses with absent or mismatched currency (converted by default)
+	CurrencyPolicy CurrencyPolicy
+
+	// MaxInFlight requests to the source at the same time (0 - unlimited)
+	MaxInFlight int
+
+	// RawPayloadSampleRate of the requests which keep the raw wire payloads (0 - disabled, 1 - all)
+	RawPayloadSampleRate float64
+
+	// RawPayloadMaxSize of the retained raw request and response in bytes
+	RawPayloadMaxSize int
+
+	// TraceSink of the payloads captured automatically when the failure rate (errors, parse failures)
+	// of the window exceeds AnomalyErrorRate, the number of the captures per window is limited
+	TraceSink          TraceSink
+	AnomalyErrorRate   float64
+	AnomalyCaptures    int
+	AnomalyWindow      time.Duration
+	AnomalyMinRequests int
+
+	// NoBidStatusCodes of the HTTP responses treated as benign no-bid (204 and 404 by default)
+	NoBidStatusCodes []int
+
+	// MaxRetries of the failed requests (connection errors, 502 and 503 responses)
+	MaxRetries int
+
+	// RetryMinBudget of the remaining request time required for the retry
+	RetryMinBudget time.Duration
+
+	// PlacementDataProvider of the placement first-party data sent in `imp.ext.data`
+	PlacementDataProvider PlacementDataProvider
+
+	// PublisherProvider of the site or application publisher (the account ID of the placements by default)
+	PublisherProvider PublisherProvider
+
+	// PublisherDataProvider of the publisher first-party data sent in `site.ext.data` / `app.ext.data`
+	PublisherDataProvider PublisherDataProvider
+
+	// PublisherDataKeys allowed to be sent to the source (all keys if empty)
+	PublisherDataKeys []string
+
+	// ImpIDCodec of the impression IDs in the requests and responses
+	ImpIDCodec adresponse.ImpIDCodec
+
+	// StrictImpIDMatch refuses the fallback matching of the response impression IDs
+	// by the ID variation, only the IDs sent in the request are accepted
+	StrictImpIDMatch bool
+
+	// RewardedProvider detects the rewarded placements
+	RewardedProvider RewardedProvider
+
+	// RPSBurst of the source RPS limit, the requests above the RPS are allowed
+	// up to the burst after the idle time (the RPS by default)
+	RPSBurst int
+
+	// AdaptiveRPS lowers the source RPS towards the floor (10% of the RPS by default)
+	// when the timeout and error rate of the interval exceeds the threshold (20% by default)
+	// and ramps it back up when the source is healthy
+	AdaptiveRPS       bool
+	AdaptiveRPSFloor  int
+	AdaptiveErrorRate float64
+	AdaptiveInterval  time.Duration
+
+	// KeyRPS limit of the requests per key (publisher or zone) with the independent budgets
+	KeyRPS     int
+	KeyRPSFunc RateLimitKeyFunc
+
+	// BlockedZones and BlockedDomains (with the subdomains) excluded from the source,
+	// merged with the source config (`blocked_zones`, `blocked_domains`)
+	BlockedZones   []uint64
+	BlockedDomains []string
+
+	// SourceBlocklistProvider of the placements which blocklist the source
+	SourceBlocklistProvider SourceBlocklistProvider
+
+	// HourlySpendCap and DailySpendCap of the source won bids in the system currency
+	// (the source daily budget if the daily cap is not defined, 0 - unlimited),
+	// the spend is reset at the start of each hour and day in the SpendCapLocation (UTC by default)
+	HourlySpendCap   float64
+	DailySpendCap    float64
+	SpendCapLocation *time.Location
+
+	// TraceIDHeader of the outbound requests with the auction trace ID (X-Trace-Id by default)
+	TraceIDHeader string
+
+	// DynamicWeight of the source computed by the win rate, eCPM, timeout rate and discrepancy
+	// and recomputed once per WeightInterval up to WeightMax (the source minimal weight is used if disabled)
+	DynamicWeight       bool
+	WeightInterval      time.Duration
+	WeightMax           float64
+	DiscrepancyProvider DiscrepancyProvider
+
+	// MaxSeatBids and MaxResponseBids accepted from the source after the filtering (0 - unlimited)
+	MaxSeatBids     int
+	MaxResponseBids int
+
+	// AllowedSeats and BlockedSeats of the buyers sent in the requests (wseat, bseat),
+	// the bids of other seats are rejected
+	AllowedSeats []string
+	BlockedSeats []string
+
+	// PreferredSeats of the source with the selection boost factor of the seat bids,
+	// the preferred seats win the price ties (boost 1) or get the price boost in the bid selection
+	PreferredSeats map[string]float64
+
+	// WarmupConnections opened to the source on the driver creation (disabled if 0)
+	// and the timeout of the warm-up requests
+	WarmupConnections int
+	WarmupTimeout     time.Duration
+
+	// WarmupGrace window after the driver creation when the timeouts and errors
+	// don't feed the error counter, the error metrics and the latency budget (disabled if 0)
+	WarmupGrace time.Duration
+
+	// SecondPriceIncrement (CPM in the system currency) added to the competing price
+	// of the second-price auction (adresponse.DefaultSecondPriceIncrement by default)
+	SecondPriceIncrement float64
+
+	// FormatBidFloor overrides the source minimal bid per format type,
+	// the floors of the source config (`bid_floors`) have priority
+	FormatBidFloor map[types.FormatType]float64
+
+	// NotifyURLPolicy validates the notification URLs of the bids before firing,
+	// the public http(s) URLs are allowed by default
+	NotifyURLPolicy *NotifyURLPolicy
+
+	// MediaTypes bought by the source, the requests without the impressions of these types
+	// are skipped (all types if empty), the source config (`media_types`) has priority
+	MediaTypes []types.FormatType
+
+	// URLNormalization of the site page and referrer URLs,
+	// the source config (`url_normalization`) has priority
+	URLNormalization *URLNormalization
+
+	// EmptyDevicePolicy of the requests without the IP address or the user agent,
+	// the source config (`empty_device`) has priority
+	EmptyDevicePolicy *EmptyDevicePolicy
+
+	// ConfigRevision of the source settings reported by the snapshot,
+	// the hash of the source settings is used if empty
+	ConfigRevision string
+
+	// ErrorHistorySize of the last request errors reported by the snapshot (10 by default)
+	ErrorHistorySize int
+
+	// BillingNotices keeps the billing notice URLs (burl) until the impression event,
+	// the billing notices are fired on the win if nil
+	BillingNotices *BillingNotices
+
+	// RetryAfterMax of the source back off requested by the partner Retry-After (5 minutes by default)
+	RetryAfterMax time.Duration
+
+	// Gzip compression of the request bodies (Content-Encoding: gzip),
+	// the source config (`gzip`) has priority
+	Gzip bool
+
+	// LenientDecoding of the JSON responses tolerating the common spec violations
+	// (numeric strings, boolean flags, unknown enum values), the source config
+	// (`lenient_decoding`) has priority
+	LenientDecoding bool
+
+	// ResponseSchemaMetrics counts the schema features (version, mtype, eventtrackers, ext fields)
+	// of the JSON response bids, the response body is buffered for the detection
+	ResponseSchemaMetrics bool
+
+	// DeferredNURL fires the win and billing notices by the driver when the internal clearing is final
+	// with the final clearing price instead of returning the URLs with the raw bid price
+	DeferredNURL bool
+
+	// LateMacros of the markup left as the placeholders until the render time
+	// and bound by FinalizeMarkup of the response items (e.g., the final clearing price)
+	LateMacros []string
+
+	// PricingModel of the source bid prices (CPM by default), the source config (`pricing_model`)
+	// has priority. The CPC and CPV bids are converted into the effective CPM by the action rate
+	// estimates of the placements, the bids of the placements without the estimate are dropped.
+	PricingModel       types.PricingModel
+	ActionRateProvider ActionRateProvider
+
+	// MarkupWrapper of the third-party HTML markup by the source trust level (not wrapped if nil)
+	MarkupWrapper *adresponse.MarkupWrapper
+
+	// MRAIDProvider of the MRAID API frameworks supported by the application placements,
+	// the MRAID creatives are accepted only for the MRAID-capable placements if defined
+	MRAIDProvider MRAIDProvider
+
+	// RequestDowngrade removes the invalid impressions and objects from the request
+	// and sends the remaining valid request instead of failing the whole bid
+	RequestDowngrade bool
+
+	// PriceDecimals of the request prices (floors), the prices are formatted as the decimals
+	// with the limited number of the decimal places if defined
+	PriceDecimals int
+
+	// LanguageProvider of the creative languages allowed by the placements,
+	// the bids in other languages are rejected if defined
+	LanguageProvider LanguageProvider
+
+	// VideoProvider of the video parameters of the placements (mimes, durations, protocols, skip settings)
+	VideoProvider VideoProvider
+
+	// DealProvider of the private marketplace deals of the placements
+	DealProvider DealProvider
+
+	// SKAdNProvider of the application placements and the public keys of the ad networks
+	// used to verify the SKAdNetwork signatures of the bids in the strict validation mode
+	SKAdNProvider SKAdNProvider
+	SKAdNKeys     SKAdNKeys
+
+	// RequestFieldFilter of the encoded requests, the filter of the source config
+	// (`request_fields` with `allow` and `deny` lists) has priority
+	RequestFieldFilter *RequestFieldFilter
+
+	// Logger of the driver, the context logger is used if not defined
+	Logger *zap.Logger
+
+	// MetricsRegistry of the driver metrics (default prometheus registry if not defined)
+	MetricsRegistry prometheus.Registerer
+
+	// Clock returns the current time (time.Now by default)
+	Clock func() time.Time
+}
+
+// DriverOption set function
+type DriverOption func(opts *DriverOptions)
+
+// WithProtocolNegotiation enables the protocol version fallback
+// based on the source error responses
+func WithProtocolNegotiation(probeInterval time.Duration) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.ProtocolNegotiation = true
+		opts.ProtocolProbeInterval = probeInterval
+	}
+}
+
+// WithMaxProtocolVersion of the source protocol
+func WithMaxProtocolVersion(ver string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.MaxProtocolVersion = ver
+	}
+}
+
+// WithNetworkOverhead which is reserved from the request deadline
+// for the network transfer and response processing
+func WithNetworkOverhead(overhead time.Duration) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.NetworkOverhead = max(overhead, 0)
+	}
+}
+
+// WithDirectBidCache enables short-term caching of the winning direct (pop) bids
+// which will be served on the subsequent requests of the same zone
+func WithDirectBidCache(ttl time.Duration, maxUses int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.DirectBidCacheTTL = ttl
+		opts.DirectBidCacheMaxUses = maxUses
+	}
+}
+
+// WithFrequencyProvider set the provider of the user frequency capping signals
+func WithFrequencyProvider(provider FrequencyProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.FrequencyProvider = provider
+	}
+}
+
+// WithSegmentProvider set the audience segments provider (DMP) with
+// the maximal time of the segments resolving
+func WithSegmentProvider(provider SegmentProvider, timeout time.Duration) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.SegmentProvider = provider
+		opts.SegmentTimeout = timeout
+	}
+}
+
+// WithPageContextProvider set the provider of the contextual page data
+// which is cached per page URL for the TTL duration
+func WithPageContextProvider(provider PageContextProvider, ttl time.Duration) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.PageContextProvider = provider
+		opts.PageContextTTL = ttl
+	}
+}
+
+// WithPageContextLimits set the timeout of the page context resolving
+// and the maximal number of the cached pages
+func WithPageContextLimits(timeout time.Duration, size int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.PageContextTimeout = timeout
+		opts.PageContextCacheSize = size
+	}
+}
+
+// WithSourceKeywordsFormat set the keywords format (string, kwarray or both) supported by the source
+func WithSourceKeywordsFormat(format KeywordsFormat) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.KeywordsFormat = format
+	}
+}
+
+// WithSourceCategoryTaxonomy set the category taxonomy (cattax) used by the source
+func WithSourceCategoryTaxonomy(taxonomy int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.CategoryTaxonomy = taxonomy
+	}
+}
+
+// WithBlockedCategories set the list of blocked advertisement categories
+func WithBlockedCategories(categories ...string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.BlockedCategories = categories
+	}
+}
+
+// WithSourceBlockedAttributes set the default blocked creative attributes per media type
+func WithSourceBlockedAttributes(attrs BlockedAttributes) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.BlockedAttributes = &attrs
+	}
+}
+
+// WithBlockedAttributesProvider set the provider of the blocked creative attributes per placement
+func WithBlockedAttributesProvider(provider BlockedAttributesProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.BlockedAttributesProvider = provider
+	}
+}
+
+// WithSourceClickBrowser set the default click browser type of the in-app placements
+func WithSourceClickBrowser(browser ClickBrowser) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.ClickBrowser = browser
+	}
+}
+
+// WithClickBrowserProvider set the provider of the click browser type per placement
+func WithClickBrowserProvider(provider ClickBrowserProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.ClickBrowserProvider = provider
+	}
+}
+
+// WithSourceGDPR forces the GDPR applicability flag of the source requests
+func WithSourceGDPR(applies bool) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.GDPR = &applies
+	}
+}
+
+// WithSourceGDPRGeoDetection enables inference of the GDPR applicability
+// by the user country (EEA/UK) with the default value for the unknown countries
+func WithSourceGDPRGeoDetection(defaultApplies bool) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.GDPRGeoDetection = true
+		opts.GDPRDefault = defaultApplies
+	}
+}
+
+// WithStrictValidation enables the strict validation of the bids by the OpenRTB specification
+func WithStrictValidation(mode StrictValidationMode, reporter ViolationReporter) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.StrictValidation = mode
+		opts.ViolationReporter = reporter
+	}
+}
+
+// WithCorrelation enables the impression correlation token in `imp.ext.corr` which has to be
+// echoed by the partner in `bid.ext.corr` (or the tag ID in `bid.ext.tagid`),
+// the mismatched bids are the sign of the stale cached bids
+func WithCorrelation(mode CorrelationMode, key []byte) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.CorrelationMode = mode
+		opts.CorrelationKey = key
+	}
+}
+
+// WithBudgetThrottle enables throttling of the source requests if the percentile
+// of the latency or the response size exceeds the budget
+func WithBudgetThrottle(latency time.Duration, size int64, percentile float64) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.BudgetThrottle = true
+		opts.LatencyBudget = latency
+		opts.ResponseSizeBudget = size
+		opts.BudgetPercentile = percentile
+	}
+}
+
+// WithSourceCurrency set the currency of the source bids with the exchange rate provider
+// used to convert the floors into the source currency and bids back into the system currency,
+// the exchange rates of the factory are kept if the provider is nil
+func WithSourceCurrency(currency string, rates ExchangeRateProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.Currency = currency
+		if rates != nil {
+			opts.ExchangeRates = rates
+		}
+	}
+}
+
+// WithSourceCurrencies set the currencies of the bids accepted in addition to the source currency,
+// the bids in these currencies are converted into the system currency by the exchange rates
+func WithSourceCurrencies(currencies ...string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.Currencies = currencies
+	}
+}
+
+// WithCurrencyPolicy set the policy of the responses with absent or mismatched currency
+func WithCurrencyPolicy(policy CurrencyPolicy) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.CurrencyPolicy = policy
+	}
+}
+
+// WithMaxInFlight set the limit of the concurrent requests to the source
+func WithMaxInFlight(limit int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.MaxInFlight = max(limit, 0)
+	}
+}
+
+// WithRawPayloads enables retention of the raw request and response payloads
+// for the sampled requests accessible by `BidResponse.Get("raw_request"/"raw_response")`
+func WithRawPayloads(sampleRate float64, maxSize int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.RawPayloadSampleRate = sampleRate
+		opts.RawPayloadMaxSize = maxSize
+	}
+}
+
+// WithAnomalyTrace enables the automatic capture of the trace payloads into the sink
+// for the limited number of the requests when the failure rate of the source spikes
+func WithAnomalyTrace(sink TraceSink, errorRate float64, captures int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.TraceSink = sink
+		opts.AnomalyErrorRate = errorRate
+		opts.AnomalyCaptures = captures
+	}
+}
+
+// WithAnomalyWindow set the window of the failure rate and the minimal number of the requests
+// in the window to trigger the anomaly trace capture
+func WithAnomalyWindow(window time.Duration, minRequests int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.AnomalyWindow = window
+		opts.AnomalyMinRequests = minRequests
+	}
+}
+
+// WithNoBidStatusCodes set the HTTP status codes which are treated as no-bid responses
+// instead of errors. The list replaces the default codes (204, 404) so they have to be
+// included explicitly if required.
+func WithNoBidStatusCodes(codes ...int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.NoBidStatusCodes = append([]int{}, codes...)
+	}
+}
+
+// WithRetries enables retries of the failed requests if the remaining
+// latency budget is more than the previous attempt duration and the minimal budget
+func WithRetries(maxRetries int, minBudget time.Duration) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.MaxRetries = max(maxRetries, 0)
+		opts.RetryMinBudget = minBudget
+	}
+}
+
+// WithPlacementDataProvider set the provider of the placement first-party data
+func WithPlacementDataProvider(provider PlacementDataProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.PlacementDataProvider = provider
+	}
+}
+
+// WithPublisherProvider set the provider of the site or application publisher
+func WithPublisherProvider(provider PublisherProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.PublisherProvider = provider
+	}
+}
+
+// WithPublisherDataProvider set the provider of the publisher first-party data
+// with the allowlist of the keys which can be sent to the source
+func WithPublisherDataProvider(provider PublisherDataProvider, allowedKeys ...string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.PublisherDataProvider = provider
+		opts.PublisherDataKeys = allowedKeys
+	}
+}
+
+// WithSourceImpIDCodec set the scheme of the impression IDs used for the source
+func WithSourceImpIDCodec(codec adresponse.ImpIDCodec) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.ImpIDCodec = codec
+	}
+}
+
+// WithRPSBurst set the burst size of the source RPS limit
+func WithRPSBurst(burst int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.RPSBurst = burst
+	}
+}
+
+// WithAdaptiveRPS enables the adaptive RPS of the source lowered to the floor
+// on the error rate spikes and recovered gradually when the source is healthy
+func WithAdaptiveRPS(floor int, errorRate float64, interval time.Duration) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.AdaptiveRPS = true
+		opts.AdaptiveRPSFloor = floor
+		opts.AdaptiveErrorRate = errorRate
+		opts.AdaptiveInterval = interval
+	}
+}
+
+// WithKeyRateLimit set the requests per second limit per key returned by the key function,
+// for example RateLimitBySite or RateLimitByZone
+func WithKeyRateLimit(rps int, keyFn RateLimitKeyFunc) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.KeyRPS = rps
+		opts.KeyRPSFunc = keyFn
+	}
+}
+
+// WithTraceIDHeader set the header name of the auction trace ID
+func WithTraceIDHeader(header string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.TraceIDHeader = header
+	}
+}
+
+// WithDynamicWeight enables the dynamic weighting of the source
+func WithDynamicWeight(interval time.Duration, maxWeight float64, discrepancy DiscrepancyProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.DynamicWeight = true
+		opts.WeightInterval = interval
+		opts.WeightMax = maxWeight
+		opts.DiscrepancyProvider = discrepancy
+	}
+}
+
+// WithBidDensity set the maximal number of the bids accepted per seat and per response,
+// the most expensive bids are kept (0 - unlimited)
+func WithBidDensity(maxSeatBids, maxResponseBids int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.MaxSeatBids = maxSeatBids
+		opts.MaxResponseBids = maxResponseBids
+	}
+}
+
+// WithPreferredSeats set the preferred seats of the source with the selection boost factor
+func WithPreferredSeats(seats map[string]float64) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.PreferredSeats = seats
+	}
+}
+
+// WithWarmup set the number of the connections pre-warmed on the driver creation
+func WithWarmup(connections int, timeout time.Duration) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.WarmupConnections = connections
+		opts.WarmupTimeout = timeout
+	}
+}
+
+// WithWarmupGrace set the window after the driver creation when the cold-start
+// timeouts and errors are not counted to prevent the source flapping right after deploys
+func WithWarmupGrace(grace time.Duration) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.WarmupGrace = grace
+	}
+}
+
+// WithSecondPriceIncrement set the increment of the second-price auction settlement
+func WithSecondPriceIncrement(increment float64) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.SecondPriceIncrement = increment
+	}
+}
+
+// WithMarkupTrustLevel set the default markup wrapper of the source trust level
+func WithMarkupTrustLevel(level adresponse.MarkupTrustLevel) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.MarkupWrapper = adresponse.DefaultMarkupWrapper(level)
+	}
+}
+
+// WithMarkupWrapper set the custom wrapper of the third-party HTML markup
+func WithMarkupWrapper(wrapper *adresponse.MarkupWrapper) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.MarkupWrapper = wrapper
+	}
+}
+
+// WithMRAIDProvider set the provider of the MRAID API frameworks of the placements
+func WithMRAIDProvider(provider MRAIDProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.MRAIDProvider = provider
+	}
+}
+
+// WithSourceFormatBidFloor set the minimal bid values per format type
+// overriding the source minimal bid (e.g., the lower floor of the pop formats)
+func WithSourceFormatBidFloor(floors map[types.FormatType]float64) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.FormatBidFloor = floors
+	}
+}
+
+// WithStrictImpIDMatch enables the strict matching of the response impression IDs
+func WithStrictImpIDMatch() DriverOption {
+	return func(opts *DriverOptions) {
+		opts.StrictImpIDMatch = true
+	}
+}
+
+// WithNotifyURLPolicy set the validation policy of the notification URLs
+func WithNotifyURLPolicy(policy *NotifyURLPolicy) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.NotifyURLPolicy = policy
+	}
+}
+
+// WithDeferredNURL enables the win and billing notice firing after the internal clearing
+// with the final clearing price in the `${AUCTION_PRICE}` macro
+func WithDeferredNURL() DriverOption {
+	return func(opts *DriverOptions) {
+		opts.DeferredNURL = true
+	}
+}
+
+// WithLateMacros set the markup macros bound at the render time by FinalizeMarkup
+// of the response items instead of the response preparation
+// (e.g., adresponse.MacroAuctionPrice, adresponse.MacroTimestamp, adresponse.MacroCacheBuster)
+func WithLateMacros(macros ...string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.LateMacros = macros
+	}
+}
+
+// WithVideoProvider set the provider of the video parameters of the placements
+func WithVideoProvider(provider VideoProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.VideoProvider = provider
+	}
+}
+
+// WithDealProvider set the provider of the private marketplace deals of the placements
+func WithDealProvider(provider DealProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.DealProvider = provider
+	}
+}
+
+// WithRequestFieldFilter set the allowlist and the denylist of the request fields
+// by the dot-separated path (like `device.ifa` or `user.geo`)
+func WithRequestFieldFilter(allow, deny []string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.RequestFieldFilter = &RequestFieldFilter{Allow: allow, Deny: deny}
+	}
+}
+
+// WithSourceSKAdN set the SKAdNetwork parameters provider and the public keys of the ad networks
+func WithSourceSKAdN(provider SKAdNProvider, keys SKAdNKeys) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.SKAdNProvider = provider
+		opts.SKAdNKeys = keys
+	}
+}
+
+// WithRewardedProvider set the detector of the rewarded placements
+func WithRewardedProvider(provider RewardedProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.RewardedProvider = provider
+	}
+}
+
+// WithMediaTypes set the media types bought by the source
+func WithMediaTypes(mediaTypes ...types.FormatType) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.MediaTypes = mediaTypes
+	}
+}
+
+// WithSourceEmptyDevicePolicy set the policy of the requests without the IP address
+// and the requests without the device user agent (keep the placeholder, omit or skip)
+func WithSourceEmptyDevicePolicy(ip, device EmptyValuePolicy) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.EmptyDevicePolicy = &EmptyDevicePolicy{IP: ip, Device: device}
+	}
+}
+
+// WithSourceURLNormalization set the normalization of the site page and referrer URLs
+// (the credentials and fragments removal, the optional query removal and the length cap)
+func WithSourceURLNormalization(maxLength int, stripQuery bool) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.URLNormalization = &URLNormalization{MaxLength: maxLength, StripQuery: stripQuery}
+	}
+}
+
+// WithConfigRevision set the revision of the source settings reported by the snapshot
+func WithConfigRevision(revision string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.ConfigRevision = revision
+	}
+}
+
+// WithErrorHistory set the number of the last request errors reported by the snapshot
+func WithErrorHistory(size int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.ErrorHistorySize = size
+	}
+}
+
+// WithBillingNotices set the billing notices fired on the impression events instead of the win
+func WithBillingNotices(notices *BillingNotices) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.BillingNotices = notices
+	}
+}
+
+// WithRetryAfterMax set the maximal back off of the source requested by the partner Retry-After
+func WithRetryAfterMax(maxBackoff time.Duration) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.RetryAfterMax = maxBackoff
+	}
+}
+
+// WithGzip enables the gzip compression of the request bodies,
+// the gzip responses are decompressed independently of the option
+func WithGzip() DriverOption {
+	return func(opts *DriverOptions) {
+		opts.Gzip = true
+	}
+}
+
+// WithLenientDecoding enables the lenient decoding of the JSON responses
+// tolerating the common spec violations of the bid fields
+func WithLenientDecoding() DriverOption {
+	return func(opts *DriverOptions) {
+		opts.LenientDecoding = true
+	}
+}
+
+// WithResponseSchemaMetrics enables the metrics of the schema features used by the partner
+// in the JSON responses to see when the legacy code paths are safe to drop
+func WithResponseSchemaMetrics() DriverOption {
+	return func(opts *DriverOptions) {
+		opts.ResponseSchemaMetrics = true
+	}
+}
+
+// WithSourcePricingModel set the pricing model of the source bids and the provider
+// of the action rate estimates (CTR, VTR) of the placements used to convert the bids into eCPM
+func WithSourcePricingModel(model types.PricingModel, provider ActionRateProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.PricingModel = model
+		opts.ActionRateProvider = provider
+	}
+}
+
+// WithSpendCaps set the hourly and the daily caps of the source spend (0 - unlimited)
+// and the location of the day start (UTC if nil)
+func WithSpendCaps(hourly, daily float64, location *time.Location) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.HourlySpendCap = hourly
+		opts.DailySpendCap = daily
+		opts.SpendCapLocation = location
+	}
+}
+
+// WithBlockedZones set the zones and the domains (with the subdomains) excluded from the source
+func WithBlockedZones(zones []uint64, domains ...string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.BlockedZones = zones
+		opts.BlockedDomains = domains
+	}
+}
+
+// WithSourceBlocklistProvider set the provider of the placements which blocklist the source
+func WithSourceBlocklistProvider(provider SourceBlocklistProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.SourceBlocklistProvider = provider
+	}
+}
+
+// WithSpoofDetection enables the cross-check of the creative adomain and bundle across the repeated bids
+// and the detection of the new adomain bursts of the seats (the defaults are used for zero values)
+func WithSpoofDetection(mode SpoofMode, window time.Duration, maxNewDomains int, quarantine time.Duration) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.SpoofMode = mode
+		opts.SpoofWindow = window
+		opts.SpoofMaxNewDomains = maxNewDomains
+		opts.SpoofQuarantine = quarantine
+	}
+}
+
+// WithLanguageProvider set the provider of the creative languages allowed by the placements
+func WithLanguageProvider(provider LanguageProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.LanguageProvider = provider
+	}
+}
+
+// WithPriceDecimals set the number of the decimal places of the request prices
+func WithPriceDecimals(decimals int) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.PriceDecimals = decimals
+	}
+}
+
+// WithRequestDowngrade enables the removal of the invalid impressions and objects from the request
+// instead of failing the whole bid, the removed objects are logged and counted
+func WithRequestDowngrade(enabled bool) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.RequestDowngrade = enabled
+	}
+}
+
+// WithSourceSeats set the allowed and blocked buyer seats of the source
+func WithSourceSeats(allowed, blocked []string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.AllowedSeats = allowed
+		opts.BlockedSeats = blocked
+	}
+}
+
+// WithBlockedAdvDomains set the list of blocked advertiser domains
+func WithBlockedAdvDomains(domains ...string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.BlockedAdvDomains = domains
+	}
+}
+
+// WithBlockedApps set the list of blocked application bundles of the advertisers
+func WithBlockedApps(bundles ...string) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.BlockedApps = bundles
+	}
+}
+
+// WithBlockedAdvertisersProvider set the provider of the blocked advertisers of the placements
+func WithBlockedAdvertisersProvider(provider BlockedAdvertisersProvider) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.BlockedAdvertisersProvider = provider
+	}
+}
+
+func (opts *DriverOptions) apply(options ...any) {
+	for _, opt := range options {
+		if fn, _ := opt.(DriverOption); fn != nil {
+			fn(opts)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bsm/openrtb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adquery/bidrequest"
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/billing"
+	"github.com/geniusrabbit/adcorelib/eventtraking/eventstream"
+	"github.com/geniusrabbit/adcorelib/net/httpclient/stdhttpclient"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+var testFormats = types.NewSimpleFormatAccessor([]*types.Format{
+	{ID: 1, Codename: "banner_300x250", Types: *types.NewFormatTypeBitset(types.FormatBannerType), Width: 300, Height: 250},
+	{ID: 2, Codename: "native", Types: *types.NewFormatTypeBitset(types.FormatNativeType), Config: &types.FormatConfig{}},
+	{ID: 3, Codename: "direct", Types: *types.NewFormatTypeBitset(types.FormatDirectType)},
+	{ID: 4, Codename: "video", Types: *types.NewFormatTypeBitset(types.FormatVideoType), Width: 640, Height: 360,
+		Config: &types.FormatConfig{Assets: []types.FormatFileRequirement{{AllowedTypes: []string{"video/mp4", "image/png"}}}}},
+})
+
+// testTarget of the impression with the codename (tagid)
+type testTarget struct {
+	adtype.TargetEmpty
+	codename string
+}
+
+func (t *testTarget) Codename() string { return t.codename }
+
+// testSourceOption changes the test source before the driver creation
+type testSourceOption func(source *admodels.RTBSource)
+
+// newTestDriver returns the driver of the source served by the test handler
+func newTestDriver(t *testing.T, handler http.HandlerFunc, options ...any) *driver {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	source := &admodels.RTBSource{
+		ID:          1,
+		Protocol:    "openrtb",
+		URL:         server.URL,
+		Method:      http.MethodPost,
+		RequestType: RequestTypeJSON,
+		Timeout:     1000,
+	}
+	for _, opt := range options {
+		if fn, _ := opt.(testSourceOption); fn != nil {
+			fn(source)
+		}
+	}
+	options = append([]any{DriverOption(func(opts *DriverOptions) {
+		opts.MetricsRegistry = prometheus.NewRegistry()
+	})}, options...)
+	d, err := newDriver(context.Background(), source,
+		stdhttpclient.NewDriverWithHTTPClient(server.Client()), options...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return d
+}
+
+// newTestRequest returns the bid request with the impressions of the format codes
+func newTestRequest(ctx context.Context, codes ...string) *bidrequest.BidRequest {
+	imp := &adtype.Impression{ID: "imp1", FormatCodes: codes, Target: &testTarget{
+		TargetEmpty: adtype.TargetEmpty{Acc: &admodels.Account{IDval: 1}},
+		codename:    "zone1",
+	}}
+	imp.InitFormats(testFormats)
+	return &bidrequest.BidRequest{
+		IDVal:    "auction1",
+		Timemark: time.Now(),
+		Ctx:      ctx,
+		Imps:     []*adtype.Impression{imp},
+	}
+}
+
+// testBidResponse returns the response with the bid of the banner markup for each impression of the request
+func testBidResponse(t *testing.T, data []byte, price float64) []byte {
+	t.Helper()
+	var request openrtb.BidRequest
+	if !assert.NoError(t, json.Unmarshal(data, &request)) {
+		return nil
+	}
+	response := openrtb.BidResponse{ID: request.ID, SeatBid: []openrtb.SeatBid{{}}}
+	for i, imp := range request.Imp {
+		response.SeatBid[0].Bid = append(response.SeatBid[0].Bid, openrtb.Bid{
+			ID:         strconv.Itoa(i + 1),
+			ImpID:      imp.ID,
+			Price:      price,
+			AdMarkup:   `<div></div>`,
+			CreativeID: "c" + strconv.Itoa(i+1),
+		})
+	}
+	body, err := json.Marshal(response)
+	assert.NoError(t, err)
+	return body
+}
+
+func TestRequestTimeMax(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		timeout  int
+		deadline time.Duration
+		expected time.Duration
+	}{
+		{name: "no_deadline", timeout: 500, expected: 500 * time.Millisecond},
+		{name: "deadline_shrinks", timeout: 500, deadline: 300 * time.Millisecond, expected: 250 * time.Millisecond},
+		{name: "deadline_after_timeout", timeout: 500, deadline: time.Second, expected: 500 * time.Millisecond},
+		{name: "deadline_no_timeout", deadline: 300 * time.Millisecond, expected: 250 * time.Millisecond},
+		{name: "deadline_expired", timeout: 500, deadline: 20 * time.Millisecond, expected: time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := &driver{
+				source: &admodels.RTBSource{Timeout: test.timeout},
+				opts: DriverOptions{
+					NetworkOverhead: 50 * time.Millisecond,
+					Clock:           func() time.Time { return now },
+				},
+			}
+			ctx := context.Background()
+			if test.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, now.Add(test.deadline))
+				defer cancel()
+			}
+			assert.Equal(t, test.expected, d.requestTimeMax(newTestRequest(ctx)))
+		})
+	}
+}
+
+// testEncodeRequest returns the decoded JSON request encoded by the driver
+func testEncodeRequest(t *testing.T, d *driver, request adtype.BidRequester) map[string]any {
+	t.Helper()
+	data, _, err := d.encodeRequest(request, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var rtbRequest map[string]any
+	if !assert.NoError(t, json.Unmarshal(data, &rtbRequest)) {
+		t.FailNow()
+	}
+	return rtbRequest
+}
+
+// testCounters returns the counter values of the metric family by the label value
+func testCounters(t *testing.T, registry *prometheus.Registry, name, label string) map[string]float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	counters := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			var value string
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == label {
+					value = pair.GetValue()
+				}
+			}
+			counters[value] += metric.GetCounter().GetValue()
+		}
+	}
+	return counters
+}
+
+// testMetricsRegistry sets the registry of the driver metrics
+func testMetricsRegistry(registry *prometheus.Registry) DriverOption {
+	return func(opts *DriverOptions) {
+		opts.MetricsRegistry = registry
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	var (
+		registry = prometheus.NewRegistry()
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+	)
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		close(started)
+		<-release
+		_, _ = w.Write(testBidResponse(t, data, 1))
+	}, WithMaxInFlight(1), testMetricsRegistry(registry))
+
+	done := make(chan adtype.Response)
+	go func() { done <- d.Bid(newTestRequest(context.Background(), "banner_300x250")) }()
+	<-started
+
+	// The requests over the cap are skipped by the test and rejected by the bid
+	ok, reason := d.TestWithReason(newTestRequest(context.Background(), "banner_300x250"))
+	assert.False(t, ok)
+	assert.Equal(t, SkipReasonOverloaded, reason)
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.ErrorIs(t, resp.Error(), ErrSourceOverloaded)
+	assert.Equal(t, map[string]float64{"": 2}, testCounters(t, registry, "adsource_overloaded_total", ""))
+
+	close(release)
+	resp = <-done
+	assert.NoError(t, resp.Error())
+	assert.Len(t, resp.Ads(), 1)
+	assert.Zero(t, d.inFlight.Load())
+	ok, _ = d.TestWithReason(newTestRequest(context.Background(), "banner_300x250"))
+	assert.True(t, ok)
+}
+
+func TestNoBidStatusCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		codes   []int
+		status  int
+		noBid   bool
+		balance int32
+	}{
+		{name: "default_no_content", status: http.StatusNoContent, noBid: true},
+		{name: "default_not_found", status: http.StatusNotFound, noBid: true},
+		{name: "default_bad_request", status: http.StatusBadRequest, balance: 1},
+		{name: "custom_bad_request", codes: []int{http.StatusNoContent, http.StatusBadRequest},
+			status: http.StatusBadRequest, noBid: true},
+		{name: "custom_not_found", codes: []int{http.StatusNoContent, http.StatusBadRequest},
+			status: http.StatusNotFound, balance: 1},
+	}
+	// The no-bid responses don't affect the error balance of the source
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := []any{}
+			if test.codes != nil {
+				options = append(options, WithNoBidStatusCodes(test.codes...))
+			}
+			d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.ReadAll(r.Body)
+				w.WriteHeader(test.status)
+			}, options...)
+			resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+			if test.noBid {
+				assert.ErrorIs(t, resp.Error(), ErrResponseNoBid)
+			} else {
+				assert.ErrorIs(t, resp.Error(), ErrInvalidResponseStatus)
+			}
+			assert.Equal(t, test.balance, d.errorCounter.Balance())
+		})
+	}
+}
+
+func TestSourceImpIDCodec(t *testing.T) {
+	var impIDs []string
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var request openrtb.BidRequest
+		_ = json.Unmarshal(data, &request)
+		for _, imp := range request.Imp {
+			impIDs = append(impIDs, imp.ID)
+		}
+		_, _ = w.Write(testBidResponse(t, data, 1))
+	}, WithSourceImpIDCodec(adresponse.HashImpIDCodec))
+
+	request := newTestRequest(context.Background(), "banner_300x250")
+	resp := d.Bid(request)
+	assert.NoError(t, resp.Error())
+	assert.Len(t, resp.Ads(), 1)
+
+	// The request is sent with the opaque IDs which are matched back by the codec
+	imp := request.Imps[0]
+	assert.Equal(t, []string{adresponse.HashImpIDCodec.Encode(imp, imp.Formats()[0])}, impIDs)
+}
+
+func TestDeferredNURL(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var resp openrtb.BidResponse
+		_ = json.Unmarshal(testBidResponse(t, data, 2), &resp)
+		resp.SeatBid[0].Bid[0].NURL = "https://example.com/nurl?p=${AUCTION_PRICE}"
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+	var (
+		publisher = &testPublisher{}
+		ctx       = eventstream.WithWins(context.Background(), eventstream.WinNotifications(publisher))
+	)
+	ctx = eventstream.WithStream(ctx, &testEventStream{})
+
+	// The win notice URL is returned to the caller with the bid price
+	response := newTestDriver(t, handler).Bid(newTestRequest(ctx, "banner_300x250"))
+	if assert.NoError(t, response.Error()) && assert.Len(t, response.Ads(), 1) {
+		item := response.Ads()[0].(adtype.ResponseItem)
+		assert.Equal(t, "https://example.com/nurl?p=2.000000", item.ContentItemString(adtype.ContentItemNotifyWinURL))
+	}
+
+	// The deferred notice is fired by the driver with the final clearing price
+	d := newTestDriver(t, handler, WithDeferredNURL())
+	response = d.Bid(newTestRequest(ctx, "banner_300x250"))
+	if !assert.NoError(t, response.Error()) || !assert.Len(t, response.Ads(), 1) {
+		return
+	}
+	item := response.Ads()[0].(*adresponse.ResponseBannerBidItem)
+	assert.Empty(t, item.ContentItemString(adtype.ContentItemNotifyWinURL))
+
+	item.PriceScope.ImpPrice = billing.MoneyFloat(1.5) / 1000
+	d.ProcessResponseItem(response, nil)
+	var urls []string
+	for _, message := range publisher.messages {
+		urls = append(urls, message.(*adtype.WinEvent).URL)
+	}
+	assert.Equal(t, []string{"https://example.com/nurl?p=1.500000"}, urls)
+}
+
+func TestDeferredBURL(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var resp openrtb.BidResponse
+		_ = json.Unmarshal(testBidResponse(t, data, 2), &resp)
+		resp.SeatBid[0].Bid[0].NURL = "https://example.com/nurl?p=${AUCTION_PRICE}"
+		resp.SeatBid[0].Bid[0].BURL = "https://example.com/burl?p=${AUCTION_PRICE}"
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+	var (
+		publisher = &testPublisher{}
+		ctx       = eventstream.WithWins(context.Background(), eventstream.WinNotifications(publisher))
+	)
+	ctx = eventstream.WithStream(ctx, &testEventStream{})
+
+	// The billing notice is deferred with the win notice
+	d := newTestDriver(t, handler, WithDeferredNURL())
+	response := d.Bid(newTestRequest(ctx, "banner_300x250"))
+	if !assert.NoError(t, response.Error()) || !assert.Len(t, response.Ads(), 1) {
+		return
+	}
+	item := response.Ads()[0].(*adresponse.ResponseBannerBidItem)
+	assert.Empty(t, item.ContentItemString(adtype.ContentItemNotifyDisplayURL))
+
+	bidResp := response.(*adresponse.BidResponse)
+	item.PriceScope.ImpPrice = billing.MoneyFloat(1.5) / 1000
+	assert.Equal(t, "https://example.com/burl?p=1.500000", bidResp.BillingNoticeURL(item))
+
+	d.ProcessResponseItem(response, nil)
+	var urls []string
+	for _, message := range publisher.messages {
+		urls = append(urls, message.(*adtype.WinEvent).URL)
+	}
+	assert.Equal(t, []string{
+		"https://example.com/nurl?p=1.500000",
+		"https://example.com/burl?p=1.500000",
+	}, urls)
+}
+
+func TestImpIDMatch(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var request openrtb.BidRequest
+		_ = json.Unmarshal(data, &request)
+		bid := openrtb.Bid{ID: "1", ImpID: request.Imp[0].ID, Price: 1, CreativeID: "c1", AdMarkup: "https://example.com/landing"}
+		fallback, unknown := bid, bid
+		fallback.ID, fallback.ImpID, fallback.Price = "2", "imp1", 0.5
+		unknown.ID, unknown.ImpID = "3", "other"
+		_ = json.NewEncoder(w).Encode(openrtb.BidResponse{ID: request.ID, SeatBid: []openrtb.SeatBid{
+			{Bid: []openrtb.Bid{bid}},
+			{Bid: []openrtb.Bid{fallback, unknown}},
+		}})
+	}
+	// The strict mode refuses the bid of the impression ID variation
+	tests := []struct {
+		name     string
+		options  []any
+		fallback adresponse.RejectionReason
+	}{
+		{name: "fallback", fallback: adresponse.RejectionLostAuction},
+		{name: "strict", options: []any{WithStrictImpIDMatch()}, fallback: adresponse.RejectionUnknownImp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			d := newTestDriver(t, handler, append(tt.options, testMetricsRegistry(registry))...)
+			resp := d.Bid(newTestRequest(context.Background(), "direct"))
+			if assert.NoError(t, resp.Error()) && assert.Len(t, resp.Ads(), 1) {
+				assert.Equal(t, map[string]adresponse.RejectionReason{"2": tt.fallback, "3": adresponse.RejectionUnknownImp},
+					testRejectionReasons(resp.(*adresponse.BidResponse)))
+			}
+			assert.Equal(t, map[string]float64{"exact": 1, "fallback": 1, "none": 1},
+				testCounters(t, registry, "adsource_imp_id_match_total", "match"))
+		})
+	}
+}
+
+func TestResponseSchemaMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var response openrtb.BidResponse
+		_ = json.Unmarshal(testBidResponse(t, data, 1), &response)
+		response.SeatBid[0].Bid[0].NURL = "http://win"
+		_ = json.NewEncoder(w).Encode(response)
+	}, WithResponseSchemaMetrics(), testMetricsRegistry(registry))
+
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.NoError(t, resp.Error())
+	assert.Len(t, resp.Ads(), 1)
+	assert.Equal(t, map[string]float64{"bid": 1, "nurl": 1},
+		testCounters(t, registry, "adsource_response_schema_total", "feature"))
+}
@@ -0,0 +1,73 @@
+package adsourceopenrtb
+
+import (
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// EmptyValuePolicy of the request with the missing device value
+type EmptyValuePolicy string
+
+// Empty value policies
+const (
+	// EmptyValueKeep sends the request as is with the placeholder values (IP `0.0.0.0`), it's the default
+	EmptyValueKeep EmptyValuePolicy = "keep"
+	// EmptyValueOmit removes the placeholder field from the request
+	EmptyValueOmit EmptyValuePolicy = "omit"
+	// EmptyValueSkip skips the request by the source test
+	EmptyValueSkip EmptyValuePolicy = "skip"
+)
+
+// EmptyDevicePolicy of the requests without the IP address or the device user agent,
+// many partners treat the placeholder IP `0.0.0.0` as the invalid traffic
+type EmptyDevicePolicy struct {
+	// IP policy of the request without IPv4 and IPv6 addresses,
+	// the omit policy removes the placeholder IP of the device
+	IP EmptyValuePolicy `json:"ip,omitempty"`
+
+	// Device policy of the request without the device or the user agent,
+	// the omit policy removes the device object of the request without the user agent
+	Device EmptyValuePolicy `json:"device,omitempty"`
+}
+
+// Skip returns true if the request has to be skipped by the policy
+func (p *EmptyDevicePolicy) Skip(req adtype.BidRequester) bool {
+	if p == nil {
+		return false
+	}
+	return (p.IP == EmptyValueSkip && !requestHasIP(req)) ||
+		(p.Device == EmptyValueSkip && !requestHasUserAgent(req))
+}
+
+func (p *EmptyDevicePolicy) omitIP(req adtype.BidRequester) bool {
+	return p != nil && p.IP == EmptyValueOmit && !requestHasIP(req)
+}
+
+func (p *EmptyDevicePolicy) omitDevice(req adtype.BidRequester) bool {
+	return p != nil && p.Device == EmptyValueOmit && !requestHasUserAgent(req)
+}
+
+// requestHasIP returns true if the request has IPv4 or IPv6 address of the user
+func requestHasIP(req adtype.BidRequester) bool {
+	user := req.UserInfo()
+	if user == nil {
+		return false
+	}
+	return user.Geo.IPv4String() != "" || user.Geo.IPv6String() != ""
+}
+
+// requestHasUserAgent returns true if the request has the device with the user agent
+func requestHasUserAgent(req adtype.BidRequester) bool {
+	device := req.DeviceInfo()
+	return device != nil && device.Browser != nil && device.Browser.UA != ""
+}
+
+// sourceEmptyDevicePolicy returns the empty device policy from the source config
+// (`empty_device` with `ip` and `device` policies) or the driver options
+func sourceEmptyDevicePolicy(source *admodels.RTBSource, opts *DriverOptions) *EmptyDevicePolicy {
+	var policy EmptyDevicePolicy
+	if sourceConfigValue(source, sourceConfigEmptyDevice, &policy) {
+		return &policy
+	}
+	return opts.EmptyDevicePolicy
+}
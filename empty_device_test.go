@@ -0,0 +1,63 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adquery/bidrequest"
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/udetect"
+)
+
+// newTestDeviceRequest returns the request with the user IP and the device user agent if defined
+func newTestDeviceRequest(ip, ua string) *bidrequest.BidRequest {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.User = &adtype.User{Geo: &udetect.Geo{IP: net.ParseIP(ip)}}
+	request.Device = &udetect.Device{Browser: &udetect.Browser{UA: ua}}
+	return request
+}
+
+func TestEmptyDevicePolicySkip(t *testing.T) {
+	d := newTestDriver(t, nil, WithSourceEmptyDevicePolicy(EmptyValueSkip, EmptyValueKeep))
+
+	ok, reason := d.TestWithReason(newTestDeviceRequest("", "Mozilla/5.0"))
+	assert.False(t, ok)
+	assert.Equal(t, SkipReasonEmptyDevice, reason)
+
+	ok, _ = d.TestWithReason(newTestDeviceRequest("2001:db8::1", ""))
+	assert.True(t, ok)
+
+	// The source config has priority over the driver options
+	d = newTestDriver(t, nil, WithSourceEmptyDevicePolicy(EmptyValueSkip, EmptyValueKeep),
+		testSourceConfig(map[string]any{"empty_device": map[string]any{"device": "skip"}}))
+	ok, _ = d.TestWithReason(newTestDeviceRequest("", "Mozilla/5.0"))
+	assert.True(t, ok)
+	ok, reason = d.TestWithReason(newTestDeviceRequest("203.0.113.1", ""))
+	assert.False(t, ok)
+	assert.Equal(t, SkipReasonEmptyDevice, reason)
+}
+
+func TestEmptyDevicePolicyOmit(t *testing.T) {
+	d := newTestDriver(t, nil, WithSourceEmptyDevicePolicy(EmptyValueOmit, EmptyValueOmit))
+
+	rtbRequest := testEncodeRequest(t, d, newTestDeviceRequest("203.0.113.1", "Mozilla/5.0"))
+	assert.Equal(t, "203.0.113.1", rtbRequest["device"].(map[string]any)["ip"])
+
+	rtbRequest = testEncodeRequest(t, d, newTestDeviceRequest("", "Mozilla/5.0"))
+	assert.NotContains(t, rtbRequest["device"], "ip")
+
+	rtbRequest = testEncodeRequest(t, d, newTestDeviceRequest("203.0.113.1", ""))
+	assert.NotContains(t, rtbRequest, "device")
+
+	// The placeholder IP is kept by default
+	rtbRequest = testEncodeRequest(t, newTestDriver(t, nil), newTestDeviceRequest("", "Mozilla/5.0"))
+	assert.Contains(t, rtbRequest["device"], "ip")
+
+	// The OpenRTB 3.x requests have the same policy
+	policy := WithEmptyDevicePolicy(&EmptyDevicePolicy{IP: EmptyValueOmit, Device: EmptyValueOmit})
+	assert.Empty(t, BuildRequestV3(newTestDeviceRequest("", "Mozilla/5.0"), policy).Device.IP)
+	assert.Nil(t, BuildRequestV3(newTestDeviceRequest("203.0.113.1", ""), policy).Device)
+}
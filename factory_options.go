@@ -0,0 +1,67 @@
+package adsourceopenrtb
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// FactoryOption set function of the factory
+type FactoryOption func(fc *factory)
+
+// WithLogger of the drivers created by the factory
+// which is used instead of the context logger
+func WithLogger(logger *zap.Logger) FactoryOption {
+	return func(fc *factory) {
+		fc.driverOptions = append(fc.driverOptions, DriverOption(func(opts *DriverOptions) {
+			opts.Logger = logger
+		}))
+	}
+}
+
+// WithMetricsRegistry of the driver metrics created by the factory
+func WithMetricsRegistry(reg prometheus.Registerer) FactoryOption {
+	return func(fc *factory) {
+		fc.driverOptions = append(fc.driverOptions, DriverOption(func(opts *DriverOptions) {
+			opts.MetricsRegistry = reg
+		}))
+	}
+}
+
+// WithClock of the drivers used for the latency measurement and time budgets
+func WithClock(now func() time.Time) FactoryOption {
+	return func(fc *factory) {
+		fc.driverOptions = append(fc.driverOptions, DriverOption(func(opts *DriverOptions) {
+			opts.Clock = now
+		}))
+	}
+}
+
+// WithExchangeRates of the drivers used to convert the bid floors into the source currencies
+// and the bids back into the system currency, the source options have priority
+func WithExchangeRates(rates ExchangeRateProvider) FactoryOption {
+	return func(fc *factory) {
+		fc.driverOptions = append(fc.driverOptions, DriverOption(func(opts *DriverOptions) {
+			opts.ExchangeRates = rates
+		}))
+	}
+}
+
+// WithFactoryBillingNotices of the drivers fired on the impression events of the stream (see NewBillingStream)
+func WithFactoryBillingNotices(notices *BillingNotices) FactoryOption {
+	return func(fc *factory) {
+		fc.driverOptions = append(fc.driverOptions, DriverOption(func(opts *DriverOptions) {
+			opts.BillingNotices = notices
+		}))
+	}
+}
+
+// WithDriverOptions applied to all drivers created by the factory
+func WithDriverOptions(options ...DriverOption) FactoryOption {
+	return func(fc *factory) {
+		for _, opt := range options {
+			fc.driverOptions = append(fc.driverOptions, opt)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/net/httpclient"
+	"github.com/geniusrabbit/adcorelib/net/httpclient/stdhttpclient"
+)
+
+func TestFactoryOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var (
+		logger   = zap.NewNop()
+		registry = prometheus.NewRegistry()
+		now      = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		timeout  time.Duration
+	)
+	fc := NewFactory(func(_ context.Context, tm time.Duration) (httpclient.Driver, error) {
+		timeout = tm
+		return stdhttpclient.NewDriverWithHTTPClient(server.Client()), nil
+	}, WithLogger(logger), WithMetricsRegistry(registry), WithClock(func() time.Time { return now }),
+		WithDriverOptions(WithMaxInFlight(2)), WithExchangeRates(testExchangeRates))
+
+	source := &admodels.RTBSource{ID: 1, Protocol: "openrtb", URL: server.URL, Method: http.MethodPost, RequestType: RequestTypeJSON}
+	tester, err := fc.New(context.Background(), source, WithMaxInFlight(3))
+	if !assert.NoError(t, err) {
+		return
+	}
+	d := tester.(*driver)
+	assert.Equal(t, defaultTimeout, timeout)
+	assert.Same(t, logger, d.opts.Logger)
+	assert.Equal(t, now, d.now())
+
+	// The source options override the options of the factory
+	assert.Equal(t, 3, d.opts.MaxInFlight)
+	assert.NotNil(t, d.opts.ExchangeRates)
+
+	// The driver metrics are registered in the registry of the factory
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.ErrorIs(t, resp.Error(), ErrResponseNoBid)
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, families)
+}
@@ -0,0 +1,48 @@
+package adsourceopenrtb
+
+import (
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// PlacementDataProvider returns the first-party data of the placement
+// (custom key-values of the zone/target) sent in `imp.ext.data`
+type PlacementDataProvider interface {
+	PlacementData(imp *adtype.Impression) map[string]any
+}
+
+// PlacementDataProviderFunc wrapper of the function to the PlacementDataProvider interface
+type PlacementDataProviderFunc func(imp *adtype.Impression) map[string]any
+
+// PlacementData returns the first-party data of the placement
+func (f PlacementDataProviderFunc) PlacementData(imp *adtype.Impression) map[string]any {
+	return f(imp)
+}
+
+// PublisherDataProvider returns the first-party data of the publisher
+// sent in `site.ext.data` or `app.ext.data`
+type PublisherDataProvider interface {
+	PublisherData(request adtype.BidRequester) map[string]any
+}
+
+// PublisherDataProviderFunc wrapper of the function to the PublisherDataProvider interface
+type PublisherDataProviderFunc func(request adtype.BidRequester) map[string]any
+
+// PublisherData returns the first-party data of the publisher
+func (f PublisherDataProviderFunc) PublisherData(request adtype.BidRequester) map[string]any {
+	return f(request)
+}
+
+// filterDataKeys returns the data with the allowed keys only.
+// All keys are allowed if the allowlist is empty.
+func filterDataKeys(data map[string]any, allowed []string) map[string]any {
+	if len(data) == 0 || len(allowed) == 0 {
+		return data
+	}
+	filtered := make(map[string]any, min(len(data), len(allowed)))
+	for _, key := range allowed {
+		if val, ok := data[key]; ok {
+			filtered[key] = val
+		}
+	}
+	return filtered
+}
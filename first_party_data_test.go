@@ -0,0 +1,70 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/udetect"
+)
+
+func TestPlacementData(t *testing.T) {
+	provider := PlacementDataProviderFunc(func(imp *adtype.Impression) map[string]any {
+		if imp.Target.Codename() != "zone1" {
+			return nil
+		}
+		return map[string]any{"section": "sport", "position": 2}
+	})
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {}, WithPlacementDataProvider(provider))
+
+	request := newTestRequest(context.Background(), "banner_300x250")
+	rtbRequest := testEncodeRequest(t, d, request)
+	imp := rtbRequest["imp"].([]any)[0].(map[string]any)
+	assert.Equal(t, map[string]any{"data": map[string]any{"section": "sport", "position": 2.}}, imp["ext"])
+
+	// The OpenRTB 3.0 impressions have the same extension
+	rtbRequestV3 := BuildRequestV3(request, WithPlacementData(provider.PlacementData))
+	if assert.Len(t, rtbRequestV3.Impressions, 1) {
+		assert.JSONEq(t, `{"data":{"section":"sport","position":2}}`, string(rtbRequestV3.Impressions[0].Ext))
+	}
+
+	// The placements without the data have no extension
+	request.Imps[0].Target = &testTarget{codename: "zone2"}
+	rtbRequest = testEncodeRequest(t, d, request)
+	assert.NotContains(t, rtbRequest["imp"].([]any)[0], "ext")
+}
+
+func TestPublisherData(t *testing.T) {
+	provider := PublisherDataProviderFunc(func(adtype.BidRequester) map[string]any {
+		return map[string]any{"category": "news", "email": "user@example.com"}
+	})
+	data := func(rtbRequest map[string]any, key string) string {
+		inventory, _ := rtbRequest[key].(map[string]any)
+		ext, _ := json.Marshal(inventory["ext"])
+		return string(ext)
+	}
+
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.Site = &udetect.Site{Domain: "example.com"}
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {}, WithPublisherDataProvider(provider))
+	assert.JSONEq(t, `{"data":{"category":"news","email":"user@example.com"}}`, data(testEncodeRequest(t, d, request), "site"))
+
+	// Only the allowed keys are sent to the source
+	d = newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {}, WithPublisherDataProvider(provider, "category"))
+	assert.JSONEq(t, `{"data":{"category":"news"}}`, data(testEncodeRequest(t, d, request), "site"))
+
+	request.Site, request.App = nil, &udetect.App{Bundle: "com.example"}
+	assert.JSONEq(t, `{"data":{"category":"news"}}`, data(testEncodeRequest(t, d, request), "app"))
+}
+
+func TestFilterDataKeys(t *testing.T) {
+	data := map[string]any{"a": 1, "b": 2}
+	assert.Equal(t, data, filterDataKeys(data, nil))
+	assert.Equal(t, map[string]any{"a": 1}, filterDataKeys(data, []string{"a", "c"}))
+	assert.Empty(t, filterDataKeys(data, []string{"c"}))
+	assert.Nil(t, filterDataKeys(nil, []string{"a"}))
+}
@@ -0,0 +1,88 @@
+package adsourceopenrtb
+
+import (
+	"strings"
+
+	"github.com/demdxx/gocast/v2"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/udetect"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// Request values of the GDPR applicability (bool or 0/1) and the TCF consent string
+// provided by the upstream systems
+const (
+	RequestKeyGDPR        = "gdpr"
+	RequestKeyGDPRConsent = "gdpr_consent"
+)
+
+// gdprCountries of the EEA and the United Kingdom in ISO-3166-1 alpha-2 and alpha-3 codes
+var gdprCountries = map[string]struct{}{}
+
+func init() {
+	for _, codes := range [][2]string{
+		{"AT", "AUT"}, {"BE", "BEL"}, {"BG", "BGR"}, {"HR", "HRV"}, {"CY", "CYP"},
+		{"CZ", "CZE"}, {"DK", "DNK"}, {"EE", "EST"}, {"FI", "FIN"}, {"FR", "FRA"},
+		{"DE", "DEU"}, {"GR", "GRC"}, {"HU", "HUN"}, {"IE", "IRL"}, {"IT", "ITA"},
+		{"LV", "LVA"}, {"LT", "LTU"}, {"LU", "LUX"}, {"MT", "MLT"}, {"NL", "NLD"},
+		{"PL", "POL"}, {"PT", "PRT"}, {"RO", "ROU"}, {"SK", "SVK"}, {"SI", "SVN"},
+		{"ES", "ESP"}, {"SE", "SWE"}, {"IS", "ISL"}, {"LI", "LIE"}, {"NO", "NOR"},
+		{"GB", "GBR"},
+	} {
+		gdprCountries[codes[0]] = struct{}{}
+		gdprCountries[codes[1]] = struct{}{}
+	}
+	// Greece is also coded as EL in the EU documents, UK is used instead of GB sometimes
+	gdprCountries["EL"] = struct{}{}
+	gdprCountries["UK"] = struct{}{}
+}
+
+// IsGDPRCountry returns true if the country (ISO-3166-1 alpha-2 or alpha-3)
+// is the member of the EEA or the United Kingdom
+func IsGDPRCountry(country string) bool {
+	_, ok := gdprCountries[strings.ToUpper(strings.TrimSpace(country))]
+	return ok
+}
+
+// gdprApplies returns the `regs.ext.gdpr` value. The explicit flag has priority,
+// otherwise the flag is detected by the user country with the default value
+// for the unknown (empty or undefined `**`) countries. Returns -1 if the flag should not be sent.
+func (opts *BidRequestRTBOptions) gdprApplies(countries ...string) int {
+	if opts.GDPR != nil {
+		return b2i(*opts.GDPR)
+	}
+	if !opts.GDPRGeoDetection {
+		return -1
+	}
+	for _, country := range countries {
+		if country != "" && country != udetect.UndefinedCountryCode {
+			return b2i(IsGDPRCountry(country))
+		}
+	}
+	return b2i(opts.GDPRDefault)
+}
+
+// regsExt returns the extension of the regulations object
+func (opts *BidRequestRTBOptions) regsExt(countries ...string) []byte {
+	var ext []byte
+	if gdpr := opts.gdprApplies(countries...); gdpr >= 0 {
+		ext = adresponse.ExtSet(ext, "gdpr", gdpr)
+	}
+	return ext
+}
+
+// requestGDPR returns the GDPR applicability of the request values if it's defined
+func requestGDPR(request adtype.BidRequester) (applies, ok bool) {
+	val := request.Get(RequestKeyGDPR)
+	if val == nil {
+		return false, false
+	}
+	return gocast.Bool(val), true
+}
+
+// requestGDPRConsent returns the TCF consent string of the request values
+func requestGDPRConsent(request adtype.BidRequester) string {
+	return strings.TrimSpace(gocast.Str(request.Get(RequestKeyGDPRConsent)))
+}
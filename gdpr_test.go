@@ -0,0 +1,113 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adquery/bidrequest"
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/udetect"
+)
+
+func TestIsGDPRCountry(t *testing.T) {
+	for _, country := range []string{"DE", "deu", " fr ", "EL", "UK", "GBR", "NO"} {
+		assert.True(t, IsGDPRCountry(country), country)
+	}
+	for _, country := range []string{"", "US", "USA", "CH", "**"} {
+		assert.False(t, IsGDPRCountry(country), country)
+	}
+}
+
+func TestGDPRApplies(t *testing.T) {
+	applies, notApplies := true, false
+	tests := []struct {
+		name      string
+		opts      BidRequestRTBOptions
+		countries []string
+		result    int
+	}{
+		{name: "no_detection", countries: []string{"DE"}, result: -1},
+		{name: "explicit", opts: BidRequestRTBOptions{GDPR: &applies}, countries: []string{"US"}, result: 1},
+		{name: "explicit_priority", opts: BidRequestRTBOptions{GDPR: &notApplies, GDPRGeoDetection: true},
+			countries: []string{"DE"}, result: 0},
+		{name: "user_country", opts: BidRequestRTBOptions{GDPRGeoDetection: true}, countries: []string{"DE", "US"}, result: 1},
+		{name: "non_eea_country", opts: BidRequestRTBOptions{GDPRGeoDetection: true, GDPRDefault: true},
+			countries: []string{"US", "DE"}, result: 0},
+		{name: "device_country", opts: BidRequestRTBOptions{GDPRGeoDetection: true}, countries: []string{"", "FR"}, result: 1},
+		{name: "unknown_country", opts: BidRequestRTBOptions{GDPRGeoDetection: true, GDPRDefault: true},
+			countries: []string{"", udetect.UndefinedCountryCode}, result: 1},
+		{name: "unknown_country_default", opts: BidRequestRTBOptions{GDPRGeoDetection: true}, result: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.result, test.opts.gdprApplies(test.countries...))
+		})
+	}
+}
+
+func TestGDPRGeoDetection(t *testing.T) {
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {},
+		WithSourceGDPRGeoDetection(true))
+
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.User = &adtype.User{Geo: &udetect.Geo{Country: "US"}}
+	rtbRequest := testEncodeRequest(t, d, request)
+	assert.Equal(t, map[string]any{"gdpr": 0.}, rtbRequest["regs"].(map[string]any)["ext"])
+
+	request.User = &adtype.User{Geo: &udetect.Geo{Country: "DE"}}
+	rtbRequest = testEncodeRequest(t, d, request)
+	assert.Equal(t, map[string]any{"gdpr": 1.}, rtbRequest["regs"].(map[string]any)["ext"])
+
+	// The users of the unknown country have the default applicability of the source
+	request.User = nil
+	rtbRequest = testEncodeRequest(t, d, request)
+	assert.Equal(t, map[string]any{"gdpr": 1.}, rtbRequest["regs"].(map[string]any)["ext"])
+}
+
+func TestGDPRConsent(t *testing.T) {
+	newRequest := func(values map[string]any) *bidrequest.BidRequest {
+		request := newTestRequest(context.Background(), "banner_300x250")
+		request.User = &adtype.User{ID: "user1", Geo: &udetect.Geo{Country: "US"}}
+		for key, val := range values {
+			request.Set(key, val)
+		}
+		return request
+	}
+	regsExt := func(rtbRequest map[string]any) any {
+		regs, _ := rtbRequest["regs"].(map[string]any)
+		return regs["ext"]
+	}
+	userExt := func(rtbRequest map[string]any) any {
+		return rtbRequest["user"].(map[string]any)["ext"]
+	}
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {})
+
+	// The applicability and the consent of the request values
+	rtbRequest := testEncodeRequest(t, d, newRequest(map[string]any{
+		RequestKeyGDPR: "1", RequestKeyGDPRConsent: " CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA ",
+	}))
+	assert.Equal(t, map[string]any{"gdpr": 1.}, regsExt(rtbRequest))
+	assert.Equal(t, map[string]any{"consent": "CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA"}, userExt(rtbRequest))
+
+	rtbRequest = testEncodeRequest(t, d, newRequest(map[string]any{RequestKeyGDPR: false}))
+	assert.Equal(t, map[string]any{"gdpr": 0.}, regsExt(rtbRequest))
+	assert.Nil(t, userExt(rtbRequest))
+
+	rtbRequest = testEncodeRequest(t, d, newRequest(nil))
+	assert.Nil(t, regsExt(rtbRequest), "the applicability is not sent if it's unknown")
+
+	// The source option has priority over the request values and the geo detection
+	d = newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {},
+		WithSourceGDPR(true), WithSourceGDPRGeoDetection(false))
+	rtbRequest = testEncodeRequest(t, d, newRequest(map[string]any{RequestKeyGDPR: 0, RequestKeyGDPRConsent: "consent"}))
+	assert.Equal(t, map[string]any{"gdpr": 1.}, regsExt(rtbRequest))
+	assert.Equal(t, map[string]any{"consent": "consent"}, userExt(rtbRequest))
+
+	// The OpenRTB 3.x requests have the same regulations
+	rtbRequestV3 := BuildRequestV3(newRequest(nil), WithGDPR(true), WithGDPRConsent("consent"))
+	assert.JSONEq(t, `{"gdpr":1}`, string(rtbRequestV3.Regulations.Ext))
+	assert.JSONEq(t, `{"consent":"consent"}`, string(rtbRequestV3.User.Ext))
+}
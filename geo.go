@@ -0,0 +1,56 @@
+package adsourceopenrtb
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/demdxx/gocast/v2"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// Request values of the user time zone (IANA name like `Europe/Berlin`) and the availability
+// of the geolocation API to the JavaScript code of the banner provided by the upstream systems
+const (
+	RequestKeyTimezone = "timezone"
+	RequestKeyGeoFetch = "geofetch"
+)
+
+// timezoneLocations cache of the loaded time zones
+var timezoneLocations sync.Map
+
+// timezoneLocation returns the location of the IANA time zone name or nil if it's unknown
+func timezoneLocation(name string) *time.Location {
+	if name = strings.TrimSpace(name); name == "" {
+		return nil
+	}
+	if loc, ok := timezoneLocations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	timezoneLocations.Store(name, loc)
+	return loc
+}
+
+// requestUTCOffset returns the offset in minutes from UTC of the request time zone at the moment
+func requestUTCOffset(request adtype.BidRequester, now time.Time) (offset int, ok bool) {
+	loc := timezoneLocation(gocast.Str(request.Get(RequestKeyTimezone)))
+	if loc == nil {
+		return 0, false
+	}
+	_, seconds := now.In(loc).Zone()
+	return seconds / 60, true
+}
+
+// requestGeoFetch returns the geolocation API availability of the request values if it's defined
+func requestGeoFetch(request adtype.BidRequester) (enabled, ok bool) {
+	val := request.Get(RequestKeyGeoFetch)
+	if val == nil {
+		return false, false
+	}
+	return gocast.Bool(val), true
+}
@@ -0,0 +1,87 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/udetect"
+)
+
+func TestRequestUTCOffset(t *testing.T) {
+	var (
+		winter = time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+		summer = time.Date(2026, 7, 15, 12, 0, 0, 0, time.UTC)
+	)
+	request := newTestRequest(context.Background(), "banner_300x250")
+	_, ok := requestUTCOffset(request, winter)
+	assert.False(t, ok)
+
+	request.Set(RequestKeyTimezone, "Unknown/Zone")
+	_, ok = requestUTCOffset(request, winter)
+	assert.False(t, ok)
+
+	// The offset is computed at the moment with the daylight saving time
+	request.Set(RequestKeyTimezone, " Europe/Berlin ")
+	offset, ok := requestUTCOffset(request, winter)
+	assert.True(t, ok)
+	assert.Equal(t, 60, offset)
+	offset, _ = requestUTCOffset(request, summer)
+	assert.Equal(t, 120, offset)
+
+	request.Set(RequestKeyTimezone, "America/St_Johns")
+	offset, _ = requestUTCOffset(request, winter)
+	assert.Equal(t, -210, offset)
+}
+
+func TestRequestGeoFetch(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	_, ok := requestGeoFetch(request)
+	assert.False(t, ok)
+
+	request.Set(RequestKeyGeoFetch, "1")
+	enabled, ok := requestGeoFetch(request)
+	assert.True(t, ok)
+	assert.True(t, enabled)
+
+	request.Set(RequestKeyGeoFetch, false)
+	enabled, ok = requestGeoFetch(request)
+	assert.True(t, ok)
+	assert.False(t, enabled)
+}
+
+func TestGeoUTCOffset(t *testing.T) {
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	d := newTestDriver(t, nil, DriverOption(func(opts *DriverOptions) {
+		opts.Clock = func() time.Time { return now }
+	}))
+
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.Set(RequestKeyTimezone, "Asia/Tokyo")
+	request.Set(RequestKeyGeoFetch, true)
+	rtbRequest := testEncodeRequest(t, d, request)
+	device := rtbRequest["device"].(map[string]any)
+	assert.Equal(t, 540., device["geo"].(map[string]any)["utcoffset"])
+	assert.Equal(t, 1., device["geofetch"])
+	assert.Equal(t, 540., rtbRequest["user"].(map[string]any)["geo"].(map[string]any)["utcoffset"])
+
+	// The offset of the detected geo is kept
+	request.User = &adtype.User{Geo: &udetect.Geo{Country: "DE", UTCOffset: 60}}
+	rtbRequest = testEncodeRequest(t, d, request)
+	assert.Equal(t, 60., rtbRequest["user"].(map[string]any)["geo"].(map[string]any)["utcoffset"])
+
+	// The geo without the offset is sent as is without the time zone
+	rtbRequest = testEncodeRequest(t, d, newTestRequest(context.Background(), "banner_300x250"))
+	device = rtbRequest["device"].(map[string]any)
+	assert.NotContains(t, device["geo"], "utcoffset")
+	assert.NotContains(t, device, "geofetch")
+
+	// The OpenRTB 3.x requests have the same geo
+	rtbRequestV3 := BuildRequestV3(newTestRequest(context.Background(), "banner_300x250"), WithUTCOffset(-300), WithGeoFetch(false))
+	assert.Equal(t, -300, rtbRequestV3.Device.Geo.UTCOffset)
+	assert.Equal(t, -300, rtbRequestV3.User.Geo.UTCOffset)
+	assert.Equal(t, 0, rtbRequestV3.Device.GeoFetch)
+}
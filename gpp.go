@@ -0,0 +1,47 @@
+package adsourceopenrtb
+
+import (
+	"strings"
+
+	"github.com/demdxx/gocast/v2"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// Request values of the GPP (Global Privacy Platform) string and the applicable section IDs
+// (list or comma separated string) provided by the upstream systems
+const (
+	RequestKeyGPP    = "gpp"
+	RequestKeyGPPSID = "gpp_sid"
+)
+
+// requestGPP returns the GPP string and the section IDs of the request values
+func requestGPP(request adtype.BidRequester) (gpp string, sids []int) {
+	gpp = strings.TrimSpace(gocast.Str(request.Get(RequestKeyGPP)))
+	if gpp == "" {
+		return "", nil
+	}
+	switch v := request.Get(RequestKeyGPPSID).(type) {
+	case nil:
+	case string:
+		for _, sid := range strings.Split(v, ",") {
+			if sid = strings.TrimSpace(sid); sid != "" {
+				sids = append(sids, gocast.Int(sid))
+			}
+		}
+	default:
+		sids = gocast.AnySlice[int](v)
+	}
+	return gpp, sids
+}
+
+// gppFields sets the GPP consent of the OpenRTB 2.6 regulations object
+func (opts *BidRequestRTBOptions) gppFields(fields jsonFields) {
+	if opts.GPP == "" || !opts.versionAtLeast(ProtocolVersion26) {
+		return
+	}
+	fields.Set("regs.gpp", opts.GPP)
+	if len(opts.GPPSID) > 0 {
+		fields.Set("regs.gpp_sid", opts.GPPSID)
+	}
+}
@@ -0,0 +1,67 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestGPP(t *testing.T) {
+	tests := []struct {
+		name string
+		gpp  any
+		sids any
+		res  string
+		ids  []int
+	}{
+		{name: "empty", gpp: " ", sids: "7"},
+		{name: "no_sids", gpp: "DBABMA~CPXxRfAPXxRfAAfKABENB", res: "DBABMA~CPXxRfAPXxRfAAfKABENB"},
+		{name: "string_sids", gpp: " DBABMA ", sids: "7, 8,", res: "DBABMA", ids: []int{7, 8}},
+		{name: "list_sids", gpp: "DBABMA", sids: []any{2, "6"}, res: "DBABMA", ids: []int{2, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := newTestRequest(context.Background(), "banner_300x250")
+			request.Set(RequestKeyGPP, tt.gpp)
+			if tt.sids != nil {
+				request.Set(RequestKeyGPPSID, tt.sids)
+			}
+			gpp, sids := requestGPP(request)
+			assert.Equal(t, tt.res, gpp)
+			assert.Equal(t, tt.ids, sids)
+		})
+	}
+}
+
+func TestGPPVersion(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250")
+		gpp     = WithGPP("DBABMA", 7, 8)
+		regs    = func(data []byte) map[string]any {
+			var rtbRequest struct {
+				Regs map[string]any `json:"regs"`
+			}
+			assert.NoError(t, json.Unmarshal(data, &rtbRequest))
+			return rtbRequest.Regs
+		}
+	)
+
+	// The GPP fields are defined since OpenRTB 2.6
+	data, err := EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion25), gpp)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, regs(data), "gpp")
+	}
+	data, err = EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion26), gpp)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "DBABMA", regs(data)["gpp"])
+		assert.Equal(t, []any{7., 8.}, regs(data)["gpp_sid"])
+	}
+
+	// The GPP consent is taken from the request values by the driver
+	request.Set(RequestKeyGPP, "DBABMA")
+	request.Set(RequestKeyGPPSID, "2")
+	rtbRequest := testEncodeRequest(t, newTestDriver(t, nil, WithMaxProtocolVersion(ProtocolVersion26)), request)
+	assert.Equal(t, map[string]any{"gpp": "DBABMA", "gpp_sid": []any{2.}}, rtbRequest["regs"])
+}
@@ -0,0 +1,86 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/net/httpclient"
+	"github.com/geniusrabbit/adcorelib/net/httpclient/stdhttpclient"
+)
+
+// contextRequester is implemented by the custom HTTP clients which can bind
+// the request to the context on creation
+type contextRequester interface {
+	RequestWithContext(ctx context.Context, method, url string, body io.Reader) (httpclient.Request, error)
+}
+
+// contextDoer is implemented by the custom HTTP clients which can cancel
+// the request execution by the context
+type contextDoer interface {
+	DoWithContext(ctx context.Context, req httpclient.Request) (httpclient.Response, error)
+}
+
+// newHTTPRequest creates new HTTP request bound to the context, so the transport
+// closes the connection once the context is cancelled or the deadline is exceeded
+func newHTTPRequest(ctx context.Context, client httpclient.Driver, method, url string, body io.Reader) (httpclient.Request, error) {
+	if ctx == nil {
+		return client.Request(method, url, body)
+	}
+	if cli, ok := client.(contextRequester); ok {
+		return cli.RequestWithContext(ctx, method, url, body)
+	}
+	req, err := client.Request(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if stdReq, _ := req.(*stdhttpclient.Request); stdReq != nil && stdReq.HTTP != nil {
+		stdReq.HTTP = stdReq.HTTP.WithContext(ctx)
+	}
+	return req, nil
+}
+
+// doHTTPRequest executes the request, the request created by newHTTPRequest
+// is aborted by the transport once the context is done
+func doHTTPRequest(ctx context.Context, client httpclient.Driver, req httpclient.Request) (httpclient.Response, error) {
+	if ctx == nil {
+		return client.Do(req)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if cli, ok := client.(contextDoer); ok {
+		return cli.DoWithContext(ctx, req)
+	}
+	return client.Do(req)
+}
+
+// Reasons of the source requests cancelled by the auction context
+const (
+	cancelReasonDeadline = "deadline"
+	cancelReasonCanceled = "canceled"
+)
+
+// contextError returns the error of the cancelled context or nil
+func contextError(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// recordCanceled counts the request cancelled by the auction context distinctly
+// from the source timeouts and errors, the other errors are ignored
+func (d *driver) recordCanceled(request adtype.BidRequester, err error) {
+	ctxErr := contextError(request.Context())
+	if ctxErr == nil || !errors.Is(err, ctxErr) {
+		return
+	}
+	reason := cancelReasonCanceled
+	if errors.Is(ctxErr, context.DeadlineExceeded) {
+		reason = cancelReasonDeadline
+	}
+	d.canceledMetric.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,85 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/net/httpclient/stdhttpclient"
+)
+
+func TestHTTPRequestCancel(t *testing.T) {
+	closed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(closed)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := stdhttpclient.NewDriverWithHTTPClient(server.Client())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, err := newHTTPRequest(ctx, client, http.MethodPost, server.URL, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	begin := time.Now()
+	resp, err := doHTTPRequest(ctx, client, req)
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(begin), time.Second)
+
+	// The connection of the cancelled request is closed by the transport
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Error("the request is not cancelled on the server side")
+	}
+
+	// The request of the expired context is not sent at all
+	_, err = doHTTPRequest(ctx, client, req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestBidCanceled(t *testing.T) {
+	var (
+		registry = prometheus.NewRegistry()
+		requests atomic.Int32
+	)
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}, testMetricsRegistry(registry))
+
+	// The request of the cancelled auction is not sent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp := d.Bid(newTestRequest(ctx, "banner_300x250"))
+	assert.ErrorIs(t, resp.Error(), context.Canceled)
+	assert.Equal(t, int32(0), requests.Load())
+
+	// The request is stopped by the auction deadline
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	resp = d.Bid(newTestRequest(ctx, "banner_300x250"))
+	assert.ErrorIs(t, resp.Error(), context.DeadlineExceeded)
+	assert.Equal(t, int32(1), requests.Load())
+
+	assert.Equal(t, map[string]float64{"canceled": 1, "deadline": 1},
+		testCounters(t, registry, "adsource_canceled_total", "reason"))
+}
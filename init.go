@@ -19,7 +19,10 @@
 //       return httpclient.New(timeout), nil // Implement your HTTP client creation logic
 //   }
 //
-//   factory := openrtb.NewFactory(newClient)
+//   factory := openrtb.NewFactory(newClient,
+//       openrtb.WithLogger(logger),
+//       openrtb.WithMetricsRegistry(prometheus.DefaultRegisterer),
+//   )
 //
 // Creating a New Driver:
 //   source := &admodels.RTBSource{ /* initialize with source details */ }
@@ -38,6 +41,7 @@ package adsourceopenrtb
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	"github.com/demdxx/gocast/v2"
@@ -55,13 +59,18 @@ const (
 type NewClientFnk func(context.Context, time.Duration) (httpclient.Driver, error)
 
 type factory struct {
-	newClientFnk NewClientFnk
+	newClientFnk  NewClientFnk
+	driverOptions []any
 }
 
-func NewFactory(newClient NewClientFnk) *factory {
-	return &factory{
+func NewFactory(newClient NewClientFnk, options ...FactoryOption) *factory {
+	fc := &factory{
 		newClientFnk: newClient,
 	}
+	for _, opt := range options {
+		opt(fc)
+	}
+	return fc
 }
 
 func (fc *factory) New(ctx context.Context, source *admodels.RTBSource, opts ...any) (adtype.SourceTester, error) {
@@ -73,10 +82,12 @@ func (fc *factory) New(ctx context.Context, source *admodels.RTBSource, opts ...
 	if err != nil {
 		return nil, err
 	}
-	dr, err := newDriver(ctx, source, ncli, opts...)
+	// The options of the factory are applied first and can be overridden by the source options
+	dr, err := newDriver(ctx, source, ncli, append(append([]any{}, fc.driverOptions...), opts...)...)
 	if err != nil {
 		return nil, err
 	}
+	dr.warmup(ctx)
 	return dr, nil
 }
 
@@ -92,6 +103,15 @@ func (*factory) Info() info.Platform {
 				Link:  "https://www.iab.com/guidelines/real-time-bidding-rtb-project/",
 			},
 		},
+		Options: []*info.FieldOption{
+			{
+				Name:        "request_type",
+				Type:        info.FieldOptionString,
+				Description: "Encoding of the requests and the responses",
+				Default:     TransportJSON,
+				Select:      transportSelectItems(),
+			},
+		},
 		Subprotocols: []info.Subprotocol{
 			{
 				Name:        "VAST",
@@ -109,7 +129,7 @@ func (*factory) Info() info.Platform {
 				Name:        "OpenNative",
 				Protocol:    "opennative",
 				Description: "Partial implementation of the OpenRTB Native Ads protocol",
-				Versions:    []string{"1.1", "1.2"},
+				Versions:    slices.Clone(compiledCapabilities.NativeVersions),
 				Docs: []info.Documentation{
 					{
 						Title: "OpenRTB Native Ads Specification 1.1",
@@ -128,3 +148,12 @@ func (*factory) Info() info.Platform {
 func (*factory) Protocols() []string {
 	return []string{"openrtb", "openrtb2", "openrtb3"}
 }
+
+// transportSelectItems of the request encodings supported by the driver
+func transportSelectItems() []info.FieldOptionSelectItem {
+	items := make([]info.FieldOptionSelectItem, 0, len(compiledCapabilities.Transports))
+	for _, transport := range compiledCapabilities.Transports {
+		items = append(items, info.FieldOptionSelectItem{Name: transport, Value: transport})
+	}
+	return items
+}
@@ -0,0 +1,82 @@
+package adsourceopenrtb
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// jsonFields is the set of values by the dot-separated path (like `site.kwarray` or `imp.0.rwdd`)
+// which are merged into the encoded JSON object.
+// It's used for the fields which are not present in the base OpenRTB structures.
+type jsonFields map[string]any
+
+// Set value by path
+func (f jsonFields) Set(path string, value any) {
+	f[path] = value
+}
+
+// Apply fields to the encoded JSON object
+func (f jsonFields) Apply(data []byte) ([]byte, error) {
+	return rewriteRequest(data, f, nil, 0)
+}
+
+// apply fields to the decoded JSON object
+func (f jsonFields) apply(obj any) any {
+	for path, value := range f {
+		obj = setJSONPath(obj, strings.Split(path, "."), value)
+	}
+	return obj
+}
+
+// rewriteRequest of the encoded JSON request by the fields not present in the base
+// OpenRTB structures, the field filter and the price precision of the source.
+// All of them are applied to the request decoded once and encoded back once,
+// the data is returned as is if nothing changes the request.
+func rewriteRequest(data []byte, fields jsonFields, filter *RequestFieldFilter, decimals int) ([]byte, error) {
+	if len(fields) == 0 && filter.IsEmpty() && decimals <= 0 {
+		return data, nil
+	}
+	var obj any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	obj = fields.apply(obj)
+	// The field restrictions are enforced after all builders
+	if !filter.IsEmpty() {
+		obj = filter.apply(obj)
+	}
+	// The prices are formatted with the decimal precision of the source
+	if decimals > 0 {
+		obj = formatJSONPrices(obj, decimals)
+	}
+	return json.Marshal(obj)
+}
+
+func setJSONPath(node any, path []string, value any) any {
+	if len(path) == 0 {
+		return value
+	}
+	switch nd := node.(type) {
+	case []any:
+		idx, err := strconv.Atoi(path[0])
+		if err != nil || idx < 0 || idx >= len(nd) {
+			return node
+		}
+		nd[idx] = setJSONPath(nd[idx], path[1:], value)
+		return nd
+	case map[string]any:
+		nd[path[0]] = setJSONPath(nd[path[0]], path[1:], value)
+		return nd
+	case nil:
+		// Arrays are never created, only objects
+		if _, err := strconv.Atoi(path[0]); err == nil {
+			return node
+		}
+		return map[string]any{path[0]: setJSONPath(nil, path[1:], value)}
+	}
+	return node
+}
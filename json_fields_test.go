@@ -0,0 +1,85 @@
+package adsourceopenrtb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONFieldsApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		fields jsonFields
+		want   string
+	}{
+		{
+			name:   "empty",
+			data:   `{"id":"1"}`,
+			fields: nil,
+			want:   `{"id":"1"}`,
+		},
+		{
+			name:   "object field",
+			data:   `{"id":"1","site":{"page":"https://example.com"}}`,
+			fields: jsonFields{"site.kwarray": []string{"a", "b"}},
+			want:   `{"id":"1","site":{"kwarray":["a","b"],"page":"https://example.com"}}`,
+		},
+		{
+			name:   "array item field",
+			data:   `{"id":"1","imp":[{"id":"a"},{"id":"b"}]}`,
+			fields: jsonFields{"imp.1.rwdd": 1},
+			want:   `{"id":"1","imp":[{"id":"a"},{"id":"b","rwdd":1}]}`,
+		},
+		{
+			name:   "new object",
+			data:   `{"id":"1","tmax":120}`,
+			fields: jsonFields{"regs.gpp": "DBAA"},
+			want:   `{"id":"1","regs":{"gpp":"DBAA"},"tmax":120}`,
+		},
+		{
+			name:   "out of range",
+			data:   `{"id":"1","imp":[{"id":"a"}]}`,
+			fields: jsonFields{"imp.3.rwdd": 1},
+			want:   `{"id":"1","imp":[{"id":"a"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.fields.Apply([]byte(tt.data))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(data))
+		})
+	}
+}
+
+func TestRewriteRequest(t *testing.T) {
+	data := []byte(`{"id":"1","imp":[{"id":"a","bidfloor":0.30000000000000004,"ext":{"x":1}}],` +
+		`"device":{"ifa":"ifa-1","ua":"agent"}}`)
+	filter := &RequestFieldFilter{Deny: []string{"device.ifa", "imp.ext"}}
+
+	rewritten, err := rewriteRequest(data, jsonFields{"imp.0.rwdd": 1}, filter, 2)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"1","imp":[{"id":"a","bidfloor":0.3,"rwdd":1}],"device":{"ua":"agent"}}`,
+		string(rewritten))
+
+	// The request without the changes is returned as is
+	rewritten, err = rewriteRequest(data, nil, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), string(rewritten))
+}
+
+// BenchmarkRewriteRequest of the fields, the filter and the prices applied by the single pass
+func BenchmarkRewriteRequest(b *testing.B) {
+	data := []byte(`{"id":"1","imp":[{"id":"a","bidfloor":0.30000000000000004,"banner":{"w":300,"h":250}},` +
+		`{"id":"b","bidfloor":1.1,"banner":{"w":728,"h":90}}],"site":{"page":"https://example.com"},` +
+		`"device":{"ifa":"ifa-1","ua":"agent","geo":{"country":"USA"}},"user":{"id":"u1"}}`)
+	fields := jsonFields{"imp.0.rwdd": 1, "site.kwarray": []string{"a", "b"}}
+	filter := &RequestFieldFilter{Deny: []string{"device.ifa"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := rewriteRequest(data, fields, filter, 2); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
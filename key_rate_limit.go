@@ -0,0 +1,93 @@
+package adsourceopenrtb
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// RateLimitKeyFunc returns the key of the independent rate limit budget of the request,
+// the empty key means that the request is not limited
+type RateLimitKeyFunc func(request adtype.BidRequester) string
+
+// RateLimitBySite keys the rate limit by the site domain or the application bundle
+func RateLimitBySite(request adtype.BidRequester) string {
+	return request.DomainName()
+}
+
+// RateLimitByZone keys the rate limit by the target zone
+func RateLimitByZone(request adtype.BidRequester) string {
+	if id := request.TargetID(); id > 0 {
+		return strconv.FormatUint(id, 10)
+	}
+	return ""
+}
+
+type rateLimitWindow struct {
+	start int64
+	count int
+}
+
+// keyedRateLimiter limits the requests per second by the composite key (publisher or zone)
+// of the source with the independent budget per key
+type keyedRateLimiter struct {
+	mx          sync.Mutex
+	rps         int
+	keyFn       RateLimitKeyFunc
+	windows     map[string]*rateLimitWindow
+	lastCleanup int64
+}
+
+func newKeyedRateLimiter(rps int, keyFn RateLimitKeyFunc) *keyedRateLimiter {
+	if rps <= 0 || keyFn == nil {
+		return nil
+	}
+	return &keyedRateLimiter{
+		rps:     rps,
+		keyFn:   keyFn,
+		windows: map[string]*rateLimitWindow{},
+	}
+}
+
+// Allow returns true if the request fits into the budget of its key
+func (l *keyedRateLimiter) Allow(request adtype.BidRequester, now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	key := l.keyFn(request)
+	if key == "" {
+		return true
+	}
+	nowNano := now.UnixNano()
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if nowNano-l.lastCleanup >= int64(time.Second) {
+		l.cleanup(nowNano)
+	}
+	window := l.windows[key]
+	if window == nil {
+		window = &rateLimitWindow{start: nowNano}
+		l.windows[key] = window
+	} else if nowNano-window.start >= int64(time.Second) {
+		window.start, window.count = nowNano, 0
+	}
+	if window.count >= l.rps {
+		return false
+	}
+	window.count++
+	return true
+}
+
+// cleanup removes the expired windows of the inactive keys
+func (l *keyedRateLimiter) cleanup(nowNano int64) {
+	l.lastCleanup = nowNano
+	for key, window := range l.windows {
+		if nowNano-window.start >= int64(time.Second) {
+			delete(l.windows, key)
+		}
+	}
+}
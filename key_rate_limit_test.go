@@ -0,0 +1,73 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/udetect"
+)
+
+// testZoneTarget of the impression with the zone ID
+type testZoneTarget struct {
+	testTarget
+	id uint64
+}
+
+func (t *testZoneTarget) ID() uint64 { return t.id }
+
+func TestRateLimitKeys(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	assert.Equal(t, "", RateLimitBySite(request))
+	assert.Equal(t, "", RateLimitByZone(request))
+
+	request.Site = &udetect.Site{Domain: "example.com"}
+	assert.Equal(t, "example.com", RateLimitBySite(request))
+	request.Site, request.App = nil, &udetect.App{Bundle: "com.example"}
+	assert.Equal(t, "com.example", RateLimitBySite(request))
+
+	request.Imps[0].Target = &testZoneTarget{id: 42}
+	assert.Equal(t, "42", RateLimitByZone(request))
+}
+
+func TestKeyedRateLimiter(t *testing.T) {
+	assert.Nil(t, newKeyedRateLimiter(0, RateLimitBySite))
+	assert.Nil(t, newKeyedRateLimiter(1, nil))
+
+	var disabled *keyedRateLimiter
+	assert.True(t, disabled.Allow(newTestRequest(context.Background()), time.Now()))
+
+	var (
+		now     = time.Unix(1_700_000_000, 0)
+		limiter = newKeyedRateLimiter(2, RateLimitBySite)
+		site    = func(domain string) adtype.BidRequester {
+			request := newTestRequest(context.Background(), "banner_300x250")
+			if domain != "" {
+				request.Site = &udetect.Site{Domain: domain}
+			}
+			return request
+		}
+	)
+
+	// Each key has the independent budget
+	assert.True(t, limiter.Allow(site("a.com"), now))
+	assert.True(t, limiter.Allow(site("a.com"), now))
+	assert.False(t, limiter.Allow(site("a.com"), now))
+	assert.True(t, limiter.Allow(site("b.com"), now))
+
+	// The requests without the key are not limited
+	for i := 0; i < 5; i++ {
+		assert.True(t, limiter.Allow(site(""), now))
+	}
+
+	// The budget of the key is restored in the next window
+	assert.False(t, limiter.Allow(site("a.com"), now.Add(999*time.Millisecond)))
+	assert.True(t, limiter.Allow(site("a.com"), now.Add(time.Second)))
+
+	// The windows of the inactive keys are removed
+	assert.True(t, limiter.Allow(site("c.com"), now.Add(3*time.Second)))
+	assert.Len(t, limiter.windows, 1)
+}
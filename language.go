@@ -0,0 +1,102 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bsm/openrtb"
+	"github.com/demdxx/gocast/v2"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// RequestKeyContentLanguage of the request values with the language of the page or application content
+const RequestKeyContentLanguage = "content_language"
+
+// LanguageProvider returns the creative languages (ISO-639-1) allowed by the placement.
+// The empty list means that the placement accepts the creatives in any language.
+type LanguageProvider interface {
+	Languages(imp *adtype.Impression) []string
+}
+
+// LanguageProviderFunc wrapper of the function to the LanguageProvider interface
+type LanguageProviderFunc func(imp *adtype.Impression) []string
+
+// Languages returns the creative languages allowed by the placement
+func (f LanguageProviderFunc) Languages(imp *adtype.Impression) []string {
+	return f(imp)
+}
+
+// normalizeLanguage returns the ISO-639-1 code of the language tag (like `en` for `en-US`)
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// normalizeLanguages returns the unique ISO-639-1 codes of the language tags
+func normalizeLanguages(langs []string) []string {
+	list := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		if lang = normalizeLanguage(lang); lang != "" && !slices.Contains(list, lang) {
+			list = append(list, lang)
+		}
+	}
+	return list
+}
+
+// requestContentLanguage returns the content language of the request values
+func requestContentLanguage(request adtype.BidRequester) string {
+	return normalizeLanguage(gocast.Str(request.Get(RequestKeyContentLanguage)))
+}
+
+// creativeLanguages returns the creative languages allowed by all placements of the request (wlang),
+// nil if any placement accepts the creatives in any language
+func (opts *BidRequestRTBOptions) creativeLanguages(req adtype.BidRequester) []string {
+	if opts.Languages == nil {
+		return nil
+	}
+	var list []string
+	for _, imp := range req.Impressions() {
+		if !opts.impAllowed(imp) {
+			continue
+		}
+		langs := normalizeLanguages(opts.Languages(imp))
+		if len(langs) == 0 {
+			return nil
+		}
+		for _, lang := range langs {
+			if !slices.Contains(list, lang) {
+				list = append(list, lang)
+			}
+		}
+	}
+	return list
+}
+
+// filterLanguageBids removes the bids with the creative language which is not allowed by the placement
+func filterLanguageBids(request adtype.BidRequester, bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) {
+	if opts.Languages == nil {
+		return
+	}
+	codec := adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+	filterBids(bidResp, func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		lang := normalizeLanguage(bid.Language)
+		if lang == "" {
+			return true
+		}
+		imp, _ := codec.Decode(request, bid.ImpID)
+		if imp == nil {
+			return true
+		}
+		if langs := normalizeLanguages(opts.Languages(imp)); len(langs) > 0 && !slices.Contains(langs, lang) {
+			rejections.Add(seat, bid, adresponse.RejectionLanguage, lang)
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,72 @@
+package adsourceopenrtb
+
+import (
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/udetect"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// testLanguages returns the language provider with the creative languages of the placements by the codename
+func testLanguages(langs map[string][]string) LanguageProvider {
+	return LanguageProviderFunc(func(imp *adtype.Impression) []string {
+		return langs[imp.Target.Codename()]
+	})
+}
+
+func TestNormalizeLanguages(t *testing.T) {
+	assert.Equal(t, "pt", normalizeLanguage(" pt_BR "))
+	assert.Equal(t, []string{"en", "de"}, normalizeLanguages([]string{"en-US", "EN", " de_DE", ""}))
+	assert.Empty(t, normalizeLanguages(nil))
+}
+
+func TestRequestLanguages(t *testing.T) {
+	d := newTestDriver(t, nil, WithLanguageProvider(testLanguages(map[string][]string{
+		"zone1": {"en-GB", "de"},
+		"zone3": {"EN", "fr"},
+	})))
+
+	request := newTestZonesRequest("example.com", 1, 3)
+	request.Device = &udetect.Device{Browser: &udetect.Browser{PrimaryLanguage: "en-US"}}
+	request.Set(RequestKeyContentLanguage, "DE-at")
+	rtbRequest := testEncodeRequest(t, d, request)
+	assert.Equal(t, "en", rtbRequest["device"].(map[string]any)["language"])
+	assert.Equal(t, map[string]any{"language": "de"}, rtbRequest["site"].(map[string]any)["content"])
+	assert.Equal(t, []any{"en", "de", "fr"}, rtbRequest["wlang"])
+
+	// The creatives in any language are requested if any placement accepts them
+	rtbRequest = testEncodeRequest(t, d, newTestZonesRequest("example.com", 1, 2))
+	assert.NotContains(t, rtbRequest, "wlang")
+	assert.NotContains(t, rtbRequest["site"], "content")
+}
+
+func TestParseLanguages(t *testing.T) {
+	var (
+		request = newTestZonesRequest("example.com", 1)
+		impID   = BuildRequestV2(request).Imp[0].ID
+		bid     = func(id string, price float64, lang string) openrtb.Bid {
+			return openrtb.Bid{ID: id, ImpID: impID, Price: price, CreativeID: id, AdMarkup: "<div></div>", Language: lang}
+		}
+		seats = []openrtb.SeatBid{{Bid: []openrtb.Bid{bid("fr", 3, "fr-FR"), bid("en", 2, "EN-us"), bid("none", 1, "")}}}
+	)
+
+	resp, err := testParseBids(t, request, seats, WithParseLanguages(testLanguages(map[string][]string{"zone1": {"en"}})))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"en", "none"}, testResponseBids(resp))
+		assert.Equal(t, map[string]adresponse.RejectionReason{
+			"fr":   adresponse.RejectionLanguage,
+			"none": adresponse.RejectionLostAuction,
+		}, testRejectionReasons(resp))
+	}
+
+	// The placements without the languages accept the bids in any language
+	resp, err = testParseBids(t, request, seats, WithParseLanguages(testLanguages(nil)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"fr", "en", "none"}, testResponseBids(resp))
+	}
+}
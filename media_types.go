@@ -0,0 +1,68 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// sourceMediaTypes returns the media types bought by the source from the source config
+// (`media_types` with the format type names like `banner` or `native`) or the driver options
+func sourceMediaTypes(source *admodels.RTBSource, opts *DriverOptions) []types.FormatType {
+	var names []string
+	if !sourceConfigValue(source, sourceConfigMediaTypes, &names) {
+		return opts.MediaTypes
+	}
+	mediaTypes := make([]types.FormatType, 0, len(names))
+	for _, name := range names {
+		if formatType := types.FormatTypeByName(name); !formatType.IsInvalid() {
+			mediaTypes = append(mediaTypes, formatType)
+		}
+	}
+	return mediaTypes
+}
+
+// testFormat returns true if the format is allowed by the source filter
+// and the format type is bought by the source
+func (d *driver) testFormat(format *types.Format) bool {
+	if !d.source.TestFormat(format) {
+		return false
+	}
+	if len(d.mediaTypes) == 0 {
+		return true
+	}
+	for _, formatType := range format.Types.Types() {
+		if slices.Contains(d.mediaTypes, formatType) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterFormatBids removes the bids of the formats not allowed by the format filter,
+// so the bids are consistent with the request even if the filter changed in between.
+// The bid is checked by the format it's served as, the same filter excludes the formats
+// from the request, so the bid of the media type switched to the excluded format is rejected.
+// The bid of the impression ID without the format is allowed if any impression format is allowed.
+func filterFormatBids(request adtype.BidRequester, bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) {
+	if opts.FormatFilter == nil {
+		return
+	}
+	codec := adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+	rejectBids(bidResp, rejections, adresponse.RejectionFormat, func(_ *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		imp, format := codec.Decode(request, bid.ImpID)
+		if imp == nil {
+			return true
+		}
+		if format != nil {
+			return opts.FormatFilter(adresponse.BidFormat(bid, imp, format))
+		}
+		return slices.ContainsFunc(imp.Formats(), opts.FormatFilter)
+	})
+}
@@ -0,0 +1,51 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+func TestFormatFilterSymmetry(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250", "native")
+		imp     = request.Imps[0]
+		banner  = imp.FormatByCode("banner_300x250")
+		native  = imp.FormatByCode("native")
+		filter  = func(format *types.Format) bool { return format.IsBanner() }
+	)
+
+	// Only the allowed format is offered in the request
+	rtbRequest := BuildRequestV2(request, WithFormatFilter(filter))
+	if assert.Len(t, rtbRequest.Imp, 1) {
+		assert.Equal(t, imp.IDByFormat(banner), rtbRequest.Imp[0].ID)
+	}
+
+	// Only the bids served as the allowed format are accepted
+	bidResp := openrtb.BidResponse{SeatBid: []openrtb.SeatBid{{Bid: []openrtb.Bid{
+		{ID: "banner", ImpID: imp.IDByFormat(banner), Price: 1},
+		{ID: "native", ImpID: imp.IDByFormat(native), Price: 1},
+		{ID: "banner_as_native", ImpID: imp.IDByFormat(banner), Price: 1,
+			Ext: openrtb.Extension(`{"mtype":4}`)},
+		{ID: "unknown", ImpID: "unknown", Price: 1},
+	}}}}
+	var rejections adresponse.BidRejections
+	filterFormatBids(request, &bidResp, &rejections, &ParseOptions{FormatFilter: filter})
+
+	var accepted, rejected []string
+	for _, bid := range bidResp.SeatBid[0].Bid {
+		accepted = append(accepted, bid.ID)
+	}
+	for _, rejection := range rejections {
+		assert.Equal(t, adresponse.RejectionFormat, rejection.Reason)
+		rejected = append(rejected, rejection.BidID)
+	}
+	assert.Equal(t, []string{"banner", "unknown"}, accepted)
+	assert.Equal(t, []string{"native", "banner_as_native"}, rejected)
+}
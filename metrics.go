@@ -0,0 +1,154 @@
+package adsourceopenrtb
+
+import (
+	"errors"
+
+	"github.com/demdxx/gocast/v2"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+)
+
+var metricLabels = []string{"id", "protocol", "driver"}
+
+// sourceMetricLabels of the source metrics
+func sourceMetricLabels(source *admodels.RTBSource) prometheus.Labels {
+	return prometheus.Labels{"id": gocast.Str(source.ID), "protocol": source.Protocol, "driver": "openrtb"}
+}
+
+// curryMetric returns the metric vector with the source labels and the own labels only
+func curryMetric[V interface{ MustCurryWith(prometheus.Labels) V }](vec V, labels prometheus.Labels) V {
+	return vec.MustCurryWith(labels)
+}
+
+// newOverloadedMetric returns the counter of the requests skipped because of
+// the in-flight requests cap registered in the registry
+func newOverloadedMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_overloaded_total",
+		Help: "Number of the source requests skipped by the in-flight requests limit",
+	}, metricLabels))
+}
+
+// newThrottledMetric returns the counter of the throttle responses (429, 503 with Retry-After) of the partner
+func newThrottledMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_throttled_total",
+		Help: "Number of the source throttle responses with Retry-After which back off the source",
+	}, metricLabels))
+}
+
+// newCanceledMetric returns the counter of the source requests cancelled by the auction context
+func newCanceledMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_canceled_total",
+		Help: "Number of the source requests cancelled by the auction deadline or the cancellation",
+	}, append(metricLabels[:len(metricLabels):len(metricLabels)], "reason")))
+}
+
+// newSkipMetric returns the counter of the skipped requests by the reason
+func newSkipMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_skip_total",
+		Help: "Number of the source requests skipped by the reason",
+	}, append(metricLabels[:len(metricLabels):len(metricLabels)], "reason")))
+}
+
+// newImpIDMatchMetric returns the counter of the response bids by the impression ID match kind
+func newImpIDMatchMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_imp_id_match_total",
+		Help: "Number of the response bids by the impression ID match kind (exact, fallback, none)",
+	}, append(metricLabels[:len(metricLabels):len(metricLabels)], "match")))
+}
+
+// newCorrelationMetric returns the counter of the response bids by the correlation token echo result
+func newCorrelationMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_bid_correlation_total",
+		Help: "Number of the response bids by the correlation token echo result (matched, mismatched)",
+	}, append(metricLabels[:len(metricLabels):len(metricLabels)], "result")))
+}
+
+// newBlockedAttrMetric returns the counter of the bids rejected by the blocked creative attribute
+func newBlockedAttrMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_bid_blocked_attr_total",
+		Help: "Number of the response bids rejected by the creative attribute (battr) blocked by the placement",
+	}, append(metricLabels[:len(metricLabels):len(metricLabels)], "attr")))
+}
+
+// newSpoofMetric returns the counter of the suspicious bids by the spoof kind
+func newSpoofMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_bid_spoof_total",
+		Help: "Number of the response bids with the suspicious advertiser domain or bundle by the kind",
+	}, append(metricLabels[:len(metricLabels):len(metricLabels)], "kind")))
+}
+
+// newDowngradeMetric returns the counter of the objects removed from the invalid requests by the object type
+func newDowngradeMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_request_downgrade_total",
+		Help: "Number of the objects (imp, site) removed from the invalid requests by the validation downgrade",
+	}, append(metricLabels[:len(metricLabels):len(metricLabels)], "object")))
+}
+
+// newToleranceMetric returns the counter of the spec violations tolerated by the lenient decoding
+func newToleranceMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_response_tolerance_total",
+		Help: "Number of the response spec violations tolerated by the lenient decoding",
+	}, append(metricLabels[:len(metricLabels):len(metricLabels)], "tolerance")))
+}
+
+// newSchemaMetric returns the counter of the response bids by the schema feature used by the partner
+func newSchemaMetric(reg prometheus.Registerer) *prometheus.CounterVec {
+	return registerCollector(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adsource_response_schema_total",
+		Help: "Number of the response bids by the OpenRTB schema feature (mtype, eventtrackers, ext fields) sent by the partner",
+	}, append(metricLabels[:len(metricLabels):len(metricLabels)], "feature")))
+}
+
+// newSpendMetric returns the gauge of the source spend in the current period (hourly, daily)
+func newSpendMetric(reg prometheus.Registerer) *prometheus.GaugeVec {
+	return registerCollector(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "adsource_spend",
+		Help: "Spend of the source won bids in the current period (hourly, daily) in the system currency",
+	}, append(metricLabels[:len(metricLabels):len(metricLabels)], "period")))
+}
+
+// newProcessingTimeMetric returns the histogram of the bidder-side processing time reported by the partner
+func newProcessingTimeMetric(reg prometheus.Registerer) *prometheus.HistogramVec {
+	return registerCollector(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "adsource_bidder_processing_seconds",
+		Help:    "Bidder-side processing time of the source requests reported by the partner",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+	}, metricLabels))
+}
+
+// newNetworkTimeMetric returns the histogram of the network time of the requests
+// excluding the bidder-side processing time
+func newNetworkTimeMetric(reg prometheus.Registerer) *prometheus.HistogramVec {
+	return registerCollector(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "adsource_network_seconds",
+		Help:    "Network time of the source requests excluding the bidder-side processing time",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+	}, metricLabels))
+}
+
+// registerCollector in the registry or returns already registered one
+func registerCollector[T prometheus.Collector](reg prometheus.Registerer, collector T) T {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	if err := reg.Register(collector); err != nil {
+		var alreadyErr prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyErr) {
+			if existing, ok := alreadyErr.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+	}
+	return collector
+}
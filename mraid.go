@@ -0,0 +1,58 @@
+package adsourceopenrtb
+
+import (
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// MRAID API frameworks (OpenRTB List: API Frameworks)
+const (
+	APIFrameworkMRAID1 = 3
+	APIFrameworkMRAID2 = 5
+	APIFrameworkMRAID3 = 6
+)
+
+// MRAIDProvider returns the MRAID API frameworks supported by the application placement.
+// The empty list means that the placement can't render the MRAID creatives.
+type MRAIDProvider interface {
+	MRAIDFrameworks(imp *adtype.Impression) []int
+}
+
+// MRAIDProviderFunc wrapper of the function to the MRAIDProvider interface
+type MRAIDProviderFunc func(imp *adtype.Impression) []int
+
+// MRAIDFrameworks returns the MRAID API frameworks of the placement
+func (f MRAIDProviderFunc) MRAIDFrameworks(imp *adtype.Impression) []int {
+	return f(imp)
+}
+
+// bannerBlockedTypes returns the blocked banner types of the format,
+// the JavaScript creatives are allowed for the MRAID placements
+func bannerBlockedTypes(format *types.Format, mraid bool) []int {
+	switch {
+	case format.IsProxy():
+		return []int{1, 2}
+	case mraid:
+		return []int{4}
+	}
+	return []int{3, 4}
+}
+
+// filterMRAIDBids removes the bids with MRAID markup for the placements without MRAID support
+func filterMRAIDBids(request adtype.BidRequester, bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) {
+	if opts.MRAID == nil {
+		return
+	}
+	codec := adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+	rejectBids(bidResp, rejections, adresponse.RejectionMRAID, func(_ *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		if !adresponse.MarkupUsesMRAID(bid.AdMarkup) {
+			return true
+		}
+		imp, _ := codec.Decode(request, bid.ImpID)
+		return imp != nil && len(opts.MRAID(imp)) > 0
+	})
+}
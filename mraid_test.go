@@ -0,0 +1,66 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+func TestMRAIDRequest(t *testing.T) {
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {},
+		WithMRAIDProvider(MRAIDProviderFunc(func(imp *adtype.Impression) []int {
+			return []int{APIFrameworkMRAID2, APIFrameworkMRAID3}
+		})))
+
+	rtbRequest := testEncodeRequest(t, d, newTestRequest(context.Background(), "banner_300x250"))
+	banner := rtbRequest["imp"].([]any)[0].(map[string]any)["banner"].(map[string]any)
+	assert.Equal(t, []any{5., 6.}, banner["api"])
+	// The JavaScript creatives are allowed for the MRAID placements
+	assert.Equal(t, []any{4.}, banner["btype"])
+
+	rtbRequest = testEncodeRequest(t, newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {}),
+		newTestRequest(context.Background(), "banner_300x250"))
+	banner = rtbRequest["imp"].([]any)[0].(map[string]any)["banner"].(map[string]any)
+	assert.NotContains(t, banner, "api")
+	assert.Equal(t, []any{3., 4.}, banner["btype"])
+}
+
+func TestMRAIDBids(t *testing.T) {
+	var (
+		request  = newTestRequest(context.Background(), "banner_300x250")
+		impID    = BuildRequestV2(request).Imp[0].ID
+		mraidAdm = `<script src="mraid.js"></script><div></div>`
+		seats    = []openrtb.SeatBid{{Bid: []openrtb.Bid{
+			{ID: "html", ImpID: impID, Price: 1, CreativeID: "c1", AdMarkup: "<div></div>"},
+			{ID: "mraid", ImpID: impID, Price: 2, CreativeID: "c2", AdMarkup: mraidAdm},
+		}}}
+		frameworks []int
+		provider   = MRAIDProviderFunc(func(*adtype.Impression) []int { return frameworks })
+	)
+
+	// The MRAID creatives are rejected for the placements without MRAID support
+	resp, err := testParseBids(t, request, seats, WithParseMRAID(provider))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"html"}, testResponseBids(resp))
+		assert.Equal(t, adresponse.RejectionMRAID, testRejectionReasons(resp)["mraid"])
+	}
+
+	frameworks = []int{APIFrameworkMRAID2}
+	resp, err = testParseBids(t, request, seats, WithParseMRAID(provider))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"html", "mraid"}, testResponseBids(resp))
+	}
+
+	// The bids are not checked without the provider
+	frameworks = nil
+	resp, err = testParseBids(t, request, seats)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"html", "mraid"}, testResponseBids(resp))
+	}
+}
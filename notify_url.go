@@ -0,0 +1,158 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	errNotifyURLScheme  = errors.New("notification URL scheme is not allowed")
+	errNotifyURLHost    = errors.New("notification URL host is not allowed")
+	errNotifyURLAddress = errors.New("notification URL address is internal")
+)
+
+// NotifyURLPolicy validates the notification URLs (nurl, burl, lurl) of the bids
+// before firing them, the URLs come from the untrusted bidder input.
+// The hosts are matched with the subdomains (`example.com` matches `ads.example.com`).
+//
+// The host names can be resolved to the internal addresses at the fetch time (DNS rebinding),
+// so the notifier which fires the URLs must check the dialed addresses by the policy too,
+// see Control and HTTPClient.
+type NotifyURLPolicy struct {
+	// AllowHosts of the notifications (any public host if empty)
+	AllowHosts []string
+
+	// DenyHosts of the notifications
+	DenyHosts []string
+
+	// AllowInternal addresses (loopback, private, link-local) of the notifications
+	AllowInternal bool
+
+	// ResolveHosts checks the resolved addresses of the host names as well
+	// before the notification is queued
+	ResolveHosts bool
+}
+
+// Validate the notification URL by the policy
+func (p *NotifyURLPolicy) Validate(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errNotifyURLScheme
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" || hostMatches(host, p.DenyHosts) ||
+		(len(p.AllowHosts) > 0 && !hostMatches(host, p.AllowHosts)) {
+		return errNotifyURLHost
+	}
+	if p.AllowInternal {
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errNotifyURLAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if isInternalAddr(addr) {
+			return errNotifyURLAddress
+		}
+		return nil
+	}
+	if p.ResolveHosts {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			if isInternalAddr(addr) {
+				return errNotifyURLAddress
+			}
+		}
+	}
+	return nil
+}
+
+// Control checks the address of the notification connection, it's used as the net.Dialer
+// control function of the notifier, so the address is checked after the name resolving
+func (p *NotifyURLPolicy) Control(_, address string, _ syscall.RawConn) error {
+	if p.AllowInternal {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if isInternalAddr(addrPort.Addr()) {
+		return errNotifyURLAddress
+	}
+	return nil
+}
+
+// HTTPClient returns the HTTP client of the notifier which fires the notification URLs,
+// the connections to the internal addresses are rejected by the policy
+func (p *NotifyURLPolicy) HTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: p.Control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return p.Validate(req.Context(), req.URL.String())
+		},
+	}
+}
+
+// defaultNotifyURLPolicy allows the public http(s) URLs only
+var defaultNotifyURLPolicy = &NotifyURLPolicy{}
+
+// notifyURLPolicy returns the notification URL policy of the driver
+func (d *driver) notifyURLPolicy() *NotifyURLPolicy {
+	if d.opts.NotifyURLPolicy != nil {
+		return d.opts.NotifyURLPolicy
+	}
+	return defaultNotifyURLPolicy
+}
+
+func hostMatches(host string, hosts []string) bool {
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// internalPrefixes of the special-purpose networks which are not covered by the netip checks
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "This network"
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT (shared address space)
+}
+
+func isInternalAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range internalPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
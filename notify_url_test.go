@@ -0,0 +1,97 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyURLPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy NotifyURLPolicy
+		url    string
+		err    error
+	}{
+		{name: "public", url: "https://ads.example.com/win?p=1"},
+		{name: "scheme", url: "ftp://example.com/win", err: errNotifyURLScheme},
+		{name: "javascript", url: "javascript:alert(1)", err: errNotifyURLScheme},
+		{name: "empty_host", url: "https:///win", err: errNotifyURLHost},
+		{name: "localhost", url: "http://localhost:8080/win", err: errNotifyURLAddress},
+		{name: "sub_localhost", url: "http://api.localhost/win", err: errNotifyURLAddress},
+		{name: "loopback", url: "http://127.0.0.1/win", err: errNotifyURLAddress},
+		{name: "loopback_ipv6", url: "http://[::1]/win", err: errNotifyURLAddress},
+		{name: "mapped_ipv4", url: "http://[::ffff:10.0.0.1]/win", err: errNotifyURLAddress},
+		{name: "private", url: "http://192.168.1.10/win", err: errNotifyURLAddress},
+		{name: "link_local", url: "http://169.254.169.254/latest/meta-data", err: errNotifyURLAddress},
+		{name: "cgnat", url: "http://100.64.1.1/win", err: errNotifyURLAddress},
+		{name: "this_network", url: "http://0.1.2.3/win", err: errNotifyURLAddress},
+		{name: "public_ip", url: "http://8.8.8.8/win"},
+		{name: "allow_internal", policy: NotifyURLPolicy{AllowInternal: true}, url: "http://127.0.0.1/win"},
+		{name: "deny_host", policy: NotifyURLPolicy{DenyHosts: []string{"example.com"}},
+			url: "https://ads.example.com/win", err: errNotifyURLHost},
+		{name: "allow_host", policy: NotifyURLPolicy{AllowHosts: []string{"example.com"}},
+			url: "https://ads.example.com/win"},
+		{name: "not_allowed_host", policy: NotifyURLPolicy{AllowHosts: []string{"example.com"}},
+			url: "https://example.org/win", err: errNotifyURLHost},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.policy.Validate(context.Background(), test.url)
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestNotifyURLPolicyControl(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  NotifyURLPolicy
+		address string
+		err     error
+	}{
+		{name: "public", address: "93.184.216.34:443"},
+		{name: "loopback", address: "127.0.0.1:80", err: errNotifyURLAddress},
+		{name: "cgnat", address: "100.127.255.1:80", err: errNotifyURLAddress},
+		{name: "private_ipv6", address: "[fd00::1]:80", err: errNotifyURLAddress},
+		{name: "allow_internal", policy: NotifyURLPolicy{AllowInternal: true}, address: "127.0.0.1:80"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.policy.Control("tcp", test.address, nil)
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestNotifyURLPolicyHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// The connection is rejected by the address resolved at the dial time
+	policy := &NotifyURLPolicy{}
+	_, err := policy.HTTPClient(time.Second).Get(strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+	assert.ErrorIs(t, err, errNotifyURLAddress)
+
+	policy = &NotifyURLPolicy{AllowInternal: true}
+	resp, err := policy.HTTPClient(time.Second).Get(server.URL)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+}
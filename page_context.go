@@ -0,0 +1,169 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geniusrabbit/adcorelib/fasttime"
+)
+
+const (
+	defaultPageContextCacheTTL  = time.Hour
+	defaultPageContextTimeout   = time.Second
+	defaultPageContextCacheSize = 10000
+
+	// pageContextErrorTTL of the failed page context resolving, the page is not requested again during it
+	pageContextErrorTTL = time.Minute
+)
+
+// PageContext describes the contextual data of the page
+type PageContext struct {
+	Keywords   []string
+	Categories []string
+}
+
+// PageContextProvider resolves the contextual data of the page by URL
+type PageContextProvider interface {
+	PageContext(ctx context.Context, pageURL string) (*PageContext, error)
+}
+
+type pageContextCacheItem struct {
+	value    *PageContext
+	expireAt int64
+}
+
+// pageContextCache keeps the page context per URL without the query and the fragment.
+// The missing pages are resolved in background to not affect the request latency.
+// The failed pages are cached as empty for the short time to not flood the provider.
+type pageContextCache struct {
+	mx        sync.Mutex
+	provider  PageContextProvider
+	ttl       time.Duration
+	timeout   time.Duration
+	size      int
+	items     map[string]pageContextCacheItem
+	inflight  map[string]struct{}
+	lastSweep int64
+}
+
+func newPageContextCache(provider PageContextProvider, ttl, timeout time.Duration, size int) *pageContextCache {
+	if ttl <= 0 {
+		ttl = defaultPageContextCacheTTL
+	}
+	if timeout <= 0 {
+		timeout = defaultPageContextTimeout
+	}
+	if size <= 0 {
+		size = defaultPageContextCacheSize
+	}
+	return &pageContextCache{
+		provider: provider,
+		ttl:      ttl,
+		timeout:  timeout,
+		size:     size,
+		items:    map[string]pageContextCacheItem{},
+		inflight: map[string]struct{}{},
+	}
+}
+
+// Get returns the cached page context or schedules the resolving of it
+func (c *pageContextCache) Get(pageURL string) *PageContext {
+	if pageURL = pageContextKey(pageURL); pageURL == "" {
+		return nil
+	}
+	now := int64(fasttime.UnixTimestampNano())
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if item, ok := c.items[pageURL]; ok && item.expireAt > now {
+		return item.value
+	}
+	if _, ok := c.inflight[pageURL]; !ok && len(c.inflight) < c.size {
+		c.inflight[pageURL] = struct{}{}
+		go c.resolve(pageURL)
+	}
+	return nil
+}
+
+func (c *pageContextCache) resolve(pageURL string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	value, err := c.provider.PageContext(ctx, pageURL)
+	now := int64(fasttime.UnixTimestampNano())
+	ttl := c.ttl
+	if err != nil {
+		value, ttl = nil, min(ttl, pageContextErrorTTL)
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	delete(c.inflight, pageURL)
+	if now-c.lastSweep >= min(c.ttl, pageContextErrorTTL).Nanoseconds() {
+		c.sweep(now)
+	}
+	if _, ok := c.items[pageURL]; !ok && len(c.items) >= c.size {
+		// Evict any page to keep the cache size bounded
+		for key := range c.items {
+			delete(c.items, key)
+			break
+		}
+	}
+	c.items[pageURL] = pageContextCacheItem{value: value, expireAt: now + ttl.Nanoseconds()}
+}
+
+// sweep removes the expired pages
+func (c *pageContextCache) sweep(now int64) {
+	for key, item := range c.items {
+		if item.expireAt <= now {
+			delete(c.items, key)
+		}
+	}
+	c.lastSweep = now
+}
+
+// pageContextKey returns the page URL without the query and the fragment,
+// the query parameters don't change the page context but multiply the pages
+func pageContextKey(pageURL string) string {
+	if i := strings.IndexAny(pageURL, "?#"); i >= 0 {
+		pageURL = pageURL[:i]
+	}
+	return pageURL
+}
+
+// mergeKeywords into the comma separated list of keywords without duplicates
+func mergeKeywords(keywords string, extra []string) string {
+	if len(extra) == 0 {
+		return keywords
+	}
+	list := splitKeywords(keywords)
+	for _, kw := range extra {
+		if kw = strings.TrimSpace(kw); kw != "" && !slices.Contains(list, kw) {
+			list = append(list, kw)
+		}
+	}
+	return strings.Join(list, ",")
+}
+
+func splitKeywords(keywords string) []string {
+	var list []string
+	for _, kw := range strings.Split(keywords, ",") {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			list = append(list, kw)
+		}
+	}
+	return list
+}
+
+func mergeCategories[T ~string](cats []T, extra []string) []T {
+	for _, cat := range extra {
+		if !slices.Contains(cats, T(cat)) {
+			cats = append(cats, T(cat))
+		}
+	}
+	return cats
+}
@@ -0,0 +1,53 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPageContextProvider struct {
+	mx       sync.Mutex
+	requests []string
+	deadline time.Duration
+}
+
+func (p *testPageContextProvider) PageContext(ctx context.Context, pageURL string) (*PageContext, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.requests = append(p.requests, pageURL)
+	if deadline, ok := ctx.Deadline(); ok {
+		p.deadline = time.Until(deadline)
+	}
+	if pageURL == "https://example.com/error" {
+		return nil, errors.New("page unavailable")
+	}
+	return &PageContext{Keywords: []string{"news"}}, nil
+}
+
+func TestPageContextCache(t *testing.T) {
+	provider := &testPageContextProvider{}
+	cache := newPageContextCache(provider, time.Hour, 100*time.Millisecond, 2)
+
+	// The pages are resolved with the provider timeout and keyed without the query
+	assert.Nil(t, cache.Get("https://example.com/page?utm=1#top"))
+	assert.Eventually(t, func() bool {
+		return cache.Get("https://example.com/page?utm=2") != nil
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"https://example.com/page"}, provider.requests)
+	assert.LessOrEqual(t, provider.deadline, 100*time.Millisecond)
+
+	// The failed page is cached as empty and not requested again
+	cache.resolve("https://example.com/error")
+	assert.Nil(t, cache.Get("https://example.com/error"))
+	assert.Len(t, provider.requests, 2)
+	assert.Empty(t, cache.inflight)
+
+	// The cache size is bounded
+	cache.resolve("https://example.com/other")
+	assert.Len(t, cache.items, 2)
+}
@@ -0,0 +1,51 @@
+package adsourceopenrtb
+
+import (
+	"net/url"
+	"strings"
+)
+
+// URLNormalization of the site page and referrer URLs before the emission,
+// the credentials and the fragments are always removed and the malformed URLs are dropped
+type URLNormalization struct {
+	// MaxLength of the URL (0 - unlimited), the query is removed first and then the URL is cut
+	MaxLength int `json:"max_length,omitempty"`
+
+	// StripQuery removes the query of the URLs
+	StripQuery bool `json:"strip_query,omitempty"`
+}
+
+// Normalize the URL, returns empty string if the URL is malformed
+func (n *URLNormalization) Normalize(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if n == nil || rawURL == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Opaque != "" || (u.Scheme != "" && u.Host == "") {
+		return ""
+	}
+	u.User = nil
+	u.Fragment, u.RawFragment = "", ""
+	if n.StripQuery {
+		u.RawQuery, u.ForceQuery = "", false
+	}
+	res := u.String()
+	if n.MaxLength <= 0 || len(res) <= n.MaxLength {
+		return res
+	}
+	u.RawQuery, u.ForceQuery = "", false
+	if res = u.String(); len(res) <= n.MaxLength {
+		return res
+	}
+	return truncateURL(res, n.MaxLength)
+}
+
+// truncateURL cuts the URL to the max length without breaking the percent-encoded octets
+func truncateURL(s string, maxLength int) string {
+	s = s[:maxLength]
+	if i := strings.LastIndexByte(s, '%'); i >= 0 && i >= len(s)-2 {
+		s = s[:i]
+	}
+	return s
+}
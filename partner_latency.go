@@ -0,0 +1,85 @@
+package adsourceopenrtb
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/net/httpclient"
+	"github.com/geniusrabbit/adcorelib/net/httpclient/stdhttpclient"
+	"github.com/geniusrabbit/adcorelib/net/httpclient/stdhttpzeroclient"
+	"github.com/geniusrabbit/adcorelib/openlatency"
+)
+
+// OpenLatency headers returned by the partners
+const (
+	// HTTPHeaderResponseTimemark is the time when the partner sent the response (in milliseconds)
+	HTTPHeaderResponseTimemark = "X-Response-Ts"
+
+	// HTTPHeaderProcessingTime is the time spent by the partner to process the request (in milliseconds)
+	HTTPHeaderProcessingTime = "X-Processing-Time"
+)
+
+// headerResponse is implemented by the custom HTTP responses which provide access to the headers
+type headerResponse interface {
+	Header(key string) string
+}
+
+// responseHeader returns the header value of the response,
+// the standard clients of adcorelib expose the headers by the wrapped HTTP response only
+func responseHeader(resp httpclient.Response, key string) string {
+	switch r := resp.(type) {
+	case *stdhttpclient.Response:
+		if r.HTTP != nil {
+			return r.HTTP.Header.Get(key)
+		}
+	case *stdhttpzeroclient.Response:
+		if r.HTTP != nil {
+			return r.HTTP.Header.Get(key)
+		}
+	case headerResponse:
+		return r.Header(key)
+	}
+	return ""
+}
+
+// partnerProcessingTime returns the bidder-side processing time reported by the partner.
+// The explicit processing time header has priority over the response timemark.
+// The timemark is compared with the request timemark and includes the outbound network time.
+func partnerProcessingTime(resp httpclient.Response, requestTime time.Time, latency time.Duration) (time.Duration, bool) {
+	var processing time.Duration
+	if val, ok := parseHeaderMillis(responseHeader(resp, HTTPHeaderProcessingTime)); ok {
+		processing = time.Duration(val * float64(time.Millisecond))
+	} else if val, ok := parseHeaderMillis(responseHeader(resp, HTTPHeaderResponseTimemark)); ok {
+		processing = time.Duration((val - float64(openlatency.RequestInitTime(requestTime))) * float64(time.Millisecond))
+	} else {
+		return 0, false
+	}
+	if processing < 0 {
+		return 0, false
+	}
+	return min(processing, latency), true
+}
+
+func parseHeaderMillis(value string) (float64, bool) {
+	if value = strings.TrimSpace(value); value == "" {
+		return 0, false
+	}
+	val, err := strconv.ParseFloat(value, 64)
+	if err != nil || val < 0 {
+		return 0, false
+	}
+	return val, true
+}
+
+// recordPartnerLatency splits the request latency into the bidder processing and network times
+func (d *driver) recordPartnerLatency(resp httpclient.Response, request adtype.BidRequester, latency time.Duration) {
+	processing, ok := partnerProcessingTime(resp, request.Time(), latency)
+	if !ok {
+		return
+	}
+	traceID := d.traceID(request)
+	observeWithTrace(d.processingMetric, processing.Seconds(), traceID)
+	observeWithTrace(d.networkMetric, (latency - processing).Seconds(), traceID)
+}
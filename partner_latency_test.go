@@ -0,0 +1,53 @@
+package adsourceopenrtb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/net/httpclient"
+	"github.com/geniusrabbit/adcorelib/net/httpclient/stdhttpclient"
+)
+
+// doTestRequest sends the request to the test handler by the standard client of adcorelib
+func doTestRequest(t *testing.T, handler http.HandlerFunc) httpclient.Response {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := stdhttpclient.NewDriverWithHTTPClient(server.Client())
+	req, err := client.Request(http.MethodGet, server.URL, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	resp, err := client.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { _ = resp.Close() })
+	return resp
+}
+
+func TestPartnerProcessingTime(t *testing.T) {
+	resp := doTestRequest(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(HTTPHeaderProcessingTime, "12.5")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	processing, ok := partnerProcessingTime(resp, time.Now(), time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, 12500*time.Microsecond, processing)
+
+	// The processing time is limited by the measured latency
+	processing, ok = partnerProcessingTime(resp, time.Now(), 10*time.Millisecond)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Millisecond, processing)
+
+	resp = doTestRequest(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	_, ok = partnerProcessingTime(resp, time.Now(), time.Second)
+	assert.False(t, ok)
+}
@@ -0,0 +1,62 @@
+package adsourceopenrtb
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geniusrabbit/adcorelib/net/httpclient"
+)
+
+// defaultRetryAfterMax of the source back off requested by the partner
+const defaultRetryAfterMax = 5 * time.Minute
+
+// retryAfter returns the back off duration requested by the partner
+// by the throttle response (429, 503) with the Retry-After header (seconds or HTTP date)
+func retryAfter(resp httpclient.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	switch resp.StatusCode() {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	default:
+		return 0, false
+	}
+	value := strings.TrimSpace(responseHeader(resp, "Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// throttle backs off the source for the duration requested by the partner,
+// returns false if the response is not the throttle response
+func (d *driver) throttle(resp httpclient.Response) bool {
+	now := d.now()
+	backoff, ok := retryAfter(resp, now)
+	if !ok {
+		return false
+	}
+	maxBackoff := d.opts.RetryAfterMax
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryAfterMax
+	}
+	until := now.Add(min(backoff, maxBackoff)).UnixNano()
+	if until > d.throttledUntil.Load() {
+		d.throttledUntil.Store(until)
+	}
+	d.throttledMetric.Inc()
+	return true
+}
+
+// isThrottled returns true if the source backs off by the partner request
+func (d *driver) isThrottled() bool {
+	return d.now().UnixNano() < d.throttledUntil.Load()
+}
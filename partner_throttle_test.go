@@ -0,0 +1,81 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name    string
+		status  int
+		value   string
+		backoff time.Duration
+		ok      bool
+	}{
+		{name: "seconds", status: http.StatusTooManyRequests, value: "30", backoff: 30 * time.Second, ok: true},
+		{name: "date", status: http.StatusServiceUnavailable, value: now.Add(time.Minute).Format(http.TimeFormat), backoff: time.Minute, ok: true},
+		{name: "past_date", status: http.StatusServiceUnavailable, value: now.Add(-time.Minute).Format(http.TimeFormat), ok: true},
+		{name: "invalid", status: http.StatusTooManyRequests, value: "soon"},
+		{name: "no_header", status: http.StatusTooManyRequests},
+		{name: "not_throttle", status: http.StatusBadRequest, value: "30"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := doTestRequest(t, func(w http.ResponseWriter, _ *http.Request) {
+				if test.value != "" {
+					w.Header().Set("Retry-After", test.value)
+				}
+				w.WriteHeader(test.status)
+			})
+			backoff, ok := retryAfter(resp, now)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.backoff, backoff)
+		})
+	}
+}
+
+func TestPartnerThrottle(t *testing.T) {
+	var (
+		now        = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		registry   = prometheus.NewRegistry()
+		retryAfter atomic.Value
+		attempts   atomic.Int32
+	)
+	retryAfter.Store("30")
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Retry-After", retryAfter.Load().(string))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}, DriverOption(func(opts *DriverOptions) {
+		opts.Clock = func() time.Time { return now }
+	}), WithRetries(2, 0), WithRetryAfterMax(time.Minute), testMetricsRegistry(registry))
+	request := newTestRequest(context.Background(), "banner_300x250")
+
+	// The throttle response is not retried and backs off the source
+	resp := d.Bid(request)
+	assert.ErrorIs(t, resp.Error(), ErrSourceThrottled)
+	assert.Equal(t, int32(1), attempts.Load())
+	assert.Equal(t, map[string]float64{"1": 1}, testCounters(t, registry, "adsource_throttled_total", "id"))
+	ok, reason := d.TestWithReason(request)
+	assert.False(t, ok)
+	assert.Equal(t, SkipReasonPartnerThrottled, reason)
+
+	now = now.Add(31 * time.Second)
+	ok, _ = d.TestWithReason(request)
+	assert.True(t, ok)
+
+	// The back off is limited by the maximal duration
+	retryAfter.Store("3600")
+	_ = d.Bid(request)
+	now = now.Add(time.Minute)
+	ok, _ = d.TestWithReason(request)
+	assert.True(t, ok)
+}
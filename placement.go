@@ -0,0 +1,63 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// Ad positions (OpenRTB List: Ad Position)
+const (
+	adPositionUnknown    = 0
+	adPositionFullscreen = 7
+)
+
+// standardInterstitialSizes of the full-screen banners (portrait and landscape)
+var standardInterstitialSizes = [][2]int{
+	{320, 480}, {480, 320},
+	{360, 640}, {640, 360},
+	{768, 1024}, {1024, 768},
+}
+
+// interstitialSizes returns the list of the full-screen sizes starting from
+// the device screen size in the device independent pixels
+func interstitialSizes(deviceW, deviceH int, pxRatio float64) [][2]int {
+	sizes := make([][2]int, 0, len(standardInterstitialSizes)+1)
+	if deviceW > 0 && deviceH > 0 {
+		if pxRatio > 1 {
+			deviceW, deviceH = int(float64(deviceW)/pxRatio), int(float64(deviceH)/pxRatio)
+		}
+		sizes = append(sizes, [2]int{deviceW, deviceH})
+	}
+	for _, size := range standardInterstitialSizes {
+		if !slices.Contains(sizes, size) {
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes
+}
+
+// impPosition returns the ad position of the impression.
+// The interstitial placements are always full-screen.
+func impPosition(imp *adtype.Impression) int {
+	if imp.Interstitial == 1 {
+		return adPositionFullscreen
+	}
+	if imp.Pos < adPositionUnknown || imp.Pos > adPositionFullscreen {
+		return adPositionUnknown
+	}
+	return imp.Pos
+}
+
+// RewardedProvider returns true if the placement is rewarded
+type RewardedProvider interface {
+	IsRewarded(imp *adtype.Impression) bool
+}
+
+// RewardedProviderFunc wrapper of the function to the RewardedProvider interface
+type RewardedProviderFunc func(imp *adtype.Impression) bool
+
+// IsRewarded returns true if the placement is rewarded
+func (f RewardedProviderFunc) IsRewarded(imp *adtype.Impression) bool {
+	return f(imp)
+}
@@ -0,0 +1,70 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	openrtbv3 "github.com/bsm/openrtb/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+func TestInterstitialSizes(t *testing.T) {
+	assert.Equal(t, standardInterstitialSizes, interstitialSizes(0, 0, 0))
+
+	// The device screen is the first size in the device independent pixels
+	sizes := interstitialSizes(1080, 1920, 3)
+	assert.Equal(t, [2]int{360, 640}, sizes[0])
+	assert.Len(t, sizes, len(standardInterstitialSizes))
+
+	sizes = interstitialSizes(412, 915, 1)
+	assert.Equal(t, [2]int{412, 915}, sizes[0])
+	assert.Len(t, sizes, len(standardInterstitialSizes)+1)
+}
+
+func TestImpPosition(t *testing.T) {
+	assert.Equal(t, adPositionFullscreen, impPosition(&adtype.Impression{Interstitial: 1, Pos: 1}))
+	assert.Equal(t, 3, impPosition(&adtype.Impression{Pos: 3}))
+	assert.Equal(t, adPositionUnknown, impPosition(&adtype.Impression{Pos: 100}))
+}
+
+func TestInterstitialRequest(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.Imps[0].Interstitial = 1
+	rtbRequest := BuildRequestV2(request)
+	rtbRequest.Device = &openrtb.Device{W: 1080, H: 1920, PxRatio: 3}
+	rtbRequest.Imp[0].Banner.Format = nil
+	openrtbV2Interstitials(rtbRequest)
+
+	imp := rtbRequest.Imp[0]
+	assert.Equal(t, 1, imp.Instl)
+	assert.Equal(t, adPositionFullscreen, imp.Banner.Pos)
+	if assert.Len(t, imp.Banner.Format, len(standardInterstitialSizes)) {
+		assert.Equal(t, openrtb.Format{W: 360, H: 640}, imp.Banner.Format[0])
+	}
+
+	// The other impressions keep the formats of the placement
+	request.Imps[0].Interstitial = 0
+	rtbRequest = BuildRequestV2(request)
+	if assert.NotNil(t, rtbRequest.Imp[0].Banner) {
+		assert.Empty(t, rtbRequest.Imp[0].Banner.Format)
+	}
+}
+
+func TestInterstitialRequestV3(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.Imps[0].Interstitial = 1
+	rtbRequest := BuildRequestV3(request)
+	rtbRequest.Device = &openrtbv3.Device{Width: 1080, Height: 1920, PixelRatio: 3}
+	rtbRequest.Impressions[0].Banner.Formats = nil
+	openrtbV3Interstitials(rtbRequest)
+
+	imp := rtbRequest.Impressions[0]
+	assert.Equal(t, 1, imp.Interstitial)
+	assert.Equal(t, openrtbv3.AdPosition(adPositionFullscreen), imp.Banner.Position)
+	if assert.Len(t, imp.Banner.Formats, len(standardInterstitialSizes)) {
+		assert.Equal(t, openrtbv3.Format{Width: 360, Height: 640}, imp.Banner.Formats[0])
+	}
+}
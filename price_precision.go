@@ -0,0 +1,61 @@
+package adsourceopenrtb
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// requestPricePaths of the request prices formatted with the decimal precision of the source:
+// the floors of the impressions and the deals of OpenRTB 2.x and of the items and the deals
+// of the OpenRTB 3.0 envelope, "*" matches each element of the array. The extensions
+// carry the partner and the publisher data which is never rewritten.
+var requestPricePaths = [][]string{
+	{"imp", "*", "bidfloor"},
+	{"imp", "*", "pmp", "deals", "*", "bidfloor"},
+	{"openrtb", "request", "item", "*", "flr"},
+	{"openrtb", "request", "item", "*", "deal", "*", "flr"},
+}
+
+// formatJSONPrices rewrites the prices of the decoded JSON request as the decimals
+// with the fixed maximal number of the decimal places, so there are no float artifacts
+// like 0.30000000000000004 which are rejected by some bidders
+func formatJSONPrices(node any, decimals int) any {
+	for _, path := range requestPricePaths {
+		formatJSONPricePath(node, path, decimals)
+	}
+	return node
+}
+
+func formatJSONPricePath(node any, path []string, decimals int) {
+	switch nd := node.(type) {
+	case []any:
+		if path[0] == "*" {
+			for _, item := range nd {
+				formatJSONPricePath(item, path[1:], decimals)
+			}
+		}
+	case map[string]any:
+		if len(path) > 1 {
+			formatJSONPricePath(nd[path[0]], path[1:], decimals)
+		} else if num, ok := nd[path[0]].(json.Number); ok {
+			nd[path[0]] = formatPrice(num, decimals)
+		}
+	}
+}
+
+// formatPrice returns the price rounded to the decimal places without the trailing zeros
+func formatPrice(num json.Number, decimals int) json.Number {
+	price, err := num.Float64()
+	if err != nil {
+		return num
+	}
+	s := strconv.FormatFloat(price, 'f', decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return json.Number(s)
+}
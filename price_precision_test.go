@@ -0,0 +1,59 @@
+package adsourceopenrtb
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatJSONPrices(t *testing.T) {
+	decode := func(data string) any {
+		var obj any
+		dec := json.NewDecoder(bytes.NewReader([]byte(data)))
+		dec.UseNumber()
+		assert.NoError(t, dec.Decode(&obj))
+		return obj
+	}
+	for _, test := range []struct {
+		name, data, want string
+	}{
+		{
+			name: "openrtb2",
+			data: `{"imp":[{"bidfloor":0.30000000000000004,"pmp":{"deals":[{"bidfloor":1.23456}]},` +
+				`"ext":{"data":{"bidfloor":0.123456}}}],"site":{"ext":{"data":{"flr":0.123456}}},` +
+				`"user":{"ext":{"bidfloor":0.123456}}}`,
+			want: `{"imp":[{"bidfloor":0.3,"pmp":{"deals":[{"bidfloor":1.235}]},` +
+				`"ext":{"data":{"bidfloor":0.123456}}}],"site":{"ext":{"data":{"flr":0.123456}}},` +
+				`"user":{"ext":{"bidfloor":0.123456}}}`,
+		},
+		{
+			name: "openrtb3",
+			data: `{"openrtb":{"request":{"item":[{"flr":0.10000000000000001,"deal":[{"flr":2.0001}],` +
+				`"ext":{"flr":0.123456}}],"ext":{"flr":0.123456}}}}`,
+			want: `{"openrtb":{"request":{"item":[{"flr":0.1,"deal":[{"flr":2}],` +
+				`"ext":{"flr":0.123456}}],"ext":{"flr":0.123456}}}}`,
+		},
+		{
+			name: "invalid_types",
+			data: `{"imp":{"bidfloor":0.123456},"openrtb":[{"request":1}]}`,
+			want: `{"imp":{"bidfloor":0.123456},"openrtb":[{"request":1}]}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := json.Marshal(formatJSONPrices(decode(test.data), 3))
+			if assert.NoError(t, err) {
+				assert.JSONEq(t, test.want, string(data))
+			}
+		})
+	}
+}
+
+func TestFormatPrice(t *testing.T) {
+	assert.Equal(t, json.Number("0.3"), formatPrice("0.30000000000000004", 4))
+	assert.Equal(t, json.Number("1"), formatPrice("1.00001", 2))
+	assert.Equal(t, json.Number("0"), formatPrice("-0.0001", 2))
+	assert.Equal(t, json.Number("12"), formatPrice("12", 2))
+	assert.Equal(t, json.Number("x"), formatPrice("x", 2))
+}
@@ -0,0 +1,74 @@
+package adsourceopenrtb
+
+import (
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// ActionRateProvider returns the estimated rate of the action per impression of the placement
+// (e.g., the CTR for the clicks), 0 if the placement has no estimate
+type ActionRateProvider interface {
+	ActionRate(imp *adtype.Impression, action adtype.Action) float64
+}
+
+// ActionRateProviderFunc wrapper of the function to the ActionRateProvider interface
+type ActionRateProviderFunc func(imp *adtype.Impression, action adtype.Action) float64
+
+// ActionRate returns the estimated rate of the action per impression of the placement
+func (f ActionRateProviderFunc) ActionRate(imp *adtype.Impression, action adtype.Action) float64 {
+	return f(imp, action)
+}
+
+// pricingModelAction returns the action paid by the pricing model or the impression for CPM
+func pricingModelAction(model types.PricingModel) adtype.Action {
+	switch model {
+	case types.PricingModelCPC:
+		return adtype.ActionClick
+	case types.PricingModelCPV:
+		return adtype.ActionView
+	case types.PricingModelCPA:
+		return adtype.ActionLead
+	}
+	return adtype.ActionImpression
+}
+
+// convertBidsToCPM converts the bid prices per action of the source pricing model into
+// the effective CPM by the estimated action rate of the placement (eCPM = price * rate * 1000),
+// so the bids are comparable with the CPM sources in the auction.
+// The bids of the placements without the estimate are dropped.
+// It returns the conversion factors of the bid impressions.
+func convertBidsToCPM(request adtype.BidRequester, bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) map[string]float64 {
+	if opts.PricingModel.IsCPM() || opts.PricingModel == types.PricingModelUndefined {
+		return nil
+	}
+	var (
+		action  = pricingModelAction(opts.PricingModel)
+		codec   = adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+		factors = map[string]float64{}
+	)
+	filterBids(bidResp, func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		factor, ok := factors[bid.ImpID]
+		if !ok {
+			imp, _ := codec.Decode(request, bid.ImpID)
+			if imp == nil {
+				// The unknown impressions are rejected by the response preparation
+				return true
+			}
+			if opts.ActionRate != nil {
+				factor = opts.ActionRate(imp, action) * 1000
+			}
+			factors[bid.ImpID] = factor
+		}
+		if factor <= 0 {
+			rejections.Add(seat, bid, adresponse.RejectionPriceUnit, "no "+opts.PricingModel.Name()+" action rate estimate")
+			return false
+		}
+		bid.Price *= factor
+		return true
+	})
+	return factors
+}
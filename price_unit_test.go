@@ -0,0 +1,112 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// testActionRate returns the action rate provider with the rates of the placements by the codename
+func testActionRate(rates map[string]float64) ActionRateProvider {
+	return ActionRateProviderFunc(func(imp *adtype.Impression, action adtype.Action) float64 {
+		if action == adtype.ActionImpression {
+			return 1
+		}
+		return rates[imp.Target.Codename()]
+	})
+}
+
+func TestPricingModelAction(t *testing.T) {
+	assert.Equal(t, adtype.ActionImpression, pricingModelAction(types.PricingModelCPM))
+	assert.Equal(t, adtype.ActionImpression, pricingModelAction(types.PricingModelUndefined))
+	assert.Equal(t, adtype.ActionClick, pricingModelAction(types.PricingModelCPC))
+	assert.Equal(t, adtype.ActionView, pricingModelAction(types.PricingModelCPV))
+	assert.Equal(t, adtype.ActionLead, pricingModelAction(types.PricingModelCPA))
+}
+
+func TestConvertBidsToCPM(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		seats   = []openrtb.SeatBid{{Bid: []openrtb.Bid{
+			{ID: "1", ImpID: impID, Price: 0.1, CreativeID: "c1", AdMarkup: "<div></div>"},
+		}}}
+	)
+	tests := []struct {
+		name     string
+		model    types.PricingModel
+		provider ActionRateProvider
+		price    float64
+		rejected map[string]adresponse.RejectionReason
+	}{
+		{name: "undefined", price: 0.1},
+		{name: "cpm", model: types.PricingModelCPM, provider: testActionRate(nil), price: 0.1},
+		{name: "cpc", model: types.PricingModelCPC, provider: testActionRate(map[string]float64{"zone1": 0.02}), price: 2},
+		{
+			name:     "no_estimate",
+			model:    types.PricingModelCPV,
+			provider: testActionRate(map[string]float64{"zone2": 0.5}),
+			rejected: map[string]adresponse.RejectionReason{"1": adresponse.RejectionPriceUnit},
+		},
+		{
+			name:     "no_provider",
+			model:    types.PricingModelCPC,
+			rejected: map[string]adresponse.RejectionReason{"1": adresponse.RejectionPriceUnit},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := testParseBids(t, request, seats, WithParsePricingModel(tt.model, tt.provider))
+			if !assert.NoError(t, err) {
+				return
+			}
+			if tt.rejected != nil {
+				assert.Empty(t, testResponseBids(resp))
+				assert.Equal(t, tt.rejected, testRejectionReasons(resp))
+				return
+			}
+			if assert.Equal(t, []string{"1"}, testResponseBids(resp)) {
+				assert.InDelta(t, tt.price, resp.BidResponse.SeatBid[0].Bid[0].Price, 1e-9)
+			}
+		})
+	}
+}
+
+func TestSourcePricingModel(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var resp openrtb.BidResponse
+		_ = json.Unmarshal(testBidResponse(t, data, 0.1), &resp)
+		resp.SeatBid[0].Bid[0].NURL = "https://example.com/nurl?p=${AUCTION_PRICE}"
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+	provider := testActionRate(map[string]float64{"zone1": 0.02})
+
+	// The source config has priority over the driver options
+	d := newTestDriver(t, handler, WithSourcePricingModel(types.PricingModelCPM, provider),
+		testSourceConfig(map[string]any{"pricing_model": "CPC"}))
+	response := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	if assert.NoError(t, response.Error()) && assert.Len(t, response.Ads(), 1) {
+		item := response.Ads()[0].(adtype.ResponseItem)
+
+		// The auction price macros are reported in the source pricing model
+		assert.Equal(t, "https://example.com/nurl?p=0.100000", item.ContentItemString(adtype.ContentItemNotifyWinURL))
+	}
+
+	// The unknown pricing model of the config is ignored
+	d = newTestDriver(t, handler, WithSourcePricingModel(types.PricingModelCPV, nil),
+		testSourceConfig(map[string]any{"pricing_model": "unknown"}))
+	assert.Equal(t, types.PricingModelCPV, sourcePricingModel(d.source, &DriverOptions{PricingModel: types.PricingModelCPV}))
+	response = d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.Empty(t, response.Ads(), "the bids without the action rate estimates are dropped")
+}
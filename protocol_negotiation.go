@@ -0,0 +1,159 @@
+package adsourceopenrtb
+
+import (
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Supported protocol versions
+const (
+	ProtocolVersion25 = "2.5"
+	ProtocolVersion26 = "2.6"
+	ProtocolVersion30 = "3.0"
+)
+
+const defaultProtocolProbeInterval = 10 * time.Minute
+
+// protocolVersions ordered from the oldest to the newest
+var protocolVersions = []string{ProtocolVersion25, ProtocolVersion26, ProtocolVersion30}
+
+// protocolNegotiator keeps the effective protocol version of the source
+// and downgrades it if the source rejects requests of the current version.
+// After the probe interval the single request is sent with the maximal version,
+// the version is upgraded only if the source accepts the probe request, so the failed
+// probe doesn't switch the whole traffic back and forth.
+type protocolNegotiator struct {
+	enabled       bool
+	maxIndex      int32
+	probeInterval time.Duration
+	now           func() time.Time
+
+	curIndex  atomic.Int32
+	probing   atomic.Bool
+	probeTime atomic.Int64
+}
+
+func newProtocolNegotiator(protocol string, opts *DriverOptions, now func() time.Time) *protocolNegotiator {
+	n := &protocolNegotiator{
+		enabled:       opts.ProtocolNegotiation,
+		maxIndex:      int32(protocolVersionIndex(protocol, opts.MaxProtocolVersion)),
+		probeInterval: opts.ProtocolProbeInterval,
+		now:           now,
+	}
+	if n.probeInterval <= 0 {
+		n.probeInterval = defaultProtocolProbeInterval
+	}
+	n.curIndex.Store(n.maxIndex)
+	return n
+}
+
+// Version returns the protocol version of the next request, it's the maximal version
+// for the one probe request in flight per the probe interval after the downgrade.
+// The request of the version must be finished by Complete.
+func (n *protocolNegotiator) Version() string {
+	idx := n.curIndex.Load()
+	if n.enabled && idx < n.maxIndex &&
+		n.now().UnixNano()-n.probeTime.Load() >= n.probeInterval.Nanoseconds() &&
+		n.probing.CompareAndSwap(false, true) {
+		idx = n.maxIndex
+	}
+	return protocolVersions[idx]
+}
+
+// Current returns the effective protocol version of the source without the probe
+func (n *protocolNegotiator) Current() string {
+	return protocolVersions[n.curIndex.Load()]
+}
+
+// Complete the request of the version. The probe is consumed only if the source responded to it,
+// the probe failed before the response (the encoding error, the canceled request)
+// is repeated by the next request.
+func (n *protocolNegotiator) Complete(version string, responded bool) {
+	if !n.enabled || version != protocolVersions[n.maxIndex] || !n.probing.Load() {
+		return
+	}
+	if responded {
+		n.probeTime.Store(n.now().UnixNano())
+	}
+	n.probing.Store(false)
+}
+
+// Versions returns the protocol versions which can be used for the source,
+// the previous versions are used only by the negotiation
+func (n *protocolNegotiator) Versions() []string {
+	if !n.enabled {
+		return []string{protocolVersions[n.maxIndex]}
+	}
+	return slices.Clone(protocolVersions[:n.maxIndex+1])
+}
+
+// Accept the version of the request processed by the source,
+// the accepted probe request upgrades the current version
+func (n *protocolNegotiator) Accept(version string) {
+	if !n.enabled {
+		return
+	}
+	idx := int32(slices.Index(protocolVersions, version))
+	for cur := n.curIndex.Load(); idx > cur && idx <= n.maxIndex; cur = n.curIndex.Load() {
+		if n.curIndex.CompareAndSwap(cur, idx) {
+			return
+		}
+	}
+}
+
+// Fallback to the protocol version reported by the source (the X-Openrtb-Version response header)
+// if the source rejects the request of the newer version. The rejection without the version
+// of the source isn't the version-specific signal and doesn't change the version,
+// the rejected probe request keeps the current version till the next probe.
+// Returns true if the version was downgraded.
+func (n *protocolNegotiator) Fallback(version string, statusCode int, sourceVersion string) bool {
+	if !n.enabled || !isProtocolRejectStatus(statusCode) {
+		return false
+	}
+	sentIdx := int32(slices.Index(protocolVersions, version))
+	idx := int32(slices.Index(protocolVersions, sourceVersion))
+	if idx < 0 || idx >= sentIdx {
+		return false
+	}
+	for cur := n.curIndex.Load(); cur == sentIdx; cur = n.curIndex.Load() {
+		if n.curIndex.CompareAndSwap(cur, idx) {
+			n.probeTime.Store(n.now().UnixNano())
+			return true
+		}
+	}
+	return false
+}
+
+func isProtocolRejectStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusBadRequest,
+		http.StatusUnsupportedMediaType,
+		http.StatusUnprocessableEntity,
+		http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// protocolVersionIndex returns the index of the maximal protocol version
+// allowed for the source protocol name
+func protocolVersionIndex(protocol, maxVersion string) int {
+	idx := slices.Index(protocolVersions, ProtocolVersion25)
+	if protocol == "openrtb3" {
+		idx = slices.Index(protocolVersions, ProtocolVersion30)
+	}
+	if maxVersion == "" {
+		return idx
+	}
+	maxIdx := slices.Index(protocolVersions, maxVersion)
+	if maxIdx < 0 {
+		return idx
+	}
+	if protocol != "openrtb3" {
+		// The 2.x protocol can be upgraded up to the latest 2.x version only
+		return min(maxIdx, slices.Index(protocolVersions, ProtocolVersion26))
+	}
+	return min(idx, maxIdx)
+}
@@ -0,0 +1,158 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtocolNegotiator(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	n := newProtocolNegotiator("openrtb", &DriverOptions{
+		ProtocolNegotiation:   true,
+		MaxProtocolVersion:    ProtocolVersion26,
+		ProtocolProbeInterval: time.Minute,
+	}, clock)
+
+	assert.Equal(t, ProtocolVersion26, n.Version())
+	assert.Equal(t, []string{ProtocolVersion25, ProtocolVersion26}, n.Versions())
+
+	// The rejection without the version of the source isn't the version signal
+	assert.False(t, n.Fallback(ProtocolVersion26, http.StatusBadRequest, ""))
+	assert.False(t, n.Fallback(ProtocolVersion26, http.StatusInternalServerError, ProtocolVersion25))
+	assert.Equal(t, ProtocolVersion26, n.Version())
+
+	assert.True(t, n.Fallback(ProtocolVersion26, http.StatusBadRequest, ProtocolVersion25))
+	assert.False(t, n.Fallback(ProtocolVersion26, http.StatusBadRequest, ProtocolVersion25), "already downgraded")
+	assert.Equal(t, ProtocolVersion25, n.Version())
+
+	// The one probe request in flight per interval uses the maximal version
+	now = now.Add(time.Minute)
+	assert.Equal(t, ProtocolVersion26, n.Version())
+	assert.Equal(t, ProtocolVersion25, n.Version())
+	n.Complete(ProtocolVersion25, true)
+	assert.Equal(t, ProtocolVersion25, n.Version(), "the probe is in flight")
+
+	// The probe without the response is repeated by the next request
+	n.Complete(ProtocolVersion26, false)
+	assert.Equal(t, ProtocolVersion26, n.Version())
+	assert.Equal(t, ProtocolVersion25, n.Current())
+
+	// The rejected probe keeps the current version till the next probe
+	n.Complete(ProtocolVersion26, true)
+	assert.False(t, n.Fallback(ProtocolVersion26, http.StatusBadRequest, ProtocolVersion25))
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, ProtocolVersion25, n.Version())
+
+	// The accepted probe upgrades the version
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, ProtocolVersion26, n.Version())
+	n.Complete(ProtocolVersion26, true)
+	n.Accept(ProtocolVersion25)
+	assert.Equal(t, ProtocolVersion25, n.Version())
+	n.Accept(ProtocolVersion26)
+	assert.Equal(t, ProtocolVersion26, n.Version())
+}
+
+func TestProtocolNegotiatorDisabled(t *testing.T) {
+	n := newProtocolNegotiator("openrtb", &DriverOptions{MaxProtocolVersion: ProtocolVersion26}, time.Now)
+	assert.False(t, n.Fallback(ProtocolVersion26, http.StatusBadRequest, ProtocolVersion25))
+	assert.Equal(t, ProtocolVersion26, n.Version())
+	assert.Equal(t, []string{ProtocolVersion26}, n.Versions())
+}
+
+func TestProtocolFallback(t *testing.T) {
+	var (
+		sourceVersion atomic.Value
+		sent          atomic.Value
+	)
+	sourceVersion.Store("")
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		sent.Store(r.Header.Get(headerRequestOpenRTBVersion))
+		if ver := sourceVersion.Load().(string); ver != "" {
+			w.Header().Set(headerRequestOpenRTBVersion, ver)
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}, WithMaxProtocolVersion(ProtocolVersion26), WithProtocolNegotiation(time.Minute))
+
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.Error(t, resp.Error())
+	assert.Equal(t, ProtocolVersion26, sent.Load())
+	assert.Equal(t, ProtocolVersion26, d.protocol.Version(), "any 400 doesn't downgrade the version")
+
+	sourceVersion.Store(ProtocolVersion25)
+	resp = d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.Error(t, resp.Error())
+	assert.Equal(t, ProtocolVersion25, d.protocol.Version())
+
+	_ = d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.Equal(t, ProtocolVersion25, sent.Load())
+}
+
+func TestProtocolReprobe(t *testing.T) {
+	var (
+		accepted atomic.Bool
+		hang     atomic.Bool
+		sent     atomic.Value
+		now      atomic.Int64
+	)
+	now.Store(time.Now().UnixNano())
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		ver := r.Header.Get(headerRequestOpenRTBVersion)
+		sent.Store(ver)
+		if hang.Load() {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		if ver == ProtocolVersion26 && !accepted.Load() {
+			w.Header().Set(headerRequestOpenRTBVersion, ProtocolVersion25)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}, WithMaxProtocolVersion(ProtocolVersion26), WithProtocolNegotiation(time.Minute),
+		DriverOption(func(opts *DriverOptions) {
+			opts.Clock = func() time.Time { return time.Unix(0, now.Load()) }
+		}))
+	bid := func(ctx context.Context) string {
+		sent.Store("")
+		_ = d.Bid(newTestRequest(ctx, "banner_300x250"))
+		return sent.Load().(string)
+	}
+	advance := func(duration time.Duration) { now.Add(int64(duration)) }
+
+	// The rejected version is downgraded to the version of the source
+	assert.Equal(t, ProtocolVersion26, bid(context.Background()))
+	assert.Equal(t, ProtocolVersion25, bid(context.Background()))
+
+	// The rejected probe keeps the downgraded version for the next interval
+	advance(time.Minute)
+	assert.Equal(t, ProtocolVersion26, bid(context.Background()))
+	assert.Equal(t, ProtocolVersion25, bid(context.Background()))
+	advance(30 * time.Second)
+	assert.Equal(t, ProtocolVersion25, bid(context.Background()))
+
+	// The probe canceled before the response doesn't consume the probe
+	advance(30 * time.Second)
+	hang.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, ProtocolVersion26, bid(ctx))
+	hang.Store(false)
+	accepted.Store(true)
+	assert.Equal(t, ProtocolVersion26, bid(context.Background()))
+
+	// The accepted probe upgrades the version of the whole traffic
+	assert.Equal(t, ProtocolVersion26, bid(context.Background()))
+	assert.Equal(t, ProtocolVersion26, d.protocol.Current())
+}
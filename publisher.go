@@ -0,0 +1,47 @@
+package adsourceopenrtb
+
+import (
+	"strconv"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// Publisher of the site or application (`site.publisher` / `app.publisher`),
+// the ID has to match the seller ID of the sellers.json
+type Publisher struct {
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Domain string `json:"domain,omitempty"`
+}
+
+// PublisherProvider returns the publisher of the request
+type PublisherProvider interface {
+	Publisher(request adtype.BidRequester) *Publisher
+}
+
+// PublisherProviderFunc wrapper of the function to the PublisherProvider interface
+type PublisherProviderFunc func(request adtype.BidRequester) *Publisher
+
+// Publisher returns the publisher of the request
+func (f PublisherProviderFunc) Publisher(request adtype.BidRequester) *Publisher {
+	return f(request)
+}
+
+// requestPublisher returns the publisher of the request from the provider
+// or the publisher with the account ID of the request placements
+func requestPublisher(request adtype.BidRequester, provider PublisherProvider) *Publisher {
+	if provider != nil {
+		if pub := provider.Publisher(request); pub != nil {
+			return pub
+		}
+	}
+	for _, imp := range request.Impressions() {
+		if imp.Target == nil {
+			continue
+		}
+		if acc := imp.Target.Account(); acc != nil && acc.ID() > 0 {
+			return &Publisher{ID: strconv.FormatUint(acc.ID(), 10)}
+		}
+	}
+	return nil
+}
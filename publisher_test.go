@@ -0,0 +1,37 @@
+package adsourceopenrtb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/udetect"
+)
+
+func TestRequestPublisher(t *testing.T) {
+	// The publisher is the account of the placements by default
+	d := newTestDriver(t, nil)
+	site := testEncodeRequest(t, d, newTestZonesRequest("example.com", 1))["site"].(map[string]any)
+	assert.Equal(t, map[string]any{"id": "1"}, site["publisher"])
+
+	// The publisher of the provider replaces the default one
+	d = newTestDriver(t, nil, WithPublisherProvider(PublisherProviderFunc(func(request adtype.BidRequester) *Publisher {
+		return &Publisher{ID: "pub-7", Name: "Publisher", Domain: "publisher.com"}
+	})))
+	request := newTestZonesRequest("", 1)
+	request.Site, request.App = nil, &udetect.App{Bundle: "com.example"}
+	app := testEncodeRequest(t, d, request)["app"].(map[string]any)
+	assert.Equal(t, map[string]any{"id": "pub-7", "name": "Publisher", "domain": "publisher.com"}, app["publisher"])
+
+	// The OpenRTB 3.x requests have the same publisher
+	rtbRequest := BuildRequestV3(request, WithPublisher(&Publisher{ID: "pub-7", Name: "Publisher"}))
+	if assert.NotNil(t, rtbRequest.App) && assert.NotNil(t, rtbRequest.App.Publisher) {
+		assert.Equal(t, "pub-7", rtbRequest.App.Publisher.ID)
+		assert.Equal(t, "Publisher", rtbRequest.App.Publisher.Name)
+	}
+
+	// The request without the account has no publisher
+	request = newTestZonesRequest("example.com")
+	assert.Nil(t, requestPublisher(request, nil))
+}
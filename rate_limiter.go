@@ -0,0 +1,96 @@
+package adsourceopenrtb
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket limits the requests per second with the burst size,
+// the tokens are refilled continuously so there are no bursts at the window boundaries
+type tokenBucket struct {
+	mx     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns the limiter of the rps with the burst (the rps by default)
+// or nil if the rps is unlimited
+func newTokenBucket(rps, burst int) *tokenBucket {
+	if rps <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rps
+	}
+	return &tokenBucket{rate: float64(rps), burst: float64(burst), tokens: float64(burst)}
+}
+
+// Allow returns true and takes the token if the request fits into the limit
+func (b *tokenBucket) Allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if !b.last.IsZero() {
+		if elapsed := now.Sub(b.last); elapsed > 0 {
+			b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		}
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// SetRate of the tokens refill per second, the burst is kept
+func (b *tokenBucket) SetRate(rps float64) {
+	if b == nil {
+		return
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.rate = rps
+}
+
+// State of the limiter: the rate, the burst and the tokens available at the time
+func (b *tokenBucket) State(now time.Time) (rate, burst, tokens float64) {
+	if b == nil {
+		return 0, 0, 0
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	tokens = b.tokens
+	if !b.last.IsZero() {
+		if elapsed := now.Sub(b.last); elapsed > 0 {
+			tokens = min(b.burst, tokens+elapsed.Seconds()*b.rate)
+		}
+	}
+	return b.rate, b.burst, tokens
+}
+
+// Rate of the tokens refill per second
+func (b *tokenBucket) Rate() float64 {
+	if b == nil {
+		return 0
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.rate
+}
+
+// Refund the token taken by the request which is rejected by the other limits
+func (b *tokenBucket) Refund() {
+	if b == nil {
+		return
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
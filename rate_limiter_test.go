@@ -0,0 +1,103 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	bucket := newTokenBucket(0, 10)
+	assert.Nil(t, bucket)
+	assert.True(t, bucket.Allow(time.Now()))
+}
+
+func TestTokenBucketBurst(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	bucket := newTokenBucket(10, 3)
+	for i := 0; i < 3; i++ {
+		assert.True(t, bucket.Allow(now), "request %d within the burst", i)
+	}
+	assert.False(t, bucket.Allow(now), "request above the burst")
+
+	// One token is refilled per 100ms for 10 RPS
+	assert.False(t, bucket.Allow(now.Add(50*time.Millisecond)))
+	assert.True(t, bucket.Allow(now.Add(100*time.Millisecond)))
+	assert.False(t, bucket.Allow(now.Add(100*time.Millisecond)))
+
+	// The tokens are capped by the burst after the idle time
+	later := now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.True(t, bucket.Allow(later), "request %d after the idle time", i)
+	}
+	assert.False(t, bucket.Allow(later))
+}
+
+func TestTokenBucketWindowBoundary(t *testing.T) {
+	// The fixed window allows 2x RPS around the window boundary, the bucket doesn't
+	now := time.Unix(1_700_000_000, 0)
+	bucket := newTokenBucket(10, 0)
+	allowed := 0
+	for i := 0; i < 40; i++ {
+		// 40 requests in 200ms around the boundary of the second
+		if bucket.Allow(now.Add(900*time.Millisecond + time.Duration(i)*5*time.Millisecond)) {
+			allowed++
+		}
+	}
+	assert.LessOrEqual(t, allowed, 12)
+	assert.GreaterOrEqual(t, allowed, 10)
+}
+
+func TestTokenBucketClockSkew(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	bucket := newTokenBucket(1, 1)
+	assert.True(t, bucket.Allow(now))
+	// The time going backwards doesn't refill the tokens
+	assert.False(t, bucket.Allow(now.Add(-time.Second)))
+	assert.False(t, bucket.Allow(now.Add(500*time.Millisecond)))
+	assert.True(t, bucket.Allow(now.Add(time.Second)))
+}
+
+func TestRateLimitTakenAfterAdmission(t *testing.T) {
+	d := newTestDriver(t, nil,
+		testSourceOption(func(source *admodels.RTBSource) { source.RPS = 1 }),
+		WithMediaTypes(types.FormatBannerType),
+		WithKeyRateLimit(1, func(request adtype.BidRequester) string { return request.ID() }))
+
+	// The request rejected by the format filter doesn't take the token
+	native := newTestRequest(context.Background(), "native")
+	for i := 0; i < 3; i++ {
+		ok, reason := d.TestWithReason(native)
+		assert.False(t, ok)
+		assert.Equal(t, SkipReasonFormatFilter, reason)
+	}
+	_, _, tokens := d.rateLimiter.State(d.now())
+	assert.Equal(t, 1., tokens)
+
+	ok, reason := d.TestWithReason(newTestRequest(context.Background(), "banner_300x250"))
+	assert.True(t, ok)
+	assert.Equal(t, SkipReasonNone, reason)
+}
+
+func TestKeyRateLimitRefundsToken(t *testing.T) {
+	d := newTestDriver(t, nil,
+		testSourceOption(func(source *admodels.RTBSource) { source.RPS = 10 }),
+		WithKeyRateLimit(1, func(request adtype.BidRequester) string { return request.ID() }))
+
+	request := newTestRequest(context.Background(), "banner_300x250")
+	ok, _ := d.TestWithReason(request)
+	assert.True(t, ok)
+	ok, reason := d.TestWithReason(request)
+	assert.False(t, ok)
+	assert.Equal(t, SkipReasonKeyRateLimited, reason)
+
+	// The token of the request rejected by the key limit is returned to the bucket
+	_, _, tokens := d.rateLimiter.State(d.now())
+	assert.InDelta(t, 9., tokens, 0.01)
+}
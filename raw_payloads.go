@@ -0,0 +1,67 @@
+package adsourceopenrtb
+
+import (
+	"io"
+	"math/rand/v2"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+const defaultRawPayloadMaxSize = 64 << 10
+
+// rawPayloads of the sampled request which are attached to the response
+type rawPayloads struct {
+	request  []byte
+	response limitedBuffer
+}
+
+// sampleRawPayloads returns the raw payloads holder if the request is sampled
+func (d *driver) sampleRawPayloads() *rawPayloads {
+	rate := d.opts.RawPayloadSampleRate
+	if rate <= 0 || (rate < 1 && rand.Float64() >= rate) {
+		return nil
+	}
+	return d.newRawPayloads()
+}
+
+// newRawPayloads returns the raw payloads holder limited by the max payload size
+func (d *driver) newRawPayloads() *rawPayloads {
+	maxSize := d.opts.RawPayloadMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultRawPayloadMaxSize
+	}
+	return &rawPayloads{response: limitedBuffer{max: maxSize}}
+}
+
+func (p *rawPayloads) setRequest(data []byte) {
+	if p != nil {
+		p.request = append([]byte(nil), data[:min(len(data), p.response.max)]...)
+	}
+}
+
+func (p *rawPayloads) responseReader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return io.TeeReader(r, &p.response)
+}
+
+func (p *rawPayloads) attach(resp *adresponse.BidResponse) {
+	if p != nil && resp != nil {
+		resp.RawRequest = p.request
+		resp.RawResponse = p.response.buf
+	}
+}
+
+// limitedBuffer keeps only the first max bytes of the written data
+type limitedBuffer struct {
+	buf []byte
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if rest := b.max - len(b.buf); rest > 0 {
+		b.buf = append(b.buf, p[:min(len(p), rest)]...)
+	}
+	return len(p), nil
+}
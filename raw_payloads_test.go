@@ -0,0 +1,50 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+func TestRawPayloads(t *testing.T) {
+	var requestBody, responseBody []byte
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		requestBody, _ = io.ReadAll(r.Body)
+		responseBody = testBidResponse(t, requestBody, 1)
+		_, _ = w.Write(responseBody)
+	}
+	tests := []struct {
+		name    string
+		rate    float64
+		maxSize int
+	}{
+		{name: "disabled"},
+		{name: "sampled", rate: 1},
+		{name: "truncated", rate: 1, maxSize: 16},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newTestDriver(t, handler, WithRawPayloads(test.rate, test.maxSize))
+			resp, _ := d.Bid(newTestRequest(context.Background(), "banner_300x250")).(*adresponse.BidResponse)
+			if !assert.NotNil(t, resp) {
+				return
+			}
+			switch {
+			case test.rate <= 0:
+				assert.Nil(t, resp.RawRequest)
+				assert.Nil(t, resp.RawResponse)
+			case test.maxSize > 0:
+				assert.Equal(t, requestBody[:test.maxSize], resp.RawRequest)
+				assert.Equal(t, responseBody[:test.maxSize], resp.RawResponse)
+			default:
+				assert.Equal(t, requestBody, resp.RawRequest)
+				assert.Equal(t, responseBody, resp.RawResponse)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package adsourceopenrtb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// RequestDialect of the source: the request options, the field restrictions
+// and the price precision which define the outgoing request of the partner
+type RequestDialect struct {
+	Options       []BidRequestRTBOption
+	Fields        *RequestFieldFilter
+	PriceDecimals int
+}
+
+// Render the outgoing JSON request of the bid request in the dialect
+func (d RequestDialect) Render(req adtype.BidRequester) ([]byte, error) {
+	var (
+		opts       = newBidRequestRTBOptions(d.Options...)
+		rtbRequest interface{ Validate() error }
+		rtbFields  jsonFields
+	)
+	if opts.ProtocolVersion == ProtocolVersion30 {
+		rtbRequest = BuildRequestV3(req, d.Options...)
+	} else {
+		rtbReq := BuildRequestV2(req, d.Options...)
+		rtbFields = openrtbV26Extend(req, rtbReq, opts)
+		rtbRequest = rtbReq
+	}
+	if err := rtbRequest.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(rtbRequest)
+	if err != nil {
+		return nil, err
+	}
+	return rewriteRequest(data, rtbFields, d.Fields, d.PriceDecimals)
+}
+
+// RequestDiffKind of the field difference
+type RequestDiffKind string
+
+// Request diff kinds
+const (
+	RequestDiffAdded   RequestDiffKind = "added"
+	RequestDiffRemoved RequestDiffKind = "removed"
+	RequestDiffChanged RequestDiffKind = "changed"
+)
+
+// RequestDiff of the field of the requests rendered in two dialects.
+// The path is dot-separated with the array indices (like `imp.0.banner.w`).
+type RequestDiff struct {
+	Path string          `json:"path"`
+	Kind RequestDiffKind `json:"kind"`
+	A    any             `json:"a,omitempty"`
+	B    any             `json:"b,omitempty"`
+}
+
+func (d RequestDiff) String() string {
+	switch d.Kind {
+	case RequestDiffAdded:
+		return fmt.Sprintf("+ %s: %s", d.Path, diffValueString(d.B))
+	case RequestDiffRemoved:
+		return fmt.Sprintf("- %s: %s", d.Path, diffValueString(d.A))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", d.Path, diffValueString(d.A), diffValueString(d.B))
+}
+
+// DiffRequestDialects renders the outgoing request of the bid request in two dialects
+// and returns the differences of the fields ordered by path.
+// It's used for the partner onboarding to check which fields are sent by the dialect.
+func DiffRequestDialects(req adtype.BidRequester, a, b RequestDialect) ([]RequestDiff, error) {
+	dataA, err := a.Render(req)
+	if err != nil {
+		return nil, err
+	}
+	dataB, err := b.Render(req)
+	if err != nil {
+		return nil, err
+	}
+	return DiffRequests(dataA, dataB)
+}
+
+// DiffRequests returns the differences of the fields of two encoded JSON requests ordered by path
+func DiffRequests(a, b []byte) ([]RequestDiff, error) {
+	objA, err := decodeDiffJSON(a)
+	if err != nil {
+		return nil, err
+	}
+	objB, err := decodeDiffJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	return diffJSONValues(nil, "", objA, objB), nil
+}
+
+func decodeDiffJSON(data []byte) (any, error) {
+	var obj any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func diffJSONValues(diffs []RequestDiff, path string, a, b any) []RequestDiff {
+	switch va := a.(type) {
+	case map[string]any:
+		if vb, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(va)+len(vb))
+			for key := range va {
+				keys = append(keys, key)
+			}
+			for key := range vb {
+				if _, ok := va[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				diffs = diffJSONField(diffs, diffPath(path, key), va, vb, key)
+			}
+			return diffs
+		}
+	case []any:
+		if vb, ok := b.([]any); ok {
+			for i := 0; i < max(len(va), len(vb)); i++ {
+				itemPath := diffPath(path, strconv.Itoa(i))
+				switch {
+				case i >= len(vb):
+					diffs = append(diffs, RequestDiff{Path: itemPath, Kind: RequestDiffRemoved, A: va[i]})
+				case i >= len(va):
+					diffs = append(diffs, RequestDiff{Path: itemPath, Kind: RequestDiffAdded, B: vb[i]})
+				default:
+					diffs = diffJSONValues(diffs, itemPath, va[i], vb[i])
+				}
+			}
+			return diffs
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		diffs = append(diffs, RequestDiff{Path: path, Kind: RequestDiffChanged, A: a, B: b})
+	}
+	return diffs
+}
+
+func diffJSONField(diffs []RequestDiff, path string, a, b map[string]any, key string) []RequestDiff {
+	va, okA := a[key]
+	vb, okB := b[key]
+	switch {
+	case !okB:
+		return append(diffs, RequestDiff{Path: path, Kind: RequestDiffRemoved, A: va})
+	case !okA:
+		return append(diffs, RequestDiff{Path: path, Kind: RequestDiffAdded, B: vb})
+	}
+	return diffJSONValues(diffs, path, va, vb)
+}
+
+func diffPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func diffValueString(val any) string {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprint(val)
+	}
+	return string(data)
+}
@@ -0,0 +1,78 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+)
+
+func TestDiffRequests(t *testing.T) {
+	diffs, err := DiffRequests(
+		[]byte(`{"id":"1","imp":[{"id":"a","bidfloor":1.5}],"tmax":100,"at":1,"site":{}}`),
+		[]byte(`{"id":"1","imp":[{"id":"a","bidfloor":1.25},{"id":"b"}],"at":2,"cur":["USD"],"site":[]}`),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []RequestDiff{
+		{Path: "at", Kind: RequestDiffChanged, A: json.Number("1"), B: json.Number("2")},
+		{Path: "cur", Kind: RequestDiffAdded, B: []any{"USD"}},
+		{Path: "imp.0.bidfloor", Kind: RequestDiffChanged, A: json.Number("1.5"), B: json.Number("1.25")},
+		{Path: "imp.1", Kind: RequestDiffAdded, B: map[string]any{"id": "b"}},
+		{Path: "site", Kind: RequestDiffChanged, A: map[string]any{}, B: []any{}},
+		{Path: "tmax", Kind: RequestDiffRemoved, A: json.Number("100")},
+	}, diffs)
+
+	var lines []string
+	for _, diff := range diffs[:4] {
+		lines = append(lines, diff.String())
+	}
+	assert.Equal(t, []string{`~ at: 1 -> 2`, `+ cur: ["USD"]`, `~ imp.0.bidfloor: 1.5 -> 1.25`, `+ imp.1: {"id":"b"}`}, lines)
+	assert.Equal(t, "- tmax: 100", diffs[5].String())
+
+	diffs, err = DiffRequests([]byte(`{"imp":[{"id":"a"},{"id":"b"}]}`), []byte(`{"imp":[{"id":"a"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []RequestDiff{{Path: "imp.1", Kind: RequestDiffRemoved, A: map[string]any{"id": "b"}}}, diffs)
+
+	diffs, err = DiffRequests([]byte(`{"id":"1"}`), []byte(`{"id":"1"}`))
+	assert.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	_, err = DiffRequests([]byte(`{"id":`), []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestDiffRequestDialects(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	partner := RequestDialect{
+		Options: []BidRequestRTBOption{
+			WithAuctionType(types.SecondPriceAuctionType),
+			WithBidFloor(1.23456),
+		},
+		Fields:        &RequestFieldFilter{Deny: []string{"user"}},
+		PriceDecimals: 2,
+	}
+
+	diffs, err := DiffRequestDialects(request, RequestDialect{}, partner)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []RequestDiff{
+		{Path: "at", Kind: RequestDiffChanged, A: json.Number("0"), B: json.Number("2")},
+		{Path: "imp.0.bidfloor", Kind: RequestDiffAdded, B: json.Number("1.23")},
+		{Path: "user", Kind: RequestDiffRemoved, A: map[string]any{"geo": map[string]any{"country": "**"}}},
+	}, diffs)
+
+	// The rendered request of the dialect is the outgoing request of the source
+	data, err := partner.Render(request)
+	if assert.NoError(t, err) {
+		var rtbRequest map[string]any
+		assert.NoError(t, json.Unmarshal(data, &rtbRequest))
+		assert.Equal(t, "auction1", rtbRequest["id"])
+		assert.NotContains(t, rtbRequest, "user")
+	}
+}
@@ -0,0 +1,29 @@
+package adsourceopenrtb
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// RequestDrop of the object removed from the invalid request by the validation downgrade
+type RequestDrop struct {
+	Object string `json:"object"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// reportRequestDrops logs and counts the objects removed from the invalid request
+func (d *driver) reportRequestDrops(request adtype.BidRequester, drops []RequestDrop) {
+	if len(drops) == 0 {
+		return
+	}
+	for _, drop := range drops {
+		if d.downgradeMetric != nil {
+			d.downgradeMetric.WithLabelValues(drop.Object).Inc()
+		}
+	}
+	d.requestLogger(request).Warn("invalid request downgraded",
+		zap.Uint64("source_id", d.source.ID),
+		zap.Any("dropped", drops))
+}
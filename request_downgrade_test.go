@@ -0,0 +1,78 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	openrtbv3 "github.com/bsm/openrtb/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/udetect"
+)
+
+func TestRequestDowngradeV2(t *testing.T) {
+	valid := &openrtb.BidRequest{ID: "1", Imp: []openrtb.Impression{{ID: "1", Banner: &openrtb.Banner{}}}}
+	assert.Empty(t, openrtbV2Downgrade(valid))
+	assert.Len(t, valid.Imp, 1)
+
+	rtbRequest := &openrtb.BidRequest{
+		ID:   "1",
+		Site: &openrtb.Site{Inventory: openrtb.Inventory{ID: "site1"}},
+		App:  &openrtb.App{},
+		Imp: []openrtb.Impression{
+			{ID: "1", Banner: &openrtb.Banner{}},
+			{ID: "2", Banner: &openrtb.Banner{}, Native: &openrtb.Native{}},
+			{ID: "3", Video: &openrtb.Video{}},
+		},
+	}
+	assert.Equal(t, []RequestDrop{
+		{Object: "site", ID: "site1", Reason: openrtb.ErrInvalidReqMultiInv.Error()},
+		{Object: "imp", ID: "2", Reason: openrtb.ErrInvalidImpMultiAssets.Error()},
+		{Object: "imp", ID: "3", Reason: openrtb.ErrInvalidVideoNoMimes.Error()},
+	}, openrtbV2Downgrade(rtbRequest))
+	assert.NoError(t, rtbRequest.Validate())
+	assert.Nil(t, rtbRequest.Site)
+	assert.Len(t, rtbRequest.Imp, 1)
+
+	// The request without the valid impressions stays invalid
+	rtbRequest = &openrtb.BidRequest{ID: "1", Imp: []openrtb.Impression{{ID: "1", Video: &openrtb.Video{}}}}
+	assert.Len(t, openrtbV2Downgrade(rtbRequest), 1)
+	assert.ErrorIs(t, rtbRequest.Validate(), openrtb.ErrInvalidReqNoImps)
+}
+
+func TestRequestDowngradeV3(t *testing.T) {
+	rtbRequest := &openrtbv3.BidRequest{
+		ID:   "1",
+		Site: &openrtbv3.Site{Inventory: openrtbv3.Inventory{ID: "site1"}},
+		App:  &openrtbv3.App{},
+		Impressions: []openrtbv3.Impression{
+			{ID: "1", Banner: &openrtbv3.Banner{}},
+			{Banner: &openrtbv3.Banner{}},
+		},
+	}
+	assert.Equal(t, []RequestDrop{
+		{Object: "site", ID: "site1", Reason: openrtbv3.ErrInvalidReqMultiInv.Error()},
+		{Object: "imp", Reason: openrtbv3.ErrInvalidImpNoID.Error()},
+	}, openrtbV3Downgrade(rtbRequest))
+	assert.NoError(t, rtbRequest.Validate())
+	assert.Empty(t, openrtbV3Downgrade(rtbRequest))
+}
+
+func TestDriverRequestDowngrade(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.Site = &udetect.Site{Domain: "example.com"}
+	request.App = &udetect.App{Bundle: "com.example"}
+
+	// The invalid request fails the bid by default
+	_, _, err := newTestDriver(t, nil).encodeRequest(request, nil)
+	assert.ErrorIs(t, err, openrtb.ErrInvalidReqMultiInv)
+
+	registry := prometheus.NewRegistry()
+	d := newTestDriver(t, nil, WithRequestDowngrade(true), testMetricsRegistry(registry))
+	rtbRequest := testEncodeRequest(t, d, request)
+	assert.NotContains(t, rtbRequest, "site")
+	assert.Equal(t, "com.example", rtbRequest["app"].(map[string]any)["bundle"])
+	assert.Equal(t, map[string]float64{"site": 1}, testCounters(t, registry, "adsource_request_downgrade_total", "object"))
+}
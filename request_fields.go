@@ -0,0 +1,114 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+)
+
+// requestRequiredFields are never removed from the request by the allowlist
+var requestRequiredFields = []string{"id", "imp.id"}
+
+// RequestFieldFilter removes the fields from the encoded request by the dot-separated path
+// (like `device.ifa` or `user.geo`) for the contractual data-sharing restrictions.
+// The arrays are transparent for the path, so `imp.ext` is applied to each impression.
+// If the allowlist is defined only the listed fields (with the whole subtree) are kept,
+// then the denylist fields are removed.
+type RequestFieldFilter struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// IsEmpty returns true if the filter doesn't change the request
+func (f *RequestFieldFilter) IsEmpty() bool {
+	return f == nil || (len(f.Allow) == 0 && len(f.Deny) == 0)
+}
+
+// Apply the filter to the encoded JSON request
+func (f *RequestFieldFilter) Apply(data []byte) ([]byte, error) {
+	return rewriteRequest(data, nil, f, 0)
+}
+
+// apply the filter to the decoded JSON request
+func (f *RequestFieldFilter) apply(obj any) any {
+	if len(f.Allow) > 0 {
+		tree := jsonFieldTree{}
+		for _, path := range slices.Concat(f.Allow, requestRequiredFields) {
+			tree.add(strings.Split(path, "."))
+		}
+		obj = tree.keep(obj)
+	}
+	for _, path := range f.Deny {
+		obj = deleteJSONPath(obj, strings.Split(path, "."))
+	}
+	return obj
+}
+
+// jsonFieldTree of the allowed fields, the nil subtree keeps all nested fields
+type jsonFieldTree map[string]jsonFieldTree
+
+func (t jsonFieldTree) add(path []string) {
+	sub, ok := t[path[0]]
+	switch {
+	case ok && sub == nil:
+		// The whole subtree is already allowed
+	case len(path) == 1:
+		t[path[0]] = nil
+	default:
+		if sub == nil {
+			sub = jsonFieldTree{}
+			t[path[0]] = sub
+		}
+		sub.add(path[1:])
+	}
+}
+
+func (t jsonFieldTree) keep(node any) any {
+	switch nd := node.(type) {
+	case []any:
+		for i := range nd {
+			nd[i] = t.keep(nd[i])
+		}
+	case map[string]any:
+		for key, val := range nd {
+			sub, ok := t[key]
+			switch {
+			case !ok:
+				delete(nd, key)
+			case sub != nil:
+				nd[key] = sub.keep(val)
+			}
+		}
+	}
+	return node
+}
+
+func deleteJSONPath(node any, path []string) any {
+	switch nd := node.(type) {
+	case []any:
+		for i := range nd {
+			nd[i] = deleteJSONPath(nd[i], path)
+		}
+	case map[string]any:
+		if len(path) == 1 {
+			delete(nd, path[0])
+		} else if val, ok := nd[path[0]]; ok {
+			nd[path[0]] = deleteJSONPath(val, path[1:])
+		}
+	}
+	return node
+}
+
+// sourceRequestFieldFilter returns the field filter from the source config (`request_fields`)
+// or the default filter of the driver options
+func sourceRequestFieldFilter(source *admodels.RTBSource, opts *DriverOptions) *RequestFieldFilter {
+	var filter *RequestFieldFilter
+	if sourceConfigValue(source, sourceConfigRequestFields, &filter) && !filter.IsEmpty() {
+		return filter
+	}
+	if opts.RequestFieldFilter.IsEmpty() {
+		return nil
+	}
+	return opts.RequestFieldFilter
+}
@@ -0,0 +1,66 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/udetect"
+)
+
+func TestRequestFieldFilter(t *testing.T) {
+	const request = `{"id":"1","imp":[{"id":"i1","tagid":"t1","ext":{"a":1}},{"id":"i2","ext":{"b":2}}],` +
+		`"device":{"ifa":"x","ua":"y","geo":{"lat":1.5,"country":"DE"}},"user":{"id":"u","geo":{"country":"DE"}}}`
+	tests := []struct {
+		name   string
+		filter *RequestFieldFilter
+		want   string
+	}{
+		{name: "empty", want: request},
+		{
+			name:   "deny",
+			filter: &RequestFieldFilter{Deny: []string{"device.ifa", "user.geo", "imp.ext", "site.page"}},
+			want:   `{"id":"1","imp":[{"id":"i1","tagid":"t1"},{"id":"i2"}],"device":{"ua":"y","geo":{"lat":1.5,"country":"DE"}},"user":{"id":"u"}}`,
+		},
+		{
+			// The request and the impression IDs are always kept
+			name:   "allow",
+			filter: &RequestFieldFilter{Allow: []string{"imp.tagid", "device.geo.country", "device"}},
+			want:   `{"id":"1","imp":[{"id":"i1","tagid":"t1"},{"id":"i2"}],"device":{"ifa":"x","ua":"y","geo":{"lat":1.5,"country":"DE"}}}`,
+		},
+		{
+			name:   "allow_deny",
+			filter: &RequestFieldFilter{Allow: []string{"imp", "device.geo"}, Deny: []string{"imp.ext", "device.geo.lat"}},
+			want:   `{"id":"1","imp":[{"id":"i1","tagid":"t1"},{"id":"i2"}],"device":{"geo":{"country":"DE"}}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := test.filter.Apply([]byte(request))
+			if assert.NoError(t, err) {
+				assert.JSONEq(t, test.want, string(data))
+			}
+		})
+	}
+
+	_, err := (&RequestFieldFilter{Deny: []string{"id"}}).Apply([]byte(`{"id":`))
+	assert.Error(t, err)
+}
+
+func TestSourceRequestFieldFilter(t *testing.T) {
+	newRequest := func() map[string]any {
+		request := newTestRequest(context.Background(), "banner_300x250")
+		request.Device = &udetect.Device{IFA: "ifa", Browser: &udetect.Browser{UA: "Mozilla/5.0"}}
+		d := newTestDriver(t, nil, WithRequestFieldFilter(nil, []string{"device.ua"}),
+			testSourceConfig(map[string]any{"request_fields": map[string]any{"deny": []string{"device.ifa"}}}))
+		return testEncodeRequest(t, d, request)
+	}
+
+	// The filter of the source config has priority over the driver options
+	device := newRequest()["device"].(map[string]any)
+	assert.NotContains(t, device, "ifa")
+	assert.Equal(t, "Mozilla/5.0", device["ua"])
+
+	assert.Nil(t, sourceRequestFieldFilter(newTestDriver(t, nil).source, &DriverOptions{RequestFieldFilter: &RequestFieldFilter{}}))
+}
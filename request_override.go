@@ -0,0 +1,60 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// RequestOverride of the source options for the single request, it's used for the targeted
+// debugging of the user or the placement in production without the source reconfiguration
+type RequestOverride struct {
+	// Sources the override is applied to (all sources if empty)
+	Sources []uint64
+
+	// Trace logs the encoded request and the response like the trace flag of the source
+	Trace bool
+
+	// Timeout shrinks the maximal time of the bid response (tmax), it never extends the source timeout
+	Timeout time.Duration
+
+	// Options of the dialect experiment applied over the request options of the source
+	Options []BidRequestRTBOption
+}
+
+type requestOverrideKey struct{}
+
+// WithRequestOverride returns the context with the override of the source options.
+// The request context is checked by the driver for each source request.
+func WithRequestOverride(ctx context.Context, override *RequestOverride) context.Context {
+	return context.WithValue(ctx, requestOverrideKey{}, override)
+}
+
+// RequestOverrideFromContext returns the override of the source options or nil
+func RequestOverrideFromContext(ctx context.Context) *RequestOverride {
+	if ctx == nil {
+		return nil
+	}
+	override, _ := ctx.Value(requestOverrideKey{}).(*RequestOverride)
+	return override
+}
+
+// requestOverride returns the override of the source options for the request or nil
+func (d *driver) requestOverride(request adtype.BidRequester) *RequestOverride {
+	override := RequestOverrideFromContext(request.Context())
+	if override == nil || (len(override.Sources) > 0 && !slices.Contains(override.Sources, d.source.ID)) {
+		return nil
+	}
+	return override
+}
+
+// isTrace returns true if the request and the response have to be logged
+func (d *driver) isTrace(request adtype.BidRequester) bool {
+	if d.source.Options.Trace != 0 {
+		return true
+	}
+	override := d.requestOverride(request)
+	return override != nil && override.Trace
+}
@@ -0,0 +1,44 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestOverrideFromContext(t *testing.T) {
+	override := &RequestOverride{Trace: true}
+	assert.Nil(t, RequestOverrideFromContext(nil))
+	assert.Nil(t, RequestOverrideFromContext(context.Background()))
+	assert.Same(t, override, RequestOverrideFromContext(WithRequestOverride(context.Background(), override)))
+}
+
+func TestRequestOverride(t *testing.T) {
+	d := newTestDriver(t, nil)
+	newRequest := func(override *RequestOverride) map[string]any {
+		return testEncodeRequest(t, d, newTestRequest(WithRequestOverride(context.Background(), override), "banner_300x250"))
+	}
+
+	rtbRequest := newRequest(&RequestOverride{Timeout: 200 * time.Millisecond, Options: []BidRequestRTBOption{WithGDPR(true)}})
+	assert.Equal(t, float64(200), rtbRequest["tmax"])
+	assert.Equal(t, map[string]any{"gdpr": float64(1)}, rtbRequest["regs"].(map[string]any)["ext"])
+
+	// The override never extends the source timeout
+	rtbRequest = newRequest(&RequestOverride{Timeout: 5 * time.Second})
+	assert.Equal(t, float64(1000), rtbRequest["tmax"])
+
+	// The override of the other sources is ignored
+	rtbRequest = newRequest(&RequestOverride{Sources: []uint64{2}, Timeout: 200 * time.Millisecond})
+	assert.Equal(t, float64(1000), rtbRequest["tmax"])
+}
+
+func TestRequestOverrideTrace(t *testing.T) {
+	d := newTestDriver(t, nil)
+	assert.False(t, d.isTrace(newTestRequest(context.Background())))
+	assert.True(t, d.isTrace(newTestRequest(WithRequestOverride(context.Background(),
+		&RequestOverride{Sources: []uint64{1}, Trace: true}))))
+	assert.False(t, d.isTrace(newTestRequest(WithRequestOverride(context.Background(),
+		&RequestOverride{Sources: []uint64{2}, Trace: true}))))
+}
@@ -0,0 +1,328 @@
+package adsourceopenrtb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/bsm/openrtb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoKind of the protobuf field value
+type protoKind int
+
+const (
+	protoKindString protoKind = iota
+	protoKindVarint
+	protoKindDouble
+	protoKindMessage
+	protoKindPackedVarint
+)
+
+var errProtobufPrivacySignal = errors.New("privacy signal can't be encoded by protobuf")
+
+// protoField of the OpenRTB 2.x protobuf schema (openrtb.proto) mapped from the JSON field name,
+// the repeated fields are detected by the JSON arrays
+type protoField struct {
+	name string
+	num  protowire.Number
+	kind protoKind
+	msg  []protoField
+
+	// ext name of the field value in the object extension if the field itself is not set
+	ext string
+
+	// strict field fails the encoding if the value can't be encoded
+	strict bool
+}
+
+// value of the field in the JSON object or in the object extension
+func (field *protoField) value(obj map[string]any) any {
+	if val := obj[field.name]; val != nil || field.ext == "" {
+		return val
+	}
+	ext, _ := obj["ext"].(map[string]any)
+	return ext[field.ext]
+}
+
+func protoStr(name string, num protowire.Number) protoField {
+	return protoField{name: name, num: num, kind: protoKindString}
+}
+
+func protoVarint(name string, num protowire.Number) protoField {
+	return protoField{name: name, num: num, kind: protoKindVarint}
+}
+
+func protoDouble(name string, num protowire.Number) protoField {
+	return protoField{name: name, num: num, kind: protoKindDouble}
+}
+
+func protoMsg(name string, num protowire.Number, msg []protoField) protoField {
+	return protoField{name: name, num: num, kind: protoKindMessage, msg: msg}
+}
+
+func protoPackedVarint(name string, num protowire.Number) protoField {
+	return protoField{name: name, num: num, kind: protoKindPackedVarint}
+}
+
+// protoPrivacy signal field which is read from the extension of the OpenRTB 2.5 requests
+// and can't be dropped silently, the request fails if the value can't be encoded
+func protoPrivacy(field protoField, ext string) protoField {
+	field.ext = ext
+	field.strict = true
+	return field
+}
+
+// OpenRTB 2.x protobuf schema of the bid request, the extensions are not encoded
+// except the privacy signals mapped to the fields of the OpenRTB 2.6 schema
+var (
+	protoSchemaFormat = []protoField{
+		protoVarint("w", 1), protoVarint("h", 2), protoVarint("wratio", 3),
+		protoVarint("hratio", 4), protoVarint("wmin", 5),
+	}
+	protoSchemaBanner = []protoField{
+		protoVarint("w", 1), protoVarint("h", 2), protoStr("id", 3), protoVarint("pos", 4),
+		protoVarint("btype", 5), protoVarint("battr", 6), protoStr("mimes", 7),
+		protoVarint("topframe", 8), protoVarint("expdir", 9), protoVarint("api", 10),
+		protoVarint("wmax", 11), protoVarint("hmax", 12), protoVarint("wmin", 13),
+		protoVarint("hmin", 14), protoMsg("format", 15, protoSchemaFormat), protoVarint("vcm", 16),
+	}
+	protoSchemaVideo = []protoField{
+		protoStr("mimes", 1), protoVarint("linearity", 2), protoVarint("minduration", 3),
+		protoVarint("maxduration", 4), protoVarint("protocol", 5), protoVarint("w", 6),
+		protoVarint("h", 7), protoVarint("startdelay", 8), protoVarint("sequence", 9),
+		protoVarint("battr", 10), protoVarint("maxextended", 11), protoVarint("minbitrate", 12),
+		protoVarint("maxbitrate", 13), protoVarint("boxingallowed", 14),
+		protoVarint("playbackmethod", 15), protoVarint("delivery", 16), protoVarint("pos", 17),
+		protoMsg("companionad", 18, protoSchemaBanner), protoVarint("api", 19),
+		protoVarint("companiontype", 20), protoVarint("protocols", 21), protoVarint("skip", 23),
+		protoVarint("skipmin", 24), protoVarint("skipafter", 25), protoVarint("placement", 26),
+		protoVarint("playbackend", 27),
+	}
+	protoSchemaNative = []protoField{
+		protoStr("request", 1), protoStr("ver", 2), protoVarint("api", 3), protoVarint("battr", 4),
+	}
+	protoSchemaDeal = []protoField{
+		protoStr("id", 1), protoDouble("bidfloor", 2), protoStr("bidfloorcur", 3),
+		protoStr("wseat", 4), protoStr("wadomain", 5), protoVarint("at", 6),
+	}
+	protoSchemaPMP = []protoField{
+		protoVarint("private_auction", 1), protoMsg("deals", 2, protoSchemaDeal),
+	}
+	protoSchemaImp = []protoField{
+		protoStr("id", 1), protoMsg("banner", 2, protoSchemaBanner),
+		protoMsg("video", 3, protoSchemaVideo), protoStr("displaymanager", 4),
+		protoStr("displaymanagerver", 5), protoVarint("instl", 6), protoStr("tagid", 7),
+		protoDouble("bidfloor", 8), protoStr("bidfloorcur", 9), protoStr("iframebuster", 10),
+		protoMsg("pmp", 11, protoSchemaPMP), protoVarint("secure", 12),
+		protoMsg("native", 13, protoSchemaNative), protoVarint("exp", 14),
+		protoVarint("clickbrowser", 16), protoVarint("rwdd", 18),
+	}
+	protoSchemaPublisher = []protoField{
+		protoStr("id", 1), protoStr("name", 2), protoStr("cat", 3), protoStr("domain", 4),
+	}
+	protoSchemaContent = []protoField{
+		protoStr("id", 1), protoVarint("episode", 2), protoStr("title", 3), protoStr("series", 4),
+		protoStr("season", 5), protoStr("url", 6), protoStr("cat", 7), protoStr("keywords", 9),
+	}
+	protoSchemaSite = []protoField{
+		protoStr("id", 1), protoStr("name", 2), protoStr("domain", 3), protoStr("cat", 4),
+		protoStr("sectioncat", 5), protoStr("pagecat", 6), protoStr("page", 7),
+		protoVarint("privacypolicy", 8), protoStr("ref", 9), protoStr("search", 10),
+		protoMsg("publisher", 11, protoSchemaPublisher), protoMsg("content", 12, protoSchemaContent),
+		protoStr("keywords", 13), protoVarint("mobile", 15),
+	}
+	protoSchemaApp = []protoField{
+		protoStr("id", 1), protoStr("name", 2), protoStr("domain", 3), protoStr("cat", 4),
+		protoStr("sectioncat", 5), protoStr("pagecat", 6), protoStr("ver", 7), protoStr("bundle", 8),
+		protoVarint("privacypolicy", 9), protoVarint("paid", 10),
+		protoMsg("publisher", 11, protoSchemaPublisher), protoMsg("content", 12, protoSchemaContent),
+		protoStr("keywords", 13), protoStr("storeurl", 16),
+	}
+	protoSchemaGeo = []protoField{
+		protoDouble("lat", 1), protoDouble("lon", 2), protoStr("country", 3), protoStr("region", 4),
+		protoStr("regionfips104", 5), protoStr("metro", 6), protoStr("city", 7), protoStr("zip", 8),
+		protoVarint("type", 9), protoVarint("utcoffset", 10), protoVarint("accuracy", 11),
+		protoVarint("lastfix", 12), protoVarint("ipservice", 13),
+	}
+	protoSchemaDevice = []protoField{
+		protoVarint("dnt", 1), protoStr("ua", 2), protoStr("ip", 3), protoMsg("geo", 4, protoSchemaGeo),
+		protoStr("didsha1", 5), protoStr("didmd5", 6), protoStr("dpidsha1", 7), protoStr("dpidmd5", 8),
+		protoStr("ipv6", 9), protoStr("carrier", 10), protoStr("language", 11), protoStr("make", 12),
+		protoStr("model", 13), protoStr("os", 14), protoStr("osv", 15), protoVarint("js", 16),
+		protoVarint("connectiontype", 17), protoVarint("devicetype", 18), protoStr("flashver", 19),
+		protoStr("ifa", 20), protoStr("macsha1", 21), protoStr("macmd5", 22), protoVarint("lmt", 23),
+		protoStr("hwv", 24), protoVarint("w", 25), protoVarint("h", 26), protoVarint("ppi", 27),
+		protoDouble("pxratio", 28), protoVarint("geofetch", 29), protoStr("mccmnc", 30),
+	}
+	protoSchemaSegment = []protoField{
+		protoStr("id", 1), protoStr("name", 2), protoStr("value", 3),
+	}
+	protoSchemaData = []protoField{
+		protoStr("id", 1), protoStr("name", 2), protoMsg("segment", 3, protoSchemaSegment),
+	}
+	protoSchemaUser = []protoField{
+		protoStr("id", 1), protoStr("buyeruid", 2), protoVarint("yob", 3), protoStr("gender", 4),
+		protoStr("keywords", 5), protoStr("customdata", 6), protoMsg("geo", 7, protoSchemaGeo),
+		protoMsg("data", 8, protoSchemaData), protoPrivacy(protoStr("consent", 10), "consent"),
+	}
+	protoSchemaRegs = []protoField{
+		protoVarint("coppa", 1), protoPrivacy(protoVarint("gdpr", 4), "gdpr"),
+		protoPrivacy(protoStr("us_privacy", 5), "us_privacy"), protoPrivacy(protoStr("gpp", 6), ""),
+		protoPrivacy(protoPackedVarint("gpp_sid", 7), ""),
+	}
+	protoSchemaSource = []protoField{
+		protoVarint("fd", 1), protoStr("tid", 2), protoStr("pchain", 3),
+	}
+	protoSchemaBidRequest = []protoField{
+		protoStr("id", 1), protoMsg("imp", 2, protoSchemaImp), protoMsg("site", 3, protoSchemaSite),
+		protoMsg("app", 4, protoSchemaApp), protoMsg("device", 5, protoSchemaDevice),
+		protoMsg("user", 6, protoSchemaUser), protoVarint("at", 7), protoVarint("tmax", 8),
+		protoStr("wseat", 9), protoVarint("allimps", 10), protoStr("cur", 11), protoStr("bcat", 12),
+		protoStr("badv", 13), protoMsg("regs", 14, protoSchemaRegs), protoVarint("test", 15),
+		protoStr("bapp", 16), protoStr("bseat", 17), protoStr("wlang", 18),
+		protoMsg("source", 19, protoSchemaSource),
+	}
+)
+
+// MarshalBidRequestProtobuf into the OpenRTB 2.x protobuf message (openrtb.proto).
+// The proto extensions can't be encoded without the schema and are skipped,
+// the privacy signals of the extensions are encoded by the OpenRTB 2.6 fields.
+func MarshalBidRequestProtobuf(req *openrtb.BidRequest) ([]byte, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return encodeRequestProtobuf(data)
+}
+
+// encodeRequestProtobuf converts the encoded JSON request into the protobuf message,
+// so the protobuf request contains the same fields as the JSON one after all the builders and filters
+func encodeRequestProtobuf(data []byte) ([]byte, error) {
+	var obj map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return appendProtoMessage(nil, obj, protoSchemaBidRequest)
+}
+
+func appendProtoMessage(buf []byte, obj map[string]any, schema []protoField) (_ []byte, err error) {
+	for i := range schema {
+		field := &schema[i]
+		val := field.value(obj)
+		if val == nil {
+			continue
+		}
+		if list, isList := val.([]any); isList && field.kind != protoKindPackedVarint {
+			for _, item := range list {
+				if buf, err = appendProtoValue(buf, field, item); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if buf, err = appendProtoValue(buf, field, val); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func appendProtoValue(buf []byte, field *protoField, val any) ([]byte, error) {
+	ok := false
+	switch field.kind {
+	case protoKindString:
+		var s string
+		if s, ok = protoJSONString(val); ok {
+			buf = protowire.AppendTag(buf, field.num, protowire.BytesType)
+			buf = protowire.AppendString(buf, s)
+		}
+	case protoKindVarint:
+		var num float64
+		if num, ok = protoJSONNumber(val); ok {
+			buf = protowire.AppendTag(buf, field.num, protowire.VarintType)
+			buf = protowire.AppendVarint(buf, uint64(int64(num)))
+		}
+	case protoKindDouble:
+		var num float64
+		if num, ok = protoJSONNumber(val); ok {
+			buf = protowire.AppendTag(buf, field.num, protowire.Fixed64Type)
+			buf = protowire.AppendFixed64(buf, math.Float64bits(num))
+		}
+	case protoKindPackedVarint:
+		var packed []byte
+		if packed, ok = protoPackedVarints(val); ok {
+			buf = protowire.AppendTag(buf, field.num, protowire.BytesType)
+			buf = protowire.AppendBytes(buf, packed)
+		}
+	case protoKindMessage:
+		var obj map[string]any
+		if obj, ok = val.(map[string]any); ok {
+			msg, err := appendProtoMessage(nil, obj, field.msg)
+			if err != nil {
+				return nil, err
+			}
+			buf = protowire.AppendTag(buf, field.num, protowire.BytesType)
+			buf = protowire.AppendBytes(buf, msg)
+		}
+	}
+	if !ok && field.strict {
+		return nil, fmt.Errorf("%w: %s", errProtobufPrivacySignal, field.name)
+	}
+	return buf, nil
+}
+
+// protoPackedVarints returns the packed varints of the JSON number or the list of numbers
+func protoPackedVarints(val any) (packed []byte, ok bool) {
+	list, isList := val.([]any)
+	if !isList {
+		list = []any{val}
+	}
+	for _, item := range list {
+		num, ok := protoJSONNumber(item)
+		if !ok {
+			return nil, false
+		}
+		packed = protowire.AppendVarint(packed, uint64(int64(num)))
+	}
+	return packed, true
+}
+
+// protoJSONString returns the string of the JSON string or number value,
+// the objects (like the native request payload) are encoded as the JSON strings
+func protoJSONString(val any) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case map[string]any:
+		data, err := json.Marshal(v)
+		return string(data), err == nil
+	}
+	return "", false
+}
+
+// protoJSONNumber returns the number of the JSON number, numeric string or boolean value
+func protoJSONNumber(val any) (float64, bool) {
+	switch v := val.(type) {
+	case json.Number:
+		num, err := v.Float64()
+		return num, err == nil
+	case string:
+		num, err := strconv.ParseFloat(v, 64)
+		return num, err == nil
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
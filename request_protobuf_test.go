@@ -0,0 +1,154 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// protoTestFields returns the values of the protobuf message fields by the field number
+func protoTestFields(t *testing.T, msg []byte) map[protowire.Number][][]byte {
+	t.Helper()
+	fields := map[protowire.Number][][]byte{}
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if !assert.GreaterOrEqual(t, n, 0) {
+			return fields
+		}
+		msg = msg[n:]
+		var val []byte
+		switch typ {
+		case protowire.BytesType:
+			val, n = protowire.ConsumeBytes(msg)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+			val = msg[:max(n, 0)]
+		}
+		if !assert.GreaterOrEqual(t, n, 0) {
+			return fields
+		}
+		fields[num] = append(fields[num], val)
+		msg = msg[n:]
+	}
+	return fields
+}
+
+func TestMarshalBidRequestProtobuf(t *testing.T) {
+	data, err := MarshalBidRequestProtobuf(&openrtb.BidRequest{
+		ID:  "req1",
+		Imp: []openrtb.Impression{{ID: "imp1", BidFloor: 0.5, Banner: &openrtb.Banner{W: 300, H: 250}}},
+		Cur: []string{"USD", "EUR"},
+		Ext: []byte(`{"x":1}`),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	req := protoTestFields(t, data)
+	assert.Equal(t, [][]byte{[]byte("req1")}, req[1])
+	assert.Equal(t, [][]byte{[]byte("USD"), []byte("EUR")}, req[11])
+	if assert.Len(t, req[2], 1) {
+		imp := protoTestFields(t, req[2][0])
+		assert.Equal(t, [][]byte{[]byte("imp1")}, imp[1])
+		if assert.Len(t, imp[8], 1) {
+			floor, _ := protowire.ConsumeFixed64(imp[8][0])
+			assert.Equal(t, 0.5, math.Float64frombits(floor))
+		}
+		if assert.Len(t, imp[2], 1) {
+			banner := protoTestFields(t, imp[2][0])
+			width, _ := protowire.ConsumeVarint(banner[1][0])
+			assert.Equal(t, uint64(300), width)
+		}
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, adresponse.ContentTypeProtobuf, r.Header.Get("Content-Type"))
+		data, _ := io.ReadAll(r.Body)
+		req := protoTestFields(t, data)
+		if !assert.Len(t, req[2], 1) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		impID := protoTestFields(t, req[2][0])[1][0]
+
+		var bid []byte
+		bid = protowire.AppendTag(bid, 1, protowire.BytesType)
+		bid = protowire.AppendString(bid, "1")
+		bid = protowire.AppendTag(bid, 2, protowire.BytesType)
+		bid = protowire.AppendBytes(bid, impID)
+		bid = protowire.AppendTag(bid, 3, protowire.Fixed64Type)
+		bid = protowire.AppendFixed64(bid, math.Float64bits(1.5))
+		bid = protowire.AppendTag(bid, 6, protowire.BytesType)
+		bid = protowire.AppendString(bid, "<div></div>")
+		bid = protowire.AppendTag(bid, 10, protowire.BytesType)
+		bid = protowire.AppendString(bid, "c1")
+		seat := protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), bid)
+
+		var resp []byte
+		resp = protowire.AppendTag(resp, 1, protowire.BytesType)
+		resp = protowire.AppendBytes(resp, req[1][0])
+		resp = protowire.AppendTag(resp, 2, protowire.BytesType)
+		resp = protowire.AppendBytes(resp, seat)
+
+		w.Header().Set("Content-Type", adresponse.ContentTypeProtobuf)
+		_, _ = w.Write(resp)
+	}, testSourceOption(func(source *admodels.RTBSource) {
+		source.RequestType = RequestTypeProtobuff
+	}))
+
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.NoError(t, resp.Error())
+	assert.Len(t, resp.Ads(), 1)
+}
+
+func TestProtobufPrivacySignals(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	for _, version := range []string{ProtocolVersion25, ProtocolVersion26} {
+		data, err := EncodeRequestV2(request, WithProtocolVersion(version),
+			WithGDPR(true), WithGDPRConsent("CPXxRfAPXxRfAAfKABENB"), WithGPP("DBABMA~CPXxRfAPXxRfAAfKABENB", 2, 6))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		if data, err = encodeRequestProtobuf(data); !assert.NoError(t, err) {
+			continue
+		}
+		req := protoTestFields(t, data)
+		if !assert.Len(t, req[14], 1, version) || !assert.Len(t, req[6], 1, version) {
+			continue
+		}
+		regs, user := protoTestFields(t, req[14][0]), protoTestFields(t, req[6][0])
+		if assert.Len(t, regs[4], 1, version) {
+			gdpr, _ := protowire.ConsumeVarint(regs[4][0])
+			assert.Equal(t, uint64(1), gdpr, version)
+		}
+		assert.Equal(t, [][]byte{[]byte("CPXxRfAPXxRfAAfKABENB")}, user[10], version)
+		if version == ProtocolVersion25 {
+			assert.Empty(t, regs[6], "the GPP is sent by OpenRTB 2.6 only")
+			continue
+		}
+		assert.Equal(t, [][]byte{[]byte("DBABMA~CPXxRfAPXxRfAAfKABENB")}, regs[6])
+		if assert.Len(t, regs[7], 1) {
+			sid, n := protowire.ConsumeVarint(regs[7][0])
+			next, _ := protowire.ConsumeVarint(regs[7][0][n:])
+			assert.Equal(t, []uint64{2, 6}, []uint64{sid, next})
+		}
+	}
+
+	// The request fails instead of dropping the signal which can't be encoded
+	_, err := encodeRequestProtobuf([]byte(`{"id":"1","regs":{"ext":{"gdpr":"yes"}}}`))
+	assert.ErrorIs(t, err, errProtobufPrivacySignal)
+	_, err = encodeRequestProtobuf([]byte(`{"id":"1","regs":{"gpp":"DBA","gpp_sid":[2,"x"]}}`))
+	assert.ErrorIs(t, err, errProtobufPrivacySignal)
+	_, err = encodeRequestProtobuf([]byte(`{"id":"1","user":{"ext":{"consent":true}}}`))
+	assert.ErrorIs(t, err, errProtobufPrivacySignal)
+}
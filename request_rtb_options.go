@@ -1,14 +1,35 @@
 package adsourceopenrtb
 
 import (
+	"slices"
 	"time"
 
 	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// KeywordsFormat of the keywords in the request
+type KeywordsFormat int
+
+// Keywords formats
+const (
+	// KeywordsFormatAuto uses the comma separated string for OpenRTB < 2.6
+	// and both forms for OpenRTB 2.6+
+	KeywordsFormatAuto KeywordsFormat = iota
+	// KeywordsFormatString uses only comma separated `keywords` field
+	KeywordsFormatString
+	// KeywordsFormatArray uses only `kwarray` field (OpenRTB 2.6)
+	KeywordsFormatArray
+	// KeywordsFormatBoth uses both `keywords` and `kwarray` fields
+	KeywordsFormatBoth
 )
 
 // BidRequestRTBOptions of request build
 type BidRequestRTBOptions struct {
-	OpenNative struct {
+	ProtocolVersion string
+	OpenNative      struct {
 		Ver string
 	}
 	FormatFilter func(f *types.Format) bool
@@ -16,22 +37,307 @@ type BidRequestRTBOptions struct {
 	TimeMax      time.Duration
 	AuctionType  types.AuctionType
 	BidFloor     float64
+
+	// FormatBidFloor overrides the bid floor per format type
+	FormatBidFloor map[types.FormatType]float64
+
+	// UserFrequency data of the user for the source
+	UserFrequency *UserFrequency
+
+	// UserSegments resolved by external audience data providers
+	UserSegments []AudienceSegments
+
+	// PageContext with contextual keywords and categories of the site page
+	PageContext *PageContext
+
+	// KeywordsFormat of the site/app/user keywords
+	KeywordsFormat KeywordsFormat
+
+	// CategoryTaxonomy of the categories in the request (cattax)
+	CategoryTaxonomy int
+
+	// BlockedAttributes returns the blocked creative attributes of the impression
+	BlockedAttributes func(imp *adtype.Impression) *BlockedAttributes
+
+	// BlockedAdvertisers returns the blocked advertisers of the impression (bcat, badv, bapp),
+	// the impression is nil for the defaults of the source
+	BlockedAdvertisers func(imp *adtype.Impression) *BlockedAdvertisers
+
+	// ClickBrowser returns the click browser type of the in-app impression
+	ClickBrowser func(imp *adtype.Impression) ClickBrowser
+
+	// GDPR explicit flag of the request (regs.ext.gdpr)
+	GDPR *bool
+
+	// GDPRGeoDetection enables detection of the GDPR applicability by the user country
+	// if there is no explicit flag, GDPRDefault is used for the unknown countries
+	GDPRGeoDetection bool
+	GDPRDefault      bool
+
+	// GDPRConsent TCF string of the user (user.ext.consent)
+	GDPRConsent string
+
+	// UTCOffset of the user time zone in minutes which is used for the geo detected without the offset
+	UTCOffset *int
+
+	// GeoFetch availability of the geolocation API to the JavaScript code of the banner (device.geofetch)
+	GeoFetch *bool
+
+	// Languages returns the creative languages (ISO-639-1) allowed by the placement (wlang)
+	Languages func(imp *adtype.Impression) []string
+
+	// ContentLanguage of the page or application content (ISO-639-1)
+	ContentLanguage string
+
+	// AllowedSeats and BlockedSeats of the buyers (wseat, bseat)
+	AllowedSeats []string
+	BlockedSeats []string
+
+	// URLNormalization of the site page and referrer (not normalized if nil)
+	URLNormalization *URLNormalization
+
+	// EmptyDevicePolicy of the requests without the IP address or the user agent
+	// (the placeholder values are sent if nil)
+	EmptyDevicePolicy *EmptyDevicePolicy
+
+	// GPP string and the applicable section IDs (regs.gpp, regs.gpp_sid) of the OpenRTB 2.6 requests
+	GPP    string
+	GPPSID []int
+
+	// AcceptedCurrencies of the bids in addition to the request currency
+	AcceptedCurrencies []string
+
+	// CurrencyRate of the bid floor conversion from the system currency into the request currency
+	CurrencyRate float64
+
+	// PlacementData returns the first-party data of the impression placement
+	PlacementData func(imp *adtype.Impression) map[string]any
+
+	// Publisher of the site or application (site.publisher, app.publisher)
+	Publisher *Publisher
+
+	// PublisherData of the site or application first-party attributes
+	PublisherData map[string]any
+
+	// ImpIDCodec of the impression IDs (codename scheme by default)
+	ImpIDCodec adresponse.ImpIDCodec
+
+	// Rewarded returns true if the impression placement is rewarded
+	Rewarded func(imp *adtype.Impression) bool
+
+	// SKAdN returns the SKAdNetwork parameters of the application placement
+	SKAdN func(imp *adtype.Impression) *SKAdNRequest
+
+	// MRAID returns the MRAID API frameworks supported by the placement
+	MRAID func(imp *adtype.Impression) []int
+
+	// PMP returns the private marketplace of the impression
+	PMP func(imp *adtype.Impression) *adresponse.PMP
+
+	// Video returns the video parameters of the placement
+	Video func(imp *adtype.Impression) *VideoPlacement
+
+	// CorrelationKey of the impression correlation tokens in `imp.ext.corr` (disabled if empty)
+	CorrelationKey []byte
+
+	// ImpFilter returns false for the impressions excluded from the request
+	ImpFilter func(imp *adtype.Impression) bool
+}
+
+func newBidRequestRTBOptions(opts ...BidRequestRTBOption) *BidRequestRTBOptions {
+	var opt BidRequestRTBOptions
+	for _, fn := range opts {
+		fn(&opt)
+	}
+	return &opt
+}
+
+// impAllowed returns true if the impression is included into the request
+func (opts *BidRequestRTBOptions) impAllowed(imp *adtype.Impression) bool {
+	return opts.ImpFilter == nil || opts.ImpFilter(imp)
+}
+
+// formatAllowed returns true if the impression format can be offered to the source
+func (opts *BidRequestRTBOptions) formatAllowed(format *types.Format) bool {
+	return opts.FormatFilter == nil || opts.FormatFilter(format)
+}
+
+// versionAtLeast returns true if the protocol version is equal or newer than the version
+func (opts *BidRequestRTBOptions) versionAtLeast(ver string) bool {
+	return slices.Index(protocolVersions, opts.ProtocolVersion) >= slices.Index(protocolVersions, ver)
+}
+
+func (opts *BidRequestRTBOptions) keywordsArray() bool {
+	switch opts.KeywordsFormat {
+	case KeywordsFormatArray, KeywordsFormatBoth:
+		return true
+	case KeywordsFormatAuto:
+		return opts.versionAtLeast(ProtocolVersion26)
+	}
+	return false
+}
+
+func (opts *BidRequestRTBOptions) keywordsString() bool {
+	return opts.KeywordsFormat != KeywordsFormatArray
+}
+
+func (opts *BidRequestRTBOptions) blockedAttributes(imp *adtype.Impression) *BlockedAttributes {
+	if opts.BlockedAttributes == nil {
+		return nil
+	}
+	return opts.BlockedAttributes(imp)
+}
+
+func (opts *BidRequestRTBOptions) clickBrowser(imp *adtype.Impression) ClickBrowser {
+	if opts.ClickBrowser == nil || imp == nil {
+		return ClickBrowserUndefined
+	}
+	return opts.ClickBrowser(imp)
+}
+
+// impExt returns the impression extension with the placement first-party data,
+// the SKAdNetwork parameters, the correlation token and the rewarded flag
+// if the protocol doesn't support the `imp.rwdd` field
+func (opts *BidRequestRTBOptions) impExt(req adtype.BidRequester, imp *adtype.Impression, ext []byte) []byte {
+	if opts.PlacementData != nil {
+		if data := opts.PlacementData(imp); len(data) > 0 {
+			ext = adresponse.ExtSet(ext, "data", data)
+		}
+	}
+	if !opts.rewardedField() && opts.rewarded(imp) {
+		ext = adresponse.ExtSet(ext, "rewarded", 1)
+	}
+	if opts.SKAdN != nil && imp != nil {
+		if skadn := opts.SKAdN(imp); skadn != nil {
+			ext = adresponse.ExtSet(ext, "skadn", skadn)
+		}
+	}
+	if token := opts.correlationToken(req, imp); token != "" {
+		ext = adresponse.ExtSet(ext, correlationExtKey, token)
+	}
+	return ext
+}
+
+// mraidFrameworks returns the MRAID API frameworks supported by the placement
+func (opts *BidRequestRTBOptions) mraidFrameworks(imp *adtype.Impression) []int {
+	if opts.MRAID == nil || imp == nil {
+		return nil
+	}
+	return opts.MRAID(imp)
+}
+
+// pmp returns the private marketplace of the impression
+func (opts *BidRequestRTBOptions) pmp(imp *adtype.Impression) *adresponse.PMP {
+	if opts.PMP == nil || imp == nil {
+		return nil
+	}
+	if pmp := opts.PMP(imp); pmp != nil && (pmp.Private || len(pmp.Deals) > 0) {
+		return pmp
+	}
+	return nil
+}
+
+func (opts *BidRequestRTBOptions) rewarded(imp *adtype.Impression) bool {
+	return opts.Rewarded != nil && imp != nil && opts.Rewarded(imp)
+}
+
+// rewardedField returns true if the `imp.rwdd` field (OpenRTB 2.6) is supported,
+// the OpenRTB 3.0 requests are built without the OpenRTB 2.6 fields
+func (opts *BidRequestRTBOptions) rewardedField() bool {
+	return opts.versionAtLeast(ProtocolVersion26) && opts.ProtocolVersion != ProtocolVersion30
+}
+
+// inventoryExt returns the site/app extension with the publisher first-party data
+func (opts *BidRequestRTBOptions) inventoryExt(ext []byte) []byte {
+	if len(opts.PublisherData) > 0 {
+		ext = adresponse.ExtSet(ext, "data", opts.PublisherData)
+	}
+	return ext
+}
+
+// impID returns the OpenRTB impression ID of the impression format
+func (opts *BidRequestRTBOptions) impID(imp *adtype.Impression, format *types.Format) string {
+	return adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec).Encode(imp, format)
 }
 
 func (opts *BidRequestRTBOptions) openNativeVer() string {
 	return opts.OpenNative.Ver
 }
 
+// impBidFloor returns the bid floor of the impression format converted into the request currency
+func (opts *BidRequestRTBOptions) impBidFloor(imp *adtype.Impression, format *types.Format) float64 {
+	floor := max(imp.BidFloorCPM.Float64(), opts.formatBidFloor(format))
+	if opts.CurrencyRate > 0 {
+		floor *= opts.CurrencyRate
+	}
+	return floor
+}
+
+// formatBidFloor returns the minimal bid floor of the format type or the default bid floor
+func (opts *BidRequestRTBOptions) formatBidFloor(format *types.Format) float64 {
+	if format != nil && len(opts.FormatBidFloor) > 0 {
+		for _, formatType := range format.Types.Types() {
+			if floor, ok := opts.FormatBidFloor[formatType]; ok {
+				return floor
+			}
+		}
+	}
+	return opts.BidFloor
+}
+
+// dealFloor returns the deal floor in the request currency
+func (opts *BidRequestRTBOptions) dealFloor(floor float64) float64 {
+	if opts.CurrencyRate > 0 {
+		floor *= opts.CurrencyRate
+	}
+	return floor
+}
+
+// bidFloorCurrency returns the currency of the bid floor, it's the first currency of the request
+// as the floors are converted into it
+func (opts *BidRequestRTBOptions) bidFloorCurrency() string {
+	return opts.currencies()[0]
+}
+
 func (opts *BidRequestRTBOptions) currencies() []string {
-	if len(opts.Currency) > 0 {
-		return opts.Currency
+	currencies := opts.Currency
+	if len(currencies) == 0 {
+		currencies = []string{SystemCurrency}
+	}
+	if len(opts.AcceptedCurrencies) == 0 {
+		return currencies
 	}
-	return []string{"USD"}
+	currencies = slices.Clone(currencies)
+	for _, cur := range opts.AcceptedCurrencies {
+		if !slices.Contains(currencies, cur) {
+			currencies = append(currencies, cur)
+		}
+	}
+	return currencies
+}
+
+// userExt returns the extension of the user object
+func (opts *BidRequestRTBOptions) userExt() []byte {
+	var ext []byte
+	if opts.UserFrequency != nil {
+		ext = adresponse.ExtSet(ext, "frequency", opts.UserFrequency)
+	}
+	if opts.GDPRConsent != "" {
+		ext = adresponse.ExtSet(ext, "consent", opts.GDPRConsent)
+	}
+	return ext
 }
 
 // BidRequestRTBOption set function
 type BidRequestRTBOption func(opts *BidRequestRTBOptions)
 
+// WithProtocolVersion of the OpenRTB request ("2.5", "2.6", "3.0")
+func WithProtocolVersion(ver string) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.ProtocolVersion = ver
+	}
+}
+
 // WithRTBOpenNativeVersion set version
 func WithRTBOpenNativeVersion(ver string) BidRequestRTBOption {
 	return func(opts *BidRequestRTBOptions) {
@@ -66,3 +372,241 @@ func WithBidFloor(bidFloor float64) BidRequestRTBOption {
 		opts.BidFloor = max(bidFloor, 0)
 	}
 }
+
+// WithUserFrequency set user frequency data
+func WithUserFrequency(freq *UserFrequency) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.UserFrequency = freq
+	}
+}
+
+// WithUserSegments set external audience segments of the user
+func WithUserSegments(segments []AudienceSegments) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.UserSegments = segments
+	}
+}
+
+// WithPageContext set contextual data of the site page
+func WithPageContext(pageContext *PageContext) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.PageContext = pageContext
+	}
+}
+
+// WithKeywordsFormat set the format of the keywords
+func WithKeywordsFormat(format KeywordsFormat) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.KeywordsFormat = format
+	}
+}
+
+// WithCategoryTaxonomy set the category taxonomy of the request categories
+func WithCategoryTaxonomy(taxonomy int) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.CategoryTaxonomy = taxonomy
+	}
+}
+
+// WithBlockedAttributes set the resolver of the blocked creative attributes per impression
+func WithBlockedAttributes(fn func(imp *adtype.Impression) *BlockedAttributes) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.BlockedAttributes = fn
+	}
+}
+
+// WithBlockedAdvertisers set the resolver of the blocked advertisers per impression
+func WithBlockedAdvertisers(fn func(imp *adtype.Impression) *BlockedAdvertisers) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.BlockedAdvertisers = fn
+	}
+}
+
+// WithClickBrowser set the resolver of the click browser type per impression
+func WithClickBrowser(fn func(imp *adtype.Impression) ClickBrowser) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.ClickBrowser = fn
+	}
+}
+
+// WithGDPR set the explicit GDPR applicability flag of the request
+func WithGDPR(applies bool) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.GDPR = &applies
+	}
+}
+
+// WithGDPRConsent set the TCF consent string of the user
+func WithGDPRConsent(consent string) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.GDPRConsent = consent
+	}
+}
+
+// WithGPP set the GPP string and the applicable section IDs of the request
+func WithGPP(gpp string, sids ...int) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.GPP = gpp
+		opts.GPPSID = sids
+	}
+}
+
+// WithUTCOffset set the offset in minutes from UTC of the user time zone
+func WithUTCOffset(offset int) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.UTCOffset = &offset
+	}
+}
+
+// WithGeoFetch set the availability of the geolocation API to the JavaScript code of the banner
+func WithGeoFetch(enabled bool) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.GeoFetch = &enabled
+	}
+}
+
+// WithGDPRGeoDetection enables detection of the GDPR applicability by the user country
+// with the default value for the requests with unknown country
+func WithGDPRGeoDetection(defaultApplies bool) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.GDPRGeoDetection = true
+		opts.GDPRDefault = defaultApplies
+	}
+}
+
+// WithCurrency set the request currency with the exchange rate
+// from the system currency which is applied to the bid floors
+func WithCurrency(currency string, rate float64) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.Currency = []string{currency}
+		opts.CurrencyRate = rate
+	}
+}
+
+// WithAcceptedCurrencies set the currencies of the bids accepted in addition to the request currency,
+// the bid floors are sent in the request currency only
+func WithAcceptedCurrencies(currencies ...string) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.AcceptedCurrencies = currencies
+	}
+}
+
+// WithPlacementData set the resolver of the placement first-party data
+func WithPlacementData(fn func(imp *adtype.Impression) map[string]any) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.PlacementData = fn
+	}
+}
+
+// WithPublisher set the publisher of the site or application
+func WithPublisher(pub *Publisher) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.Publisher = pub
+	}
+}
+
+// WithPublisherData set the publisher first-party data of the site or application
+func WithPublisherData(data map[string]any) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.PublisherData = data
+	}
+}
+
+// WithImpIDCodec set the codec of the impression IDs
+func WithImpIDCodec(codec adresponse.ImpIDCodec) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.ImpIDCodec = codec
+	}
+}
+
+// WithRewarded set the detector of the rewarded placements
+func WithRewarded(fn func(imp *adtype.Impression) bool) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.Rewarded = fn
+	}
+}
+
+// WithSKAdN set the SKAdNetwork parameters provider of the application placements
+func WithSKAdN(fn func(imp *adtype.Impression) *SKAdNRequest) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.SKAdN = fn
+	}
+}
+
+// WithMRAID set the provider of the MRAID API frameworks supported by the placements
+func WithMRAID(fn func(imp *adtype.Impression) []int) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.MRAID = fn
+	}
+}
+
+// WithPMP set the provider of the private marketplace deals of the placements
+func WithPMP(fn func(imp *adtype.Impression) *adresponse.PMP) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.PMP = fn
+	}
+}
+
+// WithFormatBidFloor set the minimal bid values per format type overriding the default bid floor
+func WithFormatBidFloor(floors map[types.FormatType]float64) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.FormatBidFloor = floors
+	}
+}
+
+// WithVideo set the provider of the video parameters of the placements
+func WithVideo(fn func(imp *adtype.Impression) *VideoPlacement) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.Video = fn
+	}
+}
+
+// WithCorrelationKey set the key of the impression correlation tokens echoed by the partner
+func WithCorrelationKey(key []byte) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.CorrelationKey = key
+	}
+}
+
+// WithImpFilter set the filter of the impressions included into the request
+func WithImpFilter(fn func(imp *adtype.Impression) bool) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.ImpFilter = fn
+	}
+}
+
+// WithLanguages set the provider of the creative languages allowed by the placements
+func WithLanguages(fn func(imp *adtype.Impression) []string) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.Languages = fn
+	}
+}
+
+// WithContentLanguage set the language of the page or application content
+func WithContentLanguage(lang string) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.ContentLanguage = lang
+	}
+}
+
+// WithSeats set the allowed and blocked buyer seats of the request
+func WithSeats(allowed, blocked []string) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.AllowedSeats = allowed
+		opts.BlockedSeats = blocked
+	}
+}
+
+// WithURLNormalization set the normalization of the site page and referrer URLs
+func WithURLNormalization(normalization *URLNormalization) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.URLNormalization = normalization
+	}
+}
+
+// WithEmptyDevicePolicy set the policy of the requests without the IP address or the user agent
+func WithEmptyDevicePolicy(policy *EmptyDevicePolicy) BidRequestRTBOption {
+	return func(opts *BidRequestRTBOptions) {
+		opts.EmptyDevicePolicy = policy
+	}
+}
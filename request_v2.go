@@ -2,28 +2,31 @@ package adsourceopenrtb
 
 import (
 	"encoding/json"
+	"slices"
+	"strconv"
 
 	"github.com/bsm/openrtb"
 	openrtbnreq "github.com/bsm/openrtb/native/request"
-	"github.com/demdxx/gocast/v2"
 	uopenrtb "github.com/geniusrabbit/udetect/openrtb"
 
 	"github.com/geniusrabbit/adcorelib/admodels/types"
 	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
 )
 
-func requestToRTBv2(req adtype.BidRequester, opts ...BidRequestRTBOption) *openrtb.BidRequest {
-	var opt BidRequestRTBOptions
-	for _, fn := range opts {
-		fn(&opt)
-	}
-	return &openrtb.BidRequest{
+// BuildRequestV2 builds the OpenRTB 2.x request from the bid request.
+// The OpenRTB 2.6 fields which are not present in the request structure
+// are added by EncodeRequestV2 only.
+func BuildRequestV2(req adtype.BidRequester, opts ...BidRequestRTBOption) *openrtb.BidRequest {
+	opt := newBidRequestRTBOptions(opts...)
+	rtbReq := &openrtb.BidRequest{
 		ID:          req.ID(),
-		Imp:         openrtbV2Impressions(req, &opt),
-		Site:        uopenrtb.SiteFrom(req.SiteInfo()),
+		Imp:         openrtbV2Impressions(req, opt),
+		Site:        openrtbV2Site(req, opt),
 		App:         uopenrtb.ApplicationFrom(req.AppInfo()),
 		Device:      uopenrtb.DeviceFrom(req.DeviceInfo(), req.UserInfo().Geo),
-		User:        uopenrtbOpenrtbV2UserInfo(req.UserInfo()),
+		User:        uopenrtbOpenrtbV2UserInfo(req.UserInfo(), opt),
 		AuctionType: int(opt.AuctionType),            // 1 = First Price, 2 = Second Price Plus
 		TMax:        int(opt.TimeMax.Milliseconds()), // Maximum amount of time in milliseconds to submit a bid
 		WSeat:       nil,                             // Array of buyer seats allowed to bid on this auction
@@ -31,14 +34,203 @@ func requestToRTBv2(req adtype.BidRequester, opts ...BidRequestRTBOption) *openr
 		Cur:         opt.currencies(),                // Array of allowed currencies
 		Bcat:        nil,                             // Blocked Advertiser Categories
 		BAdv:        nil,                             // Array of strings of blocked toplevel domains of advertisers
+		BApp:        nil,                             // Block list of applications by their bundles
 		Regs:        nil,
 		Ext:         nil,
 	}
+	openrtbV2Geo(rtbReq, opt)
+	openrtbV2Languages(req, rtbReq, opt)
+	rtbReq.WSeat, rtbReq.BSeat = opt.requestSeats()
+	if blocked := opt.blockedAdvertisers(req); blocked != nil {
+		rtbReq.Bcat, rtbReq.BAdv, rtbReq.BApp = blocked.Categories, blocked.Domains, blocked.Apps
+	}
+	rtbReq.Regs = openrtbV2Regs(rtbReq, opt)
+	openrtbV2EmptyDevice(req, rtbReq, opt.EmptyDevicePolicy)
+	if rtbReq.Site != nil {
+		rtbReq.Site.Ext = opt.inventoryExt(rtbReq.Site.Ext)
+		rtbReq.Site.Publisher = openrtbV2Publisher(rtbReq.Site.Publisher, opt.Publisher)
+	}
+	if rtbReq.App != nil {
+		rtbReq.App.Ext = opt.inventoryExt(rtbReq.App.Ext)
+		rtbReq.App.Publisher = openrtbV2Publisher(rtbReq.App.Publisher, opt.Publisher)
+	}
+	openrtbV2Interstitials(rtbReq)
+	return rtbReq
+}
+
+// openrtbV2Downgrade removes the invalid impressions and the site of the request
+// which has both the site and the application, so the remaining valid request can be sent.
+// It returns the removed objects, the request is not changed if it's valid.
+func openrtbV2Downgrade(rtbReq *openrtb.BidRequest) (drops []RequestDrop) {
+	if rtbReq.Validate() == nil {
+		return nil
+	}
+	if rtbReq.Site != nil && rtbReq.App != nil {
+		drops = append(drops, RequestDrop{Object: "site", ID: rtbReq.Site.ID, Reason: openrtb.ErrInvalidReqMultiInv.Error()})
+		rtbReq.Site = nil
+	}
+	rtbReq.Imp = slices.DeleteFunc(rtbReq.Imp, func(imp openrtb.Impression) bool {
+		if err := imp.Validate(); err != nil {
+			drops = append(drops, RequestDrop{Object: "imp", ID: imp.ID, Reason: err.Error()})
+			return true
+		}
+		return false
+	})
+	return drops
+}
+
+// openrtbV2Languages sets the ISO-639-1 languages of the device and the content
+// and the creative languages allowed by the placements
+func openrtbV2Languages(req adtype.BidRequester, rtbReq *openrtb.BidRequest, opts *BidRequestRTBOptions) {
+	if rtbReq.Device != nil {
+		rtbReq.Device.Language = normalizeLanguage(rtbReq.Device.Language)
+	}
+	if opts.ContentLanguage != "" {
+		var inventory *openrtb.Inventory
+		if rtbReq.Site != nil {
+			inventory = &rtbReq.Site.Inventory
+		} else if rtbReq.App != nil {
+			inventory = &rtbReq.App.Inventory
+		}
+		if inventory != nil {
+			if inventory.Content == nil {
+				inventory.Content = &openrtb.Content{}
+			}
+			inventory.Content.Language = opts.ContentLanguage
+		}
+	}
+	rtbReq.WLang = opts.creativeLanguages(req)
+}
+
+// openrtbV2Geo sets the UTC offset of the geo objects detected without the time zone
+// and the geolocation API availability of the device
+func openrtbV2Geo(rtbReq *openrtb.BidRequest, opts *BidRequestRTBOptions) {
+	if opts.UTCOffset != nil {
+		if rtbReq.Device != nil && rtbReq.Device.Geo != nil && rtbReq.Device.Geo.UTCOffset == 0 {
+			rtbReq.Device.Geo.UTCOffset = *opts.UTCOffset
+		}
+		if rtbReq.User != nil && rtbReq.User.Geo != nil && rtbReq.User.Geo.UTCOffset == 0 {
+			rtbReq.User.Geo.UTCOffset = *opts.UTCOffset
+		}
+	}
+	if opts.GeoFetch != nil && rtbReq.Device != nil {
+		rtbReq.Device.GeoFetch = b2i(*opts.GeoFetch)
+	}
+}
+
+// openrtbV2Publisher returns the publisher object filled by the publisher of the request
+func openrtbV2Publisher(target *openrtb.Publisher, pub *Publisher) *openrtb.Publisher {
+	if pub == nil {
+		return target
+	}
+	if target == nil {
+		target = &openrtb.Publisher{}
+	}
+	target.ID = pub.ID
+	if pub.Name != "" {
+		target.Name = pub.Name
+	}
+	if pub.Domain != "" {
+		target.Domain = pub.Domain
+	}
+	return target
+}
+
+// openrtbV2EmptyDevice applies the empty device policy to the device of the request
+func openrtbV2EmptyDevice(req adtype.BidRequester, rtbReq *openrtb.BidRequest, policy *EmptyDevicePolicy) {
+	if rtbReq.Device == nil {
+		return
+	}
+	if policy.omitDevice(req) {
+		rtbReq.Device = nil
+		return
+	}
+	if policy.omitIP(req) {
+		rtbReq.Device.IP = ""
+	}
+}
+
+// openrtbV2Interstitials sets the full-screen sizes and position of the interstitial banners
+func openrtbV2Interstitials(rtbReq *openrtb.BidRequest) {
+	var (
+		deviceW, deviceH int
+		pxRatio          float64
+	)
+	if rtbReq.Device != nil {
+		deviceW, deviceH, pxRatio = rtbReq.Device.W, rtbReq.Device.H, rtbReq.Device.PxRatio
+	}
+	for i := range rtbReq.Imp {
+		imp := &rtbReq.Imp[i]
+		if imp.Instl != 1 || imp.Banner == nil {
+			continue
+		}
+		for _, size := range interstitialSizes(deviceW, deviceH, pxRatio) {
+			imp.Banner.Format = append(imp.Banner.Format, openrtb.Format{W: size[0], H: size[1]})
+		}
+	}
+}
+
+// EncodeRequestV2 builds and encodes the OpenRTB 2.x request into JSON
+// including the OpenRTB 2.6 fields according to the protocol version option
+func EncodeRequestV2(req adtype.BidRequester, opts ...BidRequestRTBOption) ([]byte, error) {
+	rtbReq := BuildRequestV2(req, opts...)
+	fields := openrtbV26Extend(req, rtbReq, newBidRequestRTBOptions(opts...))
+	if err := rtbReq.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(rtbReq)
+	if err != nil {
+		return nil, err
+	}
+	return fields.Apply(data)
+}
+
+func openrtbV2Regs(rtbReq *openrtb.BidRequest, opts *BidRequestRTBOptions) *openrtb.Regulations {
+	var deviceCountry, userCountry string
+	if rtbReq.Device != nil && rtbReq.Device.Geo != nil {
+		deviceCountry = rtbReq.Device.Geo.Country
+	}
+	if rtbReq.User != nil && rtbReq.User.Geo != nil {
+		userCountry = rtbReq.User.Geo.Country
+	}
+	ext := opts.regsExt(userCountry, deviceCountry)
+	if ext == nil {
+		return nil
+	}
+	return &openrtb.Regulations{Ext: openrtb.Extension(ext)}
+}
+
+func openrtbV2Site(req adtype.BidRequester, opts *BidRequestRTBOptions) *openrtb.Site {
+	site := uopenrtb.SiteFrom(req.SiteInfo())
+	if site == nil {
+		return nil
+	}
+	if opts.URLNormalization != nil {
+		site.Page = opts.URLNormalization.Normalize(site.Page)
+		site.Ref = opts.URLNormalization.Normalize(site.Ref)
+	}
+	if pageCtx := opts.PageContext; pageCtx != nil {
+		site.Keywords = mergeKeywords(site.Keywords, pageCtx.Keywords)
+		site.Cat = mergeCategories(site.Cat, pageCtx.Categories)
+		if len(pageCtx.Keywords) > 0 {
+			if site.Content == nil {
+				site.Content = &openrtb.Content{}
+			}
+			site.Content.Keywords = mergeKeywords(site.Content.Keywords, pageCtx.Keywords)
+		}
+	}
+	return site
 }
 
 func openrtbV2Impressions(req adtype.BidRequester, opts *BidRequestRTBOptions) (list []openrtb.Impression) {
 	for _, imp := range req.Impressions() {
+		if !opts.impAllowed(imp) {
+			continue
+		}
 		for _, format := range imp.Formats() {
+			if !opts.formatAllowed(format) {
+				continue
+			}
 			if openRTBImp := openrtbV2ImpressionByFormat(req, imp, format, opts); openRTBImp != nil {
 				list = append(list, *openRTBImp)
 			}
@@ -53,6 +245,7 @@ func openrtbV2ImpressionByFormat(req adtype.BidRequester, imp *adtype.Impression
 		video  *openrtb.Video
 		native *openrtb.Native
 		ext    openrtb.Extension
+		battr  = opts.blockedAttributes(imp)
 	)
 
 	switch {
@@ -65,6 +258,7 @@ func openrtbV2ImpressionByFormat(req adtype.BidRequester, imp *adtype.Impression
 		if !format.IsStretch() {
 			wm, wh = 0, 0
 		}
+		mraid := opts.mraidFrameworks(imp)
 		banner = &openrtb.Banner{
 			ID:       "",
 			W:        max(w, 5),
@@ -73,13 +267,13 @@ func openrtbV2ImpressionByFormat(req adtype.BidRequester, imp *adtype.Impression
 			HMax:     wh,
 			WMin:     0,
 			HMin:     0,
-			Pos:      imp.Pos,
-			BType:    gocast.IfThen(format.IsProxy(), []int{1, 2}, []int{3, 4}), // Blocked creative types
-			BAttr:    nil,
+			Pos:      impPosition(imp),
+			BType:    bannerBlockedTypes(format, len(mraid) > 0), // Blocked creative types
+			BAttr:    battr.banner(),
 			Mimes:    nil,
 			TopFrame: 0,
 			ExpDir:   nil,
-			Api:      nil,
+			Api:      mraid,
 			Ext:      nil,
 		}
 	case format.IsNative():
@@ -87,7 +281,7 @@ func openrtbV2ImpressionByFormat(req adtype.BidRequester, imp *adtype.Impression
 			Request: openrtbV2NativeRequest(req, imp, format, opts),
 			Ver:     opts.openNativeVer(),
 			API:     nil,
-			BAttr:   nil,
+			BAttr:   battr.native(),
 			Ext:     nil,
 		}
 	case format.IsDirect():
@@ -95,45 +289,69 @@ func openrtbV2ImpressionByFormat(req adtype.BidRequester, imp *adtype.Impression
 			ext = openrtb.Extension(`{"type":"pop"}`)
 		}
 	case format.IsVideo():
-		video = &openrtb.Video{
-			Mimes:         []string{"video/mp4", "video/webm"},
-			MinDuration:   0,
-			MaxDuration:   0,
-			Protocols:     nil,
-			W:             imp.Width,
-			H:             imp.Height,
-			Pos:           imp.Pos,
-			StartDelay:    0,
-			Linearity:     0,
-			Skip:          1,
-			SkipMin:       0,
-			SkipAfter:     3,
-			BAttr:         nil,
-			BoxingAllowed: &[]int{1}[0],
-			MaxExtended:   0,
-			Ext:           nil,
-		}
+		video = openrtbV2Video(imp, format, battr, opts)
 	default:
 		return nil
 	}
 
 	// tagid := imp.Target.Codename() + "_" + format.Codename
 	return &openrtb.Impression{
-		ID:                imp.IDByFormat(format),
+		ID:                opts.impID(imp, format),
 		Banner:            banner,
 		Video:             video,
 		Native:            native,
-		DisplayManager:    "",                                            // Name of ad mediation partner, SDK technology, etc
-		DisplayManagerVer: "",                                            // Version of the above
-		Instl:             imp.Interstitial,                              // Interstitial, Default: 0 ("1": Interstitial, "0": Something else)
-		TagID:             imp.Target.Codename(),                         // IDentifier for specific ad placement or ad tag
-		BidFloor:          max(imp.BidFloorCPM.Float64(), opts.BidFloor), // Bid floor for this impression in CPM
-		BidFloorCurrency:  "",                                            // Currency of bid floor
-		Secure:            openrtb.NumberOrString(b2i(req.IsSecure())),   // Flag to indicate whether the impression requires secure HTTPS URL creative assets and markup.
-		IFrameBuster:      nil,                                           // Array of names for supportediframe busters.
-		Pmp:               nil,                                           // A reference to the PMP object containing any Deals eligible for the impression object.
-		Ext:               ext,
+		DisplayManager:    "",                                          // Name of ad mediation partner, SDK technology, etc
+		DisplayManagerVer: "",                                          // Version of the above
+		Instl:             imp.Interstitial,                            // Interstitial, Default: 0 ("1": Interstitial, "0": Something else)
+		TagID:             imp.Target.Codename(),                       // IDentifier for specific ad placement or ad tag
+		BidFloor:          opts.impBidFloor(imp, format),               // Bid floor for this impression in CPM
+		BidFloorCurrency:  opts.bidFloorCurrency(),                     // Currency of bid floor
+		Secure:            openrtb.NumberOrString(b2i(req.IsSecure())), // Flag to indicate whether the impression requires secure HTTPS URL creative assets and markup.
+		IFrameBuster:      nil,                                         // Array of names for supportediframe busters.
+		Pmp:               openrtbV2PMP(opts.pmp(imp), opts),           // A reference to the PMP object containing any Deals eligible for the impression object.
+		Ext:               openrtb.Extension(opts.impExt(req, imp, ext)),
+	}
+}
+
+// openrtbV2Video returns the video object of the impression
+func openrtbV2Video(imp *adtype.Impression, format *types.Format, battr *BlockedAttributes, opts *BidRequestRTBOptions) *openrtb.Video {
+	placement := opts.videoPlacement(imp, format)
+	w, h := imp.Width, imp.Height
+	if w < 1 && h < 1 {
+		w, h = format.Width, format.Height
+	}
+	video := &openrtb.Video{
+		Mimes:          placement.Mimes,
+		MinDuration:    placement.MinDuration,
+		MaxDuration:    placement.MaxDuration,
+		Protocols:      placement.Protocols,
+		W:              w,
+		H:              h,
+		Pos:            impPosition(imp),
+		StartDelay:     placement.StartDelay,
+		Linearity:      placement.Linearity,
+		Skip:           b2i(placement.Skippable),
+		BAttr:          battr.video(),
+		MinBitrate:     placement.MinBitrate,
+		MaxBitrate:     placement.MaxBitrate,
+		BoxingAllowed:  &[]int{1}[0],
+		PlaybackMethod: placement.PlaybackMethods,
+		Delivery:       placement.Delivery,
+		Api:            placement.API,
+		CompanionType:  placement.CompanionTypes,
+		Placement:      placement.Placement,
+	}
+	for i, companion := range placement.Companions {
+		video.CompanionAd = append(video.CompanionAd, openrtb.Banner{
+			ID: strconv.Itoa(i + 1),
+			W:  companion.W,
+			H:  companion.H,
+		})
 	}
+	if placement.Skippable {
+		video.SkipMin, video.SkipAfter = placement.SkipMin, placement.SkipAfter
+	}
+	return video
 }
 
 func openrtbV2NativeRequest(req adtype.BidRequester, imp *adtype.Impression, format *types.Format, opts *BidRequestRTBOptions) openrtb.Extension {
@@ -284,8 +502,8 @@ func openrtbV2NativeFieldAsset(field *types.FormatField) (openrtbnreq.Asset, boo
 	return openrtbnreq.Asset{}, false
 }
 
-func uopenrtbOpenrtbV2UserInfo(u *adtype.User) *openrtb.User {
-	data := make([]openrtb.Data, 0, len(u.Data))
+func uopenrtbOpenrtbV2UserInfo(u *adtype.User, opts *BidRequestRTBOptions) *openrtb.User {
+	data := make([]openrtb.Data, 0, len(u.Data)+len(opts.UserSegments))
 	for _, it := range u.Data {
 		dataItem := openrtb.Data{Name: it.Name}
 		for i := range it.Segment {
@@ -296,6 +514,20 @@ func uopenrtbOpenrtbV2UserInfo(u *adtype.User) *openrtb.User {
 		}
 		data = append(data, dataItem)
 	}
+	for _, it := range opts.UserSegments {
+		dataItem := openrtb.Data{Name: it.Provider}
+		if it.Taxonomy > 0 {
+			dataItem.Ext = adresponse.ExtSet[openrtb.Extension](nil, "segtax", it.Taxonomy)
+		}
+		for _, seg := range it.Segments {
+			dataItem.Segment = append(dataItem.Segment, openrtb.Segment{
+				ID:    seg.ID,
+				Name:  seg.Name,
+				Value: seg.Value,
+			})
+		}
+		data = append(data, dataItem)
+	}
 
 	return &openrtb.User{
 		ID:         u.ID,       // Unique consumer ID of this user on the exchange
@@ -307,6 +539,24 @@ func uopenrtbOpenrtbV2UserInfo(u *adtype.User) *openrtb.User {
 		CustomData: "",         // Optional feature to pass bidder data that was set in the exchange's cookie. The string must be in base85 cookie safe characters and be in any format. Proper JSON encoding must be used to include "escaped" quotation marks.
 		Geo:        uopenrtb.GeoFrom(u.Geo),
 		Data:       data,
-		Ext:        nil,
+		Ext:        openrtb.Extension(opts.userExt()),
+	}
+}
+
+func openrtbV2PMP(pmp *adresponse.PMP, opts *BidRequestRTBOptions) *openrtb.Pmp {
+	if pmp == nil {
+		return nil
+	}
+	deals := make([]openrtb.Deal, 0, len(pmp.Deals))
+	for _, deal := range pmp.Deals {
+		deals = append(deals, openrtb.Deal{
+			ID:               deal.ID,
+			BidFloor:         opts.dealFloor(deal.BidFloor),
+			BidFloorCurrency: opts.bidFloorCurrency(),
+			WSeat:            deal.Seats,
+			WAdvDomain:       deal.ADomains,
+			AuctionType:      deal.AuctionType,
+		})
 	}
+	return &openrtb.Pmp{Private: b2i(pmp.Private), Deals: deals}
 }
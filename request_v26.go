@@ -0,0 +1,94 @@
+package adsourceopenrtb
+
+import (
+	"strconv"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// openrtbV26Extend adjusts the OpenRTB 2.x request according to the protocol version
+// and the source dialect and returns the OpenRTB 2.6 fields which are not present
+// in the base request structures and have to be merged into the encoded request
+func openrtbV26Extend(req adtype.BidRequester, rtbReq *openrtb.BidRequest, opts *BidRequestRTBOptions) jsonFields {
+	fields := jsonFields{}
+
+	// Keywords as arrays (kwarray) and/or comma separated string (keywords)
+	if opts.keywordsArray() {
+		if rtbReq.Site != nil {
+			setKeywordsArray(fields, "site.kwarray", &rtbReq.Site.Keywords, opts)
+		}
+		if rtbReq.App != nil {
+			setKeywordsArray(fields, "app.kwarray", &rtbReq.App.Keywords, opts)
+		}
+		if rtbReq.User != nil {
+			setKeywordsArray(fields, "user.kwarray", &rtbReq.User.Keywords, opts)
+		}
+	}
+
+	// Category taxonomy of the request, site and application categories
+	if opts.CategoryTaxonomy > 0 && opts.versionAtLeast(ProtocolVersion26) {
+		fields.Set("cattax", opts.CategoryTaxonomy)
+		if rtbReq.Site != nil && len(rtbReq.Site.Cat)+len(rtbReq.Site.SectionCat)+len(rtbReq.Site.PageCat) > 0 {
+			fields.Set("site.cattax", opts.CategoryTaxonomy)
+		}
+		if rtbReq.App != nil && len(rtbReq.App.Cat)+len(rtbReq.App.SectionCat)+len(rtbReq.App.PageCat) > 0 {
+			fields.Set("app.cattax", opts.CategoryTaxonomy)
+		}
+	}
+
+	// Impression level fields: click browser type of the in-app impressions
+	// (the impression extension before OpenRTB 2.6) and rewarded flag
+	if (rtbReq.App != nil && opts.ClickBrowser != nil) || (opts.Rewarded != nil && opts.rewardedField()) {
+		codec := adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+		for i := range rtbReq.Imp {
+			imp, _ := codec.Decode(req, rtbReq.Imp[i].ID)
+			if rtbReq.App != nil {
+				if browser := opts.clickBrowser(imp); browser != ClickBrowserUndefined {
+					field := ".clickbrowser"
+					if !opts.versionAtLeast(ProtocolVersion26) {
+						field = ".ext.clickbrowser"
+					}
+					fields.Set("imp."+strconv.Itoa(i)+field, browser.Value())
+				}
+			}
+			if opts.rewardedField() && opts.rewarded(imp) {
+				fields.Set("imp."+strconv.Itoa(i)+".rwdd", 1)
+			}
+		}
+	}
+
+	// Video placement subtype of the OpenRTB 2.6 (the placement type is deprecated)
+	if opts.Video != nil && opts.versionAtLeast(ProtocolVersion26) {
+		codec := adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+		for i := range rtbReq.Imp {
+			if rtbReq.Imp[i].Video == nil {
+				continue
+			}
+			imp, format := codec.Decode(req, rtbReq.Imp[i].ID)
+			if imp == nil || format == nil {
+				continue
+			}
+			if placement := opts.videoPlacement(imp, format); placement.Plcmt > 0 {
+				fields.Set("imp."+strconv.Itoa(i)+".video.plcmt", placement.Plcmt)
+			}
+		}
+	}
+
+	// Global Privacy Platform consent of the regulations
+	opts.gppFields(fields)
+
+	return fields
+}
+
+func setKeywordsArray(fields jsonFields, path string, keywords *string, opts *BidRequestRTBOptions) {
+	if list := splitKeywords(*keywords); len(list) > 0 {
+		fields.Set(path, list)
+		if !opts.keywordsString() {
+			*keywords = ""
+		}
+	}
+}
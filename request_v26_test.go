@@ -0,0 +1,119 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/udetect"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// testRequestImp returns the first impression of the encoded JSON request
+func testRequestImp(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+	var request struct {
+		Imp []map[string]any `json:"imp"`
+	}
+	if !assert.NoError(t, json.Unmarshal(data, &request)) || !assert.NotEmpty(t, request.Imp) {
+		return map[string]any{}
+	}
+	return request.Imp[0]
+}
+
+func TestClickBrowserVersion(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.App = &udetect.App{Bundle: "com.example"}
+	clickBrowser := WithClickBrowser(func(imp *adtype.Impression) ClickBrowser { return ClickBrowserNative })
+
+	// The OpenRTB 2.5 requests send the click browser type in the impression extension
+	data, err := EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion25), clickBrowser)
+	if assert.NoError(t, err) {
+		imp := testRequestImp(t, data)
+		assert.NotContains(t, imp, "clickbrowser")
+		assert.Equal(t, map[string]any{"clickbrowser": 1.}, imp["ext"])
+	}
+
+	data, err = EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion26), clickBrowser)
+	if assert.NoError(t, err) {
+		imp := testRequestImp(t, data)
+		assert.Equal(t, 1., imp["clickbrowser"])
+		assert.NotContains(t, imp, "ext")
+	}
+
+	// The site requests don't have the click browser type
+	request.App = nil
+	data, err = EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion26), clickBrowser)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, testRequestImp(t, data), "clickbrowser")
+	}
+}
+
+func TestRewardedVersion(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	rewarded := WithRewarded(func(imp *adtype.Impression) bool { return true })
+
+	data, err := EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion25), rewarded)
+	if assert.NoError(t, err) {
+		imp := testRequestImp(t, data)
+		assert.NotContains(t, imp, "rwdd")
+		assert.Equal(t, map[string]any{"rewarded": 1.}, imp["ext"])
+	}
+
+	data, err = EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion26), rewarded)
+	if assert.NoError(t, err) {
+		imp := testRequestImp(t, data)
+		assert.Equal(t, 1., imp["rwdd"])
+		assert.NotContains(t, imp, "ext")
+	}
+
+	// The OpenRTB 3.0 requests are built without the OpenRTB 2.6 fields
+	rtbRequest := BuildRequestV3(request, WithProtocolVersion(ProtocolVersion30), rewarded)
+	if assert.Len(t, rtbRequest.Impressions, 1) {
+		assert.JSONEq(t, `{"rewarded":1}`, string(rtbRequest.Impressions[0].Ext))
+	}
+}
+
+func TestCategoryTaxonomyVersion(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.Site = &udetect.Site{Domain: "example.com", Cat: []string{"483"}}
+	taxonomy := WithCategoryTaxonomy(adresponse.CategoryTaxonomyIABContent2)
+
+	data, err := EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion25), taxonomy)
+	if assert.NoError(t, err) {
+		var rtbRequest map[string]any
+		assert.NoError(t, json.Unmarshal(data, &rtbRequest))
+		assert.NotContains(t, rtbRequest, "cattax")
+		assert.NotContains(t, rtbRequest["site"], "cattax")
+	}
+
+	data, err = EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion26), taxonomy)
+	if assert.NoError(t, err) {
+		var rtbRequest map[string]any
+		assert.NoError(t, json.Unmarshal(data, &rtbRequest))
+		assert.Equal(t, 2., rtbRequest["cattax"])
+		assert.Equal(t, 2., rtbRequest["site"].(map[string]any)["cattax"])
+	}
+}
+
+func TestVideoPlcmtVersion(t *testing.T) {
+	request := newTestRequest(context.Background(), "video")
+	video := WithVideo(func(*adtype.Impression) *VideoPlacement { return &VideoPlacement{Placement: 1, Plcmt: 2} })
+
+	// The placement subtype is sent since OpenRTB 2.6 only
+	data, err := EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion25), video)
+	if assert.NoError(t, err) {
+		imp := testRequestImp(t, data)
+		assert.Equal(t, 1., imp["video"].(map[string]any)["placement"])
+		assert.NotContains(t, imp["video"], "plcmt")
+	}
+
+	data, err = EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion26), video)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2., testRequestImp(t, data)["video"].(map[string]any)["plcmt"])
+	}
+}
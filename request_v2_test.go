@@ -0,0 +1,60 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+func TestBuildRequestV2(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250", "native")
+	rtbRequest := BuildRequestV2(request,
+		WithMaxTimeDuration(150*time.Millisecond),
+		WithAuctionType(types.SecondPriceAuctionType),
+		WithBidFloor(0.5))
+
+	assert.Equal(t, "auction1", rtbRequest.ID)
+	assert.Equal(t, 150, rtbRequest.TMax)
+	assert.Equal(t, int(types.SecondPriceAuctionType), rtbRequest.AuctionType)
+	assert.Equal(t, []string{SystemCurrency}, rtbRequest.Cur)
+	if assert.Len(t, rtbRequest.Imp, 2) {
+		assert.Equal(t, "zone1", rtbRequest.Imp[0].TagID)
+		assert.Equal(t, 0.5, rtbRequest.Imp[0].BidFloor)
+		assert.NoError(t, rtbRequest.Validate())
+	}
+}
+
+func TestEncodeRequestV2(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	data, err := EncodeRequestV2(request, WithProtocolVersion(ProtocolVersion26),
+		WithRewarded(func(*adtype.Impression) bool { return true }))
+	if assert.NoError(t, err) {
+		// The OpenRTB 2.6 fields are merged into the encoded request
+		assert.Equal(t, 1., testRequestImp(t, data)["rwdd"])
+	}
+
+	// The request without the impressions is not encoded
+	request.Imps = nil
+	_, err = EncodeRequestV2(request)
+	assert.Error(t, err)
+}
+
+func TestBuildRequestV2FormatFilter(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250", "native")
+
+	rtbRequest := BuildRequestV2(request)
+	assert.Len(t, rtbRequest.Imp, 2)
+
+	rtbRequest = BuildRequestV2(request, WithFormatFilter(func(format *types.Format) bool {
+		return format.IsBanner()
+	}))
+	if assert.Len(t, rtbRequest.Imp, 1) {
+		assert.NotNil(t, rtbRequest.Imp[0].Banner)
+		assert.Nil(t, rtbRequest.Imp[0].Native)
+	}
+}
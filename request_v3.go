@@ -2,28 +2,29 @@ package adsourceopenrtb
 
 import (
 	"encoding/json"
+	"slices"
+	"strconv"
 
 	openrtbnreq "github.com/bsm/openrtb/native/request"
 	"github.com/bsm/openrtb/v3"
-	"github.com/demdxx/gocast/v2"
 
 	"github.com/geniusrabbit/adcorelib/admodels/types"
 	"github.com/geniusrabbit/adcorelib/adtype"
 	"github.com/geniusrabbit/udetect"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
 )
 
-func requestToRTBv3(req adtype.BidRequester, opts ...BidRequestRTBOption) *openrtb.BidRequest {
-	var opt BidRequestRTBOptions
-	for _, fn := range opts {
-		fn(&opt)
-	}
-	return &openrtb.BidRequest{
+// BuildRequestV3 builds the OpenRTB request with the `github.com/bsm/openrtb/v3` structures
+func BuildRequestV3(req adtype.BidRequester, opts ...BidRequestRTBOption) *openrtb.BidRequest {
+	opt := newBidRequestRTBOptions(opts...)
+	rtbReq := &openrtb.BidRequest{
 		ID:                req.ID(),
-		Impressions:       openrtbV3Impressions(req, &opt),
-		Site:              uopenrtbOpenrtbV3SiteFrom(req.SiteInfo()),
+		Impressions:       openrtbV3Impressions(req, opt),
+		Site:              uopenrtbOpenrtbV3SiteFrom(req.SiteInfo(), opt),
 		App:               uopenrtbOpenrtbV3ApplicationFrom(req.AppInfo()),
 		Device:            uopenrtbOpenrtbV3DeviceFrom(req.DeviceInfo(), req.UserInfo().Geo),
-		User:              uopenrtbOpenrtbV3UserInfo(req.UserInfo()),
+		User:              uopenrtbOpenrtbV3UserInfo(req.UserInfo(), opt),
 		AuctionType:       int(opt.AuctionType),            // 1 = First Price, 2 = Second Price Plus
 		TimeMax:           int(opt.TimeMax.Milliseconds()), // Maximum amount of time in milliseconds to submit a bid
 		Seats:             nil,                             // Array of buyer seats allowed to bid on this auction
@@ -31,14 +32,168 @@ func requestToRTBv3(req adtype.BidRequester, opts ...BidRequestRTBOption) *openr
 		Currencies:        opt.currencies(),                // Array of allowed currencies
 		BlockedCategories: nil,                             // Blocked Advertiser Categories
 		BlockedAdvDomains: nil,                             // Array of strings of blocked toplevel domains of advertisers
+		BlockedApps:       nil,                             // Block list of applications by their bundles
 		Regulations:       nil,
 		Ext:               nil,
 	}
+	openrtbV3Geo(rtbReq, opt)
+	openrtbV3Languages(req, rtbReq, opt)
+	rtbReq.Seats, rtbReq.BlockedSeats = opt.requestSeats()
+	if blocked := opt.blockedAdvertisers(req); blocked != nil {
+		for _, cat := range blocked.Categories {
+			rtbReq.BlockedCategories = append(rtbReq.BlockedCategories, openrtb.ContentCategory(cat))
+		}
+		rtbReq.BlockedAdvDomains, rtbReq.BlockedApps = blocked.Domains, blocked.Apps
+	}
+	rtbReq.Regulations = openrtbV3Regulations(rtbReq, opt)
+	openrtbV3EmptyDevice(req, rtbReq, opt.EmptyDevicePolicy)
+	openrtbV3Interstitials(rtbReq)
+	if rtbReq.Site != nil {
+		rtbReq.Site.Ext = opt.inventoryExt(rtbReq.Site.Ext)
+		rtbReq.Site.Publisher = openrtbV3Publisher(rtbReq.Site.Publisher, opt.Publisher)
+	}
+	if rtbReq.App != nil {
+		rtbReq.App.Ext = opt.inventoryExt(rtbReq.App.Ext)
+		rtbReq.App.Publisher = openrtbV3Publisher(rtbReq.App.Publisher, opt.Publisher)
+	}
+	return rtbReq
+}
+
+// openrtbV3Publisher returns the publisher object filled by the publisher of the request
+func openrtbV3Publisher(target *openrtb.Publisher, pub *Publisher) *openrtb.Publisher {
+	if pub == nil {
+		return target
+	}
+	if target == nil {
+		target = &openrtb.Publisher{}
+	}
+	target.ID = pub.ID
+	if pub.Name != "" {
+		target.Name = pub.Name
+	}
+	if pub.Domain != "" {
+		target.Domain = pub.Domain
+	}
+	return target
+}
+
+// openrtbV3EmptyDevice applies the empty device policy to the device of the request
+func openrtbV3EmptyDevice(req adtype.BidRequester, rtbReq *openrtb.BidRequest, policy *EmptyDevicePolicy) {
+	if rtbReq.Device == nil {
+		return
+	}
+	if policy.omitDevice(req) {
+		rtbReq.Device = nil
+		return
+	}
+	if policy.omitIP(req) {
+		rtbReq.Device.IP = ""
+	}
+}
+
+// openrtbV3Interstitials sets the full-screen sizes of the interstitial banners
+func openrtbV3Interstitials(rtbReq *openrtb.BidRequest) {
+	var (
+		deviceW, deviceH int
+		pxRatio          float64
+	)
+	if rtbReq.Device != nil {
+		deviceW, deviceH, pxRatio = rtbReq.Device.Width, rtbReq.Device.Height, rtbReq.Device.PixelRatio
+	}
+	for i := range rtbReq.Impressions {
+		imp := &rtbReq.Impressions[i]
+		if imp.Interstitial != 1 || imp.Banner == nil {
+			continue
+		}
+		for _, size := range interstitialSizes(deviceW, deviceH, pxRatio) {
+			imp.Banner.Formats = append(imp.Banner.Formats, openrtb.Format{Width: size[0], Height: size[1]})
+		}
+	}
+}
+
+// openrtbV3Downgrade removes the invalid impressions and the site of the request
+// which has both the site and the application, so the remaining valid request can be sent.
+// It returns the removed objects, the request is not changed if it's valid.
+func openrtbV3Downgrade(rtbReq *openrtb.BidRequest) (drops []RequestDrop) {
+	if rtbReq.Validate() == nil {
+		return nil
+	}
+	if rtbReq.Site != nil && rtbReq.App != nil {
+		drops = append(drops, RequestDrop{Object: "site", ID: rtbReq.Site.ID, Reason: openrtb.ErrInvalidReqMultiInv.Error()})
+		rtbReq.Site = nil
+	}
+	rtbReq.Impressions = slices.DeleteFunc(rtbReq.Impressions, func(imp openrtb.Impression) bool {
+		if err := imp.Validate(); err != nil {
+			drops = append(drops, RequestDrop{Object: "imp", ID: imp.ID, Reason: err.Error()})
+			return true
+		}
+		return false
+	})
+	return drops
+}
+
+// openrtbV3Languages sets the ISO-639-1 languages of the device and the content
+// and the creative languages allowed by the placements
+func openrtbV3Languages(req adtype.BidRequester, rtbReq *openrtb.BidRequest, opts *BidRequestRTBOptions) {
+	if rtbReq.Device != nil {
+		rtbReq.Device.Language = normalizeLanguage(rtbReq.Device.Language)
+	}
+	if opts.ContentLanguage != "" {
+		var inventory *openrtb.Inventory
+		if rtbReq.Site != nil {
+			inventory = &rtbReq.Site.Inventory
+		} else if rtbReq.App != nil {
+			inventory = &rtbReq.App.Inventory
+		}
+		if inventory != nil {
+			if inventory.Content == nil {
+				inventory.Content = &openrtb.Content{}
+			}
+			inventory.Content.Language = opts.ContentLanguage
+		}
+	}
+	rtbReq.Languages = opts.creativeLanguages(req)
+}
+
+// openrtbV3Geo sets the UTC offset of the geo objects detected without the time zone
+// and the geolocation API availability of the device
+func openrtbV3Geo(rtbReq *openrtb.BidRequest, opts *BidRequestRTBOptions) {
+	if opts.UTCOffset != nil {
+		if rtbReq.Device != nil && rtbReq.Device.Geo != nil && rtbReq.Device.Geo.UTCOffset == 0 {
+			rtbReq.Device.Geo.UTCOffset = *opts.UTCOffset
+		}
+		if rtbReq.User != nil && rtbReq.User.Geo != nil && rtbReq.User.Geo.UTCOffset == 0 {
+			rtbReq.User.Geo.UTCOffset = *opts.UTCOffset
+		}
+	}
+	if opts.GeoFetch != nil && rtbReq.Device != nil {
+		rtbReq.Device.GeoFetch = b2i(*opts.GeoFetch)
+	}
+}
+func openrtbV3Regulations(rtbReq *openrtb.BidRequest, opts *BidRequestRTBOptions) *openrtb.Regulations {
+	var deviceCountry, userCountry string
+	if rtbReq.Device != nil && rtbReq.Device.Geo != nil {
+		deviceCountry = rtbReq.Device.Geo.Country
+	}
+	if rtbReq.User != nil && rtbReq.User.Geo != nil {
+		userCountry = rtbReq.User.Geo.Country
+	}
+	ext := opts.regsExt(userCountry, deviceCountry)
+	if ext == nil {
+		return nil
+	}
+	return &openrtb.Regulations{Ext: json.RawMessage(ext)}
 }
 
 func openrtbV3Impressions(req adtype.BidRequester, opts *BidRequestRTBOptions) (list []openrtb.Impression) {
 	for _, imp := range req.Impressions() {
+		if !opts.impAllowed(imp) {
+			continue
+		}
 		for _, format := range imp.Formats() {
+			if !opts.formatAllowed(format) {
+				continue
+			}
 			if openRTBImp := openrtbV3ImpressionByFormat(req, imp, format, opts); openRTBImp != nil {
 				list = append(list, *openRTBImp)
 			}
@@ -53,6 +208,7 @@ func openrtbV3ImpressionByFormat(req adtype.BidRequester, imp *adtype.Impression
 		video  *openrtb.Video
 		native *openrtb.Native
 		ext    json.RawMessage
+		battr  = opts.blockedAttributes(imp)
 	)
 
 	switch {
@@ -65,6 +221,7 @@ func openrtbV3ImpressionByFormat(req adtype.BidRequester, imp *adtype.Impression
 		if !format.IsStretch() {
 			wm, wh = 0, 0
 		}
+		mraid := opts.mraidFrameworks(imp)
 		banner = &openrtb.Banner{
 			ID:        "",
 			Width:     max(w, 5),
@@ -73,16 +230,15 @@ func openrtbV3ImpressionByFormat(req adtype.BidRequester, imp *adtype.Impression
 			HeightMax: wh,
 			WidthMin:  0,
 			HeightMin: 0,
-			Position:  openrtb.AdPosition(imp.Pos),
-			BlockedTypes: gocast.IfThen(format.IsProxy(),
-				[]openrtb.BannerType{openrtb.BannerTypeXHTMLText, openrtb.BannerTypeXHTML},
-				[]openrtb.BannerType{openrtb.BannerTypeJS, openrtb.BannerTypeFrame},
+			Position:  openrtb.AdPosition(impPosition(imp)),
+			BlockedTypes: intsToEnum[openrtb.BannerType](
+				bannerBlockedTypes(format, len(mraid) > 0),
 			), // Blocked creative types
-			BlockedAttrs: nil,
+			BlockedAttrs: intsToEnum[openrtb.CreativeAttribute](battr.banner()),
 			MIMEs:        nil,
 			TopFrame:     0,
 			ExpDirs:      nil,
-			APIs:         nil,
+			APIs:         intsToEnum[openrtb.APIFramework](mraid),
 			Ext:          nil,
 		}
 	case format.IsNative():
@@ -90,7 +246,7 @@ func openrtbV3ImpressionByFormat(req adtype.BidRequester, imp *adtype.Impression
 			Request:      openrtbV3NativeRequest(req, imp, format, opts),
 			Version:      opts.openNativeVer(),
 			APIs:         nil,
-			BlockedAttrs: nil,
+			BlockedAttrs: intsToEnum[openrtb.CreativeAttribute](battr.native()),
 			Ext:          nil,
 		}
 	case format.IsDirect():
@@ -98,47 +254,72 @@ func openrtbV3ImpressionByFormat(req adtype.BidRequester, imp *adtype.Impression
 			ext = json.RawMessage(`{"type":"pop"}`)
 		}
 	case format.IsVideo():
-		video = &openrtb.Video{
-			MIMEs:         []string{"video/mp4", "video/webm"},
-			MinDuration:   0,
-			MaxDuration:   0,
-			Protocols:     nil,
-			Width:         imp.Width,
-			Height:        imp.Height,
-			Position:      openrtb.AdPosition(imp.Pos),
-			StartDelay:    0,
-			Linearity:     0,
-			Skip:          1,
-			SkipMin:       0,
-			SkipAfter:     3,
-			BlockedAttrs:  nil,
-			BoxingAllowed: &[]int{1}[0],
-			MaxExtended:   0,
-			Ext:           nil,
-		}
+		video = openrtbV3Video(imp, format, battr, opts)
 	default:
 		return nil
 	}
 
 	// tagid := imp.Target.Codename() + "_" + format.Codename
 	return &openrtb.Impression{
-		ID:                    imp.IDByFormat(format),
+		ID:                    opts.impID(imp, format),
 		Banner:                banner,
 		Video:                 video,
 		Native:                native,
-		DisplayManager:        "",                                            // Name of ad mediation partner, SDK technology, etc
-		DisplayManagerVersion: "",                                            // Version of the above
-		Interstitial:          imp.Interstitial,                              // Interstitial, Default: 0 ("1": Interstitial, "0": Something else)
-		TagID:                 imp.Target.Codename(),                         // IDentifier for specific ad placement or ad tag
-		BidFloor:              max(imp.BidFloorCPM.Float64(), opts.BidFloor), // Bid floor for this impression in CPM
-		BidFloorCurrency:      "",                                            // Currency of bid floor
-		Secure:                openrtb.NumberOrString(b2i(req.IsSecure())),   // Flag to indicate whether the impression requires secure HTTPS URL creative assets and markup.
-		IFrameBusters:         nil,                                           // Array of names for supportediframe busters.
-		PMP:                   nil,                                           // A reference to the PMP object containing any Deals eligible for the impression object.
-		Ext:                   ext,
+		DisplayManager:        "",                                          // Name of ad mediation partner, SDK technology, etc
+		DisplayManagerVersion: "",                                          // Version of the above
+		Interstitial:          imp.Interstitial,                            // Interstitial, Default: 0 ("1": Interstitial, "0": Something else)
+		TagID:                 imp.Target.Codename(),                       // IDentifier for specific ad placement or ad tag
+		BidFloor:              opts.impBidFloor(imp, format),               // Bid floor for this impression in CPM
+		BidFloorCurrency:      opts.bidFloorCurrency(),                     // Currency of bid floor
+		Secure:                openrtb.NumberOrString(b2i(req.IsSecure())), // Flag to indicate whether the impression requires secure HTTPS URL creative assets and markup.
+		IFrameBusters:         nil,                                         // Array of names for supportediframe busters.
+		PMP:                   openrtbV3PMP(opts.pmp(imp), opts),           // A reference to the PMP object containing any Deals eligible for the impression object.
+		Ext:                   json.RawMessage(opts.impExt(req, imp, ext)),
 	}
 }
 
+// openrtbV3Video returns the video object of the impression
+func openrtbV3Video(imp *adtype.Impression, format *types.Format, battr *BlockedAttributes, opts *BidRequestRTBOptions) *openrtb.Video {
+	placement := opts.videoPlacement(imp, format)
+	w, h := imp.Width, imp.Height
+	if w < 1 && h < 1 {
+		w, h = format.Width, format.Height
+	}
+	video := &openrtb.Video{
+		MIMEs:           placement.Mimes,
+		MinDuration:     placement.MinDuration,
+		MaxDuration:     placement.MaxDuration,
+		Protocols:       intsToEnum[openrtb.Protocol](placement.Protocols),
+		Width:           w,
+		Height:          h,
+		Position:        openrtb.AdPosition(impPosition(imp)),
+		StartDelay:      openrtb.StartDelay(placement.StartDelay),
+		Linearity:       openrtb.VideoLinearity(placement.Linearity),
+		Skip:            b2i(placement.Skippable),
+		BlockedAttrs:    intsToEnum[openrtb.CreativeAttribute](battr.video()),
+		MinBitrate:      placement.MinBitrate,
+		MaxBitrate:      placement.MaxBitrate,
+		BoxingAllowed:   &[]int{1}[0],
+		PlaybackMethods: intsToEnum[openrtb.VideoPlayback](placement.PlaybackMethods),
+		Delivery:        intsToEnum[openrtb.ContentDelivery](placement.Delivery),
+		APIs:            intsToEnum[openrtb.APIFramework](placement.API),
+		CompanionTypes:  intsToEnum[openrtb.CompanionType](placement.CompanionTypes),
+		Placement:       openrtb.VideoPlacement(placement.Placement),
+		Plcmt:           openrtb.VideoPlcmt(placement.Plcmt),
+	}
+	if placement.Skippable {
+		video.SkipMin, video.SkipAfter = placement.SkipMin, placement.SkipAfter
+	}
+	for i, companion := range placement.Companions {
+		video.CompanionAds = append(video.CompanionAds, openrtb.Banner{
+			ID:     strconv.Itoa(i + 1),
+			Width:  companion.W,
+			Height: companion.H,
+		})
+	}
+	return video
+}
+
 func openrtbV3NativeRequest(req adtype.BidRequester, imp *adtype.Impression, format *types.Format, opts *BidRequestRTBOptions) json.RawMessage {
 	native := &openrtbnreq.Request{
 		Ver:              opts.openNativeVer(),                    // Version of the Native Markup
@@ -198,8 +379,8 @@ func openrtbV3NativeAssets(req adtype.BidRequester, imp *adtype.Impression, form
 	return assets
 }
 
-func uopenrtbOpenrtbV3UserInfo(u *adtype.User) *openrtb.User {
-	data := make([]openrtb.Data, 0, len(u.Data))
+func uopenrtbOpenrtbV3UserInfo(u *adtype.User, opts *BidRequestRTBOptions) *openrtb.User {
+	data := make([]openrtb.Data, 0, len(u.Data)+len(opts.UserSegments))
 	for _, it := range u.Data {
 		dataItem := openrtb.Data{Name: it.Name}
 		for i := range it.Segment {
@@ -210,6 +391,20 @@ func uopenrtbOpenrtbV3UserInfo(u *adtype.User) *openrtb.User {
 		}
 		data = append(data, dataItem)
 	}
+	for _, it := range opts.UserSegments {
+		dataItem := openrtb.Data{Name: it.Provider}
+		if it.Taxonomy > 0 {
+			dataItem.Ext = adresponse.ExtSet[json.RawMessage](nil, "segtax", it.Taxonomy)
+		}
+		for _, seg := range it.Segments {
+			dataItem.Segment = append(dataItem.Segment, openrtb.Segment{
+				ID:    seg.ID,
+				Name:  seg.Name,
+				Value: seg.Value,
+			})
+		}
+		data = append(data, dataItem)
+	}
 
 	return &openrtb.User{
 		ID:          u.ID,       // Unique consumer ID of this user on the exchange
@@ -221,7 +416,7 @@ func uopenrtbOpenrtbV3UserInfo(u *adtype.User) *openrtb.User {
 		CustomData:  "",         // Optional feature to pass bidder data that was set in the exchange's cookie. The string must be in base85 cookie safe characters and be in any format. Proper JSON encoding must be used to include "escaped" quotation marks.
 		Geo:         uopenrtbOpenrtbV3GeoFrom(u.Geo),
 		Data:        data,
-		Ext:         nil,
+		Ext:         json.RawMessage(opts.userExt()),
 	}
 }
 
@@ -244,7 +439,7 @@ func uopenrtbOpenrtbV3GeoFrom(g *udetect.Geo) *openrtb.Geo {
 	}
 }
 
-func uopenrtbOpenrtbV3SiteFrom(s *udetect.Site) *openrtb.Site {
+func uopenrtbOpenrtbV3SiteFrom(s *udetect.Site, opts *BidRequestRTBOptions) *openrtb.Site {
 	if s == nil {
 		return nil
 	}
@@ -252,7 +447,7 @@ func uopenrtbOpenrtbV3SiteFrom(s *udetect.Site) *openrtb.Site {
 	for _, ct := range s.Cat {
 		cats = append(cats, openrtb.ContentCategory(ct))
 	}
-	return &openrtb.Site{
+	site := &openrtb.Site{
 		Inventory: openrtb.Inventory{
 			ID:            s.ExtID,                 // External ID
 			Keywords:      s.Keywords,              // Comma separated list of keywords about the site.
@@ -265,6 +460,21 @@ func uopenrtbOpenrtbV3SiteFrom(s *udetect.Site) *openrtb.Site {
 		Search:   s.Search,   // Search string that caused naviation
 		Mobile:   s.Mobile,   // Mobile ("1": site is mobile optimised)
 	}
+	if opts.URLNormalization != nil {
+		site.Page = opts.URLNormalization.Normalize(site.Page)
+		site.Referrer = opts.URLNormalization.Normalize(site.Referrer)
+	}
+	if pageCtx := opts.PageContext; pageCtx != nil {
+		site.Keywords = mergeKeywords(site.Keywords, pageCtx.Keywords)
+		site.Categories = mergeCategories(site.Categories, pageCtx.Categories)
+		if len(pageCtx.Keywords) > 0 {
+			if site.Content == nil {
+				site.Content = &openrtb.Content{}
+			}
+			site.Content.Keywords = mergeKeywords(site.Content.Keywords, pageCtx.Keywords)
+		}
+	}
+	return site
 }
 
 func uopenrtbOpenrtbV3ApplicationFrom(a *udetect.App) *openrtb.App {
@@ -455,3 +665,21 @@ func openrtbV3NativeFieldAsset(field *types.FormatField) (openrtbnreq.Asset, boo
 	}
 	return openrtbnreq.Asset{}, false
 }
+
+func openrtbV3PMP(pmp *adresponse.PMP, opts *BidRequestRTBOptions) *openrtb.PMP {
+	if pmp == nil {
+		return nil
+	}
+	deals := make([]openrtb.Deal, 0, len(pmp.Deals))
+	for _, deal := range pmp.Deals {
+		deals = append(deals, openrtb.Deal{
+			ID:               deal.ID,
+			BidFloor:         opts.dealFloor(deal.BidFloor),
+			BidFloorCurrency: opts.bidFloorCurrency(),
+			Seats:            deal.Seats,
+			AdvDomains:       deal.ADomains,
+			AuctionType:      deal.AuctionType,
+		})
+	}
+	return &openrtb.PMP{Private: b2i(pmp.Private), Deals: deals}
+}
@@ -0,0 +1,86 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	openrtbv3 "github.com/bsm/openrtb/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+func TestBuildRequestV3(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250", "native")
+	rtbRequest := BuildRequestV3(request,
+		WithMaxTimeDuration(150*time.Millisecond),
+		WithAuctionType(types.SecondPriceAuctionType),
+		WithBidFloor(0.5))
+
+	assert.Equal(t, "auction1", rtbRequest.ID)
+	assert.Equal(t, 150, rtbRequest.TimeMax)
+	assert.Equal(t, int(types.SecondPriceAuctionType), rtbRequest.AuctionType)
+	assert.Equal(t, []string{SystemCurrency}, rtbRequest.Currencies)
+	if assert.Len(t, rtbRequest.Impressions, 2) {
+		assert.Equal(t, "zone1", rtbRequest.Impressions[0].TagID)
+		assert.Equal(t, 0.5, rtbRequest.Impressions[0].BidFloor)
+		assert.NoError(t, rtbRequest.Validate())
+	}
+}
+
+func TestBuildRequestV3FormatFilter(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250", "native")
+
+	rtbRequest := BuildRequestV3(request)
+	assert.Len(t, rtbRequest.Impressions, 2)
+
+	rtbRequest = BuildRequestV3(request, WithFormatFilter(func(format *types.Format) bool {
+		return format.IsNative()
+	}))
+	if assert.Len(t, rtbRequest.Impressions, 1) {
+		assert.Nil(t, rtbRequest.Impressions[0].Banner)
+		assert.NotNil(t, rtbRequest.Impressions[0].Native)
+	}
+}
+
+func TestBuildRequestV3Video(t *testing.T) {
+	request := newTestRequest(context.Background(), "video")
+	rtbRequest := BuildRequestV3(request, WithVideo(func(*adtype.Impression) *VideoPlacement {
+		return &VideoPlacement{
+			MaxDuration:    30,
+			Skippable:      true,
+			SkipAfter:      5,
+			Placement:      1,
+			Plcmt:          2,
+			Delivery:       []int{2},
+			Companions:     []VideoCompanion{{W: 300, H: 250}},
+			CompanionTypes: []int{1},
+		}
+	}))
+	if !assert.Len(t, rtbRequest.Impressions, 1) || !assert.NotNil(t, rtbRequest.Impressions[0].Video) {
+		return
+	}
+	assert.NoError(t, rtbRequest.Validate())
+
+	video := rtbRequest.Impressions[0].Video
+	assert.Nil(t, rtbRequest.Impressions[0].Banner)
+	assert.Equal(t, []string{"video/mp4"}, video.MIMEs)
+	assert.Equal(t, 5, video.MinDuration)
+	assert.Equal(t, 30, video.MaxDuration)
+	assert.Equal(t, 640, video.Width)
+	assert.Equal(t, 360, video.Height)
+	assert.Equal(t, openrtbv3.VideoLinearity(VideoLinear), video.Linearity)
+	assert.Equal(t, 1, video.Skip)
+	assert.Equal(t, 5, video.SkipAfter)
+	assert.Equal(t, openrtbv3.VideoPlacement(1), video.Placement)
+	assert.Equal(t, openrtbv3.VideoPlcmt(2), video.Plcmt)
+	assert.Equal(t, []openrtbv3.ContentDelivery{2}, video.Delivery)
+	assert.Equal(t, []openrtbv3.CompanionType{1}, video.CompanionTypes)
+	if assert.Len(t, video.CompanionAds, 1) {
+		assert.Equal(t, "1", video.CompanionAds[0].ID)
+		assert.Equal(t, 300, video.CompanionAds[0].Width)
+		assert.Equal(t, 250, video.CompanionAds[0].Height)
+	}
+}
@@ -0,0 +1,108 @@
+package adsourceopenrtb
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"slices"
+	"strconv"
+
+	"github.com/bsm/openrtb"
+)
+
+// ContentTypeXML of the XML-encoded requests
+const ContentTypeXML = "application/xml"
+
+// xmlRequestRoot element of the XML-encoded requests
+const xmlRequestRoot = "BidRequest"
+
+// MarshalBidRequestXML into the XML document with the elements named by the OpenRTB JSON fields,
+// the lists are encoded as the repeated elements
+func MarshalBidRequestXML(req *openrtb.BidRequest) ([]byte, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return encodeRequestXML(data)
+}
+
+// encodeRequestXML converts the encoded JSON request into the XML document,
+// so the XML request contains the same fields as the JSON one after all the builders and filters
+func encodeRequestXML(data []byte) ([]byte, error) {
+	var obj map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	if err := encodeXMLValue(enc, xmlRequestRoot, obj); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeXMLValue(enc *xml.Encoder, name string, val any) error {
+	if list, ok := val.([]any); ok {
+		for _, item := range list {
+			if err := encodeXMLValue(enc, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := val.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			if v[key] == nil || !isXMLName(key) {
+				continue
+			}
+			if err := encodeXMLValue(enc, key, v[key]); err != nil {
+				return err
+			}
+		}
+	case string:
+		if err := enc.EncodeToken(xml.CharData(v)); err != nil {
+			return err
+		}
+	case json.Number:
+		if err := enc.EncodeToken(xml.CharData(v.String())); err != nil {
+			return err
+		}
+	case bool:
+		if err := enc.EncodeToken(xml.CharData(strconv.FormatBool(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// isXMLName returns true if the JSON field name can be used as the XML element name
+func isXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		case i > 0 && (c == '-' || c == '.' || (c >= '0' && c <= '9')):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+)
+
+// xmlTestRequest of the XML-encoded request fields checked by the tests
+type xmlTestRequest struct {
+	XMLName xml.Name `xml:"BidRequest"`
+	ID      string   `xml:"id"`
+	Cur     []string `xml:"cur"`
+	Imp     []struct {
+		ID       string  `xml:"id"`
+		BidFloor float64 `xml:"bidfloor"`
+	} `xml:"imp"`
+}
+
+func TestMarshalBidRequestXML(t *testing.T) {
+	data, err := MarshalBidRequestXML(&openrtb.BidRequest{
+		ID:  "req1",
+		Imp: []openrtb.Impression{{ID: "imp1", BidFloor: 0.5}, {ID: "imp2"}},
+		Cur: []string{"USD", "EUR"},
+		Ext: []byte(`{"x":1,"1invalid":2}`),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	var req xmlTestRequest
+	if assert.NoError(t, xml.Unmarshal(data, &req)) {
+		assert.Equal(t, "req1", req.ID)
+		assert.Equal(t, []string{"USD", "EUR"}, req.Cur)
+		if assert.Len(t, req.Imp, 2) {
+			assert.Equal(t, "imp1", req.Imp[0].ID)
+			assert.Equal(t, 0.5, req.Imp[0].BidFloor)
+		}
+	}
+	assert.Contains(t, string(data), "<ext><x>1</x></ext>", "the invalid XML names are skipped")
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, ContentTypeXML, r.Header.Get("Content-Type"))
+		var req xmlTestRequest
+		if err := xml.NewDecoder(r.Body).Decode(&req); !assert.NoError(t, err) || !assert.Len(t, req.Imp, 1) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		_, _ = fmt.Fprintf(w, `<BidResponse><id>%s</id><seatbid><bid><id>1</id><impid>%s</impid>`+
+			`<price>1.5</price><crid>c1</crid><adm><![CDATA[<div></div>]]></adm></bid></seatbid></BidResponse>`,
+			req.ID, req.Imp[0].ID)
+	}, testSourceOption(func(source *admodels.RTBSource) {
+		source.RequestType = RequestTypeXML
+	}))
+
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.NoError(t, resp.Error())
+	assert.Len(t, resp.Ads(), 1)
+}
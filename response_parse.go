@@ -0,0 +1,480 @@
+package adsourceopenrtb
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/bsm/openrtb"
+	"go.uber.org/zap"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/context/ctxlogger"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// ParseOptions of the bid response parsing and normalization
+type ParseOptions struct {
+	// SourceID used for the violations reporting
+	SourceID uint64
+
+	// MaxBid price of the bids in the system currency (0 - unlimited)
+	MaxBid float64
+
+	// BlockedCategories of the advertisement in terms of the category taxonomy
+	BlockedCategories []string
+	CategoryTaxonomy  int
+
+	// BlockedAdvertisers returns the blocked categories, advertiser domains and application bundles
+	// of the placement, the bids are not checked if it's not defined
+	BlockedAdvertisers func(imp *adtype.Impression) *BlockedAdvertisers
+
+	// BlockedAttributes returns the blocked creative attributes of the placement,
+	// the observer receives each blocked attribute of the rejected bids
+	BlockedAttributes        func(imp *adtype.Impression) *BlockedAttributes
+	BlockedAttributeObserver func(attr int)
+
+	// Currency of the request, the exchange rates and the policy of the mismatched response currency
+	Currency       string
+	ExchangeRates  ExchangeRateProvider
+	CurrencyPolicy CurrencyPolicy
+
+	// AcceptedCurrencies of the bids converted into the system currency independently of the policy
+	AcceptedCurrencies []string
+
+	// StrictValidation mode of the bids and the violations receiver
+	StrictValidation  StrictValidationMode
+	ViolationReporter ViolationReporter
+
+	// CorrelationMode of the impression correlation token echo with the token key,
+	// the observer receives the correlation result of each response bid
+	CorrelationMode     CorrelationMode
+	CorrelationKey      []byte
+	CorrelationObserver func(matched bool)
+
+	// SpoofMode of the advertiser domain and bundle spoof detection with the check of the seat bids
+	SpoofMode  SpoofMode
+	SpoofCheck func(seat string, bid *openrtb.Bid) SpoofKind
+
+	// ImpIDCodec of the impression IDs used in the request
+	ImpIDCodec adresponse.ImpIDCodec
+
+	// ImpIDMatchObserver receives the impression ID match kind of each response bid
+	ImpIDMatchObserver func(match adresponse.ImpIDMatch)
+
+	// FormatFilter of the placement formats used in the request building,
+	// the bids of the filtered formats are rejected if it's defined
+	FormatFilter func(format *types.Format) bool
+
+	// MRAID returns the MRAID API frameworks supported by the placement,
+	// the MRAID creatives are not filtered if it's not defined
+	MRAID func(imp *adtype.Impression) []int
+
+	// Languages returns the creative languages allowed by the placement,
+	// the bid languages are not checked if it's not defined
+	Languages func(imp *adtype.Impression) []string
+
+	// PMP returns the private marketplace deals of the placement,
+	// the deal bids are not checked if it's not defined
+	PMP func(imp *adtype.Impression) *adresponse.PMP
+
+	// MarkupWrapper of the third-party HTML markup (not wrapped if nil)
+	MarkupWrapper *adresponse.MarkupWrapper
+
+	// AllowedSeats and BlockedSeats of the buyers, the bids of other seats are rejected
+	AllowedSeats []string
+	BlockedSeats []string
+
+	// PreferredSeats with the selection boost factor of the seat bids
+	PreferredSeats map[string]float64
+
+	// MaxSeatBids and MaxResponseBids accepted after the filtering (0 - unlimited),
+	// the most expensive bids are kept
+	MaxSeatBids     int
+	MaxResponseBids int
+
+	// DeferredNURL keeps the win and billing notice URLs until the internal clearing is final
+	DeferredNURL bool
+
+	// LateMacros of the markup bound at the render time by FinalizeMarkup of the items
+	LateMacros []string
+
+	// PricingModel of the source bid prices (CPM by default) and the estimated action rate
+	// of the placements used to convert the prices per action into the effective CPM
+	PricingModel types.PricingModel
+	ActionRate   func(imp *adtype.Impression, action adtype.Action) float64
+
+	// AuctionType of the source and the price increment (CPM) of the second-price settlement
+	AuctionType    types.AuctionType
+	PriceIncrement float64
+
+	// SKAdNKeys of the ad networks to verify the SKAdNetwork signatures
+	SKAdNKeys SKAdNKeys
+
+	// Logger of the parsing warnings, the request context logger is used if not defined
+	Logger *zap.Logger
+}
+
+// ParseOption set function
+type ParseOption func(opts *ParseOptions)
+
+// WithParseMaxBid set the maximal price of the bids
+func WithParseMaxBid(maxBid float64) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.MaxBid = maxBid
+	}
+}
+
+// WithParseBlockedCategories set the blocked categories in the taxonomy
+func WithParseBlockedCategories(taxonomy int, categories ...string) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.CategoryTaxonomy = taxonomy
+		opts.BlockedCategories = categories
+	}
+}
+
+// WithParseBlockedAdvertisers set the resolver of the blocked advertisers of the placements
+func WithParseBlockedAdvertisers(fn func(imp *adtype.Impression) *BlockedAdvertisers) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.BlockedAdvertisers = fn
+	}
+}
+
+// WithParseAcceptedCurrencies set the currencies of the bids accepted in addition to the requested one
+func WithParseAcceptedCurrencies(currencies ...string) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.AcceptedCurrencies = currencies
+	}
+}
+
+// WithParseCurrency set the requested currency with the exchange rates and the mismatch policy
+func WithParseCurrency(currency string, rates ExchangeRateProvider, policy CurrencyPolicy) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.Currency = currency
+		opts.ExchangeRates = rates
+		opts.CurrencyPolicy = policy
+	}
+}
+
+// WithParseStrictValidation enables the strict validation of the bids
+func WithParseStrictValidation(mode StrictValidationMode, reporter ViolationReporter) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.StrictValidation = mode
+		opts.ViolationReporter = reporter
+	}
+}
+
+// WithParseCorrelation set the correlation token echo check of the bids
+func WithParseCorrelation(mode CorrelationMode, key []byte, observer func(matched bool)) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.CorrelationMode = mode
+		opts.CorrelationKey = key
+		opts.CorrelationObserver = observer
+	}
+}
+
+// WithParseSpoofDetection set the spoof detection mode and the check of the seat bids
+func WithParseSpoofDetection(mode SpoofMode, check func(seat string, bid *openrtb.Bid) SpoofKind) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.SpoofMode = mode
+		opts.SpoofCheck = check
+	}
+}
+
+// WithParseImpIDCodec set the codec of the impression IDs
+func WithParseImpIDCodec(codec adresponse.ImpIDCodec) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.ImpIDCodec = codec
+	}
+}
+
+// WithParseImpIDMatchObserver set the observer of the impression ID match kinds
+func WithParseImpIDMatchObserver(observer func(match adresponse.ImpIDMatch)) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.ImpIDMatchObserver = observer
+	}
+}
+
+// WithParseSourceID set the source ID used for the violations reporting
+func WithParseSourceID(id uint64) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.SourceID = id
+	}
+}
+
+// WithParseBlockedAttributes set the resolver of the blocked creative attributes of the placements
+// and the observer of the blocked attributes of the rejected bids
+func WithParseBlockedAttributes(fn func(imp *adtype.Impression) *BlockedAttributes, observer func(attr int)) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.BlockedAttributes = fn
+		opts.BlockedAttributeObserver = observer
+	}
+}
+
+// WithParseFormatFilter set the filter of the placement formats allowed for the bids
+func WithParseFormatFilter(filter func(format *types.Format) bool) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.FormatFilter = filter
+	}
+}
+
+// WithParseMRAID set the provider of the MRAID-capable placements
+func WithParseMRAID(provider MRAIDProvider) ParseOption {
+	return func(opts *ParseOptions) {
+		if provider != nil {
+			opts.MRAID = provider.MRAIDFrameworks
+		}
+	}
+}
+
+// WithParseLanguages set the provider of the creative languages allowed by the placements
+func WithParseLanguages(provider LanguageProvider) ParseOption {
+	return func(opts *ParseOptions) {
+		if provider != nil {
+			opts.Languages = provider.Languages
+		}
+	}
+}
+
+// WithParsePMP set the provider of the private marketplace deals of the placements
+func WithParsePMP(provider DealProvider) ParseOption {
+	return func(opts *ParseOptions) {
+		if provider != nil {
+			opts.PMP = provider.PMP
+		}
+	}
+}
+
+// WithParseDeferredNURL set the deferred win notice mode
+func WithParseDeferredNURL(deferred bool) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.DeferredNURL = deferred
+	}
+}
+
+// WithParseBidDensity set the maximal number of the bids per seat and per response
+func WithParseBidDensity(maxSeatBids, maxResponseBids int) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.MaxSeatBids = maxSeatBids
+		opts.MaxResponseBids = maxResponseBids
+	}
+}
+
+// WithParseLateMacros set the markup macros bound at the render time
+func WithParseLateMacros(macros ...string) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.LateMacros = macros
+	}
+}
+
+// WithParsePricingModel set the pricing model of the source bids and the action rate provider
+// used to convert the prices per action into the effective CPM
+func WithParsePricingModel(model types.PricingModel, provider ActionRateProvider) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.PricingModel = model
+		if provider != nil {
+			opts.ActionRate = provider.ActionRate
+		}
+	}
+}
+
+// WithParseSeats set the allowed and blocked buyer seats
+func WithParseSeats(allowed, blocked []string) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.AllowedSeats = allowed
+		opts.BlockedSeats = blocked
+	}
+}
+
+// WithParsePreferredSeats set the preferred seats with the selection boost factor
+func WithParsePreferredSeats(seats map[string]float64) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.PreferredSeats = seats
+	}
+}
+
+// WithParseAuction set the auction type of the source and the second-price increment
+func WithParseAuction(auctionType types.AuctionType, increment float64) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.AuctionType = auctionType
+		opts.PriceIncrement = increment
+	}
+}
+
+// WithParseMarkupWrapper set the wrapper of the third-party HTML markup
+func WithParseMarkupWrapper(wrapper *adresponse.MarkupWrapper) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.MarkupWrapper = wrapper
+	}
+}
+
+// WithParseSKAdNKeys set the public keys of the ad networks to verify the SKAdNetwork signatures
+func WithParseSKAdNKeys(keys SKAdNKeys) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.SKAdNKeys = keys
+	}
+}
+
+// WithParseLogger set the logger of the parsing warnings
+func WithParseLogger(logger *zap.Logger) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.Logger = logger
+	}
+}
+
+func newParseOptions(opts ...ParseOption) *ParseOptions {
+	var opt ParseOptions
+	for _, fn := range opts {
+		fn(&opt)
+	}
+	return &opt
+}
+
+// logger returns the parsing logger or the context logger
+func (opts *ParseOptions) logger(ctx context.Context) *zap.Logger {
+	if opts.Logger != nil {
+		return opts.Logger
+	}
+	return ctxlogger.Get(ctx)
+}
+
+// currency returns the requested currency of the bids
+func (opts *ParseOptions) currency() string {
+	if opts.Currency == "" {
+		return SystemCurrency
+	}
+	return strings.ToUpper(opts.Currency)
+}
+
+// ParseBidResponse decodes the OpenRTB JSON response body and normalizes it
+// the same way as the driver does: currency conversion, price limits,
+// blocked categories and strict validation. Returns nil response for no-bid.
+func ParseBidResponse(req adtype.BidRequester, src adtype.Source, body []byte, opts ...ParseOption) (*adresponse.BidResponse, error) {
+	var bidResp openrtb.BidResponse
+	if err := adresponse.DecodeBidResponse(bytes.NewReader(body), &bidResp); err != nil {
+		return nil, err
+	}
+	return prepareBidResponse(req, src, bidResp, newParseOptions(opts...))
+}
+
+// prepareBidResponse checks and normalizes the decoded bid response and prepares the ad items
+func prepareBidResponse(request adtype.BidRequester, src adtype.Source, bidResp openrtb.BidResponse, opts *ParseOptions) (*adresponse.BidResponse, error) {
+	// Check response for support HTTPS
+	if request.IsSecure() {
+		for _, seat := range bidResp.SeatBid {
+			for _, bid := range seat.Bid {
+				if strings.Contains(bid.AdMarkup, "http://") {
+					return nil, ErrResponseAreNotSecure
+				}
+			}
+		} // end for
+	}
+
+	// No bid response doesn't need any further processing
+	if len(bidResp.SeatBid) == 0 {
+		return nil, nil
+	}
+
+	// Count the impression ID matches before any filtering
+	if opts.ImpIDMatchObserver != nil {
+		for _, seat := range bidResp.SeatBid {
+			for _, bid := range seat.Bid {
+				_, _, match := adresponse.MatchImpID(opts.ImpIDCodec, request, bid.ImpID)
+				opts.ImpIDMatchObserver(match)
+			}
+		}
+	}
+
+	// Convert prices from the response currency into the system currency
+	currency, currencyRate, err := opts.responseCurrency(bidResp.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if currency != SystemCurrency {
+		convertBidsToSystemCurrency(&bidResp, currencyRate)
+	}
+
+	// Dropped bids are collected into the rejection report of the response
+	var rejections adresponse.BidRejections
+
+	// Convert prices per action of the non-CPM sources into the effective CPM
+	priceUnits := convertBidsToCPM(request, &bidResp, &rejections, opts)
+
+	// Check response for price limits
+	if opts.MaxBid > 0 {
+		// Remove bid from response if price is more than max bid
+		// TODO: add metrics for this case
+		rejectBids(&bidResp, &rejections, adresponse.RejectionMaxBid, func(_ *openrtb.SeatBid, bid *openrtb.Bid) bool {
+			return bid.Price <= opts.MaxBid
+		})
+	}
+
+	// Check response for blocked categories
+	if len(opts.BlockedCategories) > 0 {
+		rejectBids(&bidResp, &rejections, adresponse.RejectionBlockedCategory, func(_ *openrtb.SeatBid, bid *openrtb.Bid) bool {
+			return !isBidCategoryBlocked(bid, opts.BlockedCategories, opts.CategoryTaxonomy)
+		})
+	}
+
+	// Remove bids of the advertisers blocked by the placements
+	filterBlockedAdvertiserBids(request, &bidResp, &rejections, opts)
+
+	// Remove bids with the creative attributes blocked by the placements
+	filterBlockedAttributeBids(request, &bidResp, &rejections, opts)
+
+	// Remove bids of the formats filtered out of the request building
+	filterFormatBids(request, &bidResp, &rejections, opts)
+
+	// Remove bids of the buyer seats which are not allowed or blocked
+	filterSeatBids(&bidResp, &rejections, opts)
+
+	// Remove MRAID creatives of the placements without MRAID support
+	filterMRAIDBids(request, &bidResp, &rejections, opts)
+
+	// Remove bids with the creative languages not allowed by the placements
+	filterLanguageBids(request, &bidResp, &rejections, opts)
+
+	// Remove bids which don't match the private marketplace deals
+	filterDealBids(request, &bidResp, &rejections, opts)
+
+	// Check response bids by the OpenRTB specification
+	strictValidate(request, &bidResp, &rejections, opts)
+
+	// Check the impression correlation tokens echoed by the partner
+	correlateBids(request, &bidResp, &rejections, opts)
+
+	// Check the advertiser domains and bundles of the bids for the spoofing
+	detectSpoofBids(request, &bidResp, &rejections, opts)
+
+	// Limit the number of the bids of the remaining seats and the response
+	limitBidDensity(&bidResp, &rejections, opts.MaxSeatBids, opts.MaxResponseBids)
+
+	// If the response is empty and there are no rejected bids to report, then return nil
+	if len(bidResp.SeatBid) == 0 && len(rejections) == 0 {
+		return nil, nil
+	}
+
+	// Build response
+	bidResponse := &adresponse.BidResponse{
+		Src:         src,
+		Req:         request,
+		BidResponse: bidResp,
+	}
+	bidResponse.ImpIDCodec = opts.ImpIDCodec
+	bidResponse.MarkupWrapper = opts.MarkupWrapper
+	bidResponse.SourceAuctionType = opts.AuctionType
+	bidResponse.PriceIncrement = opts.PriceIncrement
+	bidResponse.PreferredSeats = opts.PreferredSeats
+	bidResponse.PMP = opts.PMP
+	bidResponse.DeferredNURL = opts.DeferredNURL
+	bidResponse.LateMacros = opts.LateMacros
+	bidResponse.PriceUnits = priceUnits
+	bidResponse.AddRejections(rejections...)
+	if currency != SystemCurrency {
+		bidResponse.SourceCurrency, bidResponse.SourceCurrencyRate = currency, currencyRate
+	}
+	bidResponse.Prepare()
+	return bidResponse, nil
+}
@@ -0,0 +1,466 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adquery/bidrequest"
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/billing"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// testParseBids returns the response of the bids parsed with the options by the test source
+func testParseBids(t *testing.T, request adtype.BidRequester, seats []openrtb.SeatBid, opts ...ParseOption) (*adresponse.BidResponse, error) {
+	t.Helper()
+	body, err := json.Marshal(openrtb.BidResponse{ID: request.ID(), SeatBid: seats})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return ParseBidResponse(request, newTestDriver(t, func(http.ResponseWriter, *http.Request) {}), body, opts...)
+}
+
+// testResponseBids returns the IDs of the bids remaining in the response
+func testResponseBids(resp *adresponse.BidResponse) []string {
+	var ids []string
+	if resp != nil {
+		for _, seat := range resp.BidResponse.SeatBid {
+			for _, bid := range seat.Bid {
+				ids = append(ids, bid.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// testRejectionReasons returns the reasons of the rejected bids by the bid ID
+func testRejectionReasons(resp *adresponse.BidResponse) map[string]adresponse.RejectionReason {
+	reasons := map[string]adresponse.RejectionReason{}
+	if resp != nil {
+		for _, rejection := range resp.Rejections() {
+			reasons[rejection.BidID] = rejection.Reason
+		}
+	}
+	return reasons
+}
+
+func TestSecondPriceAuction(t *testing.T) {
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var resp openrtb.BidResponse
+		_ = json.Unmarshal(testBidResponse(t, data, 2), &resp)
+		winner := resp.SeatBid[0].Bid[0]
+		winner.NURL = "https://example.com/win?p=${AUCTION_PRICE}"
+		competitor := winner
+		competitor.ID, competitor.CreativeID, competitor.Price = "competitor", "competitor", 1.5
+		resp.SeatBid = []openrtb.SeatBid{
+			{Seat: "winner", Bid: []openrtb.Bid{winner}},
+			{Seat: "competitor", Bid: []openrtb.Bid{competitor}},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}, WithSecondPriceIncrement(0.1), testSourceOption(func(source *admodels.RTBSource) {
+		source.AuctionType = types.SecondPriceAuctionType
+	}))
+
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	if !assert.NoError(t, resp.Error()) || !assert.Len(t, resp.Ads(), 1) {
+		return
+	}
+	item, ok := resp.Ads()[0].(*adresponse.ResponseBannerBidItem)
+	if !assert.True(t, ok) {
+		return
+	}
+	// The winner pays the competing price plus the increment
+	assert.Equal(t, "1", item.Bid.ID)
+	assert.Equal(t, billing.MoneyFloat(1.5), item.SecondAd.Price)
+	assert.InDelta(t, 1.6/1000, item.PriceScope.ImpPrice.Float64(), 1e-9)
+	assert.Equal(t, "https://example.com/win?p=1.600000", item.Bid.NURL)
+}
+
+func TestLenientDecoding(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var request openrtb.BidRequest
+		_ = json.Unmarshal(data, &request)
+		_, _ = fmt.Fprintf(w, `{"id":%q,"seatbid":[{"bid":[{"id":"1","impid":%q,"price":"1.5","crid":"c1","adm":"<div></div>"}]}]}`,
+			request.ID, request.Imp[0].ID)
+	}
+
+	strict := newTestDriver(t, handler)
+	resp := strict.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.Empty(t, resp.Ads(), "the strict decoding rejects the numeric string price")
+
+	registry := prometheus.NewRegistry()
+	lenient := newTestDriver(t, handler, WithLenientDecoding(), DriverOption(func(opts *DriverOptions) {
+		opts.MetricsRegistry = registry
+	}))
+	resp = lenient.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.NoError(t, resp.Error())
+	assert.Len(t, resp.Ads(), 1)
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	tolerances := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "adsource_response_tolerance_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "tolerance" {
+					tolerances[label.GetValue()] += metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{string(adresponse.ToleranceNumericString): 1}, tolerances)
+}
+
+func TestParseBidResponse(t *testing.T) {
+	var (
+		src     = newTestDriver(t, func(http.ResponseWriter, *http.Request) {})
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+	)
+
+	resp, err := ParseBidResponse(request, src, []byte(`{"id":"auction1","seatbid":[{"bid":[`+
+		`{"id":"1","impid":"`+impID+`","price":1.5,"crid":"c1","adm":"<div></div>"},`+
+		`{"id":"2","impid":"`+impID+`","price":7,"crid":"c2","adm":"<div></div>"}]}]}`), WithParseMaxBid(5))
+	if assert.NoError(t, err) && assert.NotNil(t, resp) {
+		assert.Same(t, src, resp.Src)
+		assert.Equal(t, []string{"1"}, testResponseBids(resp))
+		assert.Equal(t, map[string]adresponse.RejectionReason{"2": adresponse.RejectionMaxBid}, testRejectionReasons(resp))
+		assert.Len(t, resp.Ads(), 1)
+	}
+
+	// The no-bid response has no bid response object
+	resp, err = ParseBidResponse(request, src, []byte(`{"id":"auction1"}`))
+	assert.NoError(t, err)
+	assert.Nil(t, resp)
+
+	_, err = ParseBidResponse(request, src, []byte(`{"id":`))
+	assert.Error(t, err)
+
+	// The secure requests don't accept the insecure markup
+	request.StateFlags |= bidrequest.BidRequestFlagSecure
+	_, err = ParseBidResponse(request, src, []byte(`{"id":"auction1","seatbid":[{"bid":[`+
+		`{"id":"1","impid":"`+impID+`","price":1.5,"crid":"c1","adm":"<img src=\"http://example.com\">"}]}]}`))
+	assert.ErrorIs(t, err, ErrResponseAreNotSecure)
+}
+
+func TestParseMarkupWrapper(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		seats   = []openrtb.SeatBid{{Bid: []openrtb.Bid{
+			{ID: "1", ImpID: impID, Price: 2, CreativeID: "c1", AdMarkup: "<div></div>"},
+		}}}
+	)
+	resp, err := testParseBids(t, request, seats,
+		WithParseMarkupWrapper(adresponse.DefaultMarkupWrapper(adresponse.MarkupTrustUntrusted)))
+	if !assert.NoError(t, err) || !assert.Len(t, resp.Ads(), 1) {
+		return
+	}
+	item, _ := resp.Ads()[0].(*adresponse.ResponseBannerBidItem)
+	if assert.NotNil(t, item) {
+		markup, err := item.Markup()
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(markup, "<iframe sandbox="), markup)
+	}
+}
+
+func TestParsePreferredSeats(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		seatBid = func(seat, id string, price float64) openrtb.SeatBid {
+			return openrtb.SeatBid{Seat: seat, Bid: []openrtb.Bid{
+				{ID: id, ImpID: impID, Price: price, CreativeID: id, AdMarkup: "<div></div>"},
+			}}
+		}
+		optimalBid = func(t *testing.T, seats map[string]float64, bids ...openrtb.SeatBid) string {
+			resp, err := testParseBids(t, request, bids, WithParsePreferredSeats(seats))
+			if !assert.NoError(t, err) || !assert.Len(t, resp.OptimalBids(), 1) {
+				return ""
+			}
+			return resp.OptimalBids()[0].ID
+		}
+	)
+
+	tests := []struct {
+		name     string
+		seats    map[string]float64
+		bids     []openrtb.SeatBid
+		expected string
+	}{
+		{
+			name:     "no_preferred_seats",
+			bids:     []openrtb.SeatBid{seatBid("open", "1", 2), seatBid("pg", "2", 1.8)},
+			expected: "1",
+		},
+		{
+			name:     "preferred_wins_tie",
+			seats:    map[string]float64{"pg": 1},
+			bids:     []openrtb.SeatBid{seatBid("open", "1", 2), seatBid("pg", "2", 2)},
+			expected: "2",
+		},
+		{
+			name:     "preferred_boosted",
+			seats:    map[string]float64{"pg": 1.2},
+			bids:     []openrtb.SeatBid{seatBid("open", "1", 2), seatBid("pg", "2", 1.8)},
+			expected: "2",
+		},
+		{
+			name:     "boost_not_enough",
+			seats:    map[string]float64{"pg": 1.1},
+			bids:     []openrtb.SeatBid{seatBid("open", "1", 2), seatBid("pg", "2", 1.5)},
+			expected: "1",
+		},
+		{
+			name:     "boost_below_one_ignored",
+			seats:    map[string]float64{"pg": 0.5},
+			bids:     []openrtb.SeatBid{seatBid("open", "1", 2), seatBid("pg", "2", 1.9)},
+			expected: "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, optimalBid(t, tt.seats, tt.bids...))
+		})
+	}
+}
+
+func TestParseRejections(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		seats   = []openrtb.SeatBid{
+			{Seat: "s1", Bid: []openrtb.Bid{
+				{ID: "1", ImpID: impID, Price: 2, CreativeID: "c1", AdMarkup: "<div></div>"},
+				{ID: "2", ImpID: impID, Price: 9, CreativeID: "c2", AdMarkup: "<div></div>"},
+			}},
+			{Seat: "s2", Bid: []openrtb.Bid{
+				{ID: "3", ImpID: impID, Price: 1, CreativeID: "c3", AdMarkup: "<div></div>"},
+				{ID: "4", ImpID: "unknown", Price: 3, CreativeID: "c4", AdMarkup: "<div></div>"},
+			}},
+		}
+	)
+	resp, err := testParseBids(t, request, seats, WithParseMaxBid(5))
+	if !assert.NoError(t, err) || !assert.Len(t, resp.OptimalBids(), 1) {
+		return
+	}
+	assert.Equal(t, "1", resp.OptimalBids()[0].ID)
+
+	rejections := resp.Rejections()
+	for i := range rejections {
+		// The messages are not part of the report contract
+		rejections[i].Message = ""
+	}
+	assert.ElementsMatch(t, adresponse.BidRejections{
+		{Reason: adresponse.RejectionMaxBid, Seat: "s1", BidID: "2", ImpID: impID, Price: 9, CreativeID: "c2"},
+		{Reason: adresponse.RejectionLostAuction, Seat: "s2", BidID: "3", ImpID: impID, Price: 1, CreativeID: "c3"},
+		{Reason: adresponse.RejectionUnknownImp, Seat: "s2", BidID: "4", ImpID: "unknown", Price: 3, CreativeID: "c4"},
+	}, rejections)
+
+	resp.AddRejections(adresponse.BidRejection{Reason: adresponse.RejectionInvalid, BidID: "5"})
+	assert.Len(t, resp.Rejections(), 4)
+}
+
+func TestParseLateMacros(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		seats   = []openrtb.SeatBid{{Bid: []openrtb.Bid{{ID: "1", ImpID: impID, Price: 2, CreativeID: "c1",
+			AdMarkup: `<div data-p="${AUCTION_PRICE}" data-t="${TIMESTAMP}" data-id="${AUCTION_ID}"></div>`}}}}
+		bannerItem = func(t *testing.T, opts ...ParseOption) *adresponse.ResponseBannerBidItem {
+			resp, err := testParseBids(t, request, seats, opts...)
+			if !assert.NoError(t, err) || !assert.Len(t, resp.Ads(), 1) {
+				t.FailNow()
+			}
+			return resp.Ads()[0].(*adresponse.ResponseBannerBidItem)
+		}
+	)
+
+	// The macros are replaced by the bid price at the preparation time by default
+	item := bannerItem(t)
+	assert.Contains(t, item.BannerInfo.HTML, `data-p="2.000000" data-t="${TIMESTAMP}" data-id="auction1"`)
+	markup, err := item.FinalizeMarkup(context.Background())
+	assert.NoError(t, err)
+	assert.Contains(t, markup, `data-p="2.000000" data-t="${TIMESTAMP}"`)
+
+	// The late macros are left until the render time and bound by the final clearing price
+	item = bannerItem(t, WithParseLateMacros(adresponse.MacroAuctionPrice, adresponse.MacroTimestamp))
+	assert.Contains(t, item.BannerInfo.HTML, `data-p="${AUCTION_PRICE}" data-t="${TIMESTAMP}" data-id="auction1"`)
+	item.PriceScope.ImpPrice = billing.MoneyFloat(1.5) / 1000
+	markup, err = item.FinalizeMarkup(context.Background())
+	assert.NoError(t, err)
+	assert.Regexp(t, `data-p="1.500000" data-t="\d+" data-id="auction1"`, markup)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = item.FinalizeMarkup(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestParseNativeSlots(t *testing.T) {
+	request := newTestRequest(context.Background(), "native")
+	request.Imps[0].Count = 3
+
+	// The placement count is requested in the native request
+	imp := BuildRequestV2(request).Imp[0]
+	if assert.NotNil(t, imp.Native) {
+		assert.Contains(t, string(imp.Native.Request), `\"plcmtcnt\":3`)
+	}
+
+	slot := func(title string) string {
+		return `{"link":{"url":"https://example.com/` + title + `"},"assets":[{"id":1,"title":{"text":"` + title + `"}}]}`
+	}
+	seats := []openrtb.SeatBid{{Bid: []openrtb.Bid{{ID: "1", ImpID: imp.ID, Price: 2, CreativeID: "c1",
+		AdMarkup: `{"native":[` + slot("a") + `,"invalid",` + slot("c") + `,` + slot("d") + `]}`}}}}
+	resp, err := testParseBids(t, request, seats)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The slots over the placement count and the invalid slots are skipped
+	var ids, links []string
+	for _, ad := range resp.Ads() {
+		item := ad.(*adresponse.ResponseNativeBidItem)
+		ids = append(ids, item.ItemID)
+		links = append(links, item.ActionLink)
+	}
+	assert.Equal(t, []string{"imp1", "imp1_2"}, ids)
+	assert.Equal(t, []string{"https://example.com/a", "https://example.com/c"}, links)
+
+	// The list of the native objects without the envelope
+	seats[0].Bid[0].AdMarkup = `[` + slot("a") + `,` + slot("b") + `]`
+	resp, err = testParseBids(t, request, seats)
+	if assert.NoError(t, err) {
+		assert.Len(t, resp.Ads(), 2)
+	}
+
+	// The bid is rejected if no slot is valid
+	seats[0].Bid[0].AdMarkup = `["invalid"]`
+	resp, err = testParseBids(t, request, seats)
+	if assert.NoError(t, err) {
+		assert.Empty(t, resp.Ads())
+		assert.Equal(t, map[string]adresponse.RejectionReason{"1": adresponse.RejectionInvalidMarkup}, testRejectionReasons(resp))
+	}
+}
+
+// testVASTMarkup of the wrapper ad
+const testVASTMarkup = `<VAST version="3.0"><Ad id="1"><Wrapper><AdSystem>test</AdSystem>` +
+	`<VASTAdTagURI><![CDATA[https://ads.example.com/vast.xml]]></VASTAdTagURI>` +
+	`<Creatives><Creative><Linear><VideoClicks>` +
+	`<ClickThrough><![CDATA[https://example.com/click]]></ClickThrough>` +
+	`</VideoClicks></Linear></Creative></Creatives></Wrapper></Ad></VAST>`
+
+func TestParseVASTBid(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250", "video")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		markup  = testVASTMarkup
+	)
+
+	// The VAST markup of the bid of the banner impression is matched with the video format
+	resp, err := testParseBids(t, request, []openrtb.SeatBid{{Bid: []openrtb.Bid{
+		{ID: "1", ImpID: impID, Price: 1.5, CreativeID: "c1", AdMarkup: markup},
+	}}})
+	if !assert.NoError(t, err) || !assert.Len(t, resp.Ads(), 1) {
+		return
+	}
+	item, ok := resp.Ads()[0].(*adresponse.ResponseVASTBidItem)
+	if assert.True(t, ok, "the VAST bid is the video item") {
+		assert.True(t, item.Format().IsVideo())
+		assert.True(t, item.VASTInfo.IsWrapper())
+		assert.Equal(t, "https://ads.example.com/vast.xml", item.VASTInfo.AdTagURI)
+	}
+
+	// The invalid VAST markup is rejected
+	resp, err = testParseBids(t, request, []openrtb.SeatBid{{Bid: []openrtb.Bid{
+		{ID: "1", ImpID: impID, Price: 1.5, CreativeID: "c1", AdMarkup: `<VAST version="3.0"></VAST>`},
+	}}})
+	if assert.NoError(t, err) {
+		assert.Empty(t, resp.Ads())
+		assert.Equal(t, map[string]adresponse.RejectionReason{"1": adresponse.RejectionInvalidMarkup}, testRejectionReasons(resp))
+	}
+
+	// The VAST markup isn't matched without the video format of the impression
+	request = newTestRequest(context.Background(), "banner_300x250")
+	resp, err = testParseBids(t, request, []openrtb.SeatBid{{Bid: []openrtb.Bid{
+		{ID: "1", ImpID: impID, Price: 1.5, CreativeID: "c1", AdMarkup: markup},
+	}}})
+	if assert.NoError(t, err) && assert.Len(t, resp.Ads(), 1) {
+		assert.IsType(t, &adresponse.ResponseBannerBidItem{}, resp.Ads()[0])
+	}
+}
+
+func TestParseBidMarkupType(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250", "native", "video")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		native  = `{"link":{"url":"https://example.com"},"assets":[{"id":1,"title":{"text":"title"}}]}`
+	)
+	tests := []struct {
+		name   string
+		bid    openrtb.Bid
+		item   adtype.ResponseItemCommon
+		format string
+	}{
+		{
+			name:   "banner",
+			bid:    openrtb.Bid{AdMarkup: "<div></div>", Ext: openrtb.Extension(`{"mtype":1}`)},
+			item:   &adresponse.ResponseBannerBidItem{},
+			format: "banner_300x250",
+		},
+		{
+			name:   "video",
+			bid:    openrtb.Bid{AdMarkup: testVASTMarkup, Ext: openrtb.Extension(`{"mtype":2}`)},
+			item:   &adresponse.ResponseVASTBidItem{},
+			format: "video",
+		},
+		{
+			name:   "native",
+			bid:    openrtb.Bid{AdMarkup: native, Ext: openrtb.Extension(`{"mtype":4}`)},
+			item:   &adresponse.ResponseNativeBidItem{},
+			format: "native",
+		},
+		{
+			// The markup type is authoritative, the markup content isn't checked
+			name:   "banner_vast",
+			bid:    openrtb.Bid{AdMarkup: testVASTMarkup, Ext: openrtb.Extension(`{"mtype":1}`)},
+			item:   &adresponse.ResponseBannerBidItem{},
+			format: "banner_300x250",
+		},
+		{
+			name:   "undefined_vast",
+			bid:    openrtb.Bid{AdMarkup: testVASTMarkup},
+			item:   &adresponse.ResponseVASTBidItem{},
+			format: "video",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bid := tt.bid
+			bid.ID, bid.ImpID, bid.Price, bid.CreativeID = "1", impID, 1.5, "c1"
+			resp, err := testParseBids(t, request, []openrtb.SeatBid{{Bid: []openrtb.Bid{bid}}})
+			if !assert.NoError(t, err) || !assert.Len(t, resp.Ads(), 1) {
+				return
+			}
+			assert.IsType(t, tt.item, resp.Ads()[0])
+			assert.Equal(t, tt.format, resp.Ads()[0].(adtype.ResponseItem).Format().Codename)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/net/httpclient"
+)
+
+// send the encoded request to the source. The failed attempt is retried
+// only for the idempotent failure classes and only if the remaining
+// latency budget is enough for one more attempt.
+func (d *driver) send(ctx context.Context, request adtype.BidRequester, data []byte, version string) (httpclient.Response, error) {
+	for attempt := 0; ; attempt++ {
+		attemptBegin := d.now()
+		httpRequest, err := d.request(ctx, request, data, version)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := doHTTPRequest(ctx, d.netClient, httpRequest)
+		if attempt >= d.opts.MaxRetries || !isRetryableFailure(resp, err, d.now()) ||
+			!d.hasRetryBudget(request, d.now().Sub(attemptBegin)) {
+			return resp, err
+		}
+		if resp != nil {
+			_ = resp.Close()
+		}
+		d.requestLogger(request).Debug("retry bid request",
+			zap.String("source_url", d.source.URL),
+			zap.Int("attempt", attempt+1),
+			zap.Error(err))
+	}
+}
+
+// sendContext returns the context of the source request attempts. The retried attempts
+// are bounded by the source timeout from the request time, so all attempts together
+// can't overrun the latency budget of the source. The context must be cancelled
+// after the response is read.
+func (d *driver) sendContext(request adtype.BidRequester) (context.Context, context.CancelFunc) {
+	ctx := request.Context()
+	if d.opts.MaxRetries <= 0 || d.source.Timeout <= 0 || request.Time().IsZero() {
+		return ctx, func() {}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithDeadline(ctx, request.Time().Add(time.Duration(d.source.Timeout)*time.Millisecond))
+}
+
+// hasRetryBudget returns true if the remaining time of the request
+// is enough for the attempt of the same duration
+func (d *driver) hasRetryBudget(request adtype.BidRequester, attemptTime time.Duration) bool {
+	need := max(attemptTime, d.opts.RetryMinBudget)
+	if ctx := request.Context(); ctx != nil {
+		if deadline, ok := ctx.Deadline(); ok {
+			return deadline.Sub(d.now())-d.opts.NetworkOverhead >= need
+		}
+	}
+	if d.source.Timeout <= 0 {
+		return false
+	}
+	return d.now().Sub(request.Time())+need <= time.Duration(d.source.Timeout)*time.Millisecond
+}
+
+// isRetryableFailure returns true for the failures which don't depend on the request
+// processing by the source, like connection errors or temporary unavailability
+func isRetryableFailure(resp httpclient.Response, err error, now time.Time) bool {
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+			errors.Is(err, http.ErrHandlerTimeout) {
+			return false
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return false
+		}
+		return true
+	}
+	if resp == nil {
+		return false
+	}
+	// The partner throttle with Retry-After is honored by the back off instead of the retry
+	if _, ok := retryAfter(resp, now); ok {
+		return false
+	}
+	switch resp.StatusCode() {
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+)
+
+func TestIsRetryableFailure(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.True(t, isRetryableFailure(nil, errors.New("connection refused"), now))
+	assert.False(t, isRetryableFailure(nil, context.DeadlineExceeded, now))
+	assert.False(t, isRetryableFailure(nil, context.Canceled, now))
+
+	tests := []struct {
+		name      string
+		status    int
+		value     string
+		retryable bool
+	}{
+		{name: "bad_gateway", status: http.StatusBadGateway, retryable: true},
+		{name: "unavailable", status: http.StatusServiceUnavailable, retryable: true},
+		{name: "retry_after", status: http.StatusServiceUnavailable, value: "30"},
+		{name: "retry_after_date", status: http.StatusServiceUnavailable, value: now.Add(time.Minute).Format(http.TimeFormat)},
+		{name: "bad_request", status: http.StatusBadRequest},
+		{name: "internal_error", status: http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := doTestRequest(t, func(w http.ResponseWriter, _ *http.Request) {
+				if test.value != "" {
+					w.Header().Set("Retry-After", test.value)
+				}
+				w.WriteHeader(test.status)
+			})
+			assert.Equal(t, test.retryable, isRetryableFailure(resp, nil, now))
+		})
+	}
+}
+
+func TestRetryUnavailable(t *testing.T) {
+	var attempts atomic.Int32
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(testBidResponse(t, data, 1))
+	}, WithRetries(2, 0))
+
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.NoError(t, resp.Error())
+	assert.Len(t, resp.Ads(), 1)
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	var attempts atomic.Int32
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}, WithRetries(2, 0))
+
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.Error(t, resp.Error())
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestRetryBudget(t *testing.T) {
+	var attempts atomic.Int32
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if attempts.Add(1) > 1 {
+			// The retried attempt is aborted by the source timeout
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithRetries(3, 0), testSourceOption(func(source *admodels.RTBSource) {
+		source.Timeout = 100
+	}))
+
+	begin := time.Now()
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.ErrorIs(t, resp.Error(), context.DeadlineExceeded)
+	assert.Less(t, time.Since(begin), time.Second)
+	assert.Equal(t, int32(2), attempts.Load())
+}
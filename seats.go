@@ -0,0 +1,62 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bsm/openrtb"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// sourceSeats returns the allowed and blocked buyer seats of the source config
+// (`allowed_seats`, `blocked_seats`) merged with the driver options
+func sourceSeats(source *admodels.RTBSource, opts *DriverOptions) (allowed, blocked []string) {
+	var cfgAllowed, cfgBlocked []string
+	_ = sourceConfigValue(source, sourceConfigAllowedSeats, &cfgAllowed)
+	_ = sourceConfigValue(source, sourceConfigBlockedSeats, &cfgBlocked)
+	return normalizeSeats(slices.Concat(opts.AllowedSeats, cfgAllowed)),
+		normalizeSeats(slices.Concat(opts.BlockedSeats, cfgBlocked))
+}
+
+// normalizeSeats returns the unique non-empty seat IDs
+func normalizeSeats(seats []string) []string {
+	list := make([]string, 0, len(seats))
+	for _, seat := range seats {
+		if seat = strings.TrimSpace(seat); seat != "" && !slices.Contains(list, seat) {
+			list = append(list, seat)
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	return list
+}
+
+// requestSeats returns the allowed (wseat) and blocked (bseat) buyer seats of the request,
+// only one of the lists is sent and the allowlist has priority
+func (opts *BidRequestRTBOptions) requestSeats() (wseat, bseat []string) {
+	if len(opts.AllowedSeats) > 0 {
+		return opts.AllowedSeats, nil
+	}
+	return nil, opts.BlockedSeats
+}
+
+// filterSeatBids removes the bids of the seats which are not allowed or blocked.
+// The bids without the seat ID are kept because the seat can't be checked.
+func filterSeatBids(bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) {
+	if len(opts.AllowedSeats) == 0 && len(opts.BlockedSeats) == 0 {
+		return
+	}
+	rejectBids(bidResp, rejections, adresponse.RejectionSeat, func(seat *openrtb.SeatBid, _ *openrtb.Bid) bool {
+		if seat.Seat == "" {
+			return true
+		}
+		if len(opts.AllowedSeats) > 0 && !slices.Contains(opts.AllowedSeats, seat.Seat) {
+			return false
+		}
+		return !slices.Contains(opts.BlockedSeats, seat.Seat)
+	})
+}
@@ -0,0 +1,95 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+func TestRequestSeats(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+
+	// The source config seats are merged with the driver options
+	d := newTestDriver(t, nil, WithSourceSeats([]string{"s1", " "}, []string{"b1"}),
+		testSourceConfig(map[string]any{"allowed_seats": []string{"s2", "s1"}, "blocked_seats": []string{" b2 "}}))
+	assert.Equal(t, []string{"s1", "s2"}, d.opts.AllowedSeats)
+	assert.Equal(t, []string{"b1", "b2"}, d.opts.BlockedSeats)
+
+	// The allowlist has priority, only one of the lists is sent
+	rtbRequest := testEncodeRequest(t, d, request)
+	assert.Equal(t, []any{"s1", "s2"}, rtbRequest["wseat"])
+	assert.NotContains(t, rtbRequest, "bseat")
+
+	rtbRequest = testEncodeRequest(t, newTestDriver(t, nil, WithSourceSeats(nil, []string{"b1"})), request)
+	assert.NotContains(t, rtbRequest, "wseat")
+	assert.Equal(t, []any{"b1"}, rtbRequest["bseat"])
+
+	rtbRequest = testEncodeRequest(t, newTestDriver(t, nil), request)
+	assert.NotContains(t, rtbRequest, "wseat")
+	assert.NotContains(t, rtbRequest, "bseat")
+
+	rtbRequestV3 := BuildRequestV3(request, WithSeats([]string{"s1"}, []string{"b1"}))
+	assert.Equal(t, []string{"s1"}, rtbRequestV3.Seats)
+	assert.Empty(t, rtbRequestV3.BlockedSeats)
+	rtbRequestV3 = BuildRequestV3(request, WithSeats(nil, []string{"b1"}))
+	assert.Equal(t, []string{"b1"}, rtbRequestV3.BlockedSeats)
+}
+
+func TestParseSeats(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		seat    = func(name string, price float64) openrtb.SeatBid {
+			id := name
+			if id == "" {
+				id = "no_seat"
+			}
+			return openrtb.SeatBid{Seat: name, Bid: []openrtb.Bid{
+				{ID: id, ImpID: impID, Price: price, CreativeID: id, AdMarkup: "<div></div>"},
+			}}
+		}
+		seats = []openrtb.SeatBid{seat("s1", 1), seat("s2", 2), seat("b1", 3), seat("", 0.5)}
+	)
+	tests := []struct {
+		name     string
+		allowed  []string
+		blocked  []string
+		rejected map[string]adresponse.RejectionReason
+	}{
+		{
+			name: "any",
+			rejected: map[string]adresponse.RejectionReason{
+				"s1": adresponse.RejectionLostAuction, "s2": adresponse.RejectionLostAuction,
+				"no_seat": adresponse.RejectionLostAuction,
+			},
+		},
+		{
+			name:    "allowed",
+			allowed: []string{"s1"},
+			rejected: map[string]adresponse.RejectionReason{
+				"s2": adresponse.RejectionSeat, "b1": adresponse.RejectionSeat,
+				"no_seat": adresponse.RejectionLostAuction,
+			},
+		},
+		{
+			name:    "blocked",
+			blocked: []string{"b1"},
+			rejected: map[string]adresponse.RejectionReason{
+				"s1": adresponse.RejectionLostAuction, "b1": adresponse.RejectionSeat,
+				"no_seat": adresponse.RejectionLostAuction,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := testParseBids(t, request, seats, WithParseSeats(tt.allowed, tt.blocked))
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.rejected, testRejectionReasons(resp))
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package adsourceopenrtb
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+var errSKAdNInvalidPublicKey = errors.New("invalid SKAdNetwork public key")
+
+// SKAdNRequest is the SKAdNetwork extension of the impression (imp.ext.skadn)
+type SKAdNRequest struct {
+	// Version of the SKAdNetwork supported by the device (deprecated by versions)
+	Version string `json:"version,omitempty"`
+
+	// Versions of the SKAdNetwork supported by the device and the publisher app
+	Versions []string `json:"versions,omitempty"`
+
+	// SourceApp is the App Store ID of the publisher app
+	SourceApp string `json:"sourceapp,omitempty"`
+
+	// SKAdNetIDs of the ad networks declared in the publisher app Info.plist
+	SKAdNetIDs []string `json:"skadnetids,omitempty"`
+
+	// ProductPage supports the custom product pages (1 - yes)
+	ProductPage int `json:"productpage,omitempty"`
+
+	// SKOverlay supports the StoreKit overlay (1 - yes)
+	SKOverlay int `json:"skoverlay,omitempty"`
+}
+
+// SKAdNProvider returns the SKAdNetwork parameters of the application placement
+type SKAdNProvider interface {
+	SKAdN(imp *adtype.Impression) *SKAdNRequest
+}
+
+// SKAdNProviderFunc wrapper of the function to the SKAdNProvider interface
+type SKAdNProviderFunc func(imp *adtype.Impression) *SKAdNRequest
+
+// SKAdN returns the SKAdNetwork parameters of the placement
+func (f SKAdNProviderFunc) SKAdN(imp *adtype.Impression) *SKAdNRequest {
+	return f(imp)
+}
+
+// SKAdNKeys of the ad networks by the lowercase SKAdNetwork ID
+type SKAdNKeys map[string]*ecdsa.PublicKey
+
+// PublicKey returns the key of the ad network or nil
+func (keys SKAdNKeys) PublicKey(network string) *ecdsa.PublicKey {
+	return keys[strings.ToLower(network)]
+}
+
+// ParseSKAdNPublicKey from the PEM or DER encoded ECDSA P-256 public key
+// registered by the ad network
+func ParseSKAdNPublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	key, err := x509.ParsePKIXPublicKey(data)
+	if err != nil {
+		return nil, errors.Join(errSKAdNInvalidPublicKey, err)
+	}
+	pubKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errSKAdNInvalidPublicKey
+	}
+	return pubKey, nil
+}
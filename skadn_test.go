@@ -0,0 +1,38 @@
+package adsourceopenrtb
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSKAdNPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pemKey, err := ParseSKAdNPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(pemKey))
+
+	derKey, err := ParseSKAdNPublicKey(der)
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(derKey))
+
+	_, err = ParseSKAdNPublicKey([]byte("invalid"))
+	assert.ErrorIs(t, err, errSKAdNInvalidPublicKey)
+
+	keys := SKAdNKeys{"example.skadnetwork": derKey}
+	assert.Equal(t, derKey, keys.PublicKey("Example.SKAdNetwork"))
+	assert.Nil(t, keys.PublicKey("other.skadnetwork"))
+}
@@ -0,0 +1,77 @@
+package adsourceopenrtb
+
+import (
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// SkipReason of the request skipped by the source test
+type SkipReason int
+
+// Skip reasons
+const (
+	SkipReasonNone SkipReason = iota
+	SkipReasonErrorBreaker
+	SkipReasonRPSLimited
+	SkipReasonTargetingMismatch
+	SkipReasonFormatFilter
+	SkipReasonOverloaded
+	SkipReasonBudgetThrottled
+	SkipReasonKeyRateLimited
+	SkipReasonSpendCapped
+	SkipReasonBlocklisted
+	SkipReasonPartnerThrottled
+	SkipReasonEmptyDevice
+)
+
+// String name of the skip reason used in the metrics
+func (r SkipReason) String() string {
+	switch r {
+	case SkipReasonErrorBreaker:
+		return "error-breaker"
+	case SkipReasonRPSLimited:
+		return "rps-limited"
+	case SkipReasonTargetingMismatch:
+		return "targeting-mismatch"
+	case SkipReasonFormatFilter:
+		return "format-filter"
+	case SkipReasonOverloaded:
+		return "overloaded"
+	case SkipReasonBudgetThrottled:
+		return "budget-throttled"
+	case SkipReasonKeyRateLimited:
+		return "key-rps-limited"
+	case SkipReasonSpendCapped:
+		return "spend-capped"
+	case SkipReasonBlocklisted:
+		return "blocklisted"
+	case SkipReasonPartnerThrottled:
+		return "partner-throttled"
+	case SkipReasonEmptyDevice:
+		return "empty-device"
+	}
+	return "none"
+}
+
+// skip the request by the reason and count it in the metrics
+func (d *driver) skip(reason SkipReason) (bool, SkipReason) {
+	d.latencyMetrics.IncSkip()
+	d.skipMetric.WithLabelValues(reason.String()).Inc()
+	return false, reason
+}
+
+// hasAllowedFormats returns true if any impression of the request
+// has the format allowed by the source filter and bought by the source
+func (d *driver) hasAllowedFormats(request adtype.BidRequester) bool {
+	imps := request.Impressions()
+	if len(imps) == 0 {
+		return true
+	}
+	for _, imp := range imps {
+		for _, format := range imp.Formats() {
+			if d.testFormat(format) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+)
+
+func TestSkipReasonString(t *testing.T) {
+	names := map[string]bool{}
+	for reason := SkipReasonNone; reason <= SkipReasonEmptyDevice; reason++ {
+		names[reason.String()] = true
+	}
+	assert.Len(t, names, int(SkipReasonEmptyDevice)+1, "the metric names are unique")
+	assert.Equal(t, "none", SkipReason(-1).String())
+	assert.Equal(t, "error-breaker", SkipReasonErrorBreaker.String())
+}
+
+func TestWithReason(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	d := newTestDriver(t, nil, testMetricsRegistry(registry),
+		WithMaxInFlight(1), WithBudgetThrottle(0, 0, 0),
+		testSourceOption(func(source *admodels.RTBSource) {
+			source.RPS = 1
+			source.Options.ErrorsIgnore = 1
+		}))
+	request := newTestRequest(context.Background(), "banner_300x250")
+
+	// Each check skips the request by own reason in the order of the checks
+	d.source.Filter.Secure = types.SecureOnly
+	d.inFlight.Store(1)
+	d.budget.admitRate.Store(math.Float64bits(0))
+	steps := []SkipReason{SkipReasonTargetingMismatch, SkipReasonOverloaded, SkipReasonBudgetThrottled, SkipReasonNone, SkipReasonRPSLimited}
+	for i, expected := range steps {
+		switch i {
+		case 1:
+			d.source.Filter.Secure = types.SecureAny
+		case 2:
+			d.inFlight.Store(0)
+		case 3:
+			d.budget.admitRate.Store(math.Float64bits(1))
+		}
+		ok, reason := d.TestWithReason(request)
+		assert.Equal(t, expected, reason, "step %d", i)
+		assert.Equal(t, expected == SkipReasonNone, ok, "step %d", i)
+	}
+	assert.False(t, d.Test(request))
+
+	assert.Equal(t, map[string]float64{
+		"targeting-mismatch": 1,
+		"overloaded":         1,
+		"budget-throttled":   1,
+		"rps-limited":        2,
+	}, testCounters(t, registry, "adsource_skip_total", "reason"))
+}
@@ -0,0 +1,194 @@
+package adsourceopenrtb
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+)
+
+const (
+	// defaultErrorHistory size of the last errors kept by the driver
+	defaultErrorHistory = 10
+
+	// errorBreakerLimit of the error balance of the error breaker
+	errorBreakerLimit = 1000
+)
+
+// DriverSnapshot of the driver state consistent at the snapshot time,
+// it's safe to expose it in the admin API of the application
+type DriverSnapshot struct {
+	ID             uint64    `json:"id"`
+	Protocol       string    `json:"protocol"`
+	ConfigRevision string    `json:"config_revision"`
+	Time           time.Time `json:"time"`
+
+	// RPSLimit is the effective requests per second limit (0 - unlimited),
+	// RPSBurst and RPSAvailable are the size and the available tokens of the limiter
+	RPSLimit     float64 `json:"rps_limit"`
+	RPSBurst     float64 `json:"rps_burst"`
+	RPSAvailable float64 `json:"rps_available"`
+
+	// InFlight requests of the source
+	InFlight int64 `json:"in_flight"`
+
+	// ErrorBalance of the error breaker (positive if the errors prevail) and the time of the last skip by the breaker
+	ErrorBalance     int32     `json:"error_balance"`
+	BreakerSkippedAt time.Time `json:"breaker_skipped_at,omitzero"`
+
+	// ThrottledUntil time of the back off requested by the partner
+	ThrottledUntil time.Time `json:"throttled_until,omitzero"`
+
+	// Errors of the last bid requests, the newest is the last one
+	Errors []ErrorRecord `json:"errors,omitempty"`
+}
+
+// ErrorRecord of the failed bid request
+type ErrorRecord struct {
+	Time       time.Time `json:"time"`
+	Stage      BidStage  `json:"stage"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Retryable  bool      `json:"retryable,omitempty"`
+	Error      string    `json:"error"`
+}
+
+// Snapshot returns the current state of the driver
+func (d *driver) Snapshot() *DriverSnapshot {
+	now := d.now()
+	snapshot := &DriverSnapshot{
+		ID:             d.ID(),
+		Protocol:       d.source.Protocol,
+		ConfigRevision: d.configRevision,
+		Time:           now,
+		InFlight:       d.inFlight.Load(),
+		ErrorBalance:   d.errorCounter.Balance(),
+		Errors:         d.errorHistory.List(),
+	}
+	snapshot.RPSLimit, snapshot.RPSBurst, snapshot.RPSAvailable = d.rateLimiter.State(now)
+	if skippedAt := d.errorCounter.skippedAt.Load(); skippedAt > 0 {
+		snapshot.BreakerSkippedAt = time.Unix(0, skippedAt)
+	}
+	if until := d.throttledUntil.Load(); until > now.UnixNano() {
+		snapshot.ThrottledUntil = time.Unix(0, until)
+	}
+	return snapshot
+}
+
+// errorBreaker of the source requests with the observable error balance.
+// It follows the probability skip of the errorcounter.ErrorCounter, but keeps
+// the balance as its own state, so the balance reported by the snapshot
+// is the same value the breaker decides on.
+type errorBreaker struct {
+	balance   atomic.Int32
+	skippedAt atomic.Int64
+}
+
+// Inc the error balance
+func (b *errorBreaker) Inc() {
+	b.add(1)
+}
+
+// Dec the error balance
+func (b *errorBreaker) Dec() {
+	b.add(-1)
+}
+
+// add the delta to the balance clamped by the breaker limit
+func (b *errorBreaker) add(delta int32) {
+	for {
+		val := b.balance.Load()
+		next := max(min(val+delta, errorBreakerLimit), -errorBreakerLimit)
+		if next == val || b.balance.CompareAndSwap(val, next) {
+			return
+		}
+	}
+}
+
+// Next returns true if the request is allowed by the breaker
+func (b *errorBreaker) Next(now time.Time) bool {
+	if rand.Float64()*1.03 > b.skipFactor() {
+		return true
+	}
+	b.skippedAt.Store(now.UnixNano())
+	return false
+}
+
+// skipFactor of the requests by the error balance (the sigmoid of the balance share)
+func (b *errorBreaker) skipFactor() float64 {
+	x := float64(b.balance.Load()) / errorBreakerLimit * 7.8
+	return 1.0 / (1.0 + math.Exp(-(x - 10)))
+}
+
+// Balance of the errors and the successful requests
+func (b *errorBreaker) Balance() int32 {
+	return b.balance.Load()
+}
+
+// errorHistory keeps the last errors of the bid requests
+type errorHistory struct {
+	mx      sync.Mutex
+	records []ErrorRecord
+	next    int
+	full    bool
+}
+
+func newErrorHistory(size int) *errorHistory {
+	if size <= 0 {
+		size = defaultErrorHistory
+	}
+	return &errorHistory{records: make([]ErrorRecord, size)}
+}
+
+// Add the error of the bid request
+func (h *errorHistory) Add(now time.Time, err *BidError) {
+	if h == nil || err == nil {
+		return
+	}
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.records[h.next] = ErrorRecord{
+		Time:       now,
+		Stage:      err.Stage,
+		StatusCode: err.StatusCode,
+		Retryable:  err.Retryable,
+		Error:      err.Err.Error(),
+	}
+	if h.next++; h.next == len(h.records) {
+		h.next, h.full = 0, true
+	}
+}
+
+// List of the errors from the oldest to the newest
+func (h *errorHistory) List() []ErrorRecord {
+	if h == nil {
+		return nil
+	}
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if !h.full {
+		return append([]ErrorRecord(nil), h.records[:h.next]...)
+	}
+	return append(append(make([]ErrorRecord, 0, len(h.records)), h.records[h.next:]...), h.records[:h.next]...)
+}
+
+// sourceConfigRevision returns the revision of the driver options or the hash of the source settings
+func sourceConfigRevision(source *admodels.RTBSource, opts *DriverOptions) string {
+	if opts.ConfigRevision != "" {
+		return opts.ConfigRevision
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(source.URL + "\n" + source.Protocol + "\n" +
+		strconv.Itoa(source.RPS) + "\n" + strconv.Itoa(source.Timeout) + "\n"))
+	if source.Config.Data != nil {
+		if data, err := json.Marshal(*source.Config.Data); err == nil {
+			_, _ = hash.Write(data)
+		}
+	}
+	return strconv.FormatUint(hash.Sum64(), 16)
+}
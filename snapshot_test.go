@@ -0,0 +1,63 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorBreakerBalance(t *testing.T) {
+	var breaker errorBreaker
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < errorBreakerLimit; j++ {
+				breaker.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(errorBreakerLimit), breaker.Balance())
+
+	// The balance at the limit skips about 10% of the requests
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	skipped := 0
+	for i := 0; i < 1000; i++ {
+		if !breaker.Next(now) {
+			skipped++
+		}
+	}
+	assert.InDelta(t, 100, skipped, 70)
+	assert.Equal(t, now.UnixNano(), breaker.skippedAt.Load())
+
+	for i := 0; i < 3*errorBreakerLimit; i++ {
+		breaker.Dec()
+	}
+	assert.Equal(t, int32(-errorBreakerLimit), breaker.Balance())
+	for i := 0; i < 1000; i++ {
+		assert.True(t, breaker.Next(now))
+	}
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.Error(t, resp.Error())
+	_ = d.bidError(BidStageLimit, nil, ErrSourceOverloaded)
+
+	snapshot := d.Snapshot()
+	assert.Equal(t, int32(1), snapshot.ErrorBalance)
+	if assert.Len(t, snapshot.Errors, 1, "the limit rejections are not recorded") {
+		assert.Equal(t, BidStageStatus, snapshot.Errors[0].Stage)
+		assert.Equal(t, http.StatusInternalServerError, snapshot.Errors[0].StatusCode)
+	}
+}
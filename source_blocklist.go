@@ -0,0 +1,94 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// SourceBlocklistProvider returns true if the placement (zone) of the impression blocklists the source
+type SourceBlocklistProvider interface {
+	IsSourceBlocked(imp *adtype.Impression, sourceID uint64) bool
+}
+
+// SourceBlocklistProviderFunc wrapper of the function to the SourceBlocklistProvider interface
+type SourceBlocklistProviderFunc func(imp *adtype.Impression, sourceID uint64) bool
+
+// IsSourceBlocked returns true if the placement of the impression blocklists the source
+func (f SourceBlocklistProviderFunc) IsSourceBlocked(imp *adtype.Impression, sourceID uint64) bool {
+	return f(imp, sourceID)
+}
+
+// sourceBlocklist of the zones and the domains excluded from the source and the zones
+// which exclude the source. The blocked impressions are removed from the source requests,
+// the request is skipped if all impressions or the domain are blocked.
+type sourceBlocklist struct {
+	sourceID uint64
+	zones    []uint64
+	domains  []string
+	provider SourceBlocklistProvider
+}
+
+// newSourceBlocklist of the source config (`blocked_zones`, `blocked_domains`) merged with
+// the driver options, nil if there is nothing to block
+func newSourceBlocklist(source *admodels.RTBSource, opts *DriverOptions) *sourceBlocklist {
+	var (
+		zones, cfgZones     []uint64
+		domains, cfgDomains []string
+	)
+	_ = sourceConfigValue(source, sourceConfigBlockedZones, &cfgZones)
+	_ = sourceConfigValue(source, sourceConfigBlockedDomains, &cfgDomains)
+	zones = slices.Concat(opts.BlockedZones, cfgZones)
+	for _, domain := range slices.Concat(opts.BlockedDomains, cfgDomains) {
+		if domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), ".")); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	if len(zones) == 0 && len(domains) == 0 && opts.SourceBlocklistProvider == nil {
+		return nil
+	}
+	slices.Sort(zones)
+	return &sourceBlocklist{
+		sourceID: source.ID,
+		zones:    slices.Compact(zones),
+		domains:  domains,
+		provider: opts.SourceBlocklistProvider,
+	}
+}
+
+// Allow returns true if the request has the domain and any impression allowed for the source
+func (b *sourceBlocklist) Allow(request adtype.BidRequester) bool {
+	if b == nil {
+		return true
+	}
+	if len(b.domains) > 0 {
+		if domain := strings.ToLower(request.DomainName()); domain != "" && hostMatches(domain, b.domains) {
+			return false
+		}
+	}
+	imps := request.Impressions()
+	if len(imps) == 0 {
+		return true
+	}
+	for _, imp := range imps {
+		if b.AllowImp(imp) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowImp returns true if the placement of the impression and the source don't blocklist each other
+func (b *sourceBlocklist) AllowImp(imp *adtype.Impression) bool {
+	if b == nil || imp == nil {
+		return true
+	}
+	if zoneID := uint64(imp.TargetID()); zoneID > 0 {
+		if _, found := slices.BinarySearch(b.zones, zoneID); found {
+			return false
+		}
+	}
+	return b.provider == nil || !b.provider.IsSourceBlocked(imp, b.sourceID)
+}
@@ -0,0 +1,96 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/adquery/bidrequest"
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/udetect"
+)
+
+// newTestZonesRequest returns the bid request of the site with the banner impressions of the zones
+func newTestZonesRequest(domain string, zones ...uint64) *bidrequest.BidRequest {
+	request := newTestRequest(context.Background())
+	request.Site = &udetect.Site{Domain: domain}
+	request.Imps = request.Imps[:0]
+	for _, zone := range zones {
+		imp := &adtype.Impression{
+			ID:          "imp" + strconv.FormatUint(zone, 10),
+			FormatCodes: []string{"banner_300x250"},
+			Target: &testZoneTarget{id: zone, testTarget: testTarget{
+				TargetEmpty: adtype.TargetEmpty{Acc: &admodels.Account{IDval: 1}},
+				codename:    "zone" + strconv.FormatUint(zone, 10),
+			}},
+		}
+		imp.InitFormats(testFormats)
+		request.Imps = append(request.Imps, imp)
+	}
+	return request
+}
+
+// testRequestTagIDs returns the tag IDs of the impressions of the encoded request
+func testRequestTagIDs(t *testing.T, d *driver, request adtype.BidRequester) []string {
+	t.Helper()
+	var tagIDs []string
+	for _, imp := range testEncodeRequest(t, d, request)["imp"].([]any) {
+		tagIDs = append(tagIDs, imp.(map[string]any)["tagid"].(string))
+	}
+	return tagIDs
+}
+
+func TestSourceBlocklist(t *testing.T) {
+	assert.Nil(t, newSourceBlocklist(&admodels.RTBSource{}, &DriverOptions{BlockedDomains: []string{" "}}))
+	assert.True(t, (*sourceBlocklist)(nil).Allow(newTestZonesRequest("example.com", 1)))
+
+	// The blocklists of the options and the source config are merged
+	d := newTestDriver(t, nil, WithBlockedZones([]uint64{2}, " Example.COM. "),
+		testSourceConfig(map[string]any{"blocked_zones": []uint64{3, 2}, "blocked_domains": []string{"bad.org"}}))
+	assert.Equal(t, []uint64{2, 3}, d.blocklist.zones)
+	assert.Equal(t, []string{"example.com", "bad.org"}, d.blocklist.domains)
+
+	tests := []struct {
+		name    string
+		request *bidrequest.BidRequest
+		allow   bool
+		tagIDs  []string
+	}{
+		{name: "domain", request: newTestZonesRequest("example.com", 1)},
+		{name: "subdomain", request: newTestZonesRequest("News.Example.com", 1)},
+		{name: "config_domain", request: newTestZonesRequest("bad.org", 1)},
+		{name: "all_zones", request: newTestZonesRequest("example.org", 2, 3)},
+		{name: "allowed", request: newTestZonesRequest("notexample.com", 1), allow: true, tagIDs: []string{"zone1"}},
+		{name: "some_zones", request: newTestZonesRequest("example.org", 2, 1, 3), allow: true, tagIDs: []string{"zone1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := d.TestWithReason(tt.request)
+			assert.Equal(t, tt.allow, ok)
+			if !tt.allow {
+				assert.Equal(t, SkipReasonBlocklisted, reason)
+				return
+			}
+			// The blocked impressions are removed from the source request
+			assert.Equal(t, tt.tagIDs, testRequestTagIDs(t, d, tt.request))
+		})
+	}
+}
+
+func TestSourceBlocklistProvider(t *testing.T) {
+	d := newTestDriver(t, nil, WithSourceBlocklistProvider(SourceBlocklistProviderFunc(
+		func(imp *adtype.Impression, sourceID uint64) bool {
+			return sourceID == 1 && imp.Target.Codename() == "zone2"
+		},
+	)))
+	request := newTestZonesRequest("example.com", 1, 2)
+	assert.True(t, d.Test(request))
+	assert.Equal(t, []string{"zone1"}, testRequestTagIDs(t, d, request))
+
+	ok, reason := d.TestWithReason(newTestZonesRequest("example.com", 2))
+	assert.False(t, ok)
+	assert.Equal(t, SkipReasonBlocklisted, reason)
+}
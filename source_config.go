@@ -0,0 +1,114 @@
+package adsourceopenrtb
+
+import (
+	"encoding/json"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+)
+
+// Keys of the source config (RTBSource.Config JSON object)
+const (
+	sourceConfigRequestFields  = "request_fields"
+	sourceConfigBidFloors      = "bid_floors"
+	sourceConfigLenient        = "lenient_decoding"
+	sourceConfigPricingModel   = "pricing_model"
+	sourceConfigBlockedZones   = "blocked_zones"
+	sourceConfigBlockedDomains = "blocked_domains"
+	sourceConfigPriceDecimals  = "price_decimals"
+	sourceConfigAllowedSeats   = "allowed_seats"
+	sourceConfigBlockedSeats   = "blocked_seats"
+	sourceConfigCurrency       = "currency"
+	sourceConfigCurrencies     = "currencies"
+	sourceConfigGzip           = "gzip"
+	sourceConfigURLNormalize   = "url_normalization"
+	sourceConfigMediaTypes     = "media_types"
+	sourceConfigEmptyDevice    = "empty_device"
+
+	sourceConfigBlockedCategories = "blocked_categories"
+	sourceConfigBlockedAdvDomains = "blocked_adomains"
+	sourceConfigBlockedApps       = "blocked_apps"
+)
+
+// sourceConfigValue decodes the value of the source config by the key into the target.
+// It returns false if the config or the key is not defined or can't be decoded.
+func sourceConfigValue(source *admodels.RTBSource, key string, target any) bool {
+	if source == nil || source.Config.Data == nil {
+		return false
+	}
+	data, err := json.Marshal(*source.Config.Data)
+	if err != nil {
+		return false
+	}
+	var cfg map[string]json.RawMessage
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return false
+	}
+	raw := cfg[key]
+	return len(raw) > 0 && json.Unmarshal(raw, target) == nil
+}
+
+// sourceFormatBidFloors returns the bid floors per format type from the source config (`bid_floors`
+// with the format type names like `banner`, `native` or `direct`) merged over the driver options
+func sourceFormatBidFloors(source *admodels.RTBSource, opts *DriverOptions) map[types.FormatType]float64 {
+	var (
+		named  map[string]float64
+		floors = make(map[types.FormatType]float64, len(opts.FormatBidFloor))
+	)
+	for formatType, floor := range opts.FormatBidFloor {
+		floors[formatType] = floor
+	}
+	if sourceConfigValue(source, sourceConfigBidFloors, &named) {
+		for name, floor := range named {
+			if formatType := types.FormatTypeByName(name); !formatType.IsInvalid() {
+				floors[formatType] = floor
+			}
+		}
+	}
+	if len(floors) == 0 {
+		return nil
+	}
+	return floors
+}
+
+// sourceLenientDecoding returns true if the lenient response decoding is enabled
+// by the source config (`lenient_decoding`) or the driver options
+func sourceLenientDecoding(source *admodels.RTBSource, opts *DriverOptions) bool {
+	var lenient bool
+	if sourceConfigValue(source, sourceConfigLenient, &lenient) {
+		return lenient
+	}
+	return opts.LenientDecoding
+}
+
+// sourcePricingModel returns the pricing model of the source bids from the source config
+// (`pricing_model` like `CPM`, `CPC` or `CPV`) or the driver options
+func sourcePricingModel(source *admodels.RTBSource, opts *DriverOptions) types.PricingModel {
+	var name string
+	if sourceConfigValue(source, sourceConfigPricingModel, &name) {
+		if model := types.PricingModelByName(name); model != types.PricingModelUndefined {
+			return model
+		}
+	}
+	return opts.PricingModel
+}
+
+// sourcePriceDecimals returns the number of the decimal places of the request prices
+// from the source config (`price_decimals`) or the driver options, 0 if not limited
+func sourcePriceDecimals(source *admodels.RTBSource, opts *DriverOptions) int {
+	var decimals int
+	if sourceConfigValue(source, sourceConfigPriceDecimals, &decimals) {
+		return decimals
+	}
+	return opts.PriceDecimals
+}
+
+// sourceURLNormalization returns the normalization of the site URLs from the source config
+// (`url_normalization` with `max_length` and `strip_query`) or the driver options
+func sourceURLNormalization(source *admodels.RTBSource, opts *DriverOptions) *URLNormalization {
+	var normalization URLNormalization
+	if sourceConfigValue(source, sourceConfigURLNormalize, &normalization) {
+		return &normalization
+	}
+	return opts.URLNormalization
+}
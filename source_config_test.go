@@ -0,0 +1,70 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/billing"
+)
+
+// testSourceConfig sets the source config data
+func testSourceConfig(config map[string]any) testSourceOption {
+	return func(source *admodels.RTBSource) {
+		var data any = config
+		source.Config.Data = &data
+	}
+}
+
+func TestSourceFormatBidFloors(t *testing.T) {
+	source := &admodels.RTBSource{}
+	assert.Nil(t, sourceFormatBidFloors(source, &DriverOptions{}))
+
+	opts := &DriverOptions{FormatBidFloor: map[types.FormatType]float64{
+		types.FormatBannerType: 2,
+		types.FormatNativeType: 0.5,
+	}}
+	testSourceConfig(map[string]any{
+		"bid_floors": map[string]any{"native": 0.1, "direct": 0.01, "unknown": 5},
+	})(source)
+	assert.Equal(t, map[types.FormatType]float64{
+		types.FormatBannerType: 2,
+		types.FormatNativeType: 0.1,
+		types.FormatDirectType: 0.01,
+	}, sourceFormatBidFloors(source, opts))
+	assert.Len(t, opts.FormatBidFloor, 2, "the driver options are not changed")
+
+	// The invalid config is ignored
+	testSourceConfig(map[string]any{"bid_floors": "banner"})(source)
+	assert.Equal(t, opts.FormatBidFloor, sourceFormatBidFloors(source, opts))
+}
+
+func TestFormatBidFloorRequest(t *testing.T) {
+	d := newTestDriver(t, nil,
+		testSourceOption(func(source *admodels.RTBSource) { source.MinBid = billing.MoneyFloat(1.) }),
+		WithSourceFormatBidFloor(map[types.FormatType]float64{types.FormatBannerType: 2}),
+		testSourceConfig(map[string]any{"bid_floors": map[string]any{"native": 0.1}}))
+
+	tests := []struct {
+		name     string
+		format   string
+		impFloor float64
+		expected float64
+	}{
+		{name: "banner", format: "banner_300x250", expected: 2},
+		{name: "native", format: "native", expected: 0.1},
+		{name: "source_min_bid", format: "direct", expected: 1},
+		{name: "impression_floor", format: "native", impFloor: 0.5, expected: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := newTestRequest(context.Background(), tt.format)
+			request.Imps[0].BidFloorCPM = billing.MoneyFloat(tt.impFloor)
+			imp := testEncodeRequest(t, d, request)["imp"].([]any)[0].(map[string]any)
+			assert.Equal(t, tt.expected, imp["bidfloor"])
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package adsourceopenrtb
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWeightInterval     = time.Minute
+	defaultWeightMax          = 1.
+	defaultWeightECPMRef      = 1.
+	sourceWeightSmoothing     = 0.5
+	sourceWeightMinimalSample = 100
+)
+
+// DiscrepancyProvider returns the impressions discrepancy of the source
+// from 0 (no discrepancy) to 1 (the source doesn't count any impression)
+type DiscrepancyProvider interface {
+	Discrepancy(sourceID uint64) float64
+}
+
+// DiscrepancyProviderFunc wrapper of the function to the DiscrepancyProvider interface
+type DiscrepancyProviderFunc func(sourceID uint64) float64
+
+// Discrepancy of the source impressions
+func (f DiscrepancyProviderFunc) Discrepancy(sourceID uint64) float64 {
+	return f(sourceID)
+}
+
+// sourceWeighter computes the weight of the source for the auction scheduler
+// by the fill rate, the win rate, the eCPM of the wins, the timeout rate
+// and the discrepancy. The weight is recomputed once per interval
+// and smoothed with the previous value.
+type sourceWeighter struct {
+	sourceID    uint64
+	interval    time.Duration
+	maxWeight   float64
+	ecpmRef     float64
+	discrepancy DiscrepancyProvider
+	now         func() time.Time
+
+	requests atomic.Int64
+	bids     atomic.Int64
+	wins     atomic.Int64
+	timeouts atomic.Int64
+	// revenue of the wins in micro CPM
+	revenue atomic.Int64
+
+	mx       sync.Mutex
+	calcTime time.Time
+	// score bits of the float64 value from 0 to 1, the negative value means undefined
+	score atomic.Uint64
+}
+
+func newSourceWeighter(sourceID uint64, opts *DriverOptions, now func() time.Time) *sourceWeighter {
+	w := &sourceWeighter{
+		sourceID:    sourceID,
+		interval:    opts.WeightInterval,
+		maxWeight:   opts.WeightMax,
+		ecpmRef:     defaultWeightECPMRef,
+		discrepancy: opts.DiscrepancyProvider,
+		now:         now,
+		calcTime:    now(),
+	}
+	if w.interval <= 0 {
+		w.interval = defaultWeightInterval
+	}
+	if w.maxWeight <= 0 {
+		w.maxWeight = defaultWeightMax
+	}
+	w.score.Store(math.Float64bits(-1))
+	return w
+}
+
+// RecordRequest sent to the source
+func (w *sourceWeighter) RecordRequest(timeout bool) {
+	if w == nil {
+		return
+	}
+	w.requests.Add(1)
+	if timeout {
+		w.timeouts.Add(1)
+	}
+}
+
+// RecordBids received from the source
+func (w *sourceWeighter) RecordBids(count int) {
+	if w != nil && count > 0 {
+		w.bids.Add(int64(count))
+	}
+}
+
+// RecordWin of the source bid with the eCPM price
+func (w *sourceWeighter) RecordWin(ecpm float64) {
+	if w == nil {
+		return
+	}
+	w.wins.Add(1)
+	w.revenue.Add(int64(ecpm * 1e6))
+}
+
+// Weight of the source, the minimal weight is returned until the statistics is collected
+func (w *sourceWeighter) Weight(minimal float64) float64 {
+	if w == nil {
+		return minimal
+	}
+	if now := w.now(); now.Sub(w.lastCalcTime()) >= w.interval {
+		w.recalc(now)
+	}
+	score := math.Float64frombits(w.score.Load())
+	if score < 0 {
+		return minimal
+	}
+	return max(minimal, score*w.maxWeight)
+}
+
+func (w *sourceWeighter) lastCalcTime() time.Time {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return w.calcTime
+}
+
+func (w *sourceWeighter) recalc(now time.Time) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if now.Sub(w.calcTime) < w.interval {
+		return
+	}
+	w.calcTime = now
+
+	requests := w.requests.Load()
+	if requests < sourceWeightMinimalSample {
+		// Keep collecting the statistics of the interval
+		return
+	}
+	var (
+		bids     = float64(w.bids.Swap(0))
+		wins     = float64(w.wins.Swap(0))
+		timeouts = float64(w.timeouts.Swap(0))
+		revenue  = float64(w.revenue.Swap(0)) / 1e6
+		total    = float64(w.requests.Swap(0))
+	)
+	score := w.calcScore(total, bids, wins, timeouts, revenue)
+	if prev := math.Float64frombits(w.score.Load()); prev >= 0 {
+		score = sourceWeightSmoothing*score + (1-sourceWeightSmoothing)*prev
+	}
+	w.score.Store(math.Float64bits(score))
+}
+
+func (w *sourceWeighter) calcScore(requests, bids, wins, timeouts, revenue float64) float64 {
+	fillRate := min(bids/requests, 1)
+	timeoutRate := min(timeouts/requests, 1)
+	winRate := 0.
+	if bids > 0 {
+		winRate = min(wins/bids, 1)
+	}
+	ecpmFactor := 0.
+	if wins > 0 {
+		ecpm := revenue / wins
+		ecpmFactor = ecpm / (ecpm + w.ecpmRef)
+	}
+	discrepancy := 0.
+	if w.discrepancy != nil {
+		discrepancy = min(max(w.discrepancy.Discrepancy(w.sourceID), 0), 1)
+	}
+	return fillRate * (0.5 + 0.5*winRate) * (0.5 + 0.5*ecpmFactor) * (1 - timeoutRate) * (1 - discrepancy)
+}
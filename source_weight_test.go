@@ -0,0 +1,71 @@
+package adsourceopenrtb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+)
+
+func TestSourceWeighter(t *testing.T) {
+	var (
+		now  = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		opts = &DriverOptions{
+			WeightInterval:      time.Minute,
+			WeightMax:           10,
+			DiscrepancyProvider: DiscrepancyProviderFunc(func(uint64) float64 { return 0.2 }),
+		}
+		w = newSourceWeighter(1, opts, func() time.Time { return now })
+	)
+	record := func(requests, timeouts, bids, wins int) {
+		for i := 0; i < requests; i++ {
+			w.RecordRequest(i < timeouts)
+		}
+		w.RecordBids(bids)
+		for i := 0; i < wins; i++ {
+			w.RecordWin(1)
+		}
+	}
+
+	// The minimal weight is used until the statistics of the interval is collected
+	record(100, 10, 50, 25)
+	assert.Equal(t, 0.5, w.Weight(0.5))
+
+	// fill 0.5 * win 0.75 * eCPM 0.75 * timeouts 0.9 * discrepancy 0.8
+	now = now.Add(time.Minute)
+	assert.InDelta(t, 2.025, w.Weight(0.5), 1e-9)
+
+	// The weight is kept until the minimal sample of the requests
+	record(50, 0, 0, 0)
+	now = now.Add(time.Minute)
+	assert.InDelta(t, 2.025, w.Weight(0.5), 1e-9)
+
+	// The new score is smoothed with the previous one
+	record(50, 0, 0, 0)
+	now = now.Add(time.Minute)
+	assert.InDelta(t, 1.0125, w.Weight(0.5), 1e-9)
+	assert.Equal(t, 1.5, w.Weight(1.5), "the weight isn't lower than the minimal")
+
+	var disabled *sourceWeighter
+	disabled.RecordRequest(true)
+	disabled.RecordBids(1)
+	disabled.RecordWin(1)
+	assert.Equal(t, 0.5, disabled.Weight(0.5))
+}
+
+func TestDynamicWeight(t *testing.T) {
+	minimal := testSourceOption(func(source *admodels.RTBSource) { source.MinimalWeight = 0.3 })
+
+	d := newTestDriver(t, nil, minimal)
+	assert.Nil(t, d.weighter)
+	assert.Equal(t, 0.3, d.Weight())
+
+	d = newTestDriver(t, nil, minimal, WithDynamicWeight(0, 0, nil))
+	if assert.NotNil(t, d.weighter) {
+		assert.Equal(t, defaultWeightInterval, d.weighter.interval)
+		assert.Equal(t, defaultWeightMax, d.weighter.maxWeight)
+	}
+	assert.Equal(t, 0.3, d.Weight())
+}
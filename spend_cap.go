@@ -0,0 +1,93 @@
+package adsourceopenrtb
+
+import (
+	"sync"
+	"time"
+)
+
+// SpendInfo of the source in the current hour and day (system currency)
+type SpendInfo struct {
+	Hourly    float64 `json:"hourly"`
+	HourlyCap float64 `json:"hourly_cap,omitempty"`
+	Daily     float64 `json:"daily"`
+	DailyCap  float64 `json:"daily_cap,omitempty"`
+}
+
+// IsCapped returns true if any cap of the source spend is reached
+func (info SpendInfo) IsCapped() bool {
+	return (info.HourlyCap > 0 && info.Hourly >= info.HourlyCap) ||
+		(info.DailyCap > 0 && info.Daily >= info.DailyCap)
+}
+
+// spendCap accumulates the spend of the won bids of the source and stops the bidding
+// once the hourly or the daily cap is reached. The spend is reset at the start
+// of each hour and day in the location of the caps.
+type spendCap struct {
+	mx        sync.Mutex
+	hourlyCap float64
+	dailyCap  float64
+	location  *time.Location
+
+	hour   time.Time
+	day    time.Time
+	hourly float64
+	daily  float64
+}
+
+func newSpendCap(hourlyCap, dailyCap float64, location *time.Location) *spendCap {
+	if hourlyCap <= 0 && dailyCap <= 0 {
+		return nil
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &spendCap{hourlyCap: hourlyCap, dailyCap: dailyCap, location: location}
+}
+
+// Allow returns true if the spend of the source is under the caps
+func (c *spendCap) Allow(now time.Time) bool {
+	if c == nil {
+		return true
+	}
+	return !c.Info(now).IsCapped()
+}
+
+// Add the spend of the won bid
+func (c *spendCap) Add(now time.Time, amount float64) {
+	if c == nil || amount <= 0 {
+		return
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.reset(now)
+	c.hourly += amount
+	c.daily += amount
+}
+
+// Info returns the spend of the current hour and day with the caps
+func (c *spendCap) Info(now time.Time) SpendInfo {
+	if c == nil {
+		return SpendInfo{}
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.reset(now)
+	return SpendInfo{
+		Hourly:    c.hourly,
+		HourlyCap: c.hourlyCap,
+		Daily:     c.daily,
+		DailyCap:  c.dailyCap,
+	}
+}
+
+// reset the spend of the passed hour and day
+func (c *spendCap) reset(now time.Time) {
+	now = now.In(c.location)
+	year, month, day := now.Date()
+	if hour := time.Date(year, month, day, now.Hour(), 0, 0, 0, c.location); hour.After(c.hour) {
+		c.hour, c.hourly = hour, 0
+	}
+	if day := time.Date(year, month, day, 0, 0, 0, 0, c.location); day.After(c.day) {
+		c.day, c.daily = day, 0
+	}
+}
@@ -0,0 +1,119 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+	"github.com/geniusrabbit/adcorelib/adtype"
+	"github.com/geniusrabbit/adcorelib/billing"
+	"github.com/geniusrabbit/adcorelib/eventtraking/eventstream"
+)
+
+func TestSpendCap(t *testing.T) {
+	assert.Nil(t, newSpendCap(0, 0, nil))
+	assert.True(t, (*spendCap)(nil).Allow(time.Now()))
+	assert.Equal(t, SpendInfo{}, (*spendCap)(nil).Info(time.Now()))
+
+	var (
+		location = time.FixedZone("UTC+3", 3*60*60)
+		now      = time.Date(2026, 1, 1, 22, 30, 0, 0, location)
+		caps     = newSpendCap(2, 3, location)
+	)
+	caps.Add(now, 1.5)
+	caps.Add(now, -1)
+	assert.Equal(t, SpendInfo{Hourly: 1.5, HourlyCap: 2, Daily: 1.5, DailyCap: 3}, caps.Info(now))
+	assert.True(t, caps.Allow(now))
+
+	// The hourly cap stops the bidding until the next hour
+	caps.Add(now.Add(10*time.Minute), 0.5)
+	assert.False(t, caps.Allow(now.Add(20*time.Minute)))
+	assert.True(t, caps.Allow(now.Add(30*time.Minute)))
+	assert.Equal(t, SpendInfo{HourlyCap: 2, Daily: 2, DailyCap: 3}, caps.Info(now.Add(30*time.Minute)))
+
+	// The daily cap stops the bidding until the next day in the location of the caps
+	caps.Add(now.Add(40*time.Minute), 1)
+	assert.False(t, caps.Allow(now.Add(time.Hour)))
+	assert.False(t, caps.Allow(now.Add(89*time.Minute)), "the day starts at 21:00 UTC")
+	assert.Equal(t, SpendInfo{HourlyCap: 2, DailyCap: 3}, caps.Info(now.Add(90*time.Minute)))
+}
+
+func TestDriverSpendCap(t *testing.T) {
+	var (
+		registry = prometheus.NewRegistry()
+		now      = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		clock    = DriverOption(func(opts *DriverOptions) { opts.Clock = func() time.Time { return now } })
+		handler  = func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(testBidResponse(t, data, 2))
+		}
+		d   = newTestDriver(t, handler, WithSpendCaps(0, 0.003, nil), clock, testMetricsRegistry(registry))
+		ctx = eventstream.WithStream(context.Background(), &testEventStream{})
+	)
+	win := func() float64 {
+		response := d.Bid(newTestRequest(ctx, "banner_300x250"))
+		if !assert.NoError(t, response.Error()) || !assert.Len(t, response.Ads(), 1) {
+			t.FailNow()
+		}
+		d.ProcessResponseItem(response, nil)
+		return response.Ads()[0].(adtype.ResponseItem).FinalPrice(adtype.ActionImpression).Float64()
+	}
+
+	price := win()
+	if !assert.Greater(t, price, 0.) {
+		return
+	}
+	assert.Equal(t, SpendInfo{Hourly: price, Daily: price, DailyCap: 0.003}, d.Spend())
+	assert.Equal(t, map[string]float64{"hourly": price, "daily": price}, testSpendGauges(t, registry))
+	assert.True(t, d.Test(newTestRequest(ctx, "banner_300x250")))
+
+	// The won bids above the cap stop the bidding until the next day
+	for d.Spend().Daily < 0.003 {
+		win()
+	}
+	ok, reason := d.TestWithReason(newTestRequest(ctx, "banner_300x250"))
+	assert.False(t, ok)
+	assert.Equal(t, SkipReasonSpendCapped, reason)
+
+	now = now.Add(12 * time.Hour)
+	assert.True(t, d.Test(newTestRequest(ctx, "banner_300x250")))
+	assert.NotNil(t, d.Metrics())
+	assert.Equal(t, map[string]float64{"hourly": 0, "daily": 0}, testSpendGauges(t, registry),
+		"the reset of the period is observed by the metrics")
+
+	// The daily budget of the source is the default daily cap
+	d = newTestDriver(t, handler, testSourceOption(func(source *admodels.RTBSource) {
+		source.DailyBudget = billing.MoneyFloat(5.)
+	}))
+	assert.Equal(t, 5., d.Spend().DailyCap)
+	assert.Nil(t, newTestDriver(t, handler).spend, "the spend is not tracked without the caps")
+}
+
+// testSpendGauges returns the values of the spend metric by the period
+func testSpendGauges(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	gauges := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "adsource_spend" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == "period" {
+					gauges[pair.GetValue()] = metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	return gauges
+}
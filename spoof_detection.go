@@ -0,0 +1,186 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bsm/openrtb"
+	"go.uber.org/zap"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// SpoofMode of the advertiser domain and bundle spoof detection
+type SpoofMode int
+
+// Spoof detection modes
+const (
+	// SpoofOff disables the spoof detection
+	SpoofOff SpoofMode = iota
+	// SpoofReport reports the suspicious bids but keeps them
+	SpoofReport
+	// SpoofQuarantine reports and removes the suspicious bids and the bids of the quarantined seats
+	SpoofQuarantine
+)
+
+// SpoofKind of the suspicious bid
+type SpoofKind string
+
+// Spoof kinds
+const (
+	SpoofNone SpoofKind = ""
+	// SpoofCreativeMismatch is the creative declared with another adomain or bundle before
+	SpoofCreativeMismatch SpoofKind = "creative_mismatch"
+	// SpoofDomainBurst is the seat which introduces too many new adomains in the window
+	SpoofDomainBurst SpoofKind = "domain_burst"
+	// SpoofQuarantined is the bid of the seat quarantined after the burst
+	SpoofQuarantined SpoofKind = "quarantined"
+)
+
+// Defaults of the spoof detection
+const (
+	defaultSpoofWindow        = 10 * time.Minute
+	defaultSpoofMaxNewDomains = 50
+	defaultSpoofQuarantine    = time.Hour
+	spoofCreativeTTL          = 24 * time.Hour
+	spoofMaxCreatives         = 100_000
+	spoofMaxSeatDomains       = 10_000
+)
+
+type spoofCreative struct {
+	identity string
+	seenAt   time.Time
+}
+
+type spoofSeat struct {
+	// domains known for the seat, the first window of the seat learns them without counting
+	domains      map[string]struct{}
+	learnedUntil time.Time
+
+	windowStart      time.Time
+	newDomains       int
+	quarantinedUntil time.Time
+}
+
+// spoofDetector cross-checks the adomain and the bundle declared by the creatives
+// across the repeated bids and tracks the new adomains introduced by the seats
+type spoofDetector struct {
+	mx            sync.Mutex
+	window        time.Duration
+	maxNewDomains int
+	quarantine    time.Duration
+	creatives     map[string]spoofCreative
+	seats         map[string]*spoofSeat
+}
+
+func newSpoofDetector(window time.Duration, maxNewDomains int, quarantine time.Duration) *spoofDetector {
+	if window <= 0 {
+		window = defaultSpoofWindow
+	}
+	if maxNewDomains <= 0 {
+		maxNewDomains = defaultSpoofMaxNewDomains
+	}
+	if quarantine <= 0 {
+		quarantine = defaultSpoofQuarantine
+	}
+	return &spoofDetector{
+		window:        window,
+		maxNewDomains: maxNewDomains,
+		quarantine:    quarantine,
+		creatives:     map[string]spoofCreative{},
+		seats:         map[string]*spoofSeat{},
+	}
+}
+
+// Check the bid of the seat and returns the kind of the anomaly or SpoofNone
+func (d *spoofDetector) Check(seatName string, bid *openrtb.Bid, now time.Time) SpoofKind {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	seat := d.seats[seatName]
+	if seat == nil {
+		seat = &spoofSeat{domains: map[string]struct{}{}, learnedUntil: now.Add(d.window), windowStart: now}
+		d.seats[seatName] = seat
+	}
+	if now.Before(seat.quarantinedUntil) {
+		return SpoofQuarantined
+	}
+
+	kind := SpoofNone
+	if bid.CreativeID != "" {
+		key := seatName + "\x00" + bid.CreativeID
+		identity := bidIdentity(bid)
+		if prev, ok := d.creatives[key]; ok && now.Sub(prev.seenAt) < spoofCreativeTTL && prev.identity != identity {
+			kind = SpoofCreativeMismatch
+		} else {
+			if len(d.creatives) >= spoofMaxCreatives {
+				clear(d.creatives)
+			}
+			d.creatives[key] = spoofCreative{identity: identity, seenAt: now}
+		}
+	}
+
+	if now.Sub(seat.windowStart) >= d.window {
+		seat.windowStart, seat.newDomains = now, 0
+	}
+	for _, domain := range bid.AdvDomain {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if _, ok := seat.domains[domain]; ok || domain == "" {
+			continue
+		}
+		if len(seat.domains) >= spoofMaxSeatDomains {
+			clear(seat.domains)
+		}
+		seat.domains[domain] = struct{}{}
+		if now.Before(seat.learnedUntil) {
+			continue
+		}
+		if seat.newDomains++; seat.newDomains > d.maxNewDomains {
+			seat.quarantinedUntil = now.Add(d.quarantine)
+			seat.newDomains = 0
+			kind = SpoofDomainBurst
+		}
+	}
+	return kind
+}
+
+// bidIdentity of the advertiser declared by the bid
+func bidIdentity(bid *openrtb.Bid) string {
+	domains := make([]string, 0, len(bid.AdvDomain))
+	for _, domain := range bid.AdvDomain {
+		domains = append(domains, strings.ToLower(strings.TrimSpace(domain)))
+	}
+	slices.Sort(domains)
+	return strings.Join(domains, ",") + "|" + strings.ToLower(bid.Bundle)
+}
+
+// detectSpoofBids checks the advertiser domains and bundles of the response bids,
+// reports the suspicious bids and removes them in the quarantine mode
+func detectSpoofBids(request adtype.BidRequester, bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) {
+	if opts.SpoofMode == SpoofOff || opts.SpoofCheck == nil {
+		return
+	}
+	filterBids(bidResp, func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		kind := opts.SpoofCheck(seat.Seat, bid)
+		if kind == SpoofNone {
+			return true
+		}
+		opts.logger(request.Context()).Warn("bid advertiser looks spoofed",
+			zap.String("trace_id", RequestTraceID(request)),
+			zap.Uint64("source_id", opts.SourceID),
+			zap.String("seat", seat.Seat),
+			zap.String("bid_id", bid.ID),
+			zap.String("crid", bid.CreativeID),
+			zap.Strings("adomain", bid.AdvDomain),
+			zap.String("spoof", string(kind)))
+		if opts.SpoofMode != SpoofQuarantine {
+			return true
+		}
+		rejections.Add(seat, bid, adresponse.RejectionSpoof, string(kind))
+		return false
+	})
+}
@@ -0,0 +1,109 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+func TestSpoofDetectorCreativeMismatch(t *testing.T) {
+	var (
+		detector = newSpoofDetector(time.Minute, 100, time.Hour)
+		now      = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	)
+	bid := func(crid, bundle string, domains ...string) *openrtb.Bid {
+		return &openrtb.Bid{ID: "1", CreativeID: crid, Bundle: bundle, AdvDomain: domains}
+	}
+
+	assert.Equal(t, SpoofNone, detector.Check("seat1", bid("c1", "com.app", "a.com", "b.com"), now))
+	assert.Equal(t, SpoofNone, detector.Check("seat1", bid("c1", "COM.APP", "B.com", " a.com"), now), "the same identity")
+	assert.Equal(t, SpoofCreativeMismatch, detector.Check("seat1", bid("c1", "com.app", "c.com"), now))
+	assert.Equal(t, SpoofCreativeMismatch, detector.Check("seat1", bid("c1", "com.other", "a.com", "b.com"), now))
+	assert.Equal(t, SpoofNone, detector.Check("seat2", bid("c1", "com.app", "c.com"), now), "the creative IDs are per seat")
+	assert.Equal(t, SpoofNone, detector.Check("seat1", bid("", "", "d.com"), now), "the bid without the creative ID")
+
+	// The creative identity is forgotten after the TTL
+	now = now.Add(spoofCreativeTTL)
+	assert.Equal(t, SpoofNone, detector.Check("seat1", bid("c1", "com.app", "c.com"), now))
+	assert.Equal(t, SpoofCreativeMismatch, detector.Check("seat1", bid("c1", "com.app", "a.com"), now))
+}
+
+func TestSpoofDetectorDomainBurst(t *testing.T) {
+	var (
+		detector = newSpoofDetector(time.Minute, 2, time.Hour)
+		now      = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	)
+	check := func(domain string) SpoofKind {
+		return detector.Check("seat1", &openrtb.Bid{ID: "1", AdvDomain: []string{domain}}, now)
+	}
+
+	// The domains of the first window are learned without counting
+	for _, domain := range []string{"a.com", "b.com", "c.com", "d.com"} {
+		assert.Equal(t, SpoofNone, check(domain))
+	}
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, SpoofNone, check("a.com"), "the known domain")
+	assert.Equal(t, SpoofNone, check("e.com"))
+	assert.Equal(t, SpoofNone, check("f.com"))
+	assert.Equal(t, SpoofDomainBurst, check("g.com"))
+	assert.Equal(t, SpoofQuarantined, check("a.com"))
+	assert.Equal(t, SpoofNone, detector.Check("seat2", &openrtb.Bid{ID: "1", AdvDomain: []string{"a.com"}}, now),
+		"the quarantine is per seat")
+
+	// The new domains are counted per window after the quarantine
+	now = now.Add(time.Hour)
+	assert.Equal(t, SpoofNone, check("h.com"))
+	assert.Equal(t, SpoofNone, check("i.com"))
+	now = now.Add(time.Minute)
+	assert.Equal(t, SpoofNone, check("j.com"))
+	assert.Equal(t, SpoofNone, check("k.com"))
+}
+
+func TestSpoofDetection(t *testing.T) {
+	for _, test := range []struct {
+		mode SpoofMode
+		ads  int
+	}{
+		{mode: SpoofReport, ads: 1},
+		{mode: SpoofQuarantine, ads: 0},
+	} {
+		var requests atomic.Int32
+		d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			var resp openrtb.BidResponse
+			_ = json.Unmarshal(testBidResponse(t, data, 1), &resp)
+			// The same creative is declared with the other advertiser by the second response
+			resp.SeatBid[0].Seat = "seat1"
+			resp.SeatBid[0].Bid[0].AdvDomain = []string{"a.com"}
+			if requests.Add(1) > 1 {
+				resp.SeatBid[0].Bid[0].AdvDomain = []string{"b.com"}
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		}, WithSpoofDetection(test.mode, time.Minute, 10, time.Hour))
+
+		resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+		assert.NoError(t, resp.Error())
+		assert.Len(t, resp.Ads(), 1)
+
+		// The quarantined bid is rejected and reported by the response
+		resp = d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+		assert.Len(t, resp.Ads(), test.ads, "mode %d", test.mode)
+		if test.mode == SpoofQuarantine {
+			bidResp, _ := resp.(*adresponse.BidResponse)
+			if assert.NotNil(t, bidResp) && assert.Len(t, bidResp.Rejections(), 1) {
+				assert.Equal(t, adresponse.RejectionSpoof, bidResp.Rejections()[0].Reason)
+				assert.Equal(t, string(SpoofCreativeMismatch), bidResp.Rejections()[0].Message)
+			}
+		}
+	}
+}
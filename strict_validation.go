@@ -0,0 +1,76 @@
+package adsourceopenrtb
+
+import (
+	"context"
+
+	"github.com/bsm/openrtb"
+	"go.uber.org/zap"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+// StrictValidationMode of the response bids
+type StrictValidationMode int
+
+// Strict validation modes
+const (
+	// StrictValidationOff disables the strict validation
+	StrictValidationOff StrictValidationMode = iota
+	// StrictValidationReport reports the violations but keeps the bids
+	StrictValidationReport
+	// StrictValidationDrop reports the violations and removes the invalid bids
+	StrictValidationDrop
+)
+
+// ViolationReporter receives the OpenRTB specification violations of the source bids
+type ViolationReporter interface {
+	ReportViolations(ctx context.Context, sourceID uint64, bid *openrtb.Bid, violations []adresponse.BidViolation)
+}
+
+// ViolationReporterFunc wrapper of the function to the ViolationReporter interface
+type ViolationReporterFunc func(ctx context.Context, sourceID uint64, bid *openrtb.Bid, violations []adresponse.BidViolation)
+
+// ReportViolations of the source bid
+func (f ViolationReporterFunc) ReportViolations(ctx context.Context, sourceID uint64, bid *openrtb.Bid, violations []adresponse.BidViolation) {
+	f(ctx, sourceID, bid, violations)
+}
+
+// strictValidate checks the response bids by the specification,
+// reports the violations and removes invalid bids in the drop mode
+func strictValidate(request adtype.BidRequester, bidResp *openrtb.BidResponse, rejections *adresponse.BidRejections, opts *ParseOptions) {
+	if opts.StrictValidation == StrictValidationOff {
+		return
+	}
+	codec := adresponse.ImpIDCodecOrDefault(opts.ImpIDCodec)
+	filterBids(bidResp, func(seat *openrtb.SeatBid, bid *openrtb.Bid) bool {
+		_, format := codec.Decode(request, bid.ImpID)
+		violations := adresponse.StrictValidateBid(bid, format)
+		violations = append(violations, adresponse.ValidateBidSKAdN(bid, opts.SKAdNKeys.PublicKey)...)
+		if len(violations) == 0 {
+			return true
+		}
+		opts.logger(request.Context()).Warn("bid violates OpenRTB specification",
+			zap.String("trace_id", RequestTraceID(request)),
+			zap.Uint64("source_id", opts.SourceID),
+			zap.String("bid_id", bid.ID),
+			zap.Errors("violations", violationErrors(violations)))
+		if opts.ViolationReporter != nil {
+			opts.ViolationReporter.ReportViolations(request.Context(), opts.SourceID, bid, violations)
+		}
+		if opts.StrictValidation != StrictValidationDrop {
+			return true
+		}
+		rejections.Add(seat, bid, adresponse.RejectionInvalid, violations[0].Error())
+		return false
+	})
+}
+
+func violationErrors(violations []adresponse.BidViolation) []error {
+	errs := make([]error, 0, len(violations))
+	for _, v := range violations {
+		errs = append(errs, v)
+	}
+	return errs
+}
@@ -0,0 +1,53 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bsm/openrtb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adsource-openrtb/adresponse"
+)
+
+func TestStrictValidation(t *testing.T) {
+	var (
+		request = newTestRequest(context.Background(), "banner_300x250")
+		impID   = BuildRequestV2(request).Imp[0].ID
+		seats   = []openrtb.SeatBid{{Bid: []openrtb.Bid{
+			{ID: "valid", ImpID: impID, Price: 1, CreativeID: "c1", W: 300, H: 250, AdMarkup: "<div></div>"},
+			{ID: "invalid", ImpID: impID, Price: 1, W: 300, H: 250, AdMarkup: "<div></div>"},
+		}}}
+		reported []string
+		reporter = ViolationReporterFunc(func(_ context.Context, sourceID uint64, bid *openrtb.Bid, violations []adresponse.BidViolation) {
+			assert.Equal(t, uint64(1), sourceID)
+			for _, violation := range violations {
+				reported = append(reported, bid.ID+":"+violation.Field)
+			}
+		})
+	)
+
+	resp, err := testParseBids(t, request, seats, WithParseSourceID(1))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"valid", "invalid"}, testResponseBids(resp))
+	}
+
+	// The violations are reported but the bids are kept
+	resp, err = testParseBids(t, request, seats, WithParseSourceID(1),
+		WithParseStrictValidation(StrictValidationReport, reporter))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"valid", "invalid"}, testResponseBids(resp))
+		assert.Equal(t, []string{"invalid:crid"}, reported)
+	}
+
+	// The invalid bids are dropped into the rejection report
+	reported = nil
+	resp, err = testParseBids(t, request, seats, WithParseSourceID(1),
+		WithParseStrictValidation(StrictValidationDrop, reporter))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"valid"}, testResponseBids(resp))
+		assert.Equal(t, []string{"invalid:crid"}, reported)
+		assert.Equal(t, map[string]adresponse.RejectionReason{"invalid": adresponse.RejectionInvalid},
+			testRejectionReasons(resp))
+	}
+}
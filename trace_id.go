@@ -0,0 +1,72 @@
+package adsourceopenrtb
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/demdxx/gocast/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+const (
+	// RequestKeyTraceID of the trace ID propagated from the upstream systems in the request values
+	RequestKeyTraceID = "trace_id"
+
+	// defaultTraceIDHeader of the outbound requests
+	defaultTraceIDHeader = "X-Trace-Id"
+)
+
+// traceID of the auction request
+func (d *driver) traceID(request adtype.BidRequester) string {
+	return RequestTraceID(request)
+}
+
+// RequestTraceID of the auction propagated in the request values or generated from the auction ID.
+// The random ID is generated only if the request has no identifiers at all.
+func RequestTraceID(request adtype.BidRequester) string {
+	if id := gocast.Str(request.Get(RequestKeyTraceID)); id != "" {
+		return id
+	}
+	if id := request.AuctionID(); id != "" {
+		return id
+	}
+	if id := request.ID(); id != "" {
+		return id
+	}
+	var buf [16]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+// traceIDHeader of the outbound requests
+func (d *driver) traceIDHeader() string {
+	if d.opts.TraceIDHeader != "" {
+		return d.opts.TraceIDHeader
+	}
+	return defaultTraceIDHeader
+}
+
+// requestLogger returns the logger with the trace ID of the request
+func (d *driver) requestLogger(request adtype.BidRequester) *zap.Logger {
+	return d.logger(request.Context()).With(zap.String("trace_id", d.traceID(request)))
+}
+
+// responseLogger returns the logger with the trace ID of the response request
+func (d *driver) responseLogger(response adtype.Response) *zap.Logger {
+	if request := response.Request(); request != nil {
+		return d.requestLogger(request)
+	}
+	return d.logger(response.Context())
+}
+
+// observeWithTrace observes the value with the trace ID exemplar if the metric supports it
+func observeWithTrace(metric prometheus.Observer, value float64, traceID string) {
+	if eo, ok := metric.(prometheus.ExemplarObserver); ok && traceID != "" {
+		eo.ObserveWithExemplar(value, prometheus.Labels{"trace_id": traceID})
+		return
+	}
+	metric.Observe(value)
+}
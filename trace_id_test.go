@@ -0,0 +1,64 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTraceID(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	assert.Equal(t, "auction1", RequestTraceID(request))
+
+	request.Set(RequestKeyTraceID, "upstream-trace")
+	assert.Equal(t, "upstream-trace", RequestTraceID(request))
+
+	request = newTestRequest(context.Background(), "banner_300x250")
+	request.IDVal = ""
+	traceID := RequestTraceID(request)
+	assert.Regexp(t, "^[0-9a-f]{32}$", traceID)
+	assert.NotEqual(t, traceID, RequestTraceID(request))
+}
+
+func TestTraceIDHeader(t *testing.T) {
+	var headers []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Get("X-Trace-Id")+"|"+r.Header.Get("X-Auction"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.Set(RequestKeyTraceID, "upstream-trace")
+
+	_ = newTestDriver(t, handler).Bid(request)
+	_ = newTestDriver(t, handler, WithTraceIDHeader("X-Auction")).Bid(request)
+	assert.Equal(t, []string{"upstream-trace|", "|upstream-trace"}, headers)
+}
+
+func TestObserveWithTrace(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_latency"})
+	registry.MustRegister(histogram)
+
+	observeWithTrace(histogram, 0.1, "trace1")
+	observeWithTrace(histogram, 0.2, "")
+
+	families, err := registry.Gather()
+	if !assert.NoError(t, err) || !assert.Len(t, families, 1) {
+		return
+	}
+	metric := families[0].GetMetric()[0].GetHistogram()
+	assert.Equal(t, uint64(2), metric.GetSampleCount())
+
+	var exemplars []string
+	for _, bucket := range metric.GetBucket() {
+		if exemplar := bucket.GetExemplar(); exemplar != nil {
+			for _, label := range exemplar.GetLabel() {
+				exemplars = append(exemplars, label.GetName()+"="+label.GetValue())
+			}
+		}
+	}
+	assert.Equal(t, []string{"trace_id=trace1"}, exemplars)
+}
@@ -19,7 +19,10 @@ const (
 
 // Errors set
 var (
-	ErrResponseAreNotSecure  = errors.New("response are not secure")
-	ErrInvalidResponseStatus = errors.New("invalid response status")
-	ErrResponseNoBid         = adtype.ErrResponseNoBid
+	ErrResponseAreNotSecure     = errors.New("response are not secure")
+	ErrInvalidResponseStatus    = errors.New("invalid response status")
+	ErrResponseCurrencyMismatch = errors.New("response currency mismatch")
+	ErrSourceOverloaded         = errors.New("source overloaded")
+	ErrSourceThrottled          = errors.New("source throttled")
+	ErrResponseNoBid            = adtype.ErrResponseNoBid
 )
@@ -0,0 +1,33 @@
+package adsourceopenrtb
+
+import (
+	"context"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// UserFrequency contains the user frequency and recency data
+// which is sent to the source in `user.ext.frequency`
+type UserFrequency struct {
+	// Impressions of the source seen by the user
+	Impressions int `json:"imps"`
+
+	// Campaigns impressions seen by the user by campaign ID
+	Campaigns map[string]int `json:"campaigns,omitempty"`
+
+	// Recency in seconds since the last impression of the source
+	Recency int64 `json:"recency,omitempty"`
+}
+
+// FrequencyProvider returns the user frequency data for the source
+type FrequencyProvider interface {
+	UserFrequency(ctx context.Context, request adtype.BidRequester, sourceID uint64) *UserFrequency
+}
+
+// FrequencyProviderFunc wrapper of the function to the FrequencyProvider interface
+type FrequencyProviderFunc func(ctx context.Context, request adtype.BidRequester, sourceID uint64) *UserFrequency
+
+// UserFrequency returns the user frequency data for the source
+func (f FrequencyProviderFunc) UserFrequency(ctx context.Context, request adtype.BidRequester, sourceID uint64) *UserFrequency {
+	return f(ctx, request, sourceID)
+}
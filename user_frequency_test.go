@@ -0,0 +1,41 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+func TestUserFrequency(t *testing.T) {
+	var sourceIDs []uint64
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {},
+		DriverOption(func(opts *DriverOptions) {
+			opts.FrequencyProvider = FrequencyProviderFunc(func(_ context.Context, request adtype.BidRequester, sourceID uint64) *UserFrequency {
+				sourceIDs = append(sourceIDs, sourceID)
+				if request.ID() != "auction1" {
+					return nil
+				}
+				return &UserFrequency{Impressions: 3, Campaigns: map[string]int{"c1": 2}, Recency: 60}
+			})
+		}))
+
+	rtbRequest := testEncodeRequest(t, d, newTestRequest(context.Background(), "banner_300x250"))
+	assert.Equal(t, map[string]any{
+		"imps":      3.,
+		"campaigns": map[string]any{"c1": 2.},
+		"recency":   60.,
+	}, rtbRequest["user"].(map[string]any)["ext"].(map[string]any)["frequency"])
+	assert.Equal(t, []uint64{1}, sourceIDs)
+
+	// The users without the frequency data are sent without the extension
+	request := newTestRequest(context.Background(), "banner_300x250")
+	request.IDVal = "auction2"
+	rtbRequest = testEncodeRequest(t, d, request)
+	if user, _ := rtbRequest["user"].(map[string]any); user != nil {
+		assert.NotContains(t, user, "ext")
+	}
+}
@@ -0,0 +1,60 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"time"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// AudienceSegments resolved by the external data provider (DMP)
+type AudienceSegments struct {
+	// Provider name of the data (`user.data.name`)
+	Provider string
+
+	// Taxonomy ID of the segments (`user.data.ext.segtax`)
+	Taxonomy int
+
+	Segments []AudienceSegment
+}
+
+// AudienceSegment of the user
+type AudienceSegment struct {
+	ID    string
+	Name  string
+	Value string
+}
+
+// SegmentProvider resolves the audience segments of the user
+type SegmentProvider interface {
+	UserSegments(ctx context.Context, request adtype.BidRequester) ([]AudienceSegments, error)
+}
+
+// resolveUserSegments with timeout protection.
+// Returns nil if the provider did not respond in time or failed.
+func resolveUserSegments(ctx context.Context, provider SegmentProvider, request adtype.BidRequester, timeout time.Duration) []AudienceSegments {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result := make(chan []AudienceSegments, 1)
+	go func() {
+		segments, err := provider.UserSegments(ctx, request)
+		if err != nil {
+			segments = nil
+		}
+		result <- segments
+	}()
+
+	select {
+	case segments := <-result:
+		return segments
+	case <-ctx.Done():
+		return nil
+	}
+}
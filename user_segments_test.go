@@ -0,0 +1,77 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// testSegmentProvider wrapper of the function to the SegmentProvider interface
+type testSegmentProvider func(ctx context.Context, request adtype.BidRequester) ([]AudienceSegments, error)
+
+func (f testSegmentProvider) UserSegments(ctx context.Context, request adtype.BidRequester) ([]AudienceSegments, error) {
+	return f(ctx, request)
+}
+
+func TestUserSegments(t *testing.T) {
+	provider := testSegmentProvider(func(context.Context, adtype.BidRequester) ([]AudienceSegments, error) {
+		return []AudienceSegments{{
+			Provider: "dmp",
+			Taxonomy: 4,
+			Segments: []AudienceSegment{{ID: "1", Name: "sport"}, {ID: "2", Name: "auto", Value: "high"}},
+		}}, nil
+	})
+	d := newTestDriver(t, func(w http.ResponseWriter, _ *http.Request) {},
+		WithSegmentProvider(provider, 100*time.Millisecond))
+
+	rtbRequest := testEncodeRequest(t, d, newTestRequest(context.Background(), "banner_300x250"))
+	assert.Equal(t, []any{map[string]any{
+		"name": "dmp",
+		"segment": []any{
+			map[string]any{"id": "1", "name": "sport"},
+			map[string]any{"id": "2", "name": "auto", "value": "high"},
+		},
+		"ext": map[string]any{"segtax": 4.},
+	}}, rtbRequest["user"].(map[string]any)["data"])
+
+	// The resolving time never exceeds the half of the request time budget
+	request := newTestRequest(context.Background(), "banner_300x250")
+	assert.Equal(t, 100*time.Millisecond, d.segmentTimeout(request))
+	d.opts.SegmentTimeout = 0
+	assert.Equal(t, 500*time.Millisecond, d.segmentTimeout(request))
+}
+
+func TestResolveUserSegments(t *testing.T) {
+	request := newTestRequest(context.Background(), "banner_300x250")
+	segments := []AudienceSegments{{Provider: "dmp", Segments: []AudienceSegment{{ID: "1"}}}}
+
+	resolved := resolveUserSegments(context.Background(), testSegmentProvider(
+		func(context.Context, adtype.BidRequester) ([]AudienceSegments, error) { return segments, nil },
+	), request, time.Second)
+	assert.Equal(t, segments, resolved)
+
+	// The failed provider is ignored
+	resolved = resolveUserSegments(context.Background(), testSegmentProvider(
+		func(context.Context, adtype.BidRequester) ([]AudienceSegments, error) {
+			return segments, errors.New("failed")
+		},
+	), request, time.Second)
+	assert.Nil(t, resolved)
+
+	// The slow provider doesn't delay the request longer than the timeout
+	begin := time.Now()
+	resolved = resolveUserSegments(context.Background(), testSegmentProvider(
+		func(context.Context, adtype.BidRequester) ([]AudienceSegments, error) {
+			time.Sleep(time.Second)
+			return segments, nil
+		},
+	), request, 20*time.Millisecond)
+	assert.Nil(t, resolved)
+	assert.Less(t, time.Since(begin), 500*time.Millisecond)
+}
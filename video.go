@@ -0,0 +1,169 @@
+package adsourceopenrtb
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/geniusrabbit/adcorelib/admodels/types"
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// Video protocols (OpenRTB List: Protocols)
+const (
+	VideoProtocolVAST2        = 2
+	VideoProtocolVAST3        = 3
+	VideoProtocolVAST2Wrapper = 5
+	VideoProtocolVAST3Wrapper = 6
+	VideoProtocolVAST4        = 7
+	VideoProtocolVAST4Wrapper = 8
+)
+
+// Video linearity (OpenRTB List: Video Linearity)
+const (
+	VideoLinear    = 1
+	VideoNonLinear = 2
+)
+
+// Video playback methods (OpenRTB List: Playback Methods)
+const (
+	VideoPlaybackAutoSoundOn  = 1
+	VideoPlaybackAutoSoundOff = 2
+	VideoPlaybackClickToPlay  = 3
+	VideoPlaybackMouseOver    = 4
+	VideoPlaybackViewportOn   = 5
+	VideoPlaybackViewportOff  = 6
+)
+
+// VideoPlacement parameters of the video impression
+type VideoPlacement struct {
+	// Mimes of the video content (the video types of the format main asset by default)
+	Mimes []string
+
+	// MinDuration and MaxDuration of the video ad in seconds (5 and 60 seconds by default)
+	MinDuration int
+	MaxDuration int
+
+	// Protocols of the video bid response (VAST versions supported by the response parser by default)
+	Protocols []int
+
+	// Linearity of the impression (linear by default)
+	Linearity int
+
+	// PlaybackMethods allowed by the player
+	PlaybackMethods []int
+
+	// StartDelay in seconds (0 pre-roll, -1 generic mid-roll, -2 generic post-roll)
+	StartDelay int
+
+	// Skippable video with the minimal duration of the skippable videos
+	// and the seconds a video must play before skipping
+	Skippable bool
+	SkipMin   int
+	SkipAfter int
+
+	// Placement type of the video (OpenRTB List: Video Placement Types)
+	// and the placement subtype of the OpenRTB 2.6+ (AdCOM List: Plcmt Subtypes - Video)
+	Placement int
+	Plcmt     int
+
+	// Delivery methods of the content (OpenRTB List: Content Delivery Methods)
+	Delivery []int
+
+	// Companions of the video ad (the banner sizes) and the supported companion types
+	// (OpenRTB List: Companion Types)
+	Companions     []VideoCompanion
+	CompanionTypes []int
+
+	// MinBitrate and MaxBitrate in Kbps
+	MinBitrate int
+	MaxBitrate int
+
+	// API frameworks supported by the placement
+	API []int
+}
+
+// defaultVideoMimes of the formats without the video types of the main asset
+var defaultVideoMimes = []string{"video/mp4", "video/webm"}
+
+// VideoCompanion banner size of the video ad
+type VideoCompanion struct {
+	W int
+	H int
+}
+
+// defaultVideoPlacement of the video formats without the placement parameters
+var defaultVideoPlacement = VideoPlacement{
+	MinDuration: 5,
+	MaxDuration: 60,
+	Protocols: []int{
+		VideoProtocolVAST2, VideoProtocolVAST3, VideoProtocolVAST2Wrapper,
+		VideoProtocolVAST3Wrapper, VideoProtocolVAST4, VideoProtocolVAST4Wrapper,
+	},
+	Linearity: VideoLinear,
+	Skippable: true,
+	SkipAfter: 3,
+}
+
+// VideoProvider returns the video parameters of the placement,
+// the default parameters are used for nil
+type VideoProvider interface {
+	VideoPlacement(imp *adtype.Impression) *VideoPlacement
+}
+
+// VideoProviderFunc wrapper of the function to the VideoProvider interface
+type VideoProviderFunc func(imp *adtype.Impression) *VideoPlacement
+
+// VideoPlacement returns the video parameters of the placement
+func (f VideoProviderFunc) VideoPlacement(imp *adtype.Impression) *VideoPlacement {
+	return f(imp)
+}
+
+// videoPlacement returns the video parameters of the impression completed by the format
+// and the default parameters for the required fields
+func (opts *BidRequestRTBOptions) videoPlacement(imp *adtype.Impression, format *types.Format) VideoPlacement {
+	placement := defaultVideoPlacement
+	if opts.Video != nil && imp != nil {
+		if p := opts.Video(imp); p != nil {
+			placement = *p
+		}
+	}
+	if len(placement.Mimes) == 0 {
+		placement.Mimes = formatVideoMimes(format)
+	}
+	if len(placement.Mimes) == 0 {
+		placement.Mimes = defaultVideoMimes
+	}
+	if placement.MinDuration <= 0 {
+		placement.MinDuration = defaultVideoPlacement.MinDuration
+	}
+	if placement.MaxDuration <= 0 {
+		placement.MaxDuration = max(defaultVideoPlacement.MaxDuration, placement.MinDuration)
+	}
+	if len(placement.Protocols) == 0 {
+		placement.Protocols = defaultVideoPlacement.Protocols
+	}
+	if placement.Linearity == 0 {
+		placement.Linearity = defaultVideoPlacement.Linearity
+	}
+	return placement
+}
+
+// formatVideoMimes returns the video types allowed by the main asset of the format
+func formatVideoMimes(format *types.Format) []string {
+	if format == nil || format.Config == nil {
+		return nil
+	}
+	for _, asset := range format.Config.Assets {
+		if !asset.IsMain() {
+			continue
+		}
+		var mimes []string
+		for _, mime := range asset.AllowedTypes {
+			if strings.HasPrefix(mime, "video/") && !slices.Contains(mimes, mime) {
+				mimes = append(mimes, mime)
+			}
+		}
+		return mimes
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/adtype"
+)
+
+// testVideoImp returns the video object of the first impression of the encoded request
+func testVideoImp(t *testing.T, d *driver, request adtype.BidRequester) map[string]any {
+	t.Helper()
+	imp := testEncodeRequest(t, d, request)["imp"].([]any)[0].(map[string]any)
+	assert.NotContains(t, imp, "banner")
+	video, _ := imp["video"].(map[string]any)
+	if !assert.NotNil(t, video) {
+		t.FailNow()
+	}
+	return video
+}
+
+func TestVideoRequest(t *testing.T) {
+	request := newTestRequest(context.Background(), "video")
+
+	// The default placement with the mimes of the format main asset
+	video := testVideoImp(t, newTestDriver(t, nil), request)
+	assert.Equal(t, []any{"video/mp4"}, video["mimes"])
+	assert.Equal(t, float64(5), video["minduration"])
+	assert.Equal(t, float64(60), video["maxduration"])
+	assert.Equal(t, []any{2., 3., 5., 6., 7., 8.}, video["protocols"])
+	assert.Equal(t, float64(640), video["w"])
+	assert.Equal(t, float64(360), video["h"])
+	assert.Equal(t, float64(VideoLinear), video["linearity"])
+	assert.Equal(t, float64(1), video["skip"])
+	assert.Equal(t, float64(3), video["skipafter"])
+
+	// The placement parameters of the provider
+	d := newTestDriver(t, nil, WithVideoProvider(VideoProviderFunc(func(*adtype.Impression) *VideoPlacement {
+		return &VideoPlacement{
+			Mimes:           []string{"video/webm"},
+			MaxDuration:     30,
+			PlaybackMethods: []int{VideoPlaybackAutoSoundOff},
+			StartDelay:      -1,
+			SkipAfter:       5,
+			Placement:       1,
+			Delivery:        []int{2},
+			Companions:      []VideoCompanion{{W: 300, H: 250}},
+			CompanionTypes:  []int{1},
+		}
+	})))
+	video = testVideoImp(t, d, request)
+	assert.Equal(t, []any{"video/webm"}, video["mimes"])
+	assert.Equal(t, float64(5), video["minduration"], "the default minimal duration")
+	assert.Equal(t, float64(30), video["maxduration"])
+	assert.Equal(t, []any{2., 3., 5., 6., 7., 8.}, video["protocols"], "the default protocols")
+	assert.Equal(t, float64(VideoLinear), video["linearity"], "the default linearity")
+	assert.Equal(t, []any{float64(VideoPlaybackAutoSoundOff)}, video["playbackmethod"])
+	assert.Equal(t, float64(-1), video["startdelay"])
+	assert.NotContains(t, video, "skip", "the video is not skippable")
+	assert.NotContains(t, video, "skipafter")
+	assert.Equal(t, float64(1), video["placement"])
+	assert.Equal(t, []any{2.}, video["delivery"])
+	assert.Equal(t, []any{1.}, video["companiontype"])
+	assert.Equal(t, []any{map[string]any{"id": "1", "w": 300., "h": 250.}}, video["companionad"])
+}
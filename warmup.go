@@ -0,0 +1,73 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultWarmupTimeout = time.Second
+
+// warmup opens the keep-alive connections to the source in the background
+// by the OPTIONS requests so the first auctions after the start
+// don't pay the connection and TLS handshake latency
+func (d *driver) warmup(ctx context.Context) {
+	if d.opts.WarmupConnections <= 0 || d.source.URL == "" {
+		return
+	}
+	timeout := d.opts.WarmupTimeout
+	if timeout <= 0 {
+		timeout = defaultWarmupTimeout
+	}
+	go func() {
+		// The warm-up doesn't depend on the lifetime of the initialization context
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		for range d.opts.WarmupConnections {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.warmupConnection(ctx)
+			}()
+		}
+		wg.Wait()
+	}()
+}
+
+func (d *driver) warmupConnection(ctx context.Context) {
+	httpReq, err := newHTTPRequest(ctx, d.netClient, http.MethodOptions, d.source.URL, nil)
+	if err != nil {
+		d.logger(ctx).Debug("warmup request", zap.String("source_url", d.source.URL), zap.Error(err))
+		return
+	}
+	for key, value := range d.headers {
+		httpReq.SetHeader(key, value)
+	}
+	resp, err := doHTTPRequest(ctx, d.netClient, httpReq)
+	if err != nil {
+		d.logger(ctx).Debug("warmup request", zap.String("source_url", d.source.URL), zap.Error(err))
+		return
+	}
+	// Drain the body to return the connection into the idle pool
+	_, _ = io.Copy(io.Discard, resp.Body())
+	_ = resp.Close()
+}
+
+// inWarmupGrace returns true during the warm-up grace window after the driver creation
+func (d *driver) inWarmupGrace() bool {
+	return d.opts.WarmupGrace > 0 && d.now().Sub(d.createdAt) < d.opts.WarmupGrace
+}
+
+// recordBudget of the request latency and the response size,
+// the cold-start latency is not recorded during the warm-up grace window
+func (d *driver) recordBudget(latency time.Duration, size int64) {
+	if !d.inWarmupGrace() {
+		d.budget.Record(latency, size)
+	}
+}
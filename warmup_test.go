@@ -0,0 +1,61 @@
+package adsourceopenrtb
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/geniusrabbit/adcorelib/admodels"
+)
+
+func TestWarmup(t *testing.T) {
+	var requests atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions && r.Header.Get("X-Source-Key") == "key" {
+			requests.Add(1)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+	headers := testSourceOption(func(source *admodels.RTBSource) {
+		_ = source.Headers.SetValue(map[string]string{"X-Source-Key": "key"})
+	})
+
+	d := newTestDriver(t, handler, headers, WithWarmup(3, time.Second))
+	d.warmup(context.Background())
+	assert.Eventually(t, func() bool { return requests.Load() == 3 }, time.Second, 5*time.Millisecond)
+
+	// The warm-up is disabled by default
+	requests.Store(0)
+	d = newTestDriver(t, handler, headers)
+	d.warmup(context.Background())
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, requests.Load())
+}
+
+func TestWarmupGrace(t *testing.T) {
+	now := time.Now()
+	d := newTestDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}, WithWarmupGrace(time.Minute), DriverOption(func(opts *DriverOptions) {
+		opts.Clock = func() time.Time { return now }
+	}))
+
+	// The cold-start errors don't affect the error balance of the source
+	resp := d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.ErrorIs(t, resp.Error(), ErrInvalidResponseStatus)
+	assert.True(t, d.inWarmupGrace())
+	assert.Equal(t, int32(0), d.errorCounter.Balance())
+
+	now = now.Add(time.Minute)
+	resp = d.Bid(newTestRequest(context.Background(), "banner_300x250"))
+	assert.ErrorIs(t, resp.Error(), ErrInvalidResponseStatus)
+	assert.False(t, d.inWarmupGrace())
+	assert.Equal(t, int32(1), d.errorCounter.Balance())
+
+	// The grace window is disabled by default
+	assert.False(t, newTestDriver(t, nil).inWarmupGrace())
+}